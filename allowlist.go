@@ -0,0 +1,157 @@
+package ambassador
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IPAllowlist only lets webhook requests from published platform IP ranges
+// through, as a defense in depth beyond signatures.
+type IPAllowlist struct {
+	sync.RWMutex
+	nets []*net.IPNet
+	// Refresh fetches the current ranges, e.g. from the published list of a
+	// platform.
+	Refresh func() ([]string, error)
+	// TrustForwardedFor takes the client address from X-Forwarded-For, which
+	// is only safe behind a proxy that sets it. The client is the right-most
+	// address which is not a trusted proxy, since a client can forge any
+	// address to the left of the one its proxy appends.
+	TrustForwardedFor bool
+	proxies           []*net.IPNet
+}
+
+func NewIPAllowlist(cidrs ...string) (l *IPAllowlist, err error) {
+	l = &IPAllowlist{}
+	if err = l.Update(cidrs); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func parseCIDRs(cidrs []string) (nets []*net.IPNet, err error) {
+	nets = make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip range %q: %s", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// Update replaces the allowed ranges. Single addresses are accepted too.
+func (l *IPAllowlist) Update(cidrs []string) (err error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.nets = nets
+	return
+}
+
+// Reload updates the ranges by Refresh.
+func (l *IPAllowlist) Reload() (err error) {
+	if l.Refresh == nil {
+		return
+	}
+	cidrs, err := l.Refresh()
+	if err != nil {
+		return
+	}
+	return l.Update(cidrs)
+}
+
+// RunRefresh reloads the ranges periodically until the context is done. The
+// previous ranges are kept when a reload fails.
+func (l *IPAllowlist) RunRefresh(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			l.Reload()
+		}
+	}
+}
+
+// SetTrustedProxies sets the ranges of the proxies in front of the webhook
+// other than the one it is connected to, whose addresses are skipped in
+// X-Forwarded-For.
+func (l *IPAllowlist) SetTrustedProxies(cidrs ...string) (err error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.proxies = nets
+	return
+}
+
+func netsContain(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *IPAllowlist) Allowed(ip net.IP) bool {
+	l.RLock()
+	defer l.RUnlock()
+	return netsContain(l.nets, ip)
+}
+
+func (l *IPAllowlist) clientIP(r *http.Request) net.IP {
+	if l.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addrs := strings.Split(forwarded, ",")
+			l.RLock()
+			defer l.RUnlock()
+			var ip net.IP
+			for i := len(addrs) - 1; i >= 0; i-- {
+				if ip = net.ParseIP(strings.TrimSpace(addrs[i])); ip == nil || !netsContain(l.proxies, ip) {
+					break
+				}
+			}
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Middleware responds 403 to requests from addresses out of the ranges.
+func (l *IPAllowlist) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := l.clientIP(r); ip == nil || !l.Allowed(ip) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
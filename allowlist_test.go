@@ -0,0 +1,78 @@
+package ambassador
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPAllowlistRanges(t *testing.T) {
+	l, err := NewIPAllowlist("31.13.24.0/21", "203.0.113.7", "2a03:2880::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"31.13.24.1", true},
+		{"31.13.31.255", true},
+		{"31.13.32.0", false},
+		{"203.0.113.7", true},
+		{"203.0.113.8", false},
+		{"2a03:2880:f000::1", true},
+		{"2a03:2881::1", false},
+	}
+	for _, c := range cases {
+		if allowed := l.Allowed(net.ParseIP(c.ip)); allowed != c.allowed {
+			t.Errorf("expect %s to be allowed %v", c.ip, c.allowed)
+		}
+	}
+	if _, err := NewIPAllowlist("31.13.24.0/33"); err == nil {
+		t.Error("expect an invalid range to fail")
+	}
+}
+
+func TestIPAllowlistForwardedFor(t *testing.T) {
+	l, _ := NewIPAllowlist("31.13.24.0/21")
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(remote, forwarded string) int {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("10.0.0.1:1234", "31.13.24.1"); code != http.StatusForbidden {
+		t.Errorf("expect X-Forwarded-For to be ignored unless trusted, got %d", code)
+	}
+	if code := serve("31.13.24.1:1234", ""); code != http.StatusOK {
+		t.Errorf("expect an allowed remote address to pass, got %d", code)
+	}
+
+	l.TrustForwardedFor = true
+	if err := l.SetTrustedProxies("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		forwarded string
+		status    int
+	}{
+		{"31.13.24.1", http.StatusOK},
+		{"31.13.24.1, 10.0.0.2", http.StatusOK},
+		// a spoofed allowed address on the left of the real client
+		{"31.13.24.1, 198.51.100.1", http.StatusForbidden},
+		{"31.13.24.1, 198.51.100.1, 10.0.0.2", http.StatusForbidden},
+		{"31.13.24.1, garbage", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if code := serve("10.0.0.1:1234", c.forwarded); code != c.status {
+			t.Errorf("expect %d for %q, got %d", c.status, c.forwarded, code)
+		}
+	}
+}
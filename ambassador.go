@@ -3,14 +3,47 @@ package ambassador
 import (
 	"io"
 	"net/http"
+	"time"
 )
 
 type Message struct {
 	SenderId    string
 	ReplyToken  string
 	RecipientId string
-	Timestamp   int64
-	Content     interface{}
+	// ChatId is set when a message comes from a group chat or a channel.
+	ChatId    string
+	MessageId string
+	// InReplyTo is the id of the message which a message replies to.
+	InReplyTo string
+	Timestamp int64
+	Content   interface{}
+	// ReceivedAt is when a webhook received a message.
+	ReceivedAt time.Time
+	// CorrelationId traces a message through logs, workers and outbound
+	// API calls.
+	CorrelationId string
+	// Stale is set when a message is older than the freshness window of a
+	// webhook.
+	Stale bool
+	// Profile is the profile of the sender attached by EnrichProfiles.
+	Profile *UserProfile
+}
+
+// ReplyTarget returns what Send expects to reply to a message: the reply
+// token if the platform issues one, otherwise the chat of the message.
+func (m *Message) ReplyTarget() string {
+	if m.ReplyToken != "" {
+		return m.ReplyToken
+	}
+	return m.chat()
+}
+
+// chat returns the id of the conversation which a message belongs to.
+func (m *Message) chat() string {
+	if m.ChatId != "" {
+		return m.ChatId
+	}
+	return m.SenderId
 }
 
 type LocationContent struct {
@@ -20,21 +53,69 @@ type LocationContent struct {
 
 type TextContent struct {
 	Text string
+	NLP  *NLP
 }
 
 type CommandContent struct {
-	Payload string
+	Payload  string
+	Referral *ReferralContent
+	// Args are the named arguments of a slash command.
+	Args map[string]string
+	// CallbackId is the id of a button press which the platform expects to
+	// be answered, e.g. a Telegram callback query. See AnswerCallback.
+	CallbackId string
+}
+
+// ReferralContent tells where a user comes from, e.g. the ref of an m.me
+// link. Signed refs can be decoded by a RefCodec.
+type ReferralContent struct {
+	Ref    string
+	Source string
+	Type   string
 }
 
+type FollowContent struct{}
+
 type Ambassador interface {
 	Translate(r io.Reader) (messages []Message, err error)
 	AskQuestion(text string, answers []map[string]string) (err error)
 	SendText(text string) (err error)
 	GetLastSent() []interface{}
 	SendTemplate(elements interface{}) (err error)
+	// SendTyping turns the typing indicator on or off. Platforms without an
+	// indicator ignore it.
+	SendTyping(on bool) (err error)
+	// WithTyping shows the typing indicator for a duration before the
+	// messages staged after it are sent.
+	WithTyping(d time.Duration) (err error)
+	// MarkRead acknowledges a received message right away, before a reply
+	// is sent.
+	MarkRead(msg Message) (err error)
 	Send(recipientId string) (err error)
 }
 
+// MessageBuilder stages messages on an ambassador.
+type MessageBuilder func(a Ambassador) error
+
+// Pusher is implemented by ambassadors which send messages proactively in a
+// different way from replying, e.g. LINE push messages.
+type Pusher interface {
+	Push(recipientId string) (err error)
+}
+
+// deliver stages messages by a builder and sends them to a recipient
+// proactively. The messages of a builder which fails are discarded.
+func deliver(a Ambassador, recipientId string, build MessageBuilder) (err error) {
+	if err = build(a); err != nil {
+		discard(a)
+		return
+	}
+	if p, ok := a.(Pusher); ok {
+		return p.Push(recipientId)
+	}
+	return a.Send(recipientId)
+}
+
 type CarouselButton struct {
 	Label       string
 	Type        string
@@ -51,10 +132,24 @@ type Carousel struct {
 	Buttons  []CarouselButton
 }
 
+// New creates an ambassador of a platform which only needs an access token.
+// It returns nil for other platforms, whose constructors take more settings.
 func New(source, token string, client *http.Client) (a Ambassador) {
 	switch source {
 	case "facebook":
 		return NewFBAmbassador(token, client)
+	case "line":
+		return NewLineAmbassador(token, client)
+	case "slack":
+		return NewSlackAmbassador(token, client)
+	case "discord":
+		return NewDiscordAmbassador(token, client)
+	case "wechat":
+		return NewWeChatAmbassador(token, client)
+	case "zalo":
+		return NewZaloAmbassador(token, client)
+	case "webex":
+		return NewWebexAmbassador(token, client)
 	}
 	return
 }
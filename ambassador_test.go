@@ -5,6 +5,17 @@ import (
 )
 
 func TestAmbassadorNew(t *testing.T) {
-	a := New("facebook", "test-token", nil)
-	t.Log(a)
+	for _, source := range []string{"facebook", "line", "slack", "discord", "wechat", "zalo", "webex"} {
+		a := New(source, "test-token", nil)
+		if a == nil {
+			t.Errorf("expect an ambassador of %s", source)
+			continue
+		}
+		if p := platformOf(a); p != source {
+			t.Errorf("expect an ambassador of %s, got %s", source, p)
+		}
+	}
+	if a := New("whatsapp", "test-token", nil); a != nil {
+		t.Errorf("expect a platform with more settings not to be created, got %v", a)
+	}
 }
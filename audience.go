@@ -0,0 +1,97 @@
+package ambassador
+
+import (
+	"context"
+	"fmt"
+)
+
+// Audience is the target of a broadcast.
+type Audience struct {
+	// All targets every user of a channel. Recipients may still be listed
+	// for platforms which can only fan out messages one by one.
+	All        bool
+	Recipients []string
+	// NotificationTokens are facebook notification messages tokens which
+	// users grant by opting in to recurring notifications.
+	NotificationTokens []string
+	// AudienceGroupId is a LINE audience group for narrowcast messages.
+	AudienceGroupId int64
+}
+
+// BulkSender is implemented by ambassadors which can send the staged
+// messages to an audience at once.
+type BulkSender interface {
+	Broadcast(audience Audience) (err error)
+}
+
+// Broadcast sends messages built by a builder to an audience through the
+// bulk endpoints of a platform if possible, otherwise one by one. Sending
+// one by one waits for a limiter if there is one, so that a large audience
+// stays within the rate limits of the platform.
+func Broadcast(a Ambassador, audience Audience, build MessageBuilder, limiter RateLimiter) (err error) {
+	if bs, ok := unwrap(a).(BulkSender); ok {
+		if err = build(a); err != nil {
+			discard(a)
+			return
+		}
+		return bs.Broadcast(audience)
+	}
+
+	if len(audience.Recipients) == 0 {
+		return fmt.Errorf("no recipient to broadcast to")
+	}
+	errs := DeliveryErrors{}
+	for _, userId := range audience.Recipients {
+		if limiter != nil {
+			if e := limiter.Wait(context.Background()); e != nil {
+				errs[userId] = fmt.Errorf("fail to wait for the rate limit: %s", e)
+				continue
+			}
+		}
+		if e := deliver(a, userId, build); e != nil {
+			errs[userId] = e
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+// AudienceSelector resolves an audience when a broadcast is sent.
+type AudienceSelector func() (Audience, error)
+
+// AllUsers selects every user. The list function is optional and is used by
+// platforms without a way to reach all users at once.
+func AllUsers(list func() ([]string, error)) AudienceSelector {
+	return func() (audience Audience, err error) {
+		audience.All = true
+		if list != nil {
+			audience.Recipients, err = list()
+		}
+		return
+	}
+}
+
+// TopicAudience selects the subscribers of a topic.
+func TopicAudience(m *SubscriptionManager, topic string) AudienceSelector {
+	return func() (audience Audience, err error) {
+		audience.Recipients, err = m.Subscribers(topic)
+		return
+	}
+}
+
+// RecipientsAudience selects a fixed list of users, e.g. a stored segment.
+func RecipientsAudience(ids ...string) AudienceSelector {
+	return func() (Audience, error) {
+		return Audience{Recipients: ids}, nil
+	}
+}
+
+// locale returns the locale shared by an audience, or an empty string.
+func (a *Audience) locale(localeOf func(userId string) string) string {
+	if localeOf == nil || len(a.Recipients) == 0 {
+		return ""
+	}
+	return localeOf(a.Recipients[0])
+}
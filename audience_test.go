@@ -0,0 +1,67 @@
+package ambassador
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+// countingLimiter counts waits, and fails the waits listed in fail.
+type countingLimiter struct {
+	waits int
+	fail  map[int]bool
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	if l.fail[l.waits] {
+		return errors.New("limiter is down")
+	}
+	return nil
+}
+
+func TestBroadcastFanOut(t *testing.T) {
+	a := &recordAmbassador{}
+	limiter := &countingLimiter{fail: map[int]bool{2: true}}
+	err := Broadcast(a, Audience{Recipients: []string{"u1", "u2", "u3"}}, Messages(TextMessage("sale")), limiter)
+	errs, ok := err.(DeliveryErrors)
+	if !ok || len(errs) != 1 || errs["u2"] == nil {
+		t.Errorf("expect the recipient whose wait fails to be returned, got %v", err)
+	}
+	if limiter.waits != 3 || len(a.sent) != 2 || a.sent[1] != "u3:sale" {
+		t.Errorf("expect every send to wait for the limiter, got %d waits, %v", limiter.waits, a.sent)
+	}
+
+	if err := Broadcast(a, Audience{All: true}, Messages(TextMessage("sale")), nil); err == nil {
+		t.Error("expect a fan out without recipients to fail")
+	}
+}
+
+func TestLineMulticastBatches(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	server.Fail("line", testutil.Sequence(&testutil.Failure{Status: 500, Body: `{"message":"internal error"}`}))
+	line := NewLineAmbassador("token", server.Client())
+
+	recipients := []string{}
+	for i := 0; i < lineMaxMulticastReceivers+1; i++ {
+		recipients = append(recipients, fmt.Sprintf("U%d", i))
+	}
+	limiter := &countingLimiter{}
+	err := Broadcast(line, Audience{Recipients: recipients}, Messages(TextMessage("sale")), limiter)
+	errs, ok := err.(DeliveryErrors)
+	if !ok || len(errs) != lineMaxMulticastReceivers || errs["U0"] == nil || errs["U500"] != nil {
+		t.Errorf("expect the recipients of the failed batch to be returned, got %d errors", len(errs))
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || !strings.Contains(string(requests[1].Body), `"to":["U500"]`) {
+		t.Errorf("expect the batch after a failure to be sent, got %d requests", len(requests))
+	}
+	if limiter.waits != 0 {
+		t.Errorf("expect a multicast not to wait for the fan out limiter, got %d waits", limiter.waits)
+	}
+}
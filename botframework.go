@@ -0,0 +1,403 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BotFrameworkTokenURI = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	BotFrameworkScope    = "https://api.botframework.com/.default"
+)
+
+type BotActivity struct {
+	Type         string          `json:"type"`
+	Id           string          `json:"id"`
+	Timestamp    string          `json:"timestamp"`
+	ServiceUrl   string          `json:"serviceUrl"`
+	ChannelId    string          `json:"channelId"`
+	From         BotAccount      `json:"from"`
+	Conversation BotAccount      `json:"conversation"`
+	Recipient    BotAccount      `json:"recipient"`
+	Text         string          `json:"text"`
+	Value        json.RawMessage `json:"value"`
+	ReplyToId    string          `json:"replyToId"`
+	MembersAdded []BotAccount    `json:"membersAdded"`
+}
+
+type BotAccount struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// BotFrameworkCredentials acquires and caches the access token of a bot for
+// the connector API. It is shared by the ambassadors of a bot.
+type BotFrameworkCredentials struct {
+	sync.Mutex
+	AppId       string
+	AppPassword string
+	Client      *http.Client
+	token       string
+	expiresAt   time.Time
+}
+
+// Token returns the cached access token, or acquires a new one by the client
+// credentials of the bot when it is about to expire.
+func (c *BotFrameworkCredentials) Token() (token string, err error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.AppId},
+		"client_secret": {c.AppPassword},
+		"scope":         {BotFrameworkScope},
+	}
+	resp, err := client.PostForm(BotFrameworkTokenURI, form)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return "", fmt.Errorf("fail to acquire a bot framework token. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = decodeJSON(resp.Body, &result); err != nil {
+		return
+	}
+	c.token = result.AccessToken
+	// renew a minute early so that a token does not expire on the way
+	c.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return c.token, nil
+}
+
+// botServiceUrls keeps the service url of every conversation seen, which
+// replies and proactive messages of the conversation are posted to.
+var botServiceUrls = &serviceUrls{urls: map[string]string{}}
+
+type serviceUrls struct {
+	sync.Mutex
+	urls map[string]string
+}
+
+func (s *serviceUrls) set(conversationId, serviceUrl string) {
+	s.Lock()
+	defer s.Unlock()
+	s.urls[conversationId] = serviceUrl
+}
+
+func (s *serviceUrls) get(conversationId string) string {
+	s.Lock()
+	defer s.Unlock()
+	return s.urls[conversationId]
+}
+
+// BotFrameworkAmbassador talks to Teams, Skype and other channels of the
+// Bot Framework by activities. Questions are sent as hero cards and
+// templates as a carousel of adaptive cards, whose submitted values come
+// back as CommandContent.
+//
+// Requests to the webhook should be authenticated by the JWT of the
+// connector in front of it.
+type BotFrameworkAmbassador struct {
+	sync.Mutex
+	credentials *BotFrameworkCredentials
+	client      *http.Client
+	// DefaultServiceUrl is used for conversations which have not been
+	// seen, e.g. "https://smba.trafficmanager.net/amer/".
+	DefaultServiceUrl string
+	messages          []map[string]interface{}
+	lastMessages      []interface{}
+	replyTo           string
+	correlation       string
+}
+
+func NewBotFrameworkAmbassador(credentials *BotFrameworkCredentials, client *http.Client) *BotFrameworkAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &BotFrameworkAmbassador{credentials: credentials, client: client}
+}
+
+func (b *BotFrameworkAmbassador) SetCorrelationId(id string) {
+	b.correlation = id
+}
+
+func (b *BotFrameworkAmbassador) Platform() string {
+	return "botframework"
+}
+
+// Translate turns an activity into messages. The conversation is the chat
+// of a message. Members added to a conversation, other than the bot, are
+// translated into FollowContent.
+func (b *BotFrameworkAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var a BotActivity
+	if err = decodeJSON(limitReader(r), &a); err != nil {
+		return
+	}
+	messages = []Message{}
+	if a.Conversation.Id == "" {
+		return
+	}
+	if a.ServiceUrl != "" {
+		botServiceUrls.set(a.Conversation.Id, a.ServiceUrl)
+	}
+
+	msg := Message{
+		SenderId:    a.From.Id,
+		RecipientId: a.Recipient.Id,
+		ChatId:      a.Conversation.Id,
+		MessageId:   a.Id,
+		InReplyTo:   a.ReplyToId,
+	}
+	if t, err := time.Parse(time.RFC3339, a.Timestamp); err == nil {
+		msg.Timestamp = t.UnixNano() / int64(time.Millisecond)
+	}
+
+	switch a.Type {
+	case "message":
+		var value struct {
+			Payload string `json:"payload"`
+		}
+		if len(a.Value) > 0 && jsonCodec.Unmarshal(a.Value, &value) == nil && value.Payload != "" {
+			msg.Content = &CommandContent{Payload: value.Payload}
+		} else {
+			msg.Content = &TextContent{Text: a.Text}
+		}
+		messages = append(messages, msg)
+	case "conversationUpdate":
+		for _, member := range a.MembersAdded {
+			if member.Id == a.Recipient.Id {
+				continue
+			}
+			added := msg
+			added.SenderId = member.Id
+			added.Content = &FollowContent{}
+			messages = append(messages, added)
+		}
+	}
+	return
+}
+
+func (b *BotFrameworkAmbassador) stage(activity map[string]interface{}) {
+	b.Lock()
+	defer b.Unlock()
+	b.messages = append(b.messages, activity)
+}
+
+func (b *BotFrameworkAmbassador) SendText(text string) (err error) {
+	b.stage(map[string]interface{}{"type": "message", "text": text})
+	return
+}
+
+// AskQuestion sends a hero card with a message back button per answer.
+func (b *BotFrameworkAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	buttons := []interface{}{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			buttons = append(buttons, map[string]interface{}{
+				"type":        "messageBack",
+				"title":       title,
+				"displayText": title,
+				"value":       map[string]string{"payload": payload},
+			})
+		}
+	}
+	b.stage(map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.hero",
+			"content":     map[string]interface{}{"text": text, "buttons": buttons},
+		}},
+	})
+	return
+}
+
+// adaptiveCard renders an element with its image, title, text and buttons.
+func adaptiveCard(col Carousel) map[string]interface{} {
+	body := []interface{}{}
+	if col.ImageUrl != "" {
+		image := map[string]interface{}{"type": "Image", "url": col.ImageUrl, "size": "Stretch"}
+		if col.ItemUrl != "" {
+			image["selectAction"] = map[string]string{"type": "Action.OpenUrl", "url": col.ItemUrl}
+		}
+		body = append(body, image)
+	}
+	body = append(body, map[string]interface{}{"type": "TextBlock", "text": col.Title, "weight": "Bolder", "wrap": true})
+	if col.Text != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": col.Text, "wrap": true})
+	}
+
+	actions := []interface{}{}
+	for _, btn := range col.Buttons {
+		switch btn.Type {
+		case "url":
+			actions = append(actions, map[string]string{"type": "Action.OpenUrl", "title": btn.Label, "url": btn.Data})
+		case "postback":
+			actions = append(actions, map[string]interface{}{
+				"type":  "Action.Submit",
+				"title": btn.Label,
+				"data":  map[string]string{"payload": btn.Data},
+			})
+		}
+	}
+	return map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+		"actions": actions,
+	}
+}
+
+// SendTemplate sends the elements as a carousel of adaptive cards.
+func (b *BotFrameworkAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	attachments := []interface{}{}
+	for _, col := range colItems {
+		attachments = append(attachments, map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     adaptiveCard(col),
+		})
+	}
+	b.stage(map[string]interface{}{
+		"type":             "message",
+		"attachmentLayout": "carousel",
+		"attachments":      attachments,
+	})
+	return
+}
+
+// SendTyping is a no-op since typing activities are sent to a conversation.
+// Use WithTyping instead.
+func (b *BotFrameworkAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping sends a typing activity and waits for a duration before the
+// messages staged after it are sent.
+func (b *BotFrameworkAmbassador) WithTyping(d time.Duration) (err error) {
+	b.stage(map[string]interface{}{"type": "typing", "pause": d})
+	return
+}
+
+// MarkRead is a no-op since bots have no read receipts in the Bot Framework.
+func (b *BotFrameworkAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+// ReplyTo replies to an activity, which threads the replies in Teams
+// channels.
+func (b *BotFrameworkAmbassador) ReplyTo(messageId string) (err error) {
+	b.Lock()
+	defer b.Unlock()
+	b.replyTo = messageId
+	return
+}
+
+func (b *BotFrameworkAmbassador) post(uri string, activity map[string]interface{}) (err error) {
+	token, err := b.credentials.Token()
+	if err != nil {
+		return
+	}
+	body, err := jsonCodec.Marshal(activity)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", uri, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	setCorrelationHeader(req, b.correlation)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to send a bot framework activity. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (b *BotFrameworkAmbassador) cleanMessage() {
+	b.Lock()
+	defer b.Unlock()
+	b.lastMessages = make([]interface{}, 0, len(b.messages))
+	for _, m := range b.messages {
+		b.lastMessages = append(b.lastMessages, m)
+	}
+	b.messages = nil
+	b.replyTo = ""
+}
+
+func (b *BotFrameworkAmbassador) GetLastSent() []interface{} {
+	return b.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (b *BotFrameworkAmbassador) Discard() {
+	b.Lock()
+	last := b.lastMessages
+	b.Unlock()
+	b.cleanMessage()
+	b.Lock()
+	b.lastMessages = last
+	b.Unlock()
+}
+
+// Send posts the staged activities to a conversation.
+func (b *BotFrameworkAmbassador) Send(recipientId string) (err error) {
+	defer b.cleanMessage()
+	serviceUrl := botServiceUrls.get(recipientId)
+	if serviceUrl == "" {
+		serviceUrl = b.DefaultServiceUrl
+	}
+	if serviceUrl == "" {
+		return fmt.Errorf("no service url known for the conversation %s", recipientId)
+	}
+	uri := strings.TrimSuffix(serviceUrl, "/") + "/v3/conversations/" + url.PathEscape(recipientId) + "/activities"
+	if b.replyTo != "" {
+		uri += "/" + url.PathEscape(b.replyTo)
+	}
+
+	for _, m := range b.messages {
+		activity := m
+		var pause time.Duration
+		if d, ok := m["pause"].(time.Duration); ok {
+			pause = d
+			activity = map[string]interface{}{"type": "typing"}
+		}
+		if err = b.post(uri, activity); err != nil {
+			return
+		}
+		time.Sleep(pause)
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestBotFrameworkConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	credentials := &BotFrameworkCredentials{AppId: "app", AppPassword: "secret", Client: server.Client()}
+	b := NewBotFrameworkAmbassador(credentials, server.Client())
+
+	messages, err := b.Translate(strings.NewReader(`{"type":"message","id":"a1","timestamp":"2023-11-14T22:13:20Z",
+		"serviceUrl":"https://smba.trafficmanager.net/amer/","channelId":"msteams",
+		"from":{"id":"29:u1"},"conversation":{"id":"a:c1"},"recipient":{"id":"28:bot"},
+		"value":{"payload":"SIZE_M"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := messages[0]
+	if command, ok := msg.Content.(*CommandContent); !ok || command.Payload != "SIZE_M" || msg.ChatId != "a:c1" {
+		t.Fatalf("expect a submitted value as a command, got %+v", msg)
+	}
+
+	b.AskQuestion("gift wrap?", []map[string]string{{"title": "Yes", "payload": "WRAP"}})
+	b.SendTemplate([]Carousel{{Title: "Tea", Buttons: []CarouselButton{{Label: "Buy", Type: "postback", Data: "BUY_TEA"}}}})
+	if err := b.Send(msg.ReplyTarget()); err != nil {
+		t.Fatal(err)
+	}
+	b.SendText("thanks")
+	if err := b.Send(msg.ReplyTarget()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 4 || requests[0].Host != "login.microsoftonline.com" {
+		t.Fatalf("expect a token acquired once and three activities, got %+v", requests)
+	}
+	if requests[1].Path != "/amer/v3/conversations/a:c1/activities" || requests[1].Header.Get("Authorization") != "Bearer fake-token" {
+		t.Errorf("unexpected activity request: %+v", requests[1])
+	}
+	var carousel struct {
+		AttachmentLayout string `json:"attachmentLayout"`
+		Attachments      []struct {
+			ContentType string `json:"contentType"`
+		}
+	}
+	json.Unmarshal(requests[2].Body, &carousel)
+	if carousel.AttachmentLayout != "carousel" || carousel.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Errorf("unexpected template: %s", requests[2].Body)
+	}
+}
@@ -0,0 +1,157 @@
+package ambassador
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduledBroadcast sends rendered messages to an audience whenever its
+// cron schedule fires.
+type ScheduledBroadcast struct {
+	Id       string
+	Schedule *CronSchedule
+	Audience AudienceSelector
+	// Render returns the messages of a locale. The locale is empty when the
+	// scheduler has no way to tell the locales of users.
+	Render func(locale string) []OutboundMessage
+	next   time.Time
+}
+
+// BroadcastScheduler runs scheduled broadcasts through an ambassador.
+type BroadcastScheduler struct {
+	sync.Mutex
+	a Ambassador
+	// sending serializes broadcasts, which stage their messages on the
+	// same ambassador, without holding up the schedule.
+	sending sync.Mutex
+	// Locale returns the locale of a user for rendering localized content.
+	Locale func(userId string) string
+	// Limiter paces broadcasts which are sent one by one on platforms
+	// without bulk endpoints.
+	Limiter    RateLimiter
+	Location   *time.Location
+	Interval   time.Duration
+	broadcasts map[string]*ScheduledBroadcast
+	now        func() time.Time
+}
+
+func NewBroadcastScheduler(a Ambassador) *BroadcastScheduler {
+	return &BroadcastScheduler{
+		a:          a,
+		Location:   time.Local,
+		Interval:   time.Minute,
+		broadcasts: map[string]*ScheduledBroadcast{},
+		now:        time.Now,
+	}
+}
+
+// Add schedules a broadcast by a cron expression.
+func (s *BroadcastScheduler) Add(id, spec string, audience AudienceSelector, render func(locale string) []OutboundMessage) (err error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.broadcasts[id] = &ScheduledBroadcast{
+		Id:       id,
+		Schedule: schedule,
+		Audience: audience,
+		Render:   render,
+		next:     schedule.Next(s.now().In(s.Location)),
+	}
+	return
+}
+
+func (s *BroadcastScheduler) Remove(id string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.broadcasts, id)
+}
+
+// Tick sends every broadcast which is due.
+func (s *BroadcastScheduler) Tick() (err error) {
+	now := s.now().In(s.Location)
+	due := []*ScheduledBroadcast{}
+	s.Lock()
+	for _, b := range s.broadcasts {
+		if !b.next.IsZero() && !b.next.After(now) {
+			due = append(due, b)
+			b.next = b.Schedule.Next(now)
+		}
+	}
+	s.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Id < due[j].Id })
+	for _, b := range due {
+		if e := s.Broadcast(b); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Broadcast sends a broadcast immediately. A broadcast to all users of a
+// platform with bulk endpoints is sent to everyone at once, so it fails if
+// its recipients have more than one locale.
+func (s *BroadcastScheduler) Broadcast(b *ScheduledBroadcast) (err error) {
+	audience, err := b.Audience()
+	if err != nil {
+		return
+	}
+
+	groups := map[string][]string{}
+	for _, userId := range audience.Recipients {
+		var locale string
+		if s.Locale != nil {
+			locale = s.Locale(userId)
+		}
+		groups[locale] = append(groups[locale], userId)
+	}
+
+	if _, ok := unwrap(s.a).(BulkSender); ok && audience.All && len(groups) > 1 {
+		return fmt.Errorf("fail to broadcast %s to all users in %d locales at once", b.Id, len(groups))
+	}
+
+	s.sending.Lock()
+	defer s.sending.Unlock()
+	if len(groups) <= 1 {
+		return Broadcast(s.a, audience, Messages(b.Render(audience.locale(s.Locale))...), s.Limiter)
+	}
+
+	errs := DeliveryErrors{}
+	for locale, users := range groups {
+		group := Audience{Recipients: users}
+		e := Broadcast(s.a, group, Messages(b.Render(locale)...), s.Limiter)
+		if de, ok := e.(DeliveryErrors); ok {
+			for userId, userErr := range de {
+				errs[userId] = userErr
+			}
+		} else if e != nil {
+			for _, userId := range users {
+				errs[userId] = e
+			}
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+// Run checks the schedules periodically until the context is done.
+func (s *BroadcastScheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			s.Tick()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package ambassador
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestBroadcastSchedulerLocales(t *testing.T) {
+	locales := map[string]string{"U1": "en", "U2": "zh", "U3": "en"}
+	render := func(locale string) []OutboundMessage { return []OutboundMessage{TextMessage("hello " + locale)} }
+	localeOf := func(userId string) string { return locales[userId] }
+	list := func() ([]string, error) { return []string{"U1", "U2", "U3"}, nil }
+
+	a := &recordAmbassador{}
+	s := NewBroadcastScheduler(a)
+	s.Locale = localeOf
+	if err := s.Broadcast(&ScheduledBroadcast{Id: "b", Audience: AllUsers(list), Render: render}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(a.sent)
+	expected := []string{"U1:hello en", "U2:hello zh", "U3:hello en"}
+	if strings.Join(a.sent, ",") != strings.Join(expected, ",") {
+		t.Errorf("expect a fan out to be localized per user, got %v", a.sent)
+	}
+
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	s = NewBroadcastScheduler(NewLineAmbassador("token", server.Client()))
+	s.Locale = localeOf
+	if err := s.Broadcast(&ScheduledBroadcast{Id: "b", Audience: AllUsers(list), Render: render}); err == nil {
+		t.Error("expect a bulk broadcast to all users not to be split into locales")
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("expect nothing to be sent, got %+v", server.Requests())
+	}
+
+	s.Locale = func(string) string { return "en" }
+	if err := s.Broadcast(&ScheduledBroadcast{Id: "b", Audience: AllUsers(list), Render: render}); err != nil {
+		t.Fatal(err)
+	}
+	if r := server.Requests(); len(r) != 1 || r[0].Path != "/v2/bot/message/broadcast" {
+		t.Errorf("expect one broadcast to all users, got %+v", r)
+	}
+
+	// the schedule is not held up by a broadcast being sent
+	s.sending.Lock()
+	done := make(chan struct{})
+	go func() {
+		s.Add("c", "0 9 * * *", AllUsers(nil), render)
+		s.Remove("c")
+		close(done)
+	}()
+	<-done
+	s.sending.Unlock()
+}
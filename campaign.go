@@ -0,0 +1,106 @@
+package ambassador
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CampaignStep struct {
+	// Delay is counted from the previous step, or from the enrollment for
+	// the first step.
+	Delay    time.Duration
+	Messages []OutboundMessage
+}
+
+// Campaign is an ordered sequence of messages sent to a subscriber over time.
+type Campaign struct {
+	Id    string
+	Steps []CampaignStep
+	// ExitOnReply stops the sequence of a subscriber once they send anything.
+	ExitOnReply bool
+	// ExitOn stops the sequence of a subscriber when it returns true for an
+	// inbound message, e.g. a conversion postback.
+	ExitOn func(msg Message) bool
+	// Window is the period after the last inbound message in which a
+	// platform allows sending messages, e.g. 24 hours for facebook. Steps
+	// falling out of the window are not scheduled. Zero means no window.
+	Window time.Duration
+}
+
+// CampaignRunner schedules the steps of a campaign per subscriber through
+// an outbox.
+type CampaignRunner struct {
+	sync.Mutex
+	Campaign    *Campaign
+	outbox      *Outbox
+	enrollments map[string][]string
+}
+
+func NewCampaignRunner(campaign *Campaign, outbox *Outbox) *CampaignRunner {
+	return &CampaignRunner{
+		Campaign:    campaign,
+		outbox:      outbox,
+		enrollments: map[string][]string{},
+	}
+}
+
+// Enroll schedules the campaign for a user whose last inbound message was
+// received at lastInbound.
+func (r *CampaignRunner) Enroll(userId string, lastInbound time.Time) (err error) {
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.enrollments[userId]; ok {
+		return fmt.Errorf("%s has already enrolled in campaign %s", userId, r.Campaign.Id)
+	}
+
+	at := r.outbox.now()
+	ids := []string{}
+	for _, step := range r.Campaign.Steps {
+		at = at.Add(step.Delay)
+		if r.Campaign.Window > 0 && at.After(lastInbound.Add(r.Campaign.Window)) {
+			break
+		}
+		e, err := r.outbox.Schedule(userId, at, step.Messages...)
+		if err != nil {
+			r.cancel(ids)
+			return err
+		}
+		ids = append(ids, e.Id)
+	}
+	r.enrollments[userId] = ids
+	return
+}
+
+// Exit cancels the remaining steps of a user.
+func (r *CampaignRunner) Exit(userId string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+	err = r.cancel(r.enrollments[userId])
+	delete(r.enrollments, userId)
+	return
+}
+
+// HandleMessage checks the exit conditions of the campaign against an
+// inbound message. It returns true if the sender exits the campaign.
+func (r *CampaignRunner) HandleMessage(msg Message) (exited bool, err error) {
+	r.Lock()
+	_, ok := r.enrollments[msg.SenderId]
+	r.Unlock()
+	if !ok {
+		return
+	}
+	if r.Campaign.ExitOnReply || (r.Campaign.ExitOn != nil && r.Campaign.ExitOn(msg)) {
+		return true, r.Exit(msg.SenderId)
+	}
+	return
+}
+
+func (r *CampaignRunner) cancel(ids []string) (err error) {
+	for _, id := range ids {
+		if e := r.outbox.Cancel(id); e != nil {
+			err = e
+		}
+	}
+	return
+}
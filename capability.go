@@ -0,0 +1,147 @@
+package ambassador
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is returned when a platform does not support an operation.
+var ErrUnsupported = errors.New("ambassador: operation not supported by the platform")
+
+// Deleter is implemented by ambassadors which can delete or unsend a message
+// sent by the bot.
+type Deleter interface {
+	DeleteMessage(messageId string) (err error)
+}
+
+// DeleteMessage deletes a sent message if the platform supports it, or
+// returns ErrUnsupported.
+func DeleteMessage(a Ambassador, messageId string) error {
+	if d, ok := unwrap(a).(Deleter); ok {
+		return d.DeleteMessage(messageId)
+	}
+	return ErrUnsupported
+}
+
+// Discarder is implemented by ambassadors which can drop the staged
+// messages, e.g. those of a builder which fails halfway, so that they are
+// not sent along with the next messages.
+type Discarder interface {
+	Discard()
+}
+
+func discard(a Ambassador) {
+	if d, ok := unwrap(a).(Discarder); ok {
+		d.Discard()
+	}
+}
+
+// Reactor is implemented by ambassadors which can react to a message with
+// an emoji.
+type Reactor interface {
+	React(messageId, emoji string) (err error)
+}
+
+// React reacts to a message if the platform supports it, or returns
+// ErrUnsupported.
+func React(a Ambassador, messageId, emoji string) error {
+	if r, ok := unwrap(a).(Reactor); ok {
+		return r.React(messageId, emoji)
+	}
+	return ErrUnsupported
+}
+
+// Threader is implemented by ambassadors which can send the staged messages
+// as replies to a message, e.g. in a thread.
+type Threader interface {
+	ReplyTo(messageId string) (err error)
+}
+
+// PreviewController is implemented by ambassadors which can turn URL previews
+// of the staged messages on or off.
+type PreviewController interface {
+	SetLinkPreview(enabled bool) (err error)
+}
+
+// Tagger is implemented by ambassadors which can tag the staged messages to
+// send them outside the messaging window of the platform.
+type Tagger interface {
+	SetMessageTag(tag string) (err error)
+}
+
+// Unwrapper is implemented by ambassadors which wrap another one, e.g. to
+// observe its sends, so that the helpers of this package still find the
+// capabilities of the wrapped ambassador.
+type Unwrapper interface {
+	Unwrap() Ambassador
+}
+
+func unwrap(a Ambassador) Ambassador {
+	for {
+		u, ok := a.(Unwrapper)
+		if !ok {
+			return a
+		}
+		a = u.Unwrap()
+	}
+}
+
+// sendTracker tells whether a handler sends anything by an ambassador.
+type sendTracker struct {
+	Ambassador
+	sent bool
+}
+
+func (t *sendTracker) Unwrap() Ambassador {
+	return t.Ambassador
+}
+
+func (t *sendTracker) Send(recipientId string) error {
+	t.sent = true
+	return t.Ambassador.Send(recipientId)
+}
+
+func (t *sendTracker) Push(recipientId string) error {
+	t.sent = true
+	if p, ok := t.Ambassador.(Pusher); ok {
+		return p.Push(recipientId)
+	}
+	return t.Ambassador.Send(recipientId)
+}
+
+// sendTyping sends a typing indicator to a recipient on its own.
+func sendTyping(a Ambassador, recipientId string, on bool) (err error) {
+	if err = a.SendTyping(on); err != nil {
+		return
+	}
+	return a.Send(recipientId)
+}
+
+// ShowTyping is a middleware which sends the typing indicator of SendTyping
+// right away, and hides it once the handler returns if the handler sent
+// nothing, since a reply hides it anyway. Handlers get an ambassador which
+// wraps the one of the message, so they should look up capabilities by the
+// helpers of this package, e.g. DeleteMessage, rather than by asserting
+// platform types.
+//
+// A failure of the indicator does not stop the handler, and it is returned
+// if the handler succeeds.
+func ShowTyping() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			target := msg.ReplyTarget()
+			typingErr := sendTyping(a, target, true)
+			t := &sendTracker{Ambassador: a}
+			if err = next.Handle(t, msg); err != nil {
+				return
+			}
+			if typingErr == nil && !t.sent {
+				typingErr = sendTyping(a, target, false)
+			}
+			if typingErr != nil {
+				return fmt.Errorf("fail to show typing: %s", typingErr)
+			}
+			return
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestDeleteMessage(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+
+	discord := NewDiscordAmbassador("token", server.Client())
+	if err := DeleteMessage(&sendTracker{Ambassador: discord}, "c1:m1"); err != nil {
+		t.Fatal(err)
+	}
+	slack := NewSlackAmbassador("token", server.Client())
+	if err := DeleteMessage(slack, "C1:1500000000.000100"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expect a request per delete, got %d", len(requests))
+	}
+	if r := requests[0]; r.Method != "DELETE" || r.Path != "/api/v10/channels/c1/messages/m1" {
+		t.Errorf("unexpected discord delete: %s %s", r.Method, r.Path)
+	}
+	if r := requests[1]; r.Path != "/api/chat.delete" || !strings.Contains(string(r.Body), `"ts":"1500000000.000100"`) {
+		t.Errorf("unexpected slack delete: %s %s", r.Path, r.Body)
+	}
+
+	if err := DeleteMessage(discord, "m1"); err == nil || err == ErrUnsupported {
+		t.Errorf("expect a message without a channel to be refused, got %v", err)
+	}
+	if err := DeleteMessage(NewFBAmbassador("token", server.Client()), "m1"); err != ErrUnsupported {
+		t.Errorf("expect facebook not to delete messages, got %v", err)
+	}
+	if len(server.Requests()) != 2 {
+		t.Errorf("expect refused deletes not to be requested, got %d requests", len(server.Requests()))
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// console runs an echo bot. Bots try their own handlers by calling
+// ambassador.RunConsole with their router instead.
+func console(args []string) error {
+	fmt.Println("chatting with an echo bot. type /postback PAYLOAD, /location LAT LON or /N to choose an answer.")
+
+	router := ambassador.NewRouter()
+	router.Text(ambassador.HandlerFunc(func(a ambassador.Ambassador, msg ambassador.Message) error {
+		text := msg.Content.(*ambassador.TextContent).Text
+		a.AskQuestion("you said: "+text, []map[string]string{
+			{"title": "again", "payload": "AGAIN"},
+			{"title": "card", "payload": "CARD"},
+		})
+		return a.Send(msg.ReplyTarget())
+	}))
+	router.Payload("CARD", ambassador.HandlerFunc(func(a ambassador.Ambassador, msg ambassador.Message) error {
+		a.SendTemplate([]ambassador.Carousel{{
+			Title:   "A card",
+			Text:    "rendered in the terminal",
+			ItemUrl: "https://github.com/lemonlatte/ambassador",
+		}})
+		return a.Send(msg.ReplyTarget())
+	}))
+	router.Default(ambassador.HandlerFunc(func(a ambassador.Ambassador, msg ambassador.Message) error {
+		a.SendText(fmt.Sprintf("received %T %+v", msg.Content, msg.Content))
+		return a.Send(msg.ReplyTarget())
+	}))
+	return ambassador.RunConsole(os.Stdin, os.Stdout, router)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// lint reports the platform constraints violated by message specs. A spec is
+// a JSON array of outbound messages, or a single outbound message.
+func lint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	platforms := flags.String("platform", strings.Join(ambassador.LintPlatforms, ","), "comma separated platforms to check")
+	asJSON := flags.Bool("json", false, "print the issues as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ambassador lint [-platform facebook,line] [-json] spec.json...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	issues := []ambassador.LintIssue{}
+	for _, path := range flags.Args() {
+		messages, err := readSpec(path)
+		if err != nil {
+			return err
+		}
+		for _, issue := range ambassador.Lint(messages, strings.Split(*platforms, ",")...) {
+			issue.Path = path + ": " + issue.Path
+			issues = append(issues, issue)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(issues); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("%d issues found", len(issues))
+	}
+	return nil
+}
+
+func readSpec(path string) (messages []ambassador.OutboundMessage, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return nil, fmt.Errorf("%s: YAML specs are not supported yet, convert it to JSON", path)
+	}
+
+	var b []byte
+	if path == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(b, &messages); err == nil {
+		return
+	}
+	var message ambassador.OutboundMessage
+	if err = json.Unmarshal(b, &message); err != nil {
+		return nil, fmt.Errorf("%s: fail to parse the spec: %s", path, err)
+	}
+	return []ambassador.OutboundMessage{message}, nil
+}
@@ -0,0 +1,40 @@
+// Command ambassador is a toolbox for developing bots with the ambassador
+// package.
+//
+//	ambassador console    chat with an echo bot in the terminal
+//	ambassador lint       check message specs against platform constraints
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ambassador <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  console    chat with an echo bot in the terminal")
+	fmt.Fprintln(os.Stderr, "  lint       check message specs against platform constraints")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "console":
+		err = console(os.Args[2:])
+	case "lint":
+		err = lint(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
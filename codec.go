@@ -0,0 +1,151 @@
+package ambassador
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// refSeparator separates the data and the signature of a ref. m.me refs only
+// allow alphanumeric characters, "-", "_" and "=", and base64url encoding
+// without padding never produces "=".
+const refSeparator = "="
+
+const payloadSeparator = "."
+
+const signatureLength = 12
+
+var (
+	ErrInvalidSignature = errors.New("ambassador: invalid signature")
+	ErrMalformedPayload = errors.New("ambassador: malformed payload")
+	ErrPayloadExpired   = errors.New("ambassador: payload expired")
+)
+
+// RefPayload is a decoded ref with its kind, so that different sources of
+// acquisition can be told apart before unmarshaling their data.
+type RefPayload struct {
+	Kind string          `json:"k"`
+	Data json.RawMessage `json:"d,omitempty"`
+}
+
+func (p *RefPayload) Unmarshal(v interface{}) error {
+	return json.Unmarshal(p.Data, v)
+}
+
+// RefCodec marshals small values into compact signed strings which can be
+// used as ref parameters of deep links and verified once they come back.
+type RefCodec struct {
+	secret []byte
+}
+
+func NewRefCodec(secret string) *RefCodec {
+	return &RefCodec{secret: []byte(secret)}
+}
+
+func (c *RefCodec) Encode(kind string, v interface{}) (ref string, err error) {
+	payload := RefPayload{Kind: kind}
+	if v != nil {
+		if payload.Data, err = json.Marshal(v); err != nil {
+			return
+		}
+	}
+	b, err := json.Marshal(&payload)
+	if err != nil {
+		return
+	}
+	return signData(c.secret, b, refSeparator), nil
+}
+
+func (c *RefCodec) Decode(ref string) (payload *RefPayload, err error) {
+	b, err := verifyData(c.secret, ref, refSeparator)
+	if err != nil {
+		return
+	}
+	payload = &RefPayload{}
+	if err = json.Unmarshal(b, payload); err != nil {
+		return nil, ErrMalformedPayload
+	}
+	return
+}
+
+func signData(secret, data []byte, sep string) string {
+	return base64.RawURLEncoding.EncodeToString(data) + sep + signature(secret, data)
+}
+
+func verifyData(secret []byte, s, sep string) (data []byte, err error) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return nil, ErrMalformedPayload
+	}
+	data, err = base64.RawURLEncoding.DecodeString(s[:i])
+	if err != nil {
+		return nil, ErrMalformedPayload
+	}
+	if !hmac.Equal([]byte(signature(secret, data)), []byte(s[i+len(sep):])) {
+		return nil, ErrInvalidSignature
+	}
+	return
+}
+
+func signature(secret, data []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))[:signatureLength]
+}
+
+type signedPayload struct {
+	Data      json.RawMessage `json:"d"`
+	ExpiresAt int64           `json:"e,omitempty"`
+}
+
+// PayloadCodec marshals small values into signed postback and quick reply
+// payloads, so that a payload can neither be tampered with nor be used after
+// it expires.
+type PayloadCodec struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func NewPayloadCodec(secret string) *PayloadCodec {
+	return &PayloadCodec{secret: []byte(secret), now: time.Now}
+}
+
+// Encode returns a signed payload of v. A payload never expires if the ttl
+// is zero.
+func (c *PayloadCodec) Encode(v interface{}, ttl time.Duration) (payload string, err error) {
+	p := signedPayload{}
+	if p.Data, err = json.Marshal(v); err != nil {
+		return
+	}
+	if ttl > 0 {
+		p.ExpiresAt = c.now().Add(ttl).Unix()
+	}
+	b, err := json.Marshal(&p)
+	if err != nil {
+		return
+	}
+	return signData(c.secret, b, payloadSeparator), nil
+}
+
+// Decode verifies a payload and unmarshals it into v.
+func (c *PayloadCodec) Decode(payload string, v interface{}) (err error) {
+	b, err := verifyData(c.secret, payload, payloadSeparator)
+	if err != nil {
+		return
+	}
+	var p signedPayload
+	if err = json.Unmarshal(b, &p); err != nil {
+		return ErrMalformedPayload
+	}
+	if p.ExpiresAt != 0 && c.now().Unix() > p.ExpiresAt {
+		return ErrPayloadExpired
+	}
+	if err = json.Unmarshal(p.Data, v); err != nil {
+		return ErrMalformedPayload
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefCodec(t *testing.T) {
+	type campaign struct {
+		Name string
+		Seq  int
+	}
+
+	c := NewRefCodec("secret")
+	ref, err := c.Encode("campaign", &campaign{Name: "spring", Seq: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MessengerLink("mypage", ref); err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := c.Decode(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v campaign
+	if err := payload.Unmarshal(&v); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Kind != "campaign" || v.Name != "spring" || v.Seq != 3 {
+		t.Errorf("unexpected payload: %s %+v", payload.Kind, v)
+	}
+
+	if _, err := NewRefCodec("other").Decode(ref); err != ErrInvalidSignature {
+		t.Errorf("expect an invalid signature, got %v", err)
+	}
+}
+
+func TestPayloadCodec(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	c := NewPayloadCodec("secret")
+	c.now = func() time.Time { return now }
+
+	payload, err := c.Encode(map[string]int{"item": 42}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v map[string]int
+	if err := c.Decode(payload, &v); err != nil || v["item"] != 42 {
+		t.Fatalf("fail to decode the payload: %v %+v", err, v)
+	}
+	if err := c.Decode("x"+payload, &v); err == nil {
+		t.Error("expect a tampered payload to be rejected")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := c.Decode(payload, &v); err != ErrPayloadExpired {
+		t.Errorf("expect the payload to be expired, got %v", err)
+	}
+}
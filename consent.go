@@ -0,0 +1,207 @@
+package ambassador
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const ConsentPayloadPrefix = "AMBASSADOR_CONSENT:"
+
+// ConsentPolicy is a prompt users accept or decline, e.g. terms of service.
+// Bumping its version asks users who accepted an older one again.
+type ConsentPolicy struct {
+	Name    string
+	Version string
+	Text    string
+	// AcceptLabel and DeclineLabel default to "Accept" and "Decline".
+	AcceptLabel  string
+	DeclineLabel string
+}
+
+// ConsentRecord is the latest answer of a user to a policy.
+type ConsentRecord struct {
+	Platform string
+	UserId   string
+	Policy   string
+	Version  string
+	Accepted bool
+	At       time.Time
+}
+
+// ConsentContent is the answer of a user to a consent prompt. Resume is the
+// payload of the gated flow which prompted it, if any.
+type ConsentContent struct {
+	Policy   string
+	Version  string
+	Accepted bool
+	Resume   string
+}
+
+// ConsentStore keeps the latest answers of users to policies.
+type ConsentStore interface {
+	Record(r ConsentRecord) error
+	Get(platform, userId, policy string) (r ConsentRecord, ok bool, err error)
+}
+
+type MemoryConsentStore struct {
+	sync.Mutex
+	records map[string]ConsentRecord
+}
+
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{records: map[string]ConsentRecord{}}
+}
+
+func (s *MemoryConsentStore) Record(r ConsentRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	s.records[r.Platform+":"+r.UserId+":"+r.Policy] = r
+	return nil
+}
+
+func (s *MemoryConsentStore) Get(platform, userId, policy string) (r ConsentRecord, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	r, ok = s.records[platform+":"+userId+":"+policy]
+	return
+}
+
+// ConsentManager asks users for consent to policies, records their answers
+// and gates flows until they accept.
+type ConsentManager struct {
+	store ConsentStore
+	now   func() time.Time
+}
+
+func NewConsentManager(store ConsentStore) *ConsentManager {
+	if store == nil {
+		store = NewMemoryConsentStore()
+	}
+	return &ConsentManager{store: store, now: time.Now}
+}
+
+// consentPayload is the payload of an answer to a policy, which carries the
+// payload of the flow to resume after it.
+func consentPayload(p ConsentPolicy, accepted bool, resume string) string {
+	answer := "0"
+	if accepted {
+		answer = "1"
+	}
+	return ConsentPayloadPrefix + strings.Join([]string{p.Name, p.Version, answer, resume}, "|")
+}
+
+func parseConsentPayload(payload string) (c *ConsentContent, ok bool) {
+	if !strings.HasPrefix(payload, ConsentPayloadPrefix) {
+		return
+	}
+	fields := strings.SplitN(strings.TrimPrefix(payload, ConsentPayloadPrefix), "|", 4)
+	if len(fields) != 4 {
+		return
+	}
+	return &ConsentContent{Policy: fields[0], Version: fields[1], Accepted: fields[2] == "1", Resume: fields[3]}, true
+}
+
+// Ask stages the prompt of a policy. Resume is the payload of the flow to
+// come back to, which is passed on by ConsentContent.
+func (m *ConsentManager) Ask(a Ambassador, p ConsentPolicy, resume string) error {
+	accept, decline := p.AcceptLabel, p.DeclineLabel
+	if accept == "" {
+		accept = "Accept"
+	}
+	if decline == "" {
+		decline = "Decline"
+	}
+	return a.AskQuestion(p.Text, []map[string]string{
+		{"title": accept, "payload": consentPayload(p, true, resume)},
+		{"title": decline, "payload": consentPayload(p, false, resume)},
+	})
+}
+
+// Consented tells whether a user accepted the current version of a policy.
+func (m *ConsentManager) Consented(platform, userId string, p ConsentPolicy) (ok bool, err error) {
+	r, ok, err := m.store.Get(platform, userId, p.Name)
+	if err != nil || !ok {
+		return false, err
+	}
+	return r.Accepted && r.Version == p.Version, nil
+}
+
+// Track is a middleware which records the answers to consent prompts and
+// passes them on as ConsentContent, to be routed by
+// Router.Content(&ConsentContent{}, h).
+func (m *ConsentManager) Track() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			command, ok := msg.Content.(*CommandContent)
+			if !ok {
+				return next.Handle(a, msg)
+			}
+			c, ok := parseConsentPayload(command.Payload)
+			if !ok {
+				return next.Handle(a, msg)
+			}
+			err := m.store.Record(ConsentRecord{
+				Platform: platformOf(a),
+				UserId:   msg.SenderId,
+				Policy:   c.Policy,
+				Version:  c.Version,
+				Accepted: c.Accepted,
+				At:       m.now(),
+			})
+			if err != nil {
+				return err
+			}
+			msg.Content = c
+			return next.Handle(a, msg)
+		})
+	}
+}
+
+// Require is a middleware which asks for consent to a policy instead of
+// handling the commands of gated payloads until the user accepts it. A
+// payload ending with "*" matches a prefix. Every message is gated if no
+// payload is given. It must be used after Track, and answers to consent
+// prompts are never gated.
+func (m *ConsentManager) Require(p ConsentPolicy, payloads ...string) Middleware {
+	gated := func(msg Message) (resume string, ok bool) {
+		if _, ok := msg.Content.(*ConsentContent); ok {
+			return "", false
+		}
+		command, isCommand := msg.Content.(*CommandContent)
+		if isCommand {
+			resume = command.Payload
+		}
+		if len(payloads) == 0 {
+			return resume, true
+		}
+		if !isCommand {
+			return
+		}
+		for _, payload := range payloads {
+			if payload == command.Payload || (strings.HasSuffix(payload, "*") && strings.HasPrefix(command.Payload, strings.TrimSuffix(payload, "*"))) {
+				return resume, true
+			}
+		}
+		return
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			resume, ok := gated(msg)
+			if !ok {
+				return next.Handle(a, msg)
+			}
+			consented, err := m.Consented(platformOf(a), msg.SenderId, p)
+			if err != nil {
+				return err
+			}
+			if consented {
+				return next.Handle(a, msg)
+			}
+			if err = m.Ask(a, p, resume); err != nil {
+				return err
+			}
+			return a.Send(msg.ReplyTarget())
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package ambassador
+
+import "testing"
+
+func TestConsentRequire(t *testing.T) {
+	consent := NewConsentManager(nil)
+	terms := ConsentPolicy{Name: "terms", Version: "2", Text: "Do you accept our terms?"}
+
+	var resumed string
+	router := NewRouter()
+	router.Use(consent.Track(), consent.Require(terms, "ORDER_*"))
+	router.Payload("ORDER_NEW", HandlerFunc(func(a Ambassador, msg Message) error {
+		resumed = "ORDER_NEW"
+		return nil
+	}))
+	router.Payload("HELP", HandlerFunc(func(a Ambassador, msg Message) error {
+		resumed = "HELP"
+		return nil
+	}))
+	router.Content(&ConsentContent{}, HandlerFunc(func(a Ambassador, msg Message) error {
+		c := msg.Content.(*ConsentContent)
+		if c.Accepted {
+			resumed = "consent:" + c.Resume
+		}
+		return nil
+	}))
+
+	a := &recordAmbassador{}
+	router.Handle(a, Message{SenderId: "u1", Content: &CommandContent{Payload: "ORDER_NEW"}})
+	if resumed != "" || len(a.sent) != 1 || a.sent[0] != "u1:Do you accept our terms?" {
+		t.Fatalf("expect the gated flow to ask for consent, got %q, %v", resumed, a.sent)
+	}
+	router.Handle(a, Message{SenderId: "u1", Content: &CommandContent{Payload: "HELP"}})
+	if resumed != "HELP" {
+		t.Errorf("expect flows out of the gate to pass, got %q", resumed)
+	}
+
+	router.Handle(a, Message{SenderId: "u1", Content: &CommandContent{Payload: consentPayload(terms, true, "ORDER_NEW")}})
+	if resumed != "consent:ORDER_NEW" {
+		t.Errorf("expect the accepted consent with the gated payload, got %q", resumed)
+	}
+	if ok, _ := consent.Consented(platformOf(a), "u1", terms); !ok {
+		t.Error("expect the consent to be recorded")
+	}
+	router.Handle(a, Message{SenderId: "u1", Content: &CommandContent{Payload: "ORDER_NEW"}})
+	if resumed != "ORDER_NEW" || len(a.sent) != 1 {
+		t.Errorf("expect the gated flow to pass after consent, got %q", resumed)
+	}
+
+	terms.Version = "3"
+	if ok, _ := consent.Consented(platformOf(a), "u1", terms); ok {
+		t.Error("expect a new version of the policy to need consent again")
+	}
+}
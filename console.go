@@ -0,0 +1,228 @@
+package ambassador
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ConsoleUserId = "console-user"
+
+// ConsoleAmbassador chats through a terminal, so a bot can be tried locally
+// without any channel. Outbound questions, carousels and keyboards are
+// rendered as text.
+//
+// Inbound lines are plain texts, or commands:
+//
+//	/postback PAYLOAD    send a postback
+//	/location LAT LON    send a location
+//	/N                   choose the Nth answer of the last question
+type ConsoleAmbassador struct {
+	sync.Mutex
+	out          io.Writer
+	staged       []string
+	answers      []map[string]string
+	lastMessages []interface{}
+}
+
+func NewConsoleAmbassador(out io.Writer) *ConsoleAmbassador {
+	return &ConsoleAmbassador{out: out}
+}
+
+func (c *ConsoleAmbassador) Platform() string {
+	return "console"
+}
+
+func (c *ConsoleAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		msg := Message{
+			SenderId:    ConsoleUserId,
+			RecipientId: "console-bot",
+			Timestamp:   time.Now().UnixNano() / int64(time.Millisecond),
+		}
+		if msg.Content, err = c.parse(line); err != nil {
+			return
+		}
+		messages = append(messages, msg)
+	}
+	err = scanner.Err()
+	return
+}
+
+func (c *ConsoleAmbassador) parse(line string) (content interface{}, err error) {
+	if !strings.HasPrefix(line, "/") {
+		return &TextContent{Text: line}, nil
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return &TextContent{Text: line}, nil
+	}
+
+	switch fields[0] {
+	case "postback":
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("usage: /postback PAYLOAD")
+		}
+		return &CommandContent{Payload: fields[1]}, nil
+	case "location":
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("usage: /location LAT LON")
+		}
+		lat, err1 := strconv.ParseFloat(fields[1], 64)
+		lon, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid location: %s", line)
+		}
+		return &LocationContent{Lat: lat, Lon: lon}, nil
+	}
+
+	if n, err := strconv.Atoi(fields[0]); err == nil {
+		c.Lock()
+		defer c.Unlock()
+		if n < 1 || n > len(c.answers) {
+			return nil, fmt.Errorf("no answer %d to choose", n)
+		}
+		return &CommandContent{Payload: c.answers[n-1]["payload"]}, nil
+	}
+	return &TextContent{Text: line}, nil
+}
+
+func (c *ConsoleAmbassador) stage(lines ...string) {
+	c.Lock()
+	defer c.Unlock()
+	c.staged = append(c.staged, lines...)
+}
+
+func (c *ConsoleAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	c.Lock()
+	c.answers = answers
+	c.Unlock()
+
+	lines := []string{text}
+	for i, answer := range answers {
+		lines = append(lines, fmt.Sprintf("  [%d] %s", i+1, answer["title"]))
+	}
+	c.stage(strings.Join(lines, "\n"))
+	return
+}
+
+func (c *ConsoleAmbassador) SendText(text string) (err error) {
+	c.stage(text)
+	return
+}
+
+func (c *ConsoleAmbassador) SendTemplate(elements interface{}) (err error) {
+	columns, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	for _, col := range columns {
+		c.stage(renderCard(col))
+	}
+	return
+}
+
+func (c *ConsoleAmbassador) SendKeyboard(text string, kb Keyboard) (err error) {
+	lines := []string{text}
+	for _, row := range kb.Rows {
+		labels := make([]string, 0, len(row))
+		for _, btn := range row {
+			labels = append(labels, "[ "+btn.Label+" ]")
+		}
+		lines = append(lines, "  "+strings.Join(labels, " "))
+	}
+	c.stage(strings.Join(lines, "\n"))
+	return
+}
+
+func (c *ConsoleAmbassador) SendTyping(on bool) (err error) {
+	if on {
+		c.stage("(typing...)")
+	}
+	return
+}
+
+func (c *ConsoleAmbassador) WithTyping(d time.Duration) (err error) {
+	c.stage(fmt.Sprintf("(typing for %s...)", d))
+	return
+}
+
+func (c *ConsoleAmbassador) MarkRead(msg Message) (err error) {
+	_, err = fmt.Fprintln(c.out, "(seen)")
+	return
+}
+
+func (c *ConsoleAmbassador) GetLastSent() []interface{} {
+	return c.lastMessages
+}
+
+func (c *ConsoleAmbassador) Send(recipientId string) (err error) {
+	c.Lock()
+	defer c.Unlock()
+	c.lastMessages = c.lastMessages[:0]
+	for _, s := range c.staged {
+		c.lastMessages = append(c.lastMessages, s)
+		if _, err = fmt.Fprintf(c.out, "bot: %s\n", s); err != nil {
+			return
+		}
+	}
+	c.staged = nil
+	return
+}
+
+func renderCard(col Carousel) string {
+	lines := []string{col.Title}
+	lines = append(lines, strings.Split(col.Text, "\n")...)
+	if col.ImageUrl != "" {
+		lines = append(lines, "image: "+col.ImageUrl)
+	}
+	if col.ItemUrl != "" {
+		lines = append(lines, "link: "+col.ItemUrl)
+	}
+	for _, btn := range col.Buttons {
+		lines = append(lines, fmt.Sprintf("< %s > %s", btn.Label, btn.Data))
+	}
+
+	width := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	border := "+" + strings.Repeat("-", width+2) + "+"
+	box := []string{"", border}
+	for _, line := range lines {
+		box = append(box, "| "+line+strings.Repeat(" ", width-len([]rune(line)))+" |")
+	}
+	return strings.Join(append(box, border), "\n")
+}
+
+// RunConsole reads lines from in and handles them as messages of a user
+// until in is closed.
+func RunConsole(in io.Reader, out io.Writer, h Handler) error {
+	c := NewConsoleAmbassador(out)
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(out, "> ")
+	for scanner.Scan() {
+		messages, err := c.Translate(strings.NewReader(scanner.Text()))
+		if err != nil {
+			fmt.Fprintln(out, err)
+		}
+		for _, msg := range messages {
+			if err := h.Handle(c, msg); err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+			}
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return scanner.Err()
+}
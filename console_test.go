@@ -0,0 +1,45 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunConsole(t *testing.T) {
+	h := HandlerFunc(func(a Ambassador, msg Message) error {
+		switch c := msg.Content.(type) {
+		case *TextContent:
+			a.AskQuestion("Size?", []map[string]string{
+				{"title": "Small", "payload": "SIZE_S"},
+				{"title": "Large", "payload": "SIZE_L"},
+			})
+		case *CommandContent:
+			a.SendText("chose " + c.Payload)
+		case *LocationContent:
+			a.SendTemplate([]Carousel{{Title: "Shop", Text: fmt.Sprintf("%.2f,%.2f", c.Lat, c.Lon), ItemUrl: "https://example.com"}})
+		}
+		return a.Send(msg.ReplyTarget())
+	})
+	in := strings.Join([]string{"hi", "/2", "/9", "/postback START", "/location 25.03 121.56", "/location north east"}, "\n")
+	out := &bytes.Buffer{}
+	if err := RunConsole(strings.NewReader(in), out, h); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"bot: Size?\n  [1] Small\n  [2] Large\n",
+		"bot: chose SIZE_L\n",
+		"no answer 9 to choose\n",
+		"bot: chose START\n",
+		"+---------------------------+\n| Shop                      |\n| 25.03,121.56              |\n| link: https://example.com |\n",
+		"invalid location: /location north east",
+	}
+	output := out.String()
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("expect %q in the output:\n%s", e, output)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TestingT is the part of testing.TB a conversation script reports to.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Expectation matches a message a bot is expected to send.
+type Expectation struct {
+	desc  string
+	match func(m MockMessage) bool
+}
+
+func (e Expectation) String() string {
+	return e.desc
+}
+
+// ExpectText expects a text.
+func ExpectText(text string) Expectation {
+	return Expectation{fmt.Sprintf("text %q", text), func(m MockMessage) bool {
+		return m.Type == MockText && m.Text == text
+	}}
+}
+
+// ExpectTextContaining expects a text with a substring.
+func ExpectTextContaining(s string) Expectation {
+	return Expectation{fmt.Sprintf("text containing %q", s), func(m MockMessage) bool {
+		return m.Type == MockText && strings.Contains(m.Text, s)
+	}}
+}
+
+// ExpectQuestion expects a question with a number of answers.
+func ExpectQuestion(text string, answers int) Expectation {
+	return Expectation{fmt.Sprintf("question %q with %d answers", text, answers), func(m MockMessage) bool {
+		return m.Type == MockQuestion && m.Text == text && len(m.Answers) == answers
+	}}
+}
+
+// ExpectTemplate expects a template with a number of elements.
+func ExpectTemplate(elements int) Expectation {
+	return Expectation{fmt.Sprintf("template with %d elements", elements), func(m MockMessage) bool {
+		return m.Type == MockTemplate && len(m.Elements) == elements
+	}}
+}
+
+// ExpectKeyboard expects a keyboard with a text.
+func ExpectKeyboard(text string) Expectation {
+	return Expectation{fmt.Sprintf("keyboard %q", text), func(m MockMessage) bool {
+		return m.Type == MockKeyboard && m.Text == text
+	}}
+}
+
+// Turn is what a user sends and what the bot is expected to reply. Typing
+// indicators are not compared.
+type Turn struct {
+	Name string
+	// Content is what the user sends, e.g. &TextContent{Text: "hi"}.
+	Content interface{}
+	// ChatId is set for a turn in a group chat.
+	ChatId string
+	Expect []Expectation
+}
+
+// Say is the content of a user saying a text.
+func Say(text string) interface{} {
+	return &TextContent{Text: text}
+}
+
+// Press is the content of a user pressing a button or a quick reply.
+func Press(payload string) interface{} {
+	return &CommandContent{Payload: payload}
+}
+
+// RunConversation plays the turns of a user against a handler, e.g. a
+// Router, through a MockAmbassador and reports the turns whose replies
+// differ, like a table-driven test:
+//
+//	ambassador.RunConversation(t, router, []ambassador.Turn{
+//		{Content: ambassador.Say("hi"), Expect: []ambassador.Expectation{
+//			ambassador.ExpectQuestion("What size?", 3),
+//		}},
+//		{Content: ambassador.Press("SIZE_M"), Expect: []ambassador.Expectation{
+//			ambassador.ExpectText("Ordered"),
+//		}},
+//	})
+//
+// It returns the ambassador, to inspect the deliveries further.
+func RunConversation(t TestingT, h Handler, turns []Turn) *MockAmbassador {
+	t.Helper()
+	a := NewMockAmbassador()
+	for i, turn := range turns {
+		a.Reset()
+		msg := Message{
+			SenderId:  MockUserId,
+			ChatId:    turn.ChatId,
+			MessageId: fmt.Sprintf("mock-%d", i+1),
+			Content:   turn.Content,
+		}
+		name := fmt.Sprintf("turn %d", i+1)
+		if turn.Name != "" {
+			name += " (" + turn.Name + ")"
+		}
+		if err := h.Handle(a, msg); err != nil {
+			t.Errorf("%s: fail to handle: %s", name, err)
+			continue
+		}
+		var got []MockMessage
+		for _, d := range a.Deliveries() {
+			for _, m := range d.Messages {
+				if m.Type != MockTyping {
+					got = append(got, m)
+				}
+			}
+		}
+		if diff, ok := diffReplies(turn.Expect, got); !ok {
+			t.Errorf("%s: unexpected replies (- expected, + got):\n%s", name, diff)
+		}
+	}
+	return a
+}
+
+func diffReplies(expect []Expectation, got []MockMessage) (diff string, ok bool) {
+	ok = true
+	lines := []string{}
+	n := len(expect)
+	if len(got) > n {
+		n = len(got)
+	}
+	for i := 0; i < n; i++ {
+		switch {
+		case i < len(expect) && i < len(got) && expect[i].match(got[i]):
+			lines = append(lines, "  "+got[i].String())
+		default:
+			ok = false
+			if i < len(expect) {
+				lines = append(lines, "- "+expect[i].String())
+			}
+			if i < len(got) {
+				lines = append(lines, "+ "+got[i].String())
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), ok
+}
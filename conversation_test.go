@@ -0,0 +1,54 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recordT struct {
+	errors []string
+}
+
+func (r *recordT) Helper() {}
+
+func (r *recordT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRunConversation(t *testing.T) {
+	d, err := ParseDefinitions([]byte(testDefinitions), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter()
+	runner := NewFlowRunner(func() []Flow { return d.Flows })
+	runner.Fallback = HandlerFunc(func(a Ambassador, msg Message) error {
+		a.WithTyping(0)
+		a.SendText("Sorry, I do not understand.")
+		return a.Send(msg.ReplyTarget())
+	})
+	router.Default(runner)
+
+	RunConversation(t, router, []Turn{
+		{Name: "greeting", Content: Say("hello"), Expect: []Expectation{ExpectTextContaining("do not understand")}},
+		{Content: Say("order please"), Expect: []Expectation{ExpectQuestion("Size?", 1)}},
+		{Content: Press("SIZE_M"), Expect: []Expectation{ExpectText("Any note?")}},
+		{Content: Say("none"), Expect: []Expectation{ExpectText("Ordered")}},
+	})
+
+	r := &recordT{}
+	RunConversation(r, router, []Turn{
+		{Name: "order", Content: Press("ORDER"), Expect: []Expectation{ExpectQuestion("Size?", 3), ExpectText("Thanks")}},
+	})
+	if len(r.errors) != 1 {
+		t.Fatalf("expect a failed turn, got %q", r.errors)
+	}
+	expected := `turn 1 (order): unexpected replies (- expected, + got):
+- question "Size?" with 3 answers
++ question "Size?" [M]
+- text "Thanks"`
+	if !strings.Contains(r.errors[0], expected) {
+		t.Errorf("unexpected failure:\n%s", r.errors[0])
+	}
+}
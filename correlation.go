@@ -0,0 +1,57 @@
+package ambassador
+
+import (
+	"net/http"
+)
+
+// CorrelationHeader carries the correlation id on outbound API calls.
+const CorrelationHeader = "X-Correlation-Id"
+
+// correlationHeaders are looked up for an id set by an upstream proxy.
+var correlationHeaders = []string{CorrelationHeader, "X-Request-Id"}
+
+// Correlator is implemented by ambassadors which attach a correlation id to
+// their API calls.
+type Correlator interface {
+	SetCorrelationId(id string)
+}
+
+// correlationIdOf adopts the correlation id of a request or generates one.
+func correlationIdOf(r *http.Request) string {
+	for _, name := range correlationHeaders {
+		if id := r.Header.Get(name); id != "" {
+			return id
+		}
+	}
+	return newId()
+}
+
+func withCorrelation(a Ambassador, id string) Ambassador {
+	if c, ok := unwrap(a).(Correlator); ok && id != "" {
+		c.SetCorrelationId(id)
+	}
+	return a
+}
+
+func setCorrelationHeader(req *http.Request, id string) {
+	if id != "" {
+		req.Header.Set(CorrelationHeader, id)
+	}
+}
+
+type correlationLogger struct {
+	logger Logger
+	id     string
+}
+
+func (l correlationLogger) Printf(format string, v ...interface{}) {
+	l.logger.Printf("[%s] "+format, append([]interface{}{l.id}, v...)...)
+}
+
+// CorrelationLogger prefixes every line of a logger with a correlation id.
+func CorrelationLogger(logger Logger, id string) Logger {
+	if id == "" {
+		return logger
+	}
+	return correlationLogger{logger: logger, id: id}
+}
@@ -0,0 +1,58 @@
+package ambassador
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestWebhookCorrelation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	var ids []string
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewFBAmbassador("token", server.Client()) },
+		Handler: HandlerFunc(func(a Ambassador, msg Message) error {
+			ids = append(ids, msg.CorrelationId)
+			a.SendText("hello")
+			return a.Send(msg.ReplyTarget())
+		}),
+	}
+	body := `{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"message":{"mid":"m1","text":"hi"}}]}]}`
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, req)
+	if id := rec.Header().Get(CorrelationHeader); id != "req-1" {
+		t.Errorf("expect the id of the proxy to be adopted, got %q", id)
+	}
+	if len(ids) != 1 || ids[0] != "req-1" {
+		t.Errorf("expect the message to carry the id, got %v", ids)
+	}
+	if r := server.Requests()[0]; r.Header.Get(CorrelationHeader) != "req-1" {
+		t.Errorf("expect the reply to carry the id, got %v", r.Header)
+	}
+
+	rec = httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	generated := rec.Header().Get(CorrelationHeader)
+	if generated == "" || generated == "req-1" || ids[1] != generated {
+		t.Errorf("expect an id to be generated, got %q and %v", generated, ids)
+	}
+	if r := server.Requests()[1]; r.Header.Get(CorrelationHeader) != generated {
+		t.Errorf("expect the reply to carry the generated id, got %v", r.Header)
+	}
+}
+
+func TestCorrelationLogger(t *testing.T) {
+	logger := &lineLogger{}
+	CorrelationLogger(logger, "req-1").Printf("sent %d messages", 2)
+	CorrelationLogger(logger, "").Printf("no id")
+	if len(logger.lines) != 2 || logger.lines[0] != "[req-1] sent 2 messages" || logger.lines[1] != "no id" {
+		t.Errorf("unexpected lines: %q", logger.lines)
+	}
+}
@@ -0,0 +1,32 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2017, 3, 10, 9, 31, 0, 0, time.UTC) // friday
+
+	for spec, expected := range map[string]time.Time{
+		"30 9 * * 1-5": time.Date(2017, 3, 13, 9, 30, 0, 0, time.UTC),
+		"*/15 * * * *": time.Date(2017, 3, 10, 9, 45, 0, 0, time.UTC),
+		"@daily":       time.Date(2017, 3, 11, 0, 0, 0, 0, time.UTC),
+		"0 12 1 * *":   time.Date(2017, 4, 1, 12, 0, 0, 0, time.UTC),
+		"0 8 * * 7":    time.Date(2017, 3, 12, 8, 0, 0, 0, time.UTC),
+	} {
+		s, err := ParseCron(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := s.Next(from); !next.Equal(expected) {
+			t.Errorf("%s: expect %s, got %s", spec, expected, next)
+		}
+	}
+
+	for _, spec := range []string{"* * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("expect %q to be invalid", spec)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package ambassador
+
+import "time"
+
+type DeadlineOptions struct {
+	// Threshold is the time budget from receiving a message to the end of
+	// its handling.
+	Threshold time.Duration
+	Metrics   Metrics
+	Logger    Logger
+}
+
+// Deadline measures how long messages take from being received to being
+// handled, and warns when the budget is exceeded. Platforms expect webhooks
+// to be acknowledged quickly and LINE reply tokens expire in about a minute.
+func Deadline(opts DeadlineOptions) Middleware {
+	metrics := opts.Metrics
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			start := time.Now()
+			if msg.ReceivedAt.IsZero() {
+				msg.ReceivedAt = start
+			}
+			err = next.Handle(a, msg)
+
+			elapsed := time.Since(msg.ReceivedAt)
+			tags := map[string]string{"platform": platformOf(a)}
+			metrics.Observe("ambassador.handler.duration", time.Since(start).Seconds(), tags)
+			metrics.Observe("ambassador.handler.latency", elapsed.Seconds(), tags)
+			if opts.Threshold > 0 && elapsed > opts.Threshold {
+				metrics.Count("ambassador.handler.slow", 1, tags)
+				CorrelationLogger(logger, msg.CorrelationId).Printf(
+					"ambassador: handling a message from %s took %s, over the budget of %s",
+					msg.SenderId, elapsed, opts.Threshold)
+			}
+			return
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package ambassador
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type observeMetrics struct {
+	recordMetrics
+	observed map[string]float64
+	platform string
+}
+
+func (m *observeMetrics) Observe(name string, value float64, tags map[string]string) {
+	m.observed[name] = value
+	m.platform = tags["platform"]
+}
+
+func TestDeadline(t *testing.T) {
+	metrics := &observeMetrics{observed: map[string]float64{}}
+	logger := &lineLogger{}
+	failure := errors.New("handler failed")
+	h := Deadline(DeadlineOptions{Threshold: time.Second, Metrics: metrics, Logger: logger})(
+		HandlerFunc(func(a Ambassador, msg Message) error {
+			return failure
+		}))
+
+	fresh := Message{SenderId: "u1", ReceivedAt: time.Now()}
+	if err := h.Handle(NewMockAmbassador(), fresh); err != failure {
+		t.Errorf("expect the error of the handler, got %v", err)
+	}
+	if _, ok := metrics.observed["ambassador.handler.duration"]; !ok || metrics.platform != "mock" {
+		t.Errorf("expect the duration to be observed, got %v %s", metrics.observed, metrics.platform)
+	}
+	if len(metrics.counts) != 0 || len(logger.lines) != 0 {
+		t.Errorf("expect a fresh message within the budget, got %v %v", metrics.counts, logger.lines)
+	}
+
+	late := Message{SenderId: "u2", CorrelationId: "c1", ReceivedAt: time.Now().Add(-time.Minute)}
+	h.Handle(NewMockAmbassador(), late)
+	if latency := metrics.observed["ambassador.handler.latency"]; latency < 60 {
+		t.Errorf("expect the latency from receiving the message, got %f", latency)
+	}
+	if len(metrics.counts) != 1 || !strings.HasPrefix(metrics.counts[0], "ambassador.handler.slow") {
+		t.Errorf("expect a slow handling to be counted, got %v", metrics.counts)
+	}
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "from u2") || !strings.Contains(logger.lines[0], "c1") {
+		t.Errorf("expect a slow handling to be logged, got %v", logger.lines)
+	}
+}
+
+func TestDeadlineWithoutReceivedAt(t *testing.T) {
+	var received time.Time
+	h := Deadline(DeadlineOptions{Threshold: time.Second, Logger: &lineLogger{}})(
+		HandlerFunc(func(a Ambassador, msg Message) error {
+			received = msg.ReceivedAt
+			return nil
+		}))
+	if err := h.Handle(NewMockAmbassador(), Message{SenderId: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if received.IsZero() {
+		t.Error("expect a message without a receiving time to be measured from now")
+	}
+}
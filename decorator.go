@@ -0,0 +1,87 @@
+package ambassador
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RequestDecorator changes an outbound request of an adapter before it is
+// sent, e.g. to add the headers or the auth scheme an API gateway needs. An
+// error fails the request.
+type RequestDecorator func(req *http.Request) (err error)
+
+type decoratingTransport struct {
+	next       http.RoundTripper
+	decorators []RequestDecorator
+}
+
+func (t *decoratingTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	req = req.Clone(req.Context())
+	for _, d := range t.decorators {
+		if err = d(req); err != nil {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// DecorateClient returns a copy of a client which runs every request through
+// decorators in order. Since every adapter takes a client, it is how the
+// requests of any platform are decorated:
+//
+//	client := DecorateClient(nil, WithHeaders(http.Header{"X-Gateway-Key": {key}}))
+//	fb := NewFBAmbassador(token, client)
+func DecorateClient(client *http.Client, decorators ...RequestDecorator) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	next := c.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.Transport = &decoratingTransport{next: next, decorators: decorators}
+	return &c
+}
+
+// WithHeaders sets headers on every request.
+func WithHeaders(h http.Header) RequestDecorator {
+	return func(req *http.Request) (err error) {
+		for key, values := range h {
+			req.Header[http.CanonicalHeaderKey(key)] = values
+		}
+		return
+	}
+}
+
+// ViaGateway sends every request to a gateway, keeping its path and query
+// under the path of the gateway, with the platform host in the
+// X-Forwarded-Host header for the gateway to route by.
+func ViaGateway(gateway *url.URL) RequestDecorator {
+	return func(req *http.Request) (err error) {
+		req.Header.Set("X-Forwarded-Host", req.URL.Host)
+		u := *req.URL
+		u.Scheme = gateway.Scheme
+		u.Host = gateway.Host
+		u.Path = singleJoiningSlash(gateway.Path, req.URL.Path)
+		u.RawPath = ""
+		req.URL = &u
+		req.Host = gateway.Host
+		return
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch {
+	case a == "" || a == "/":
+		return b
+	case a[len(a)-1] == '/' && len(b) > 0 && b[0] == '/':
+		return a + b[1:]
+	case a[len(a)-1] != '/' && (len(b) == 0 || b[0] != '/'):
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,39 @@
+package ambassador
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDecorateClient(t *testing.T) {
+	var got *http.Request
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{}`))
+	}))
+	defer gateway.Close()
+	u, _ := url.Parse(gateway.URL + "/bridge/")
+
+	client := DecorateClient(nil, ViaGateway(u), WithHeaders(http.Header{"x-gateway-key": {"k1"}}))
+	l := NewLineAmbassador("token", client)
+	l.SendText("hi")
+	if err := l.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.URL.Path != "/bridge/v2/bot/message/reply" {
+		t.Fatalf("expect the request to go through the gateway, got %+v", got)
+	}
+	if got.Header.Get("X-Forwarded-Host") != "api.line.me" || got.Header.Get("X-Gateway-Key") != "k1" ||
+		got.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("unexpected headers: %v", got.Header)
+	}
+
+	denied := errors.New("denied")
+	client = DecorateClient(nil, func(req *http.Request) error { return denied })
+	if _, err := client.Get(gateway.URL); err == nil {
+		t.Error("expect a decorator error to fail the request")
+	}
+}
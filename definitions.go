@@ -0,0 +1,262 @@
+package ambassador
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Definitions are the conversation designs of a bot which are kept out of
+// code: keyword rules, question sets and dialog flows.
+type Definitions struct {
+	Rules   []Rule   `json:"rules,omitempty"`
+	Surveys []Survey `json:"surveys,omitempty"`
+	Flows   []Flow   `json:"flows,omitempty"`
+}
+
+func validateOutbound(messages []OutboundMessage) error {
+	for i, m := range messages {
+		switch m.Type {
+		case OutboundText:
+			if m.Text == "" {
+				return fmt.Errorf("text message %d has no text", i)
+			}
+		case OutboundQuestion:
+			if len(m.Answers) == 0 {
+				return fmt.Errorf("question %d has no answer", i)
+			}
+			for _, answer := range m.Answers {
+				if answer["title"] == "" || answer["payload"] == "" {
+					return fmt.Errorf("question %d has an answer without a title or a payload", i)
+				}
+			}
+		case OutboundTemplate:
+			if len(m.Elements) == 0 {
+				return fmt.Errorf("template %d has no element", i)
+			}
+		case OutboundKeyboard:
+			if m.Keyboard == nil {
+				return fmt.Errorf("keyboard message %d has no keyboard", i)
+			}
+		default:
+			return fmt.Errorf("message %d has an unknown type %q", i, m.Type)
+		}
+	}
+	return nil
+}
+
+func validateSurvey(s *Survey) error {
+	if s.Id == "" {
+		return fmt.Errorf("a survey has no id")
+	}
+	if len(s.Questions) == 0 {
+		return fmt.Errorf("survey %s has no question", s.Id)
+	}
+	ids := map[string]bool{}
+	for _, q := range s.Questions {
+		if q.Id == "" || ids[q.Id] {
+			return fmt.Errorf("survey %s has a question without a unique id: %q", s.Id, q.Id)
+		}
+		ids[q.Id] = true
+		switch q.Type {
+		case SurveyChoice:
+			if len(q.Choices) == 0 {
+				return fmt.Errorf("choice question %s of survey %s has no choice", q.Id, s.Id)
+			}
+		case SurveyText, SurveyRating:
+		default:
+			return fmt.Errorf("question %s of survey %s has an unknown type %q", q.Id, s.Id, q.Type)
+		}
+	}
+	return nil
+}
+
+// Validate checks that every definition can run: patterns compile, flows
+// only lead to their own steps, and ids are unique.
+func (d *Definitions) Validate() error {
+	for _, rule := range d.Rules {
+		if _, err := compileRule(rule); err != nil {
+			return err
+		}
+		if err := validateOutbound(rule.Reply); err != nil {
+			return fmt.Errorf("rule %s: %s", rule.Name, err)
+		}
+	}
+	surveys := map[string]bool{}
+	for i := range d.Surveys {
+		if err := validateSurvey(&d.Surveys[i]); err != nil {
+			return err
+		}
+		if surveys[d.Surveys[i].Id] {
+			return fmt.Errorf("survey %s is defined twice", d.Surveys[i].Id)
+		}
+		surveys[d.Surveys[i].Id] = true
+	}
+	flows := map[string]bool{}
+	for i := range d.Flows {
+		if err := d.Flows[i].validate(); err != nil {
+			return err
+		}
+		if flows[d.Flows[i].Id] {
+			return fmt.Errorf("flow %s is defined twice", d.Flows[i].Id)
+		}
+		flows[d.Flows[i].Id] = true
+	}
+	return nil
+}
+
+// Lint checks the messages of the definitions against the constraints of
+// platforms.
+func (d *Definitions) Lint(platforms ...string) (issues []LintIssue) {
+	prefix := func(path string, found []LintIssue) {
+		for _, issue := range found {
+			issue.Path = path + "." + issue.Path
+			issues = append(issues, issue)
+		}
+	}
+	for _, rule := range d.Rules {
+		prefix("rules["+rule.Name+"]", Lint(rule.Reply, platforms...))
+	}
+	for _, flow := range d.Flows {
+		for _, step := range flow.Steps {
+			prefix("flows["+flow.Id+"].steps["+step.Id+"]", Lint(step.Messages, platforms...))
+		}
+	}
+	return
+}
+
+// Survey returns a survey by id.
+func (d *Definitions) Survey(id string) (s *Survey, ok bool) {
+	for i := range d.Surveys {
+		if d.Surveys[i].Id == id {
+			return &d.Surveys[i], true
+		}
+	}
+	return
+}
+
+// DefinitionLoader loads definitions from a JSON or YAML file and reloads
+// them when the file changes. Invalid definitions never replace the loaded
+// ones, so a bad edit does not break a running bot.
+type DefinitionLoader struct {
+	sync.Mutex
+	path string
+	// YAML unmarshals files ending with .yaml or .yml into generic values,
+	// which are then decoded like JSON, e.g. yaml.Unmarshal of
+	// gopkg.in/yaml.v3. YAML files can not be loaded without it.
+	YAML func(b []byte, v interface{}) error
+	// Interval is how often Run checks the file.
+	Interval time.Duration
+	Logger   Logger
+	// OnLoad is called with the definitions after every successful load,
+	// e.g. to reload a RulesEngine.
+	OnLoad  func(d *Definitions)
+	defs    *Definitions
+	modTime time.Time
+}
+
+func NewDefinitionLoader(path string) *DefinitionLoader {
+	return &DefinitionLoader{path: path, Interval: 5 * time.Second, Logger: stdLogger{}, defs: &Definitions{}}
+}
+
+// ParseDefinitions decodes and validates definitions. A nil yaml decodes
+// JSON.
+func ParseDefinitions(b []byte, yaml func(b []byte, v interface{}) error) (d *Definitions, err error) {
+	if yaml != nil {
+		var v interface{}
+		if err = yaml(b, &v); err != nil {
+			return
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("fail to convert yaml definitions: %s", err)
+		}
+	}
+	d = &Definitions{}
+	if err = json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	if err = d.Validate(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Load loads the file if it changed since the last load.
+func (l *DefinitionLoader) Load() (err error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return
+	}
+	l.Lock()
+	unchanged := info.ModTime().Equal(l.modTime)
+	l.Unlock()
+	if unchanged {
+		return
+	}
+
+	b, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return
+	}
+	var yaml func(b []byte, v interface{}) error
+	if ext := strings.ToLower(filepath.Ext(l.path)); ext == ".yaml" || ext == ".yml" {
+		if l.YAML == nil {
+			return fmt.Errorf("no yaml decoder to load %s", l.path)
+		}
+		yaml = l.YAML
+	}
+	d, err := ParseDefinitions(b, yaml)
+	if err != nil {
+		return fmt.Errorf("fail to load definitions from %s: %s", l.path, err)
+	}
+
+	l.Lock()
+	l.defs = d
+	l.modTime = info.ModTime()
+	l.Unlock()
+	if l.OnLoad != nil {
+		l.OnLoad(d)
+	}
+	return
+}
+
+// Definitions returns the loaded definitions, which must not be modified.
+func (l *DefinitionLoader) Definitions() *Definitions {
+	l.Lock()
+	defer l.Unlock()
+	return l.defs
+}
+
+// Rules is a RuleStore of the loaded rules.
+func (l *DefinitionLoader) Rules() RuleStore {
+	return RuleStoreFunc(func() ([]Rule, error) {
+		return l.Definitions().Rules, nil
+	})
+}
+
+// Flows returns the loaded flows, to be run by a FlowRunner.
+func (l *DefinitionLoader) Flows() []Flow {
+	return l.Definitions().Flows
+}
+
+// Run reloads the file when it changes until the context is done.
+func (l *DefinitionLoader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(l.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := l.Load(); err != nil {
+				l.Logger.Printf("ambassador: %s", err)
+			}
+		}
+	}
+}
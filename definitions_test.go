@@ -0,0 +1,130 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testDefinitions = `{
+	"rules": [{"name": "hours", "keywords": ["hours"], "reply": [{"type": "text", "text": "9 to 5"}]}],
+	"surveys": [{"id": "nps", "questions": [{"id": "score", "type": "rating", "text": "How likely?", "scale": 10}]}],
+	"flows": [{
+		"id": "order",
+		"payload": "ORDER",
+		"keywords": ["order"],
+		"steps": [
+			{"id": "size", "messages": [{"type": "question", "text": "Size?", "answers": [{"title": "M", "payload": "SIZE_M"}]}], "next": {"SIZE_M": "note"}},
+			{"id": "note", "messages": [{"type": "text", "text": "Any note?"}], "next": {"*": "done"}},
+			{"id": "done", "messages": [{"type": "text", "text": "Ordered"}]}
+		]
+	}]
+}`
+
+func TestDefinitionLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "definitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bot.json")
+	if err := ioutil.WriteFile(path, []byte(testDefinitions), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewDefinitionLoader(path)
+	loads := 0
+	loader.OnLoad = func(d *Definitions) { loads++ }
+	if err := loader.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := loader.Load(); err != nil || loads != 1 {
+		t.Errorf("expect an unchanged file not to reload, got %d loads, %v", loads, err)
+	}
+	if s, ok := loader.Definitions().Survey("nps"); !ok || s.Questions[0].Scale != 10 {
+		t.Errorf("unexpected survey: %+v", s)
+	}
+	rules, _ := loader.Rules().LoadRules()
+	if len(rules) != 1 || rules[0].Name != "hours" {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+
+	broken := `{"flows": [{"id": "x", "payload": "X", "steps": [{"id": "a", "messages": [{"type": "text", "text": "a"}], "next": {"*": "missing"}}]}]}`
+	if err := ioutil.WriteFile(path, []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+	if err := loader.Load(); err == nil {
+		t.Error("expect a flow leading to an unknown step to fail loading")
+	}
+	if len(loader.Flows()) != 1 || loader.Flows()[0].Id != "order" {
+		t.Errorf("expect the previous definitions to be kept, got %+v", loader.Flows())
+	}
+}
+
+func TestParseDefinitionsYAML(t *testing.T) {
+	// A stand-in of a YAML decoder producing generic values.
+	yaml := func(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+	d, err := ParseDefinitions([]byte(testDefinitions), yaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Flows[0].Steps) != 3 {
+		t.Errorf("unexpected flows: %+v", d.Flows)
+	}
+
+	invalid := []string{
+		`{"rules": [{"name": "x", "pattern": "(", "reply": [{"type": "text", "text": "x"}]}]}`,
+		`{"rules": [{"name": "x", "keywords": ["x"], "reply": [{"type": "video"}]}]}`,
+		`{"surveys": [{"id": "s", "questions": [{"id": "q", "type": "choice"}]}]}`,
+		`{"surveys": [{"id": "s", "questions": [{"id": "q", "type": "text"}, {"id": "q", "type": "text"}]}]}`,
+	}
+	for _, s := range invalid {
+		if _, err := ParseDefinitions([]byte(s), nil); err == nil {
+			t.Errorf("expect invalid definitions: %s", s)
+		}
+	}
+}
+
+func TestFlowRunner(t *testing.T) {
+	d, err := ParseDefinitions([]byte(testDefinitions), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := NewFlowRunner(func() []Flow { return d.Flows })
+	fallback := 0
+	runner.Fallback = HandlerFunc(func(a Ambassador, msg Message) error {
+		fallback++
+		return nil
+	})
+
+	a := &recordAmbassador{}
+	contents := []interface{}{
+		&TextContent{Text: "hello"},
+		&TextContent{Text: "I want to order"},
+		&CommandContent{Payload: "SIZE_M"},
+		&TextContent{Text: "no onions"},
+		&TextContent{Text: "thanks"},
+	}
+	for _, c := range contents {
+		if err := runner.Handle(a, Message{SenderId: "user", Content: c}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"user:Size?", "user:Any note?", "user:Ordered"}
+	if len(a.sent) != len(expected) {
+		t.Fatalf("unexpected replies: %v", a.sent)
+	}
+	for i := range expected {
+		if a.sent[i] != expected[i] {
+			t.Errorf("expect %s, got %s", expected[i], a.sent[i])
+		}
+	}
+	if fallback != 2 {
+		t.Errorf("expect 2 messages to fall back, got %d", fallback)
+	}
+}
@@ -0,0 +1,484 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DiscordAPIBaseURI = "https://discord.com/api/v10"
+
+// DiscordSignatureHeader and DiscordTimestampHeader carry the signature of
+// an interaction request, which signs the timestamp followed by the body.
+const (
+	DiscordSignatureHeader = "X-Signature-Ed25519"
+	DiscordTimestampHeader = "X-Signature-Timestamp"
+)
+
+const (
+	discordMaxButtons      = 25
+	discordButtonsPerRow   = 5
+	discordInteractionPing = 1
+	discordCommand         = 2
+	discordComponent       = 3
+)
+
+type DiscordUser struct {
+	Id  string `json:"id"`
+	Bot bool   `json:"bot"`
+}
+
+type DiscordMember struct {
+	User *DiscordUser `json:"user"`
+}
+
+type DiscordInteraction struct {
+	Id        string                 `json:"id"`
+	Type      int                    `json:"type"`
+	Token     string                 `json:"token"`
+	ChannelId string                 `json:"channel_id"`
+	Member    *DiscordMember         `json:"member"`
+	User      *DiscordUser           `json:"user"`
+	Message   *DiscordMessage        `json:"message"`
+	Data      DiscordInteractionData `json:"data"`
+}
+
+type DiscordInteractionData struct {
+	Name     string                 `json:"name"`
+	CustomId string                 `json:"custom_id"`
+	Options  []DiscordCommandOption `json:"options"`
+}
+
+type DiscordCommandOption struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type DiscordMessage struct {
+	Id        string      `json:"id"`
+	ChannelId string      `json:"channel_id"`
+	Author    DiscordUser `json:"author"`
+	Content   string      `json:"content"`
+	Timestamp string      `json:"timestamp"`
+	Reference *struct {
+		MessageId string `json:"message_id"`
+	} `json:"message_reference"`
+}
+
+// DiscordGatewayEvent is a dispatch of the gateway relayed to the webhook,
+// e.g. by a gateway client of the bot.
+type DiscordGatewayEvent struct {
+	Op   *int            `json:"op"`
+	Type string          `json:"t"`
+	Data json.RawMessage `json:"d"`
+}
+
+// VerifyDiscordSignature verifies an interaction request by the public key
+// of the application. Discord rejects endpoints which do not verify.
+func VerifyDiscordSignature(publicKey, signature, timestamp string, body []byte) bool {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(key), append([]byte(timestamp), body...), sig)
+}
+
+// VerifyDiscord rejects interaction requests without a valid signature by
+// the public key of the application before passing them to next, e.g. a
+// Webhook, so that forged interactions never reach Translate. The pings by
+// which Discord checks the endpoint are answered after the check, and never
+// reach next.
+func VerifyDiscord(publicKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+		if err != nil {
+			http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !VerifyDiscordSignature(publicKey, r.Header.Get(DiscordSignatureHeader), r.Header.Get(DiscordTimestampHeader), body) {
+			http.Error(w, ErrInvalidSignature.Error(), http.StatusUnauthorized)
+			return
+		}
+		var i DiscordInteraction
+		if jsonCodec.Unmarshal(body, &i) == nil && i.Type == discordInteractionPing {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"type":1}`))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// discordMessage is a staged message. typing and pause are not sent as
+// messages.
+type discordMessage struct {
+	Content    string        `json:"content,omitempty"`
+	Embeds     []interface{} `json:"embeds,omitempty"`
+	Components []interface{} `json:"components,omitempty"`
+	typing     bool
+	pause      time.Duration
+}
+
+// DiscordAmbassador talks to Discord by interaction webhooks and relayed
+// gateway events. Replies to interactions are answered by the interaction
+// callback, other messages are posted to channels by the bot token.
+type DiscordAmbassador struct {
+	sync.Mutex
+	// Pages keeps paginated questions. Without it, they are kept in the
+	// DefaultPageStore.
+	Pages        PageStore
+	token        string
+	client       *http.Client
+	messages     []discordMessage
+	lastMessages []interface{}
+	replyTo      string
+	correlation  string
+}
+
+func NewDiscordAmbassador(botToken string, client *http.Client) *DiscordAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &DiscordAmbassador{token: botToken, client: client}
+}
+
+func (d *DiscordAmbassador) SetCorrelationId(id string) {
+	d.correlation = id
+}
+
+func (d *DiscordAmbassador) Platform() string {
+	return "discord"
+}
+
+func (d *DiscordAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := ioutil.ReadAll(limitReader(r))
+	if err != nil {
+		return
+	}
+	messages = []Message{}
+
+	var event DiscordGatewayEvent
+	if err = jsonCodec.Unmarshal(body, &event); err != nil {
+		return
+	}
+	if event.Op != nil {
+		if event.Type != "MESSAGE_CREATE" {
+			return
+		}
+		var m DiscordMessage
+		if err = jsonCodec.Unmarshal(event.Data, &m); err != nil {
+			return
+		}
+		if m.Author.Bot {
+			return
+		}
+		msg := Message{
+			SenderId:  m.Author.Id,
+			ChatId:    m.ChannelId,
+			MessageId: m.Id,
+			Content:   &TextContent{Text: m.Content},
+		}
+		if t, err := time.Parse(time.RFC3339, m.Timestamp); err == nil {
+			msg.Timestamp = t.UnixNano() / int64(time.Millisecond)
+		}
+		if m.Reference != nil {
+			msg.InReplyTo = m.Reference.MessageId
+		}
+		return append(messages, msg), nil
+	}
+
+	var i DiscordInteraction
+	if err = jsonCodec.Unmarshal(body, &i); err != nil {
+		return
+	}
+	msg := Message{
+		ChatId:     i.ChannelId,
+		ReplyToken: i.Id + "/" + i.Token,
+		Timestamp:  time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	if i.Member != nil && i.Member.User != nil {
+		msg.SenderId = i.Member.User.Id
+	} else if i.User != nil {
+		msg.SenderId = i.User.Id
+	}
+	switch i.Type {
+	case discordCommand:
+		args := map[string]string{}
+		for _, o := range i.Data.Options {
+			args[o.Name] = fmt.Sprint(o.Value)
+		}
+		msg.Content = &CommandContent{Payload: i.Data.Name, Args: args}
+	case discordComponent:
+		if i.Message != nil {
+			msg.MessageId = i.Message.Id
+		}
+		if text, offset, page, ok := nextPage(d.Pages, i.Data.CustomId, discordMaxButtons); ok {
+			msg.Content = &MoreAnswersContent{Text: text, Offset: offset, Answers: page}
+		} else {
+			msg.Content = &CommandContent{Payload: i.Data.CustomId}
+		}
+	default:
+		return
+	}
+	return append(messages, msg), nil
+}
+
+func (d *DiscordAmbassador) call(method, uri string, payload interface{}) (err error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := jsonCodec.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(b)
+	}
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bot "+d.token)
+	setCorrelationHeader(req, d.correlation)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to call discord. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (d *DiscordAmbassador) stage(m discordMessage) {
+	d.Lock()
+	defer d.Unlock()
+	d.messages = append(d.messages, m)
+}
+
+func (d *DiscordAmbassador) SendText(text string) (err error) {
+	d.stage(discordMessage{Content: text})
+	return
+}
+
+// AskQuestion renders answers as buttons in rows of five. Answers beyond the
+// 25 buttons Discord allows are paginated behind a "More…" button.
+func (d *DiscordAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	page, err := paginate(d.Pages, text, answers, discordMaxButtons)
+	if err != nil {
+		return
+	}
+	d.askQuestion(text, page)
+	return
+}
+
+func (d *DiscordAmbassador) askQuestion(text string, answers []map[string]string) {
+	rows := []interface{}{}
+	buttons := []interface{}{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if !ok1 || !ok2 {
+			continue
+		}
+		buttons = append(buttons, map[string]interface{}{
+			"type":      2,
+			"style":     1,
+			"label":     title,
+			"custom_id": payload,
+		})
+		if len(buttons) == discordButtonsPerRow {
+			rows = append(rows, map[string]interface{}{"type": 1, "components": buttons})
+			buttons = []interface{}{}
+		}
+	}
+	if len(buttons) > 0 {
+		rows = append(rows, map[string]interface{}{"type": 1, "components": buttons})
+	}
+	d.stage(discordMessage{Content: text, Components: rows})
+}
+
+// SendTemplate renders every element as an embed with link buttons.
+func (d *DiscordAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	m := discordMessage{}
+	buttons := []interface{}{}
+	for _, col := range colItems {
+		embed := map[string]interface{}{
+			"title":       col.Title,
+			"description": col.Text,
+		}
+		if col.ItemUrl != "" {
+			embed["url"] = col.ItemUrl
+		}
+		if col.ImageUrl != "" {
+			embed["image"] = map[string]string{"url": col.ImageUrl}
+		}
+		m.Embeds = append(m.Embeds, embed)
+		for _, btn := range col.Buttons {
+			if btn.Type == "url" && len(buttons) < discordButtonsPerRow {
+				buttons = append(buttons, map[string]interface{}{"type": 2, "style": 5, "label": btn.Label, "url": btn.Data})
+			}
+		}
+	}
+	if len(buttons) > 0 {
+		m.Components = []interface{}{map[string]interface{}{"type": 1, "components": buttons}}
+	}
+	d.stage(m)
+	return
+}
+
+// SendTyping triggers the typing indicator of a channel, which lasts ten
+// seconds or until a message arrives, so turning it off is a no-op.
+func (d *DiscordAmbassador) SendTyping(on bool) (err error) {
+	if on {
+		d.stage(discordMessage{typing: true})
+	}
+	return
+}
+
+// WithTyping shows the typing indicator for a duration before sending the
+// messages staged after it.
+func (d *DiscordAmbassador) WithTyping(dur time.Duration) (err error) {
+	d.stage(discordMessage{typing: true, pause: dur})
+	return
+}
+
+// MarkRead is a no-op since bots have no read receipts in Discord.
+func (d *DiscordAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+// ReplyTo makes the staged messages replies to a message of the channel.
+func (d *DiscordAmbassador) ReplyTo(messageId string) (err error) {
+	d.Lock()
+	defer d.Unlock()
+	d.replyTo = messageId
+	return
+}
+
+func splitDiscordRef(ref string) (channel, id string, err error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("a discord message is referred by channel:id, got %s", ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+// DeleteMessage deletes a message referred by "channel:id".
+func (d *DiscordAmbassador) DeleteMessage(messageId string) (err error) {
+	channel, id, err := splitDiscordRef(messageId)
+	if err != nil {
+		return
+	}
+	return d.call("DELETE", DiscordAPIBaseURI+"/channels/"+channel+"/messages/"+id, nil)
+}
+
+// React reacts to a message referred by "channel:id" with a unicode emoji.
+func (d *DiscordAmbassador) React(messageId, emoji string) (err error) {
+	channel, id, err := splitDiscordRef(messageId)
+	if err != nil {
+		return
+	}
+	return d.call("PUT", DiscordAPIBaseURI+"/channels/"+channel+"/messages/"+id+
+		"/reactions/"+url.PathEscape(emoji)+"/@me", nil)
+}
+
+func (d *DiscordAmbassador) cleanMessage() {
+	d.Lock()
+	defer d.Unlock()
+	d.lastMessages = make([]interface{}, 0, len(d.messages))
+	for _, m := range d.messages {
+		d.lastMessages = append(d.lastMessages, m)
+	}
+	d.messages = nil
+	d.replyTo = ""
+}
+
+func (d *DiscordAmbassador) GetLastSent() []interface{} {
+	return d.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (d *DiscordAmbassador) Discard() {
+	d.Lock()
+	last := d.lastMessages
+	d.Unlock()
+	d.cleanMessage()
+	d.Lock()
+	d.lastMessages = last
+	d.Unlock()
+}
+
+// Send answers an interaction by its reply token, merging the staged
+// messages into one since an interaction has one response, or posts them to
+// a channel one by one.
+func (d *DiscordAmbassador) Send(recipientId string) (err error) {
+	defer d.cleanMessage()
+
+	if parts := strings.SplitN(recipientId, "/", 2); len(parts) == 2 {
+		merged := discordMessage{}
+		contents := []string{}
+		for _, m := range d.messages {
+			if m.Content != "" {
+				contents = append(contents, m.Content)
+			}
+			merged.Embeds = append(merged.Embeds, m.Embeds...)
+			merged.Components = append(merged.Components, m.Components...)
+		}
+		merged.Content = strings.Join(contents, "\n\n")
+		// An interaction is answered once, so a typing indicator on its own
+		// does not spend it.
+		if merged.Content == "" && len(merged.Embeds) == 0 && len(merged.Components) == 0 {
+			return
+		}
+		return d.call("POST", DiscordAPIBaseURI+"/interactions/"+parts[0]+"/"+parts[1]+"/callback",
+			map[string]interface{}{"type": 4, "data": merged})
+	}
+
+	channelURI := DiscordAPIBaseURI + "/channels/" + recipientId
+	for _, m := range d.messages {
+		if m.typing {
+			if err = d.call("POST", channelURI+"/typing", nil); err != nil {
+				return
+			}
+			time.Sleep(m.pause)
+			continue
+		}
+		payload := map[string]interface{}{
+			"content":    m.Content,
+			"embeds":     m.Embeds,
+			"components": m.Components,
+		}
+		if d.replyTo != "" {
+			payload["message_reference"] = map[string]string{"message_id": d.replyTo}
+		}
+		if err = d.call("POST", channelURI+"/messages", payload); err != nil {
+			return
+		}
+	}
+	return
+}
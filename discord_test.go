@@ -0,0 +1,88 @@
+package ambassador
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestDiscordInteraction(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	d := NewDiscordAmbassador("token", server.Client())
+
+	messages, err := d.Translate(strings.NewReader(`{"id":"i1","type":2,"token":"tk","channel_id":"c1",
+		"member":{"user":{"id":"u1"}},"data":{"name":"order","options":[{"name":"id","value":42}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := messages[0]
+	command, ok := msg.Content.(*CommandContent)
+	if !ok || command.Payload != "order" || command.Args["id"] != "42" || msg.SenderId != "u1" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	d.SendText("looking up")
+	d.AskQuestion("track it?", []map[string]string{{"title": "Yes", "payload": "TRACK"}})
+	if err := d.Send(msg.ReplyTarget()); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/api/v10/interactions/i1/tk/callback" {
+		t.Fatalf("expect one interaction callback, got %+v", requests)
+	}
+	var callback struct {
+		Data discordMessage `json:"data"`
+	}
+	json.Unmarshal(requests[0].Body, &callback)
+	if callback.Data.Content != "looking up\n\ntrack it?" || len(callback.Data.Components) != 1 {
+		t.Errorf("unexpected callback: %s", requests[0].Body)
+	}
+}
+
+func TestVerifyDiscord(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := hex.EncodeToString(public)
+	passed := 0
+	handler := VerifyDiscord(publicKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed++
+	}))
+
+	sign := func(body string) string {
+		return hex.EncodeToString(ed25519.Sign(private, []byte("1700000000"+body)))
+	}
+	ping := `{"id":"1","type":1}`
+	command := `{"id":"i1","type":2,"token":"tk","data":{"name":"order"}}`
+	cases := []struct {
+		body, signature string
+		status          int
+		response        string
+	}{
+		{ping, sign(ping), http.StatusOK, `{"type":1}`},
+		{ping, "", http.StatusUnauthorized, ""},
+		{command, sign(ping), http.StatusUnauthorized, ""},
+		{command, sign(command), http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(c.body))
+		req.Header.Set(DiscordSignatureHeader, c.signature)
+		req.Header.Set(DiscordTimestampHeader, "1700000000")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != c.status || (c.response != "" && rec.Body.String() != c.response) {
+			t.Errorf("expect %d %s for %s, got %d %s", c.status, c.response, c.body, rec.Code, rec.Body)
+		}
+	}
+	if passed != 1 {
+		t.Errorf("expect only the signed command to pass, got %d", passed)
+	}
+}
@@ -0,0 +1,391 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emailMenus keeps the payloads of the last numbered options sent to each
+// address, since emails have no buttons.
+var emailMenus = &numberedMenus{menus: map[string][]string{}}
+
+// emailPart is a staged part of an email with the payloads of its numbered
+// options.
+type emailPart struct {
+	Text     string `json:"text"`
+	HTML     string `json:"html"`
+	payloads []string
+}
+
+// EmailAmbassador turns inbound emails into messages and sends replies as
+// HTML emails over SMTP. Staged messages are sent as one email, and the
+// message id of an inbound email is threaded by ReplyTo.
+type EmailAmbassador struct {
+	sync.Mutex
+	addr string
+	auth smtp.Auth
+	from string
+	// Subject is the subject of emails which do not reply to an email.
+	Subject      string
+	parts        []emailPart
+	lastMessages []interface{}
+	replyTo      string
+	correlation  string
+	sendMail     func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailAmbassador returns an ambassador sending from an address through
+// an SMTP server, e.g. "smtp.example.com:587".
+func NewEmailAmbassador(addr string, auth smtp.Auth, from string) *EmailAmbassador {
+	return &EmailAmbassador{addr: addr, auth: auth, from: from, sendMail: smtp.SendMail}
+}
+
+func (e *EmailAmbassador) SetCorrelationId(id string) {
+	e.correlation = id
+}
+
+func (e *EmailAmbassador) Platform() string {
+	return "email"
+}
+
+// emailText returns the plain text of a MIME entity, preferring the
+// text/plain part of a multipart entity.
+func emailText(header textproto.MIMEHeader, body io.Reader) (text string, err error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, err = "text/plain", nil
+	}
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		htmlText := ""
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType != "text/plain" && partType != "text/html" && !strings.HasPrefix(partType, "multipart/") {
+				continue
+			}
+			// multipart.Reader decodes quoted-printable parts itself.
+			t, err := emailText(part.Header, part)
+			if err != nil {
+				return "", err
+			}
+			if partType == "text/html" {
+				htmlText = t
+				continue
+			}
+			return t, nil
+		}
+		return htmlText, nil
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return
+	}
+	if mediaType == "text/html" {
+		return stripTags(string(b)), nil
+	}
+	return string(b), nil
+}
+
+// stripTags turns the html of an email without a plain text part into
+// rough plain text.
+func stripTags(s string) string {
+	buffer := &bytes.Buffer{}
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			buffer.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(buffer.String())
+}
+
+// stripQuoted removes the quoted email below a reply.
+func stripQuoted(text string) string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "On ") && strings.HasSuffix(trimmed, "wrote:") {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Translate turns a MIME message into a message. The text is the plain text
+// body without the quoted email, and the chat is the sender address.
+func (e *EmailAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	m, err := mail.ReadMessage(limitReader(r))
+	if err != nil {
+		return
+	}
+	from, err := mail.ParseAddress(m.Header.Get("From"))
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse the sender of an email: %s", err)
+	}
+	text, err := emailText(textproto.MIMEHeader(m.Header), m.Body)
+	if err != nil {
+		return
+	}
+	msg := Message{
+		SenderId:  strings.ToLower(from.Address),
+		MessageId: m.Header.Get("Message-Id"),
+		InReplyTo: m.Header.Get("In-Reply-To"),
+		Content:   &TextContent{Text: stripQuoted(text)},
+	}
+	if to, err := mail.ParseAddress(m.Header.Get("To")); err == nil {
+		msg.RecipientId = to.Address
+	}
+	if date, err := m.Header.Date(); err == nil {
+		msg.Timestamp = date.UnixNano() / int64(time.Millisecond)
+	}
+	if payload, ok := emailMenus.lookup(msg.SenderId, strings.SplitN(stripQuoted(text), "\n", 2)[0]); ok {
+		msg.Content = &CommandContent{Payload: payload}
+	}
+	return []Message{msg}, nil
+}
+
+func (e *EmailAmbassador) stage(p emailPart) {
+	e.Lock()
+	defer e.Unlock()
+	e.parts = append(e.parts, p)
+}
+
+func (e *EmailAmbassador) SendText(text string) (err error) {
+	e.stage(emailPart{Text: text, HTML: "<p>" + strings.Replace(html.EscapeString(text), "\n", "<br>", -1) + "</p>"})
+	return
+}
+
+// AskQuestion numbers the answers as an ordered list. Replying with a number
+// in the first line comes back as a CommandContent of its payload.
+func (e *EmailAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	lines := []string{text}
+	items := []string{}
+	payloads := []string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			payloads = append(payloads, payload)
+			lines = append(lines, fmt.Sprintf("%d. %s", len(payloads), title))
+			items = append(items, "<li>"+html.EscapeString(title)+"</li>")
+		}
+	}
+	e.stage(emailPart{
+		Text:     strings.Join(lines, "\n") + "\n\nReply with the number of your answer.",
+		HTML:     fmt.Sprintf("<p>%s</p><ol>%s</ol><p>Reply with the number of your answer.</p>", html.EscapeString(text), strings.Join(items, "")),
+		payloads: payloads,
+	})
+	return
+}
+
+// SendTemplate renders every element as a card with its image, title, text
+// and links.
+func (e *EmailAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	lines := []string{}
+	cards := []string{}
+	for _, col := range colItems {
+		lines = append(lines, col.Title)
+		card := []string{}
+		if col.ImageUrl != "" {
+			card = append(card, fmt.Sprintf(`<img src="%s" alt="%s" style="max-width:100%%">`, html.EscapeString(col.ImageUrl), html.EscapeString(col.Title)))
+		}
+		title := html.EscapeString(col.Title)
+		if col.ItemUrl != "" {
+			title = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(col.ItemUrl), title)
+			lines = append(lines, col.ItemUrl)
+		}
+		card = append(card, "<h3>"+title+"</h3>")
+		if col.Text != "" {
+			lines = append(lines, col.Text)
+			card = append(card, "<p>"+html.EscapeString(col.Text)+"</p>")
+		}
+		for _, btn := range col.Buttons {
+			if btn.Type == "url" {
+				lines = append(lines, btn.Label+": "+btn.Data)
+				card = append(card, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(btn.Data), html.EscapeString(btn.Label)))
+			}
+		}
+		lines = append(lines, "")
+		cards = append(cards, `<div style="border:1px solid #ddd;padding:12px;margin:8px 0">`+strings.Join(card, "")+"</div>")
+	}
+	e.stage(emailPart{Text: strings.TrimSpace(strings.Join(lines, "\n")), HTML: strings.Join(cards, "")})
+	return
+}
+
+// SendTyping is a no-op since emails have no typing indicator.
+func (e *EmailAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping is a no-op since the staged messages are sent as one email.
+func (e *EmailAmbassador) WithTyping(d time.Duration) (err error) {
+	return
+}
+
+// MarkRead is a no-op since read receipts of emails are up to the clients.
+func (e *EmailAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+// ReplyTo threads the email into the one of a message id.
+func (e *EmailAmbassador) ReplyTo(messageId string) (err error) {
+	e.Lock()
+	defer e.Unlock()
+	e.replyTo = messageId
+	return
+}
+
+func (e *EmailAmbassador) cleanMessage() {
+	e.Lock()
+	defer e.Unlock()
+	e.lastMessages = make([]interface{}, 0, len(e.parts))
+	for _, p := range e.parts {
+		e.lastMessages = append(e.lastMessages, p)
+	}
+	e.parts = nil
+	e.replyTo = ""
+}
+
+func (e *EmailAmbassador) GetLastSent() []interface{} {
+	return e.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (e *EmailAmbassador) Discard() {
+	e.Lock()
+	last := e.lastMessages
+	e.Unlock()
+	e.cleanMessage()
+	e.Lock()
+	e.lastMessages = last
+	e.Unlock()
+}
+
+// compose writes an email to an address with plain text and html
+// alternatives of the staged parts.
+func (e *EmailAmbassador) compose(to string) []byte {
+	texts := []string{}
+	htmls := []string{}
+	for _, p := range e.parts {
+		texts = append(texts, p.Text)
+		htmls = append(htmls, p.HTML)
+	}
+
+	buffer := &bytes.Buffer{}
+	mw := multipart.NewWriter(buffer)
+	subject := e.Subject
+	if e.replyTo != "" && !strings.HasPrefix(subject, "Re: ") {
+		subject = "Re: " + subject
+	}
+	headers := []string{
+		"From: " + e.from,
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-Id: <" + newId() + "@" + emailDomain(e.from) + ">",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	if e.replyTo != "" {
+		headers = append(headers, "In-Reply-To: "+e.replyTo, "References: "+e.replyTo)
+	}
+	if e.correlation != "" {
+		headers = append(headers, CorrelationHeader+": "+e.correlation)
+	}
+	msg := &bytes.Buffer{}
+	msg.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, alt := range []struct{ contentType, body string }{
+		{"text/plain", strings.Join(texts, "\n\n")},
+		{"text/html", "<html><body>" + strings.Join(htmls, "") + "</body></html>"},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qw := quotedprintable.NewWriter(w)
+		qw.Write([]byte(alt.body))
+		qw.Close()
+	}
+	mw.Close()
+	msg.Write(buffer.Bytes())
+	return msg.Bytes()
+}
+
+// emailDomain is the domain of an address for message ids.
+func emailDomain(from string) string {
+	if a, err := mail.ParseAddress(from); err == nil {
+		from = a.Address
+	}
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		return from[i+1:]
+	}
+	return "localhost"
+}
+
+// Send sends the staged messages to an address as one email.
+func (e *EmailAmbassador) Send(recipientId string) (err error) {
+	defer e.cleanMessage()
+	if len(e.parts) == 0 {
+		return
+	}
+	from := e.from
+	if a, err := mail.ParseAddress(from); err == nil {
+		from = a.Address
+	}
+	if err = e.sendMail(e.addr, e.auth, from, []string{recipientId}, e.compose(recipientId)); err != nil {
+		return fmt.Errorf("fail to send an email: %s", err)
+	}
+	var payloads []string
+	for _, p := range e.parts {
+		if p.payloads != nil {
+			payloads = p.payloads
+		}
+	}
+	if payloads != nil {
+		emailMenus.set(strings.ToLower(recipientId), payloads)
+	}
+	return
+}
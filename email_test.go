@@ -0,0 +1,66 @@
+package ambassador
+
+import (
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestEmailConversation(t *testing.T) {
+	e := NewEmailAmbassador("smtp.example.com:587", nil, "Shop <bot@shop.example.com>")
+	e.Subject = "Your order"
+	var sentTo []string
+	var sent []byte
+	e.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		if from != "bot@shop.example.com" {
+			t.Errorf("unexpected sender: %s", from)
+		}
+		sentTo, sent = to, msg
+		return nil
+	}
+
+	e.ReplyTo("<m1@mail.example.com>")
+	e.SendText("Thanks & welcome")
+	e.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := e.Send("u1@mail.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	m, err := mail.ReadMessage(strings.NewReader(string(sent)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sentTo[0] != "u1@mail.example.com" || m.Header.Get("Subject") != "Re: Your order" || m.Header.Get("In-Reply-To") != "<m1@mail.example.com>" {
+		t.Errorf("unexpected headers: %v", m.Header)
+	}
+	text, err := emailText(map[string][]string{"Content-Type": {m.Header.Get("Content-Type")}}, m.Body)
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if err != nil || !strings.HasPrefix(text, "Thanks & welcome\n\nWhich size?\n1. Small\n2. Large") {
+		t.Errorf("unexpected plain text: %q %v", text, err)
+	}
+	if !strings.Contains(string(sent), "Thanks &amp; welcome") {
+		t.Errorf("expect an escaped html alternative: %s", sent)
+	}
+
+	messages, err := e.Translate(strings.NewReader("From: User <U1@mail.example.com>\r\n" +
+		"To: bot@shop.example.com\r\n" +
+		"Message-Id: <m2@mail.example.com>\r\n" +
+		"Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"2\r\n\r\nOn Mon, Shop wrote:\r\n> Which size?\r\n" +
+		"--b\r\nContent-Type: text/html\r\n\r\n<p>2</p>\r\n--b--\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].SenderId != "u1@mail.example.com" {
+		t.Errorf("expect the number as its payload, got %+v", messages[0])
+	}
+
+	messages, _ = e.Translate(strings.NewReader("From: u2@mail.example.com\r\nContent-Type: text/plain\r\n\r\nHello\r\n> quoted\r\n"))
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "Hello" {
+		t.Errorf("unexpected text: %+v", messages[0].Content)
+	}
+}
@@ -0,0 +1,108 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	FBProfileURI   = "https://graph.facebook.com/v2.6/"
+	LineProfileURI = "https://api.line.me/v2/bot/profile/"
+)
+
+// ProfileFetcher is implemented by ambassadors which can fetch the profile
+// of a user from the platform.
+type ProfileFetcher interface {
+	FetchProfile(userId string) (*UserProfile, error)
+}
+
+// FetchProfile fetches the name, locale and timezone of a user.
+func (a *FBAmbassador) FetchProfile(userId string) (p *UserProfile, err error) {
+	query := url.Values{}
+	query.Set("fields", "first_name,last_name,locale,timezone")
+	query.Set("access_token", a.token)
+	req, _ := http.NewRequest("GET", FBProfileURI+url.PathEscape(userId)+"?"+query.Encode(), nil)
+	setCorrelationHeader(req, a.correlation)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("fail to fetch the fb profile of %s. status: %s", userId, resp.Status)
+	}
+
+	var profile struct {
+		FirstName string  `json:"first_name"`
+		LastName  string  `json:"last_name"`
+		Locale    string  `json:"locale"`
+		Timezone  float64 `json:"timezone"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return
+	}
+	return &UserProfile{
+		Id:        userId,
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+		Locale:    profile.Locale,
+		UTCOffset: profile.Timezone,
+	}, nil
+}
+
+// FetchProfile fetches the display name and language of a user. LINE does
+// not tell the time zone of users.
+func (l *LineAmbassador) FetchProfile(userId string) (p *UserProfile, err error) {
+	var profile struct {
+		DisplayName string `json:"displayName"`
+		Language    string `json:"language"`
+	}
+	if err = l.do("GET", LineProfileURI+url.PathEscape(userId), nil, &profile); err != nil {
+		return
+	}
+	return &UserProfile{Id: userId, FirstName: profile.DisplayName, Locale: profile.Language}, nil
+}
+
+type EnrichOptions struct {
+	Profiles ProfileStore
+	// MaxAge refetches profiles older than it. Profiles are never refetched
+	// if it is zero.
+	MaxAge time.Duration
+	Logger Logger
+}
+
+// EnrichProfiles attaches the profile of the sender to messages. Unknown
+// profiles are fetched from the platform once and kept in the store, where
+// delivery windows and variants pick up their time zones and locales.
+// Failures to fetch a profile are logged and do not stop the message.
+func EnrichProfiles(opts EnrichOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			if msg.Profile == nil && msg.SenderId != "" {
+				p, err := opts.Profiles.GetProfile(msg.SenderId)
+				if err != nil {
+					CorrelationLogger(logger, msg.CorrelationId).Printf("ambassador: fail to get the profile of %s: %s", msg.SenderId, err)
+				}
+				stale := p == nil || (opts.MaxAge > 0 && time.Since(p.FetchedAt) > opts.MaxAge)
+				if f, ok := unwrap(a).(ProfileFetcher); ok && err == nil && stale {
+					if fetched, err := f.FetchProfile(msg.SenderId); err != nil {
+						CorrelationLogger(logger, msg.CorrelationId).Printf("ambassador: fail to fetch the profile of %s: %s", msg.SenderId, err)
+					} else {
+						fetched.FetchedAt = time.Now()
+						opts.Profiles.PutProfile(fetched)
+						p = fetched
+					}
+				}
+				msg.Profile = p
+			}
+			return next.Handle(a, msg)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package ambassador
+
+import (
+	"testing"
+)
+
+type profileAmbassador struct {
+	recordAmbassador
+	fetched int
+}
+
+func (a *profileAmbassador) FetchProfile(userId string) (*UserProfile, error) {
+	a.fetched++
+	return &UserProfile{Id: userId, Locale: "zh_TW", UTCOffset: 8}, nil
+}
+
+func TestEnrichProfiles(t *testing.T) {
+	a := &profileAmbassador{}
+	profiles := NewMemoryProfileStore()
+	var got *UserProfile
+	h := EnrichProfiles(EnrichOptions{Profiles: profiles})(HandlerFunc(func(a Ambassador, msg Message) error {
+		got = msg.Profile
+		return nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		if err := h.Handle(a, Message{SenderId: "u1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got == nil || got.Locale != "zh_TW" {
+		t.Fatalf("expect the profile to be attached, got %+v", got)
+	}
+	if a.fetched != 1 {
+		t.Errorf("expect the profile to be fetched once, got %d", a.fetched)
+	}
+	if p, _ := profiles.GetProfile("u1"); p.Location() == nil {
+		t.Error("expect the stored profile to have a time zone")
+	}
+}
@@ -0,0 +1,60 @@
+package ambassador
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	ExportJSON = "json"
+	ExportCSV  = "csv"
+)
+
+// Exporter dumps conversation histories, e.g. for data portability requests
+// or offline analysis.
+type Exporter struct {
+	Store TranscriptStore
+}
+
+func NewExporter(store TranscriptStore) *Exporter {
+	return &Exporter{Store: store}
+}
+
+// Export writes the entries matching a query as a JSON array or as CSV.
+// Media are exported as references, not as files.
+func (x *Exporter) Export(w io.Writer, q TranscriptQuery, format string) (err error) {
+	entries, err := x.Store.Query(q)
+	if err != nil {
+		return
+	}
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"at", "tenant", "platform", "user_id", "direction", "message_id", "type", "text", "media", "payload"})
+		for _, e := range entries {
+			cw.Write([]string{
+				e.At.UTC().Format(time.RFC3339Nano),
+				e.Tenant,
+				e.Platform,
+				e.UserId,
+				e.Direction,
+				e.MessageId,
+				e.Type,
+				e.Text,
+				strings.Join(e.Media, " "),
+				string(e.Payload),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown export format: %s", format)
+}
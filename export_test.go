@@ -0,0 +1,42 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestTranscriptExport(t *testing.T) {
+	store := NewMemoryTranscriptStore()
+	h := Transcript(TranscriptOptions{Store: store, Tenant: "acme"})(HandlerFunc(func(a Ambassador, msg Message) error {
+		a.SendText("hello")
+		return a.Send(msg.SenderId)
+	}))
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	a := NewFBAmbassador("test-token", server.Client())
+	if err := h.Handle(a, Message{SenderId: "u1", MessageId: "m1", Content: &TextContent{Text: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	h.Handle(a, Message{SenderId: "u2", Content: &TextContent{Text: "other"}})
+
+	var b bytes.Buffer
+	if err := NewExporter(store).Export(&b, TranscriptQuery{UserId: "u1"}, ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expect a header, an inbound and an outbound row, got %v", rows)
+	}
+	if rows[1][1] != "acme" || rows[1][4] != DirectionInbound || rows[1][6] != "TextContent" || rows[1][7] != "hi" {
+		t.Errorf("unexpected inbound row: %v", rows[1])
+	}
+	if rows[2][4] != DirectionOutbound {
+		t.Errorf("unexpected outbound row: %v", rows[2])
+	}
+}
@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 )
 
 const FBMessengerBaseURI = "https://graph.facebook.com/v2.6/me/messages?access_token="
 
+const fbMaxQuickReplies = 13
+
 type FBObject struct {
 	Object string
 	Entry  []FBEntry
@@ -20,6 +24,32 @@ type FBEntry struct {
 	Id      string
 	Time    int64
 	Messags []FBMessage `json:"messaging"`
+	invalid PartialError
+}
+
+// UnmarshalJSON skips the events which can not be decoded and keeps them to
+// be reported by Translate.
+func (e *FBEntry) UnmarshalJSON(b []byte) (err error) {
+	var raw struct {
+		Id        string
+		Time      int64
+		Messaging []json.RawMessage `json:"messaging"`
+	}
+	if err = jsonCodec.Unmarshal(b, &raw); err != nil {
+		return
+	}
+	e.Id, e.Time = raw.Id, raw.Time
+	e.Messags = make([]FBMessage, 0, len(raw.Messaging))
+	for _, event := range raw.Messaging {
+		var m FBMessage
+		if err := jsonCodec.Unmarshal(event, &m); err != nil {
+			e.invalid.add(event, err)
+			continue
+		}
+		m.raw = event
+		e.Messags = append(e.Messags, m)
+	}
+	return
 }
 
 type FBSender struct {
@@ -30,22 +60,42 @@ type FBRecipient struct {
 	Id string `json:"id"`
 }
 
+type FBTokenRecipient struct {
+	Token string `json:"notification_messages_token"`
+}
+
 type FBMessage struct {
-	Sender    FBSender           `json:"sender,omitempty"`
-	Recipient FBRecipient        `json:"recipient,omitempty"`
-	Timestamp int64              `json:"timestamp,omitempty"`
-	Content   *FBMessageContent  `json:"message,omitempty"`
-	Delivery  *FBMessageDelivery `json:"delivery,omitempty"`
-	Postback  *FBMessagePostback `json:"postback,omitempty"`
-	Read      *FBMessageRead     `json:"read,omitempty"`
+	Sender    FBSender             `json:"sender,omitempty"`
+	Recipient FBRecipient          `json:"recipient,omitempty"`
+	Timestamp int64                `json:"timestamp,omitempty"`
+	Content   *FBMessageContent    `json:"message,omitempty"`
+	Delivery  *FBMessageDelivery   `json:"delivery,omitempty"`
+	Postback  *FBMessagePostback   `json:"postback,omitempty"`
+	Read      *FBMessageRead       `json:"read,omitempty"`
+	Feedback  *FBMessagingFeedback `json:"messaging_feedback,omitempty"`
+	Policy    *FBPolicyEnforcement `json:"policy-enforcement,omitempty"`
+	Account   *FBAccountUpdate     `json:"account_update,omitempty"`
+	OptOut    *FBOptOut            `json:"optout,omitempty"`
+	Edit      *FBMessageEdit       `json:"message_edit,omitempty"`
+	Referral  *FBReferral          `json:"referral,omitempty"`
+	GamePlay  *FBGamePlay          `json:"game_play,omitempty"`
+	raw       json.RawMessage
 }
 
 type FBMessageContent struct {
+	Mid         string                `json:"mid,omitempty"`
 	Text        string                `json:"text"`
 	Seq         int64                 `json:"seq,omitempty"`
 	IsEcho      bool                  `json:"is_echo,omitempty"`
 	Attachments []FBMessageAttachment `json:"attachments,omitempty"`
 	QuickReplay *FBMessageQuickReply  `json:"quick_reply,omitempty"`
+	NLP         *NLP                  `json:"nlp,omitempty"`
+	ReplyTo     *FBReplyTo            `json:"reply_to,omitempty"`
+	StickerId   int64                 `json:"sticker_id,omitempty"`
+}
+
+type FBReplyTo struct {
+	Mid string `json:"mid"`
 }
 
 type FBMessageQuickReply struct {
@@ -53,12 +103,27 @@ type FBMessageQuickReply struct {
 }
 
 type FBMessageDelivery struct {
-	Watermark int64 `json:"watermark"`
-	Seq       int64 `json:"seq"`
+	Mids      []string `json:"mids,omitempty"`
+	Watermark int64    `json:"watermark"`
+	Seq       int64    `json:"seq"`
 }
 
 type FBMessagePostback struct {
-	Payload string `json:"payload"`
+	Payload  string      `json:"payload"`
+	Referral *FBReferral `json:"referral,omitempty"`
+}
+
+type FBReferral struct {
+	Ref    string `json:"ref"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+func (r *FBReferral) content() *ReferralContent {
+	if r == nil {
+		return nil
+	}
+	return &ReferralContent{Ref: r.Ref, Source: r.Source, Type: r.Type}
 }
 
 type FBMessageAttachment struct {
@@ -98,10 +163,22 @@ type FBButtonItem struct {
 
 type FBAmbassador struct {
 	sync.Mutex
+	// Translators translate custom events. A translator of
+	// "event:<field>" gets a messaging event with the field, e.g.
+	// "event:reaction", and one of "attachment:<type>" gets the payload of
+	// an attachment, e.g. "attachment:fallback".
+	Translators Translators
+	// Pages keeps paginated questions. Without it, they are kept in the
+	// DefaultPageStore.
+	Pages        PageStore
 	token        string
 	client       *http.Client
 	messages     []interface{}
 	lastMessages []interface{}
+	replyTo      string
+	tag          string
+	correlation  string
+	sentIds      []string
 }
 
 func NewFBAmbassador(token string, client *http.Client) *FBAmbassador {
@@ -114,14 +191,43 @@ func NewFBAmbassador(token string, client *http.Client) *FBAmbassador {
 	}
 }
 
-// Translate will turn a facebook messenger object into messages
+func (a *FBAmbassador) SetCorrelationId(id string) {
+	a.correlation = id
+}
+
+func (a *FBAmbassador) Platform() string {
+	return "facebook"
+}
+
+// Translate will turn a facebook messenger object into messages. Events
+// which can not be translated are skipped and returned in a PartialError
+// along with the other messages.
 func (a *FBAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := readBody(r)
+	if err != nil {
+		return
+	}
+	defer releaseBody(body)
+	if a.Translators.empty() {
+		if texts, ok := translateFBText(body.Bytes()); ok {
+			return texts, nil
+		}
+	}
+
 	var v FBObject
-	d := json.NewDecoder(r)
-	err = d.Decode(&v)
+	err = jsonCodec.Unmarshal(body.Bytes(), &v)
 	if err != nil {
 		return
 	}
+	var events int
+	partial := &PartialError{}
+	for _, entry := range v.Entry {
+		events += len(entry.Messags) + len(entry.invalid.Events)
+		partial.Events = append(partial.Events, entry.invalid.Events...)
+	}
+	if events > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
 
 	messages = make([]Message, 0, 10)
 
@@ -133,80 +239,246 @@ func (a *FBAmbassador) Translate(r io.Reader) (messages []Message, err error) {
 				Timestamp:   fbMsg.Timestamp,
 			}
 			if fbMsg.Content != nil {
-				if attachments := fbMsg.Content.Attachments; len(attachments) != 0 {
-					a := attachments[0]
-					if a.Type == "location" {
+				msg.MessageId = fbMsg.Content.Mid
+				if fbMsg.Content.ReplyTo != nil {
+					msg.InReplyTo = fbMsg.Content.ReplyTo.Mid
+				}
+			}
+			content, err := a.Translators.translateFields("event:", fbMsg.raw)
+			if err != nil {
+				partial.add(fbMsg.raw, err)
+				continue
+			}
+			if content != nil {
+				msg.Content = content
+			} else if fbMsg.Content != nil {
+				if stickerId := fbMsg.Content.StickerId; stickerId != 0 {
+					id := strconv.FormatInt(stickerId, 10)
+					sentiment, _ := StickerSentiment("", id, nil)
+					msg.Content = &StickerContent{StickerId: id, Sentiment: sentiment}
+				} else if attachments := fbMsg.Content.Attachments; len(attachments) != 0 {
+					attachment := attachments[0]
+					content, err := a.Translators.translate("attachment:"+attachment.Type, attachment.Payload)
+					if err != nil {
+						partial.add(attachment.Payload, err)
+						continue
+					}
+					if content != nil {
+						msg.Content = content
+					} else if attachment.Type == "location" {
 						payload := FBLocationAttachment{}
-						err = json.Unmarshal(a.Payload, &payload)
-						if err != nil {
-							return
+						if err := jsonCodec.Unmarshal(attachment.Payload, &payload); err != nil {
+							partial.add(attachment.Payload, err)
+							continue
 						}
 						msg.Content = &LocationContent{
 							Lat: payload.Coordinates.Latitude,
 							Lon: payload.Coordinates.Longitude,
 						}
+					} else if product := fbProductContent(attachment); product != nil {
+						product.Text = fbMsg.Content.Text
+						msg.Content = product
 					} else {
 						msg.Content = fbMsg.Content
 					}
 				} else if fbMsg.Content.QuickReplay != nil {
-					msg.Content = &CommandContent{Payload: fbMsg.Content.QuickReplay.Payload}
+					payload := fbMsg.Content.QuickReplay.Payload
+					if text, offset, page, ok := nextPage(a.Pages, payload, fbMaxQuickReplies); ok {
+						msg.Content = &MoreAnswersContent{Text: text, Offset: offset, Answers: page}
+					} else {
+						msg.Content = &CommandContent{Payload: payload}
+					}
 				} else if fbMsg.Content.IsEcho {
 					msg.Content = fbMsg.Content
 				} else {
-					msg.Content = &TextContent{Text: fbMsg.Content.Text}
+					msg.Content = &TextContent{Text: fbMsg.Content.Text, NLP: fbMsg.Content.NLP}
 				}
 			} else if fbMsg.Delivery != nil {
-				msg.Content = fbMsg.Delivery
+				msg.Content = &DeliveryContent{
+					MessageIds: fbMsg.Delivery.Mids,
+					Watermark:  fbMsg.Delivery.Watermark,
+				}
 			} else if fbMsg.Postback != nil {
-				msg.Content = &CommandContent{Payload: fbMsg.Postback.Payload}
+				msg.Content = &CommandContent{
+					Payload:  fbMsg.Postback.Payload,
+					Referral: fbMsg.Postback.Referral.content(),
+				}
 			} else if fbMsg.Read != nil {
-				msg.Content = fbMsg.Read
+				msg.Content = &ReadContent{Watermark: fbMsg.Read.Watermark}
+			} else if fbMsg.Feedback != nil {
+				msg.Content = fbMsg.Feedback.content()
+			} else if fbMsg.Policy != nil {
+				msg.Content = &PolicyEnforcementContent{
+					Action: fbMsg.Policy.Action,
+					Reason: fbMsg.Policy.Reason,
+				}
+			} else if fbMsg.Account != nil {
+				msg.Content = &AccountUpdateContent{
+					Type:   fbMsg.Account.Type,
+					Status: fbMsg.Account.Status,
+					Reason: fbMsg.Account.Reason,
+				}
+			} else if fbMsg.OptOut != nil {
+				msg.Content = &OptOutContent{
+					Type:  fbMsg.OptOut.Type,
+					Token: fbMsg.OptOut.Token,
+				}
+			} else if fbMsg.Edit != nil {
+				msg.Content = &EditContent{
+					MessageId: fbMsg.Edit.Mid,
+					NewText:   fbMsg.Edit.Text,
+					EditCount: fbMsg.Edit.NumEdit,
+				}
+			} else if fbMsg.Referral != nil {
+				msg.Content = fbMsg.Referral.content()
+			} else if fbMsg.GamePlay != nil {
+				msg.Content = &GamePlayContent{
+					GameId:      fbMsg.GamePlay.GameId,
+					PlayerId:    fbMsg.GamePlay.PlayerId,
+					ContextType: fbMsg.GamePlay.ContextType,
+					ContextId:   fbMsg.GamePlay.ContextId,
+					Score:       fbMsg.GamePlay.Score,
+					Payload:     fbMsg.GamePlay.Payload,
+				}
 			}
 			messages = append(messages, msg)
 		}
 	}
-	return
+	return messages, partial.orNil()
 }
 
 // send function will unmarshal any object into json string and then
 // submit a http request to the facebook messenger api endpoint
 func (a *FBAmbassador) sendMessages(recipientId string) (err error) {
+	return a.sendMessagesTo(FBRecipient{recipientId}, true)
+}
+
+// sendMessagesTo sends the staged messages to a recipient. Pauses are skipped
+// unless pause is set, so that a broadcast is not delayed per recipient.
+func (a *FBAmbassador) sendMessagesTo(recipient interface{}, pause bool) (err error) {
 	fbApiUrl := FBMessengerBaseURI + a.token
 
 	for _, msgPayload := range a.messages {
+		if d, ok := msgPayload.(fbTypingPause); ok {
+			if pause {
+				time.Sleep(time.Duration(d))
+			}
+			continue
+		}
 		payload, ok := msgPayload.(map[string]interface{})
 		if !ok {
 			return fmt.Errorf("fail to type assert message: %+v", msgPayload)
 		}
-		payload["recipient"] = FBRecipient{recipientId}
+		payload["recipient"] = recipient
+		if message, ok := payload["message"].(map[string]interface{}); ok {
+			if a.replyTo != "" {
+				message["reply_to"] = FBReplyTo{a.replyTo}
+			}
+			// Sender actions can not be tagged.
+			if a.tag != "" {
+				payload["messaging_type"] = "MESSAGE_TAG"
+				payload["tag"] = a.tag
+			}
+		}
+		var result struct {
+			MessageId string `json:"message_id"`
+		}
+		if err = a.call(fbApiUrl, payload, &result); err != nil {
+			return
+		}
+		if result.MessageId != "" {
+			a.Lock()
+			a.sentIds = append(a.sentIds, result.MessageId)
+			a.Unlock()
+		}
+	}
+	return
+}
+
+func (a *FBAmbassador) post(uri string, payload interface{}) (err error) {
+	return a.call(uri, payload, nil)
+}
 
-		b, err := json.Marshal(payload)
+// call posts a payload and decodes the response into v if it is not nil.
+func (a *FBAmbassador) call(uri string, payload, v interface{}) (err error) {
+	return a.request("POST", uri, payload, v)
+}
+
+// request sends a payload, unless it is nil, by a method and decodes the
+// response into v if it is not nil.
+func (a *FBAmbassador) request(method, uri string, payload, v interface{}) (err error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := jsonCodec.Marshal(payload)
 		if err != nil {
 			return err
 		}
-		resp, err := a.client.Post(fbApiUrl, "application/json", bytes.NewBuffer(b))
+		body = bytes.NewBuffer(b)
+	}
+	req, _ := http.NewRequest(method, uri, body)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	setCorrelationHeader(req, a.correlation)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		_, err = io.Copy(buffer, resp.Body)
 		if err != nil {
-			return err
+			return
 		}
+		return fmt.Errorf("fail to %s %s. status: %s, body: %s",
+			method, req.URL.Path, resp.Status, buffer.String())
+	}
+	if v != nil {
+		return decodeJSON(resp.Body, v)
+	}
+	return
+}
+
+// Sender actions of FB which give feedback of the bot to a user.
+const (
+	FBTypingOn  = "typing_on"
+	FBTypingOff = "typing_off"
+	FBMarkSeen  = "mark_seen"
+)
 
-		if resp.StatusCode != 200 {
-			buffer := &bytes.Buffer{}
-			_, err := io.Copy(buffer, resp.Body)
-			resp.Body.Close()
+// SenderAction sends a sender action to a recipient right away, rather than
+// staging it before the messages of the next Send.
+func (a *FBAmbassador) SenderAction(recipientId, action string) (err error) {
+	switch action {
+	case FBTypingOn, FBTypingOff, FBMarkSeen:
+	default:
+		return fmt.Errorf("unknown sender action: %s", action)
+	}
+	return a.post(FBMessengerBaseURI+a.token, map[string]interface{}{
+		"recipient":     FBRecipient{recipientId},
+		"sender_action": action,
+	})
+}
 
-			if err != nil {
-				return err
-			}
-			return fmt.Errorf("fail to deliver an fb message. status: %s, body: %s",
-				resp.Status, buffer.String())
-		}
-		resp.Body.Close()
+// MarkRead marks the last message of a sender as seen right away.
+func (a *FBAmbassador) MarkRead(msg Message) (err error) {
+	return a.SenderAction(msg.SenderId, FBMarkSeen)
+}
+
+// AskQuestion sends a question style text to a recipient. Answers beyond
+// the quick reply limit are paginated behind a "More…" quick reply.
+func (a *FBAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	page, err := paginate(a.Pages, text, answers, fbMaxQuickReplies)
+	if err != nil {
+		return
 	}
+	a.askQuestion(text, page)
 	return
 }
 
-// AskQuestion sends a question style text to a recipient.
-func (a *FBAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+func (a *FBAmbassador) askQuestion(text string, answers []map[string]string) {
 	message := map[string]interface{}{
 		"text":          text,
 		"quick_replies": answers,
@@ -218,12 +490,11 @@ func (a *FBAmbassador) AskQuestion(text string, answers []map[string]string) (er
 	a.Lock()
 	defer a.Unlock()
 	a.messages = append(a.messages, payload)
-	return
 }
 
 // SendText sends a text message to a recipient.
 func (a *FBAmbassador) SendText(text string) (err error) {
-	message := map[string]string{"text": text}
+	message := map[string]interface{}{"text": text}
 	payload := map[string]interface{}{
 		"message": message,
 	}
@@ -234,15 +505,10 @@ func (a *FBAmbassador) SendText(text string) (err error) {
 	return
 }
 
-// SendTemplate sends a template message to a recipient.
-func (a *FBAmbassador) SendTemplate(elements interface{}) (err error) {
-
-	columns := []map[string]interface{}{}
-	colItems, ok := elements.([]Carousel)
-	if !ok {
-		return fmt.Errorf("can not type assert the elements")
-	}
-
+// fbElements turns carousel columns into the elements of a generic
+// template.
+func fbElements(colItems []Carousel) (columns []map[string]interface{}) {
+	columns = []map[string]interface{}{}
 	for i, col := range colItems {
 		if i > 10 {
 			break
@@ -278,13 +544,22 @@ func (a *FBAmbassador) SendTemplate(elements interface{}) (err error) {
 
 		columns = append(columns, element)
 	}
+	return
+}
+
+// SendTemplate sends a template message to a recipient.
+func (a *FBAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
 
 	msgPayload := FBMessageTemplate{
 		Type:     "generic",
-		Elements: columns,
+		Elements: fbElements(colItems),
 	}
 
-	msgBuf, err := json.Marshal(&msgPayload)
+	msgBuf, err := jsonCodec.Marshal(&msgPayload)
 	if err != nil {
 		return
 	}
@@ -304,22 +579,120 @@ func (a *FBAmbassador) SendTemplate(elements interface{}) (err error) {
 	return
 }
 
+// fbTypingPause delays the messages staged after it.
+type fbTypingPause time.Duration
+
+// SendTyping turns the typing indicator on or off by a sender action.
+func (a *FBAmbassador) SendTyping(on bool) (err error) {
+	action := FBTypingOff
+	if on {
+		action = FBTypingOn
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.messages = append(a.messages, map[string]interface{}{"sender_action": action})
+	return
+}
+
+// WithTyping shows the typing indicator for a duration before sending the
+// messages staged after it.
+func (a *FBAmbassador) WithTyping(d time.Duration) (err error) {
+	if err = a.SendTyping(true); err != nil {
+		return
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.messages = append(a.messages, fbTypingPause(d))
+	return
+}
+
+// ReplyTo makes the staged messages replies to a message.
+func (a *FBAmbassador) ReplyTo(messageId string) (err error) {
+	a.Lock()
+	defer a.Unlock()
+	a.replyTo = messageId
+	return
+}
+
+// SetMessageTag tags the staged messages, e.g. with FBTagAccountUpdate, so
+// that they can be sent outside the 24 hour messaging window.
+func (a *FBAmbassador) SetMessageTag(tag string) (err error) {
+	a.Lock()
+	defer a.Unlock()
+	a.tag = tag
+	return
+}
+
 func (a *FBAmbassador) cleanMessage() {
 	a.Lock()
 	defer a.Unlock()
+	a.replyTo = ""
+	a.tag = ""
 	a.lastMessages = a.messages
 	a.messages = []interface{}{}
 }
 
+// SentMessageIds returns the ids of the messages of the last send.
+func (a *FBAmbassador) SentMessageIds() []string {
+	a.Lock()
+	defer a.Unlock()
+	return a.sentIds
+}
+
 func (a *FBAmbassador) GetLastSent() []interface{} {
 	return a.lastMessages
 }
 
+// Discard drops the staged messages without sending them.
+func (a *FBAmbassador) Discard() {
+	a.Lock()
+	last := a.lastMessages
+	a.Unlock()
+	a.cleanMessage()
+	a.Lock()
+	a.lastMessages = last
+	a.Unlock()
+}
+
+// Broadcast sends the staged messages to every recipient and notification
+// messages token of an audience one by one, since messenger has no endpoint
+// to reach all users of a page.
+func (a *FBAmbassador) Broadcast(audience Audience) (err error) {
+	defer a.cleanMessage()
+	a.Lock()
+	a.sentIds = nil
+	a.Unlock()
+	if len(audience.Recipients)+len(audience.NotificationTokens) == 0 {
+		return fmt.Errorf("no recipient or notification token to broadcast to")
+	}
+
+	errs := DeliveryErrors{}
+	for _, token := range audience.NotificationTokens {
+		if e := a.sendMessagesTo(FBTokenRecipient{token}, false); e != nil {
+			errs[token] = e
+		}
+	}
+	for _, recipientId := range audience.Recipients {
+		if e := a.sendMessagesTo(FBRecipient{recipientId}, false); e != nil {
+			errs[recipientId] = e
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
 func (a *FBAmbassador) Send(recipientId string) (err error) {
 	defer a.cleanMessage()
+	a.Lock()
+	a.sentIds = nil
+	a.Unlock()
 	err = a.sendMessages(recipientId)
 	if err != nil {
-		b, _ := json.Marshal(a.messages)
+		b, _ := jsonCodec.Marshal(a.messages)
 		return fmt.Errorf("%s, %s", err.Error(), b)
 	}
 	return
@@ -0,0 +1,99 @@
+package ambassador
+
+import "encoding/json"
+
+// tagPostPurchase tags the send as a post purchase update unless it is
+// tagged already, so that order updates reach users outside the messaging
+// window.
+func (a *FBAmbassador) tagPostPurchase() {
+	a.Lock()
+	defer a.Unlock()
+	if a.tag == "" {
+		a.tag = FBTagPostPurchaseUpdate
+	}
+}
+
+// SendOrderStatus stages a receipt of an order.
+func (a *FBAmbassador) SendOrderStatus(o Order) (err error) {
+	elements := []map[string]interface{}{}
+	for _, item := range o.Items {
+		elements = append(elements, map[string]interface{}{
+			"title":     item.Title,
+			"subtitle":  item.Subtitle,
+			"quantity":  item.Quantity,
+			"price":     item.Price,
+			"currency":  o.Currency,
+			"image_url": item.ImageUrl,
+		})
+	}
+	receipt := map[string]interface{}{
+		"template_type":  "receipt",
+		"recipient_name": o.RecipientName,
+		"order_number":   o.Id,
+		"currency":       o.Currency,
+		"payment_method": o.PaymentMethod,
+		"order_url":      o.Url,
+		"elements":       elements,
+		"summary":        map[string]float64{"total_cost": o.Total},
+	}
+	if !o.PlacedAt.IsZero() {
+		receipt["timestamp"] = o.PlacedAt.Unix()
+	}
+	b, err := jsonCodec.Marshal(receipt)
+	if err != nil {
+		return
+	}
+
+	a.tagPostPurchase()
+	a.Lock()
+	defer a.Unlock()
+	a.messages = append(a.messages, map[string]interface{}{
+		"message": map[string]interface{}{
+			"attachment": &FBMessageAttachment{Type: "template", Payload: json.RawMessage(b)},
+		},
+	})
+	return
+}
+
+// SendShippingUpdate stages a shipment as a generic template with its
+// tracking link.
+func (a *FBAmbassador) SendShippingUpdate(s Shipment) (err error) {
+	a.tagPostPurchase()
+	return a.SendTemplate(s.carousel())
+}
+
+// FBProductAttachment is the payload of a product of a shop a user shares.
+type FBProductAttachment struct {
+	Product struct {
+		Elements []struct {
+			Id         string `json:"id"`
+			RetailerId string `json:"retailer_id"`
+			ImageUrl   string `json:"image_url"`
+			Title      string `json:"title"`
+			Subtitle   string `json:"subtitle"`
+		} `json:"elements"`
+	} `json:"product"`
+}
+
+// fbProductContent returns the products of a shared product template, or
+// nil if the attachment is not one.
+func fbProductContent(a FBMessageAttachment) *ProductContent {
+	if a.Type != "template" {
+		return nil
+	}
+	var payload FBProductAttachment
+	if err := jsonCodec.Unmarshal(a.Payload, &payload); err != nil || len(payload.Product.Elements) == 0 {
+		return nil
+	}
+	content := &ProductContent{}
+	for _, e := range payload.Product.Elements {
+		content.Products = append(content.Products, Product{
+			Id:         e.Id,
+			RetailerId: e.RetailerId,
+			Title:      e.Title,
+			Subtitle:   e.Subtitle,
+			ImageUrl:   e.ImageUrl,
+		})
+	}
+	return content
+}
@@ -0,0 +1,66 @@
+package ambassador
+
+type PolicyEnforcementContent struct {
+	Action string
+	Reason string
+}
+
+type AccountUpdateContent struct {
+	Type   string
+	Status string
+	Reason string
+}
+
+type OptOutContent struct {
+	Type  string
+	Token string
+}
+
+type FBPolicyEnforcement struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+type FBAccountUpdate struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type FBOptOut struct {
+	Type  string `json:"type"`
+	Token string `json:"notification_messages_token"`
+}
+
+type EditContent struct {
+	MessageId string
+	NewText   string
+	EditCount int
+}
+
+type FBMessageEdit struct {
+	Mid     string `json:"mid"`
+	Text    string `json:"text"`
+	NumEdit int    `json:"num_edit"`
+}
+
+// GamePlayContent is the content of a game round played in an Instant Game
+// which is connected to the bot.
+type GamePlayContent struct {
+	GameId   string
+	PlayerId string
+	// ContextType is SOLO, THREAD or GROUP.
+	ContextType string
+	ContextId   string
+	Score       int64
+	Payload     string
+}
+
+type FBGamePlay struct {
+	GameId      string `json:"game_id"`
+	PlayerId    string `json:"player_id"`
+	ContextType string `json:"context_type"`
+	ContextId   string `json:"context_id"`
+	Score       int64  `json:"score"`
+	Payload     string `json:"payload"`
+}
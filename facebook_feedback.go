@@ -0,0 +1,154 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type FeedbackQuestion struct {
+	Id                  string
+	Type                string // csat, nps or ces
+	Title               string
+	ScoreLabel          string
+	ScoreOption         string
+	FollowUpPlaceholder string
+}
+
+type FeedbackRequest struct {
+	Title         string
+	Subtitle      string
+	ButtonTitle   string
+	PrivacyUrl    string
+	ExpiresInDays int
+	Questions     []FeedbackQuestion
+}
+
+type FeedbackAnswer struct {
+	QuestionId string
+	Type       string
+	Score      int
+	FollowUp   string
+}
+
+type FeedbackContent struct {
+	Answers []FeedbackAnswer
+}
+
+type FBMessagingFeedback struct {
+	Screens []FBFeedbackScreen `json:"feedback_screens"`
+}
+
+type FBFeedbackScreen struct {
+	ScreenId  int                         `json:"screen_id"`
+	Questions map[string]FBFeedbackAnswer `json:"questions"`
+}
+
+type FBFeedbackAnswer struct {
+	Type     string            `json:"type"`
+	Payload  string            `json:"payload"`
+	FollowUp *FBFeedbackAnswer `json:"follow_up,omitempty"`
+}
+
+type fbFeedbackTemplate struct {
+	Type            string             `json:"template_type"`
+	Title           string             `json:"title"`
+	Subtitle        string             `json:"subtitle,omitempty"`
+	ButtonTitle     string             `json:"button_title"`
+	Screens         []fbFeedbackScreen `json:"feedback_screens"`
+	BusinessPrivacy map[string]string  `json:"business_privacy"`
+	ExpiresInDays   int                `json:"expires_in_days,omitempty"`
+}
+
+type fbFeedbackScreen struct {
+	Questions []fbFeedbackQuestion `json:"questions"`
+}
+
+type fbFeedbackQuestion struct {
+	Id          string            `json:"id"`
+	Type        string            `json:"type"`
+	Title       string            `json:"title,omitempty"`
+	ScoreLabel  string            `json:"score_label,omitempty"`
+	ScoreOption string            `json:"score_option,omitempty"`
+	FollowUp    map[string]string `json:"follow_up,omitempty"`
+}
+
+// SendFeedbackRequest sends a customer feedback template which lets the
+// recipient answer a CSAT, NPS or CES survey within messenger.
+func (a *FBAmbassador) SendFeedbackRequest(req FeedbackRequest) (err error) {
+	if len(req.Questions) == 0 {
+		return fmt.Errorf("a feedback request needs at least one question")
+	}
+
+	questions := make([]fbFeedbackQuestion, 0, len(req.Questions))
+	for _, q := range req.Questions {
+		question := fbFeedbackQuestion{
+			Id:          q.Id,
+			Type:        q.Type,
+			Title:       q.Title,
+			ScoreLabel:  q.ScoreLabel,
+			ScoreOption: q.ScoreOption,
+		}
+		if q.FollowUpPlaceholder != "" {
+			question.FollowUp = map[string]string{
+				"type":        "free_form",
+				"placeholder": q.FollowUpPlaceholder,
+			}
+		}
+		questions = append(questions, question)
+	}
+
+	msgBuf, err := json.Marshal(&fbFeedbackTemplate{
+		Type:            "customer_feedback",
+		Title:           req.Title,
+		Subtitle:        req.Subtitle,
+		ButtonTitle:     req.ButtonTitle,
+		Screens:         []fbFeedbackScreen{{Questions: questions}},
+		BusinessPrivacy: map[string]string{"url": req.PrivacyUrl},
+		ExpiresInDays:   req.ExpiresInDays,
+	})
+	if err != nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"attachment": &FBMessageAttachment{
+				Type:    "template",
+				Payload: json.RawMessage(msgBuf),
+			},
+		},
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.messages = append(a.messages, payload)
+	return
+}
+
+func (f *FBMessagingFeedback) content() *FeedbackContent {
+	content := &FeedbackContent{}
+	for _, screen := range f.Screens {
+		ids := make([]string, 0, len(screen.Questions))
+		for id := range screen.Questions {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			answer := screen.Questions[id]
+			score, _ := strconv.Atoi(answer.Payload)
+			fa := FeedbackAnswer{
+				QuestionId: id,
+				Type:       answer.Type,
+				Score:      score,
+			}
+			if answer.FollowUp != nil {
+				fa.FollowUp = answer.FollowUp.Payload
+			}
+			content.Answers = append(content.Answers, fa)
+		}
+	}
+	return content
+}
@@ -0,0 +1,114 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const FBPageInsightsURI = "https://graph.facebook.com/v2.6/me/insights"
+
+const (
+	FBMetricNewConversations      = "page_messages_new_conversations_unique"
+	FBMetricBlockedConversations  = "page_messages_blocked_conversations_unique"
+	FBMetricReportedConversations = "page_messages_reported_conversations_unique"
+)
+
+// FBInsightValue is the value of a metric of a day.
+type FBInsightValue struct {
+	Value   int64  `json:"value"`
+	EndTime string `json:"end_time"`
+}
+
+type FBInsightMetric struct {
+	Name   string           `json:"name"`
+	Period string           `json:"period"`
+	Values []FBInsightValue `json:"values"`
+}
+
+// Total sums the daily values of the metric.
+func (m *FBInsightMetric) Total() (total int64) {
+	for _, v := range m.Values {
+		total += v.Value
+	}
+	return
+}
+
+// FBMessagingInsights are the daily messaging metrics of a page.
+type FBMessagingInsights struct {
+	NewConversations      FBInsightMetric
+	BlockedConversations  FBInsightMetric
+	ReportedConversations FBInsightMetric
+}
+
+// FBPageInsights reads the insights of a page by its page access token.
+type FBPageInsights struct {
+	token  string
+	client *http.Client
+}
+
+func NewFBPageInsights(token string, client *http.Client) *FBPageInsights {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &FBPageInsights{token: token, client: client}
+}
+
+// Metrics returns the daily values of metrics between since and until.
+func (p *FBPageInsights) Metrics(since, until time.Time, metrics ...string) (result []FBInsightMetric, err error) {
+	query := url.Values{}
+	query.Set("metric", strings.Join(metrics, ","))
+	query.Set("period", "day")
+	query.Set("since", strconv.FormatInt(since.Unix(), 10))
+	query.Set("until", strconv.FormatInt(until.Unix(), 10))
+	query.Set("access_token", p.token)
+
+	resp, err := p.client.Get(FBPageInsightsURI + "?" + query.Encode())
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return nil, fmt.Errorf("fail to get fb page insights. status: %s, body: %s",
+			resp.Status, buffer.String())
+	}
+
+	var body struct {
+		Data []FBInsightMetric `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return
+	}
+	return body.Data, nil
+}
+
+// Messaging returns the new, blocked and reported conversations between
+// since and until.
+func (p *FBPageInsights) Messaging(since, until time.Time) (insights *FBMessagingInsights, err error) {
+	metrics, err := p.Metrics(since, until,
+		FBMetricNewConversations, FBMetricBlockedConversations, FBMetricReportedConversations)
+	if err != nil {
+		return
+	}
+	insights = &FBMessagingInsights{}
+	for _, m := range metrics {
+		switch m.Name {
+		case FBMetricNewConversations:
+			insights.NewConversations = m
+		case FBMetricBlockedConversations:
+			insights.BlockedConversations = m
+		case FBMetricReportedConversations:
+			insights.ReportedConversations = m
+		}
+	}
+	return
+}
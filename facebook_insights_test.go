@@ -0,0 +1,44 @@
+package ambassador
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestFBPageInsights(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	server.Fail("facebook", testutil.Sequence(
+		&testutil.Failure{Body: `{"data":[
+			{"name":"page_messages_new_conversations_unique","period":"day","values":[
+				{"value":3,"end_time":"2017-07-14T07:00:00+0000"},{"value":5,"end_time":"2017-07-15T07:00:00+0000"}]},
+			{"name":"page_messages_blocked_conversations_unique","period":"day","values":[
+				{"value":1,"end_time":"2017-07-15T07:00:00+0000"}]}]}`},
+		&testutil.Failure{Status: 400, Body: `{"error":{"message":"Invalid metric"}}`},
+	))
+	insights := NewFBPageInsights("token", server.Client())
+	since := time.Date(2017, 7, 13, 0, 0, 0, 0, time.UTC)
+	until := since.Add(48 * time.Hour)
+
+	m, err := insights.Messaging(since, until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NewConversations.Total() != 8 || m.BlockedConversations.Total() != 1 || m.ReportedConversations.Total() != 0 {
+		t.Errorf("unexpected insights: %+v", m)
+	}
+	r := server.Requests()[0]
+	query, _ := url.ParseQuery(r.Query)
+	if r.Method != "GET" || r.Path != "/v2.6/me/insights" || query.Get("period") != "day" ||
+		query.Get("since") != "1499904000" || query.Get("until") != "1500076800" || query.Get("access_token") != "token" ||
+		query.Get("metric") != FBMetricNewConversations+","+FBMetricBlockedConversations+","+FBMetricReportedConversations {
+		t.Errorf("unexpected request: %s %s?%s", r.Method, r.Path, r.Query)
+	}
+
+	if _, err := insights.Metrics(since, until, "page_fans"); err == nil {
+		t.Error("expect an error of the graph api to fail")
+	}
+}
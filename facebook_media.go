@@ -0,0 +1,68 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const FBAttachmentUploadURI = "https://graph.facebook.com/v2.6/me/message_attachments?access_token="
+
+// Upload uploads a reusable attachment and returns its attachment id. The
+// file is streamed to facebook without being buffered.
+func (a *FBAmbassador) Upload(r io.Reader, filename, mimeType string) (attachmentId string, err error) {
+	message, err := jsonCodec.Marshal(map[string]interface{}{
+		"attachment": map[string]interface{}{
+			"type":    attachmentType(mimeType),
+			"payload": map[string]bool{"is_reusable": true},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	req, err := newMultipartRequest(FBAttachmentUploadURI+a.token,
+		map[string]string{"message": string(message)},
+		multipartFile{Field: "filedata", Filename: filename, MimeType: mimeType, Reader: r})
+	if err != nil {
+		return
+	}
+	setCorrelationHeader(req, a.correlation)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return "", fmt.Errorf("fail to upload an fb attachment. status: %s, body: %s",
+			resp.Status, buffer.String())
+	}
+
+	var result struct {
+		AttachmentId string `json:"attachment_id"`
+	}
+	if err = decodeJSON(resp.Body, &result); err != nil {
+		return
+	}
+	return result.AttachmentId, nil
+}
+
+// SendAttachment sends an uploaded attachment, e.g. "image", by its id.
+func (a *FBAmbassador) SendAttachment(attachmentType, attachmentId string) (err error) {
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"attachment": map[string]interface{}{
+				"type":    attachmentType,
+				"payload": map[string]string{"attachment_id": attachmentId},
+			},
+		},
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	a.messages = append(a.messages, payload)
+	return
+}
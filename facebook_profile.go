@@ -0,0 +1,105 @@
+package ambassador
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const FBMessengerProfileURI = "https://graph.facebook.com/v2.6/me/messenger_profile?access_token="
+
+// Types of the items of a persistent menu.
+const (
+	FBMenuPostback = "postback"
+	FBMenuWebURL   = "web_url"
+)
+
+const (
+	fbMaxMenuItems    = 20
+	fbMaxMenuTitleLen = 30
+)
+
+// FBMenuItem is an item of a persistent menu, which sends a postback of its
+// payload or opens its url.
+type FBMenuItem struct {
+	Type                string `json:"type"`
+	Title               string `json:"title"`
+	Payload             string `json:"payload,omitempty"`
+	Url                 string `json:"url,omitempty"`
+	WebviewHeightRatio  string `json:"webview_height_ratio,omitempty"`
+	MessengerExtensions bool   `json:"messenger_extensions,omitempty"`
+}
+
+// FBPersistentMenu is the menu of a locale. A menu of the "default" locale
+// is required, which is shown to users of the other locales.
+type FBPersistentMenu struct {
+	Locale                string       `json:"locale"`
+	ComposerInputDisabled bool         `json:"composer_input_disabled"`
+	CallToActions         []FBMenuItem `json:"call_to_actions"`
+}
+
+func validateFBMenus(menus []FBPersistentMenu) error {
+	hasDefault := false
+	for _, menu := range menus {
+		if menu.Locale == "default" {
+			hasDefault = true
+		}
+		if len(menu.CallToActions) > fbMaxMenuItems {
+			return fmt.Errorf("the menu of %s has more than %d items", menu.Locale, fbMaxMenuItems)
+		}
+		for _, item := range menu.CallToActions {
+			if item.Title == "" || utf8.RuneCountInString(item.Title) > fbMaxMenuTitleLen {
+				return fmt.Errorf("the title of a menu item must have 1 to %d characters: %q", fbMaxMenuTitleLen, item.Title)
+			}
+			switch item.Type {
+			case FBMenuPostback:
+				if item.Payload == "" {
+					return fmt.Errorf("the postback menu item %s has no payload", item.Title)
+				}
+			case FBMenuWebURL:
+				if item.Url == "" {
+					return fmt.Errorf("the web url menu item %s has no url", item.Title)
+				}
+			default:
+				return fmt.Errorf("unknown menu item type: %s", item.Type)
+			}
+		}
+	}
+	if !hasDefault {
+		return fmt.Errorf("no persistent menu of the default locale")
+	}
+	return nil
+}
+
+// SetPersistentMenu replaces the persistent menus of the page. A get started
+// button is required by Messenger before a menu can be set.
+func (a *FBAmbassador) SetPersistentMenu(menus ...FBPersistentMenu) (err error) {
+	if err = validateFBMenus(menus); err != nil {
+		return
+	}
+	return a.post(FBMessengerProfileURI+a.token, map[string]interface{}{
+		"persistent_menu": menus,
+	})
+}
+
+// PersistentMenu returns the persistent menus of the page.
+func (a *FBAmbassador) PersistentMenu() (menus []FBPersistentMenu, err error) {
+	var v struct {
+		Data []struct {
+			PersistentMenu []FBPersistentMenu `json:"persistent_menu"`
+		} `json:"data"`
+	}
+	if err = a.request("GET", FBMessengerProfileURI+a.token+"&fields=persistent_menu", nil, &v); err != nil {
+		return
+	}
+	for _, d := range v.Data {
+		menus = append(menus, d.PersistentMenu...)
+	}
+	return
+}
+
+// DeletePersistentMenu removes the persistent menus of the page.
+func (a *FBAmbassador) DeletePersistentMenu() (err error) {
+	return a.request("DELETE", FBMessengerProfileURI+a.token, map[string]interface{}{
+		"fields": []string{"persistent_menu"},
+	}, nil)
+}
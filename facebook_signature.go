@@ -0,0 +1,99 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// FBSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of a webhook
+// body by the app secret.
+const FBSignatureHeader = "X-Hub-Signature-256"
+
+// FBSignature is the value of FBSignatureHeader of a body.
+func FBSignature(appSecret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyFBSignature checks the FBSignatureHeader of a webhook body, and
+// returns ErrInvalidSignature if it does not match.
+func VerifyFBSignature(appSecret, signature string, body []byte) error {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifySignature checks the FBSignatureHeader of a webhook body by the
+// secret of the app of the page.
+func (a *FBAmbassador) VerifySignature(appSecret, signature string, body []byte) error {
+	return VerifyFBSignature(appSecret, signature, body)
+}
+
+// VerifyFB rejects webhook posts without a valid FBSignatureHeader before
+// passing them to next, e.g. a Webhook, so that forged events never reach
+// Translate. GET requests, which verify the callback url, never reach next
+// and are answered by FBChallenge with the verify token.
+func VerifyFB(appSecret, verifyToken string, next http.Handler) http.Handler {
+	challenge := FBChallenge(verifyToken, nil)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			challenge(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+		if err != nil {
+			http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := VerifyFBSignature(appSecret, r.Header.Get(FBSignatureHeader), body); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FBChallenge answers the GET request by which Facebook verifies the
+// callback url of a webhook subscription, if its hub.verify_token matches,
+// and passes other requests to next, e.g. a Webhook. A nil next rejects
+// them. VerifyFB answers the challenge by it, so that it is only needed
+// next to a webhook without signature verification.
+func FBChallenge(verifyToken string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			if next == nil {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("hub.mode") != "subscribe" || verifyToken == "" ||
+			subtle.ConstantTimeCompare([]byte(q.Get("hub.verify_token")), []byte(verifyToken)) != 1 {
+			http.Error(w, "invalid verify token", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, q.Get("hub.challenge"))
+	}
+}
@@ -0,0 +1,367 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestFBSenderAction(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+
+	if err := fb.SenderAction("u1", "dance"); err == nil {
+		t.Error("expect an unknown sender action to fail")
+	}
+	if err := fb.MarkRead(Message{SenderId: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || !strings.Contains(string(requests[0].Body), `"sender_action":"`+FBMarkSeen+`"`) {
+		t.Errorf("expect a mark seen sender action, got %+v", requests)
+	}
+}
+
+func TestFBTaggedBroadcast(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+
+	fb.SetMessageTag(FBTagAccountUpdate)
+	fb.WithTyping(time.Hour)
+	fb.SendText("your account is updated")
+	done := make(chan error, 1)
+	go func() { done <- fb.Broadcast(Audience{Recipients: []string{"u1", "u2"}}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect a broadcast not to pause per recipient")
+	}
+
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("expect a typing action and a message per recipient, got %d", len(requests))
+	}
+	for _, r := range requests {
+		action := strings.Contains(string(r.Body), `"sender_action"`)
+		tagged := strings.Contains(string(r.Body), `"tag":"`+FBTagAccountUpdate+`"`)
+		if action == tagged {
+			t.Errorf("expect only messages to be tagged, got %s", r.Body)
+		}
+	}
+}
+
+func TestShowTyping(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+
+	silent := ShowTyping()(HandlerFunc(func(a Ambassador, msg Message) error {
+		return nil
+	}))
+	reply := ShowTyping()(HandlerFunc(func(a Ambassador, msg Message) error {
+		a.SendText("hello")
+		return a.Send(msg.ReplyTarget())
+	}))
+	msg := Message{SenderId: "u1", Content: &TextContent{Text: "hi"}}
+	if err := silent.Handle(fb, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := reply.Handle(fb, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if platformOf(&sendTracker{Ambassador: fb}) != "facebook" {
+		t.Error("expect the platform of a wrapped ambassador")
+	}
+
+	requests := server.Requests()
+	expected := []string{`"sender_action":"typing_on"`, `"sender_action":"typing_off"`, `"sender_action":"typing_on"`, `"text":"hello"`}
+	if len(requests) != len(expected) {
+		t.Fatalf("expect %d requests, got %d", len(expected), len(requests))
+	}
+	for i := range expected {
+		if !strings.Contains(string(requests[i].Body), expected[i]) {
+			t.Errorf("expect %s, got %s", expected[i], requests[i].Body)
+		}
+	}
+
+	lineServer := testutil.NewFakeServer()
+	defer lineServer.Close()
+	line := NewLineAmbassador("token", lineServer.Client())
+	line.ReplyTokens = NewMemoryLineReplyTokenStore()
+	line.ReplyTokens.PutReplyToken("typing-token", LineReplyToken{To: "U1", IssuedAt: time.Now()})
+	msg = Message{SenderId: "U1", ReplyToken: "typing-token", Content: &TextContent{Text: "hi"}}
+	if err := reply.Handle(line, msg); err != nil {
+		t.Fatal(err)
+	}
+	requests = lineServer.Requests()
+	if len(requests) != 2 || requests[0].Path != "/v2/bot/chat/loading/start" || requests[1].Path != "/v2/bot/message/reply" {
+		t.Errorf("expect the loading animation to keep the reply token for the reply, got %+v", requests)
+	}
+}
+
+func TestVerifyFB(t *testing.T) {
+	body := `{"object":"page","entry":[]}`
+	var got string
+	handler := VerifyFB("app-secret", "verify", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = string(b)
+	}))
+
+	cases := []struct {
+		signature string
+		status    int
+	}{
+		{FBSignature("app-secret", []byte(body)), http.StatusOK},
+		{FBSignature("other", []byte(body)), http.StatusForbidden},
+		{"sha1=abc", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		got = ""
+		r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+		r.Header.Set(FBSignatureHeader, c.signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("expect %d for %q, got %d", c.status, c.signature, w.Code)
+		}
+		if c.status == http.StatusOK && got != body {
+			t.Errorf("expect the body to be passed on, got %q", got)
+		}
+	}
+}
+
+func TestFBChallenge(t *testing.T) {
+	posted := false
+	handler := VerifyFB("app-secret", "verify", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/webhook?hub.mode=subscribe&hub.verify_token=verify&hub.challenge=1158201444", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "1158201444" {
+		t.Errorf("expect the challenge to be echoed, got %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/webhook?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=1", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expect a wrong verify token to be rejected, got %d", w.Code)
+	}
+
+	body := `{"object":"page","entry":[]}`
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/webhook", strings.NewReader(body)))
+	if posted || w.Code != http.StatusForbidden {
+		t.Errorf("expect a GET with a body not to reach the webhook, got %d", w.Code)
+	}
+	r := httptest.NewRequest("POST", "/webhook", strings.NewReader(body))
+	r.Header.Set(FBSignatureHeader, FBSignature("app-secret", []byte(body)))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !posted {
+		t.Error("expect events to be passed to the webhook")
+	}
+
+	huge := strings.Repeat(" ", MaxPayloadSize+1)
+	r = httptest.NewRequest("POST", "/webhook", strings.NewReader(huge))
+	r.Header.Set(FBSignatureHeader, FBSignature("app-secret", []byte(huge)))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect a payload over the limit to be rejected, got %d", w.Code)
+	}
+}
+
+func TestFBPersistentMenu(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Query().Get("fields")+" "+string(b))
+		if r.Method == "GET" {
+			w.Write([]byte(`{"data":[{"persistent_menu":[{"locale":"default","composer_input_disabled":false,"call_to_actions":[{"type":"postback","title":"Orders","payload":"ORDERS"}]}]}]}`))
+			return
+		}
+		w.Write([]byte(`{"result":"success"}`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	fb := NewFBAmbassador("token", DecorateClient(nil, ViaGateway(u)))
+
+	menu := FBPersistentMenu{Locale: "default", CallToActions: []FBMenuItem{
+		{Type: FBMenuPostback, Title: "Orders", Payload: "ORDERS"},
+		{Type: FBMenuWebURL, Title: "Shop", Url: "https://shop.example.com"},
+	}}
+	if err := fb.SetPersistentMenu(FBPersistentMenu{Locale: "zh_TW", CallToActions: menu.CallToActions}); err == nil {
+		t.Error("expect a menu without the default locale to fail")
+	}
+	if err := fb.SetPersistentMenu(FBPersistentMenu{Locale: "default", CallToActions: []FBMenuItem{{Type: FBMenuPostback, Title: "Orders"}}}); err == nil {
+		t.Error("expect a postback item without a payload to fail")
+	}
+	if err := fb.SetPersistentMenu(menu); err != nil {
+		t.Fatal(err)
+	}
+	menus, err := fb.PersistentMenu()
+	if err != nil || len(menus) != 1 || menus[0].CallToActions[0].Payload != "ORDERS" {
+		t.Errorf("unexpected menus: %+v %v", menus, err)
+	}
+	if err := fb.DeletePersistentMenu(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 3 || !strings.HasPrefix(requests[0], `POST  {"persistent_menu":[{"locale":"default"`) ||
+		requests[1] != "GET persistent_menu " || requests[2] != `DELETE  {"fields":["persistent_menu"]}` {
+		t.Errorf("unexpected requests: %q", requests)
+	}
+}
+
+func TestFBTranslateEvents(t *testing.T) {
+	cases := []struct {
+		name     string
+		event    string
+		expected interface{}
+	}{
+		{
+			"messaging feedback",
+			`{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1458692752478,"messaging_feedback":{"feedback_screens":[{"screen_id":0,
+				"questions":{"nps":{"type":"nps","payload":"9"},"csat":{"type":"csat","payload":"4","follow_up":{"type":"free_form","payload":"Good service!"}}}}]}}`,
+			&FeedbackContent{Answers: []FeedbackAnswer{
+				{QuestionId: "csat", Type: "csat", Score: 4, FollowUp: "Good service!"},
+				{QuestionId: "nps", Type: "nps", Score: 9},
+			}},
+		},
+		{
+			"policy enforcement",
+			`{"recipient":{"id":"p1"},"timestamp":1458692752478,"policy-enforcement":{"action":"block","reason":"The bot violated our Platform Policies"}}`,
+			&PolicyEnforcementContent{Action: "block", Reason: "The bot violated our Platform Policies"},
+		},
+		{
+			"account update",
+			`{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1458692752478,"account_update":{"type":"messaging_feature","status":"restricted","reason":"spam"}}`,
+			&AccountUpdateContent{Type: "messaging_feature", Status: "restricted", Reason: "spam"},
+		},
+		{
+			"opt out",
+			`{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1458692752478,"optout":{"type":"notification_messages","notification_messages_token":"token-1"}}`,
+			&OptOutContent{Type: "notification_messages", Token: "token-1"},
+		},
+		{
+			"message edit",
+			`{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1458692752478,"message_edit":{"mid":"m_1","text":"see you at 8","num_edit":2}}`,
+			&EditContent{MessageId: "m_1", NewText: "see you at 8", EditCount: 2},
+		},
+		{
+			"game play",
+			`{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1469111400000,"game_play":{"game_id":"g1","player_id":"player1","context_type":"THREAD","context_id":"c1","score":120,"payload":"{\"level\":3}"}}`,
+			&GamePlayContent{GameId: "g1", PlayerId: "player1", ContextType: "THREAD", ContextId: "c1", Score: 120, Payload: `{"level":3}`},
+		},
+	}
+	for _, c := range cases {
+		body := `{"object":"page","entry":[{"id":"p1","time":1458692752478,"messaging":[` + c.event + `]}]}`
+		messages, err := NewFBAmbassador("token", nil).Translate(strings.NewReader(body))
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if len(messages) != 1 || !reflect.DeepEqual(messages[0].Content, c.expected) {
+			t.Errorf("%s: expect %+v, got %+v", c.name, c.expected, messages)
+		}
+	}
+}
+
+func TestFBFeedbackRequest(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+
+	if err := fb.SendFeedbackRequest(FeedbackRequest{Title: "Rate us"}); err == nil {
+		t.Error("expect a feedback request without questions to fail")
+	}
+	err := fb.SendFeedbackRequest(FeedbackRequest{
+		Title:         "Rate us",
+		ButtonTitle:   "Rate",
+		PrivacyUrl:    "https://example.com/privacy",
+		ExpiresInDays: 3,
+		Questions: []FeedbackQuestion{{
+			Id: "csat", Type: "csat", Title: "How was it?", ScoreLabel: "neg_pos", ScoreOption: "five_stars",
+			FollowUpPlaceholder: "Tell us more",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var request struct {
+		Message struct {
+			Attachment struct {
+				Type    string
+				Payload fbFeedbackTemplate
+			}
+		}
+	}
+	if err := json.Unmarshal(server.Requests()[0].Body, &request); err != nil {
+		t.Fatal(err)
+	}
+	template := request.Message.Attachment.Payload
+	if request.Message.Attachment.Type != "template" || template.Type != "customer_feedback" ||
+		template.BusinessPrivacy["url"] != "https://example.com/privacy" || template.ExpiresInDays != 3 {
+		t.Errorf("unexpected feedback template: %+v", request.Message.Attachment)
+	}
+	if q := template.Screens[0].Questions[0]; q.Id != "csat" || q.ScoreOption != "five_stars" || q.FollowUp["type"] != "free_form" {
+		t.Errorf("unexpected question: %+v", q)
+	}
+}
+
+func TestFBRequestError(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	server.Fail("facebook", testutil.Sequence(&testutil.Failure{Status: 400, Body: `{"error":{"message":"Invalid field"}}`}))
+	fb := NewFBAmbassador("token", server.Client())
+
+	_, err := fb.PersistentMenu()
+	if err == nil || !strings.Contains(err.Error(), "fail to GET /v2.6/me/messenger_profile") ||
+		!strings.Contains(err.Error(), "Invalid field") || strings.Contains(err.Error(), "token") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestFBSentMessageIdsWhileSending(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			fb.SendText("hi")
+			fb.Send("u1")
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if ids := fb.SentMessageIds(); len(ids) != 1 || ids[0] != "mid.fake" {
+				t.Errorf("unexpected sent ids: %v", ids)
+			}
+			return
+		default:
+			fb.SentMessageIds()
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ambassador
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FBWebviewContextParam is the query parameter of the signed context of a
+// webview url.
+const FBWebviewContextParam = "ctx"
+
+const defaultWebviewTTL = time.Hour
+
+// FBWebviewContext is who a webview is opened for, and what for.
+type FBWebviewContext struct {
+	PSID string `json:"p"`
+	// Data is anything the bot passes to the page, e.g. an order id.
+	Data string `json:"d,omitempty"`
+}
+
+// FBWebview signs the context of the pages opened in webviews, so that a
+// page can trust the psid it is opened for without Messenger Extensions.
+type FBWebview struct {
+	codec *PayloadCodec
+	// TTL is how long a webview url stays valid.
+	TTL time.Duration
+}
+
+func NewFBWebview(secret string) *FBWebview {
+	return &FBWebview{codec: NewPayloadCodec(secret), TTL: defaultWebviewTTL}
+}
+
+// URL signs a context into the url of a page.
+func (w *FBWebview) URL(page string, c FBWebviewContext) (uri string, err error) {
+	u, err := url.Parse(page)
+	if err != nil {
+		return
+	}
+	signed, err := w.codec.Encode(&c, w.TTL)
+	if err != nil {
+		return
+	}
+	q := u.Query()
+	q.Set(FBWebviewContextParam, signed)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Button is a url button opening a page with a signed context in a webview
+// with Messenger Extensions, e.g. "tall".
+func (w *FBWebview) Button(label, page string, c FBWebviewContext, heightRatio string) (btn CarouselButton, err error) {
+	uri, err := w.URL(page, c)
+	if err != nil {
+		return
+	}
+	return CarouselButton{Label: label, Type: "url", Data: uri, HeightRatio: heightRatio, Extensions: true}, nil
+}
+
+// Context verifies the signed context of a request to a page.
+func (w *FBWebview) Context(r *http.Request) (c *FBWebviewContext, err error) {
+	signed := r.URL.Query().Get(FBWebviewContextParam)
+	if signed == "" {
+		return nil, ErrMalformedPayload
+	}
+	c = &FBWebviewContext{}
+	if err = w.codec.Decode(signed, c); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// FBSignedRequest is the payload of the signed_request returned by
+// getContext of Messenger Extensions.
+type FBSignedRequest struct {
+	PSID       string `json:"psid"`
+	Algorithm  string `json:"algorithm"`
+	ThreadType string `json:"thread_type"`
+	ThreadId   string `json:"tid"`
+	IssuedAt   int64  `json:"issued_at"`
+	PageId     string `json:"page_id"`
+}
+
+// ParseFBSignedRequest verifies a signed_request by the app secret and
+// decodes its payload.
+func ParseFBSignedRequest(appSecret, signedRequest string) (r *FBSignedRequest, err error) {
+	parts := strings.SplitN(signedRequest, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrMalformedPayload
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[0], "="))
+	if err != nil {
+		return nil, ErrMalformedPayload
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, ErrMalformedPayload
+	}
+	r = &FBSignedRequest{}
+	if err = jsonCodec.Unmarshal(payload, r); err != nil {
+		return nil, ErrMalformedPayload
+	}
+	if r.Algorithm != "" && r.Algorithm != "HMAC-SHA256" {
+		return nil, ErrInvalidSignature
+	}
+	return
+}
+
+// FBShareMessage is the message of beginShareFlow of Messenger Extensions,
+// to be marshaled for the page. Shared messages only support url buttons,
+// so the other buttons are dropped.
+func FBShareMessage(elements []Carousel) map[string]interface{} {
+	shared := make([]Carousel, len(elements))
+	for i, col := range elements {
+		shared[i] = col
+		shared[i].Buttons = nil
+		for _, btn := range col.Buttons {
+			if btn.Type == "url" {
+				shared[i].Buttons = append(shared[i].Buttons, btn)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"attachment": map[string]interface{}{
+			"type": "template",
+			"payload": &FBMessageTemplate{
+				Type:     "generic",
+				Elements: fbElements(shared),
+			},
+		},
+	}
+}
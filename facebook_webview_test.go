@@ -0,0 +1,59 @@
+package ambassador
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFBWebview(t *testing.T) {
+	w := NewFBWebview("secret")
+	btn, err := w.Button("Pay", "https://shop.example.com/pay?lang=en", FBWebviewContext{PSID: "u1", Data: "order-1"}, "tall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !btn.Extensions || btn.HeightRatio != "tall" || !strings.HasPrefix(btn.Data, "https://shop.example.com/pay?") {
+		t.Errorf("unexpected button: %+v", btn)
+	}
+
+	c, err := w.Context(httptest.NewRequest("GET", btn.Data, nil))
+	if err != nil || c.PSID != "u1" || c.Data != "order-1" {
+		t.Errorf("unexpected context: %+v %v", c, err)
+	}
+	if _, err := NewFBWebview("other").Context(httptest.NewRequest("GET", btn.Data, nil)); err != ErrInvalidSignature {
+		t.Errorf("expect a context signed by another secret to be rejected, got %v", err)
+	}
+}
+
+func TestParseFBSignedRequest(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"psid":"u1","algorithm":"HMAC-SHA256","thread_type":"USER_TO_PAGE","tid":"u1","issued_at":1500000000,"page_id":"p1"}`))
+	mac := hmac.New(sha256.New, []byte("app-secret"))
+	mac.Write([]byte(payload))
+	signed := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) + "." + payload
+
+	r, err := ParseFBSignedRequest("app-secret", signed)
+	if err != nil || r.PSID != "u1" || r.PageId != "p1" {
+		t.Errorf("unexpected signed request: %+v %v", r, err)
+	}
+	if _, err := ParseFBSignedRequest("wrong", signed); err != ErrInvalidSignature {
+		t.Errorf("expect an invalid signature, got %v", err)
+	}
+}
+
+func TestFBShareMessage(t *testing.T) {
+	b, _ := json.Marshal(FBShareMessage([]Carousel{{
+		Title: "Gift card",
+		Buttons: []CarouselButton{
+			{Label: "Open", Type: "url", Data: "https://shop.example.com/gift"},
+			{Label: "Buy", Type: "postback", Data: "BUY"},
+		},
+	}}))
+	expected := `{"attachment":{"payload":{"template_type":"generic","elements":[{"buttons":[{"type":"web_url","title":"Open","url":"https://shop.example.com/gift"}],"image_url":"","item_url":"","subtitle":"","title":"Gift card"}]},"type":"template"}}`
+	if string(b) != expected {
+		t.Errorf("unexpected share message: %s", b)
+	}
+}
@@ -0,0 +1,180 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Most webhook payloads carry plain text messages only. They are decoded
+// into pooled scratch structs of the few fields a text message has, instead
+// of the full platform objects with a pointer for every kind of event.
+// Payloads with anything else fall back to the full translation.
+
+var bodyPool = sync.Pool{New: func() interface{} { return &bytes.Buffer{} }}
+
+// readBody reads a webhook body into a pooled buffer, which should be
+// returned by releaseBody once the body is decoded.
+func readBody(r io.Reader) (body *bytes.Buffer, err error) {
+	body = bodyPool.Get().(*bytes.Buffer)
+	body.Reset()
+	if _, err = body.ReadFrom(limitReader(r)); err != nil {
+		releaseBody(body)
+		return nil, err
+	}
+	return
+}
+
+func releaseBody(body *bytes.Buffer) {
+	bodyPool.Put(body)
+}
+
+type fbTextEvent struct {
+	Sender    FBSender    `json:"sender"`
+	Recipient FBRecipient `json:"recipient"`
+	Timestamp int64       `json:"timestamp"`
+	Message   struct {
+		Mid         string          `json:"mid"`
+		Text        string          `json:"text"`
+		IsEcho      bool            `json:"is_echo"`
+		StickerId   int64           `json:"sticker_id"`
+		ReplyTo     FBReplyTo       `json:"reply_to"`
+		Attachments json.RawMessage `json:"attachments"`
+		QuickReply  json.RawMessage `json:"quick_reply"`
+		NLP         json.RawMessage `json:"nlp"`
+	} `json:"message"`
+}
+
+// plain tells whether an event is a text message without anything else the
+// full translation handles.
+func (e *fbTextEvent) plain() bool {
+	m := &e.Message
+	return m.Text != "" && !m.IsEcho && m.StickerId == 0 &&
+		m.Attachments == nil && m.QuickReply == nil && m.NLP == nil
+}
+
+type fbTextObject struct {
+	Entry []struct {
+		Messaging []fbTextEvent `json:"messaging"`
+	} `json:"entry"`
+}
+
+// reset zeroes the decoded events but keeps the slices for the next
+// payload.
+func (o *fbTextObject) reset() {
+	for i := range o.Entry {
+		for j := range o.Entry[i].Messaging {
+			o.Entry[i].Messaging[j] = fbTextEvent{}
+		}
+		o.Entry[i].Messaging = o.Entry[i].Messaging[:0]
+	}
+	o.Entry = o.Entry[:0]
+}
+
+var fbTextPool = sync.Pool{New: func() interface{} { return &fbTextObject{} }}
+
+// translateFBText translates a payload of text messages only. It is not ok
+// if the payload has any other event.
+func translateFBText(body []byte) (messages []Message, ok bool) {
+	o := fbTextPool.Get().(*fbTextObject)
+	defer func() {
+		o.reset()
+		fbTextPool.Put(o)
+	}()
+	if jsonCodec.Unmarshal(body, o) != nil {
+		return
+	}
+	events := 0
+	for _, entry := range o.Entry {
+		for i := range entry.Messaging {
+			if !entry.Messaging[i].plain() {
+				return
+			}
+		}
+		events += len(entry.Messaging)
+	}
+	if events > MaxEventsPerPayload {
+		return
+	}
+
+	messages = make([]Message, 0, events)
+	for _, entry := range o.Entry {
+		for i := range entry.Messaging {
+			e := &entry.Messaging[i]
+			messages = append(messages, Message{
+				SenderId:    e.Sender.Id,
+				RecipientId: e.Recipient.Id,
+				Timestamp:   e.Timestamp,
+				MessageId:   e.Message.Mid,
+				InReplyTo:   e.Message.ReplyTo.Mid,
+				Content:     &TextContent{Text: e.Message.Text},
+			})
+		}
+	}
+	return messages, true
+}
+
+type lineTextEvent struct {
+	ReplyToken string     `json:"replyToken"`
+	Type       string     `json:"type"`
+	Timestamp  int64      `json:"timestamp"`
+	Source     LineSource `json:"source"`
+	Message    struct {
+		Id              string `json:"id"`
+		Type            string `json:"type"`
+		Text            string `json:"text"`
+		QuotedMessageId string `json:"quotedMessageId"`
+	} `json:"message"`
+}
+
+type lineTextObject struct {
+	Events []lineTextEvent `json:"events"`
+}
+
+func (o *lineTextObject) reset() {
+	for i := range o.Events {
+		o.Events[i] = lineTextEvent{}
+	}
+	o.Events = o.Events[:0]
+}
+
+var lineTextPool = sync.Pool{New: func() interface{} { return &lineTextObject{} }}
+
+// translateLineText translates a payload of text messages only, and keeps
+// their reply tokens for an ambassador. It is not ok if the payload has any
+// other event.
+func translateLineText(l *LineAmbassador, body []byte) (messages []Message, ok bool) {
+	o := lineTextPool.Get().(*lineTextObject)
+	defer func() {
+		o.reset()
+		lineTextPool.Put(o)
+	}()
+	if jsonCodec.Unmarshal(body, o) != nil || len(o.Events) > MaxEventsPerPayload {
+		return
+	}
+	for i := range o.Events {
+		if o.Events[i].Type != "message" || o.Events[i].Message.Type != "text" {
+			return
+		}
+	}
+
+	messages = make([]Message, 0, len(o.Events))
+	for i := range o.Events {
+		e := &o.Events[i]
+		msg := Message{
+			SenderId:   e.Source.UserId,
+			ReplyToken: e.ReplyToken,
+			ChatId:     e.Source.GroupId + e.Source.RoomId,
+			Timestamp:  e.Timestamp,
+			MessageId:  e.Message.Id,
+			InReplyTo:  e.Message.QuotedMessageId,
+			Content:    &TextContent{Text: e.Message.Text},
+		}
+		if e.ReplyToken != "" {
+			l.addReplyToken(e.ReplyToken, msg.chat(), e.Timestamp)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, true
+}
@@ -0,0 +1,82 @@
+package ambassador
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var (
+	fbTextPayload = []byte(`{"object":"page","entry":[{"id":"p1","time":1500000000000,"messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1500000000000,"message":{"mid":"m1","text":"hello","reply_to":{"mid":"m0"}}},
+		{"sender":{"id":"u2"},"recipient":{"id":"p1"},"timestamp":1500000000001,"message":{"mid":"m2","text":"hi"}}]}]}`)
+	fbMixedPayload = []byte(`{"object":"page","entry":[{"id":"p1","time":1500000000000,"messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1500000000000,"message":{"mid":"m1","text":"hello"}},
+		{"sender":{"id":"u2"},"recipient":{"id":"p1"},"timestamp":1500000000001,"postback":{"payload":"START"}}]}]}`)
+	lineTextPayload = []byte(`{"events":[
+		{"type":"message","replyToken":"r1","timestamp":1500000000000,"source":{"type":"group","userId":"u1","groupId":"g1"},
+			"message":{"type":"text","id":"m1","text":"hello","quotedMessageId":"m0"}}]}`)
+)
+
+func TestTranslateTextFastPath(t *testing.T) {
+	messages, ok := translateFBText(fbTextPayload)
+	if !ok || len(messages) != 2 {
+		t.Fatalf("expect text messages on the fast path, got %+v", messages)
+	}
+	expected := Message{SenderId: "u1", RecipientId: "p1", Timestamp: 1500000000000, MessageId: "m1", InReplyTo: "m0", Content: &TextContent{Text: "hello"}}
+	if !reflect.DeepEqual(messages[0], expected) {
+		t.Errorf("unexpected message: %+v", messages[0])
+	}
+	// the pooled scratch must not leak the reply of the last payload
+	if messages, _ = translateFBText(fbTextPayload[:0:0]); messages != nil {
+		t.Errorf("expect an empty body to fall back, got %+v", messages)
+	}
+	messages, _ = translateFBText([]byte(`{"entry":[{"messaging":[{"sender":{"id":"u3"},"message":{"mid":"m3","text":"yo"}}]}]}`))
+	if messages[0].InReplyTo != "" {
+		t.Errorf("expect a reset scratch, got %+v", messages[0])
+	}
+
+	if _, ok := translateFBText(fbMixedPayload); ok {
+		t.Error("expect a payload with a postback to fall back")
+	}
+	messages, err := (&FBAmbassador{}).Translate(bytes.NewReader(fbMixedPayload))
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("unexpected translation: %+v %v", messages, err)
+	}
+	if command, ok := messages[1].Content.(*CommandContent); !ok || command.Payload != "START" {
+		t.Errorf("unexpected postback: %+v", messages[1])
+	}
+
+	tokens := NewMemoryLineReplyTokenStore()
+	messages, ok = translateLineText(&LineAmbassador{ReplyTokens: tokens}, lineTextPayload)
+	if !ok || messages[0].ChatId != "g1" || messages[0].InReplyTo != "m0" || messages[0].Content.(*TextContent).Text != "hello" {
+		t.Errorf("unexpected line message: %+v", messages)
+	}
+	if info, ok, _ := tokens.GetReplyToken("r1"); !ok || info.To != "g1" {
+		t.Errorf("expect the reply token to be registered, got %+v", info)
+	}
+}
+
+func BenchmarkFBTranslateText(b *testing.B) {
+	a := &FBAmbassador{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		a.Translate(bytes.NewReader(fbTextPayload))
+	}
+}
+
+func BenchmarkFBTranslateMixed(b *testing.B) {
+	a := &FBAmbassador{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		a.Translate(bytes.NewReader(fbMixedPayload))
+	}
+}
+
+func BenchmarkLineTranslateText(b *testing.B) {
+	l := &LineAmbassador{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Translate(bytes.NewReader(lineTextPayload))
+	}
+}
@@ -0,0 +1,385 @@
+// Package flex is a fluent builder of LINE Flex Messages. Which components
+// may be nested in which is enforced by the types: a baseline box only takes
+// texts and icons, a hero only a box or an image, and spans only go into
+// texts.
+//
+//	bubble := flex.Bubble().
+//		Hero(flex.Image(url).Size(flex.Full).Cover()).
+//		Body(flex.VBox(
+//			flex.Text("Brown Cafe").Weight(flex.Bold).Size(flex.XL),
+//			flex.Baseline(flex.Icon(star), flex.Text("4.0").Size(flex.SM)),
+//		)).
+//		Footer(flex.VBox(flex.Button(flex.URIAction("Call", "tel:000")).Style(flex.Link)))
+//	line.SendFlex("Brown Cafe", bubble)
+package flex
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	Bold    = "bold"
+	Regular = "regular"
+
+	XS   = "xs"
+	SM   = "sm"
+	MD   = "md"
+	LG   = "lg"
+	XL   = "xl"
+	XXL  = "xxl"
+	Full = "full"
+
+	Primary   = "primary"
+	Secondary = "secondary"
+	Link      = "link"
+
+	maxBubbles = 12
+)
+
+// Container is a bubble or a carousel of bubbles.
+type Container interface {
+	container()
+}
+
+// HeroComponent is a component which can be the hero of a bubble.
+type HeroComponent interface {
+	hero()
+}
+
+// BoxChild is a component which can be put in a horizontal or vertical
+// box.
+type BoxChild interface {
+	boxChild()
+}
+
+// BaselineChild is a component which can be put in a baseline box.
+type BaselineChild interface {
+	baselineChild()
+}
+
+// Action is the action of a tapped component.
+type Action struct {
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+	URI   string `json:"uri,omitempty"`
+	Data  string `json:"data,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+func URIAction(label, uri string) *Action {
+	return &Action{Type: "uri", Label: label, URI: uri}
+}
+
+// PostbackAction sends data back as a CommandContent.
+func PostbackAction(label, data string) *Action {
+	return &Action{Type: "postback", Label: label, Data: data}
+}
+
+// MessageAction sends a text as the user.
+func MessageAction(label, text string) *Action {
+	return &Action{Type: "message", Label: label, Text: text}
+}
+
+type BubbleContainer struct {
+	size   string
+	header *Box
+	hero   HeroComponent
+	body   *Box
+	footer *Box
+	action *Action
+}
+
+func Bubble() *BubbleContainer {
+	return &BubbleContainer{}
+}
+
+func (b *BubbleContainer) container() {}
+
+func (b *BubbleContainer) Size(size string) *BubbleContainer        { b.size = size; return b }
+func (b *BubbleContainer) Header(box *Box) *BubbleContainer         { b.header = box; return b }
+func (b *BubbleContainer) Hero(hero HeroComponent) *BubbleContainer { b.hero = hero; return b }
+func (b *BubbleContainer) Body(box *Box) *BubbleContainer           { b.body = box; return b }
+func (b *BubbleContainer) Footer(box *Box) *BubbleContainer         { b.footer = box; return b }
+func (b *BubbleContainer) Action(a *Action) *BubbleContainer        { b.action = a; return b }
+
+func (b *BubbleContainer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string        `json:"type"`
+		Size   string        `json:"size,omitempty"`
+		Header *Box          `json:"header,omitempty"`
+		Hero   HeroComponent `json:"hero,omitempty"`
+		Body   *Box          `json:"body,omitempty"`
+		Footer *Box          `json:"footer,omitempty"`
+		Action *Action       `json:"action,omitempty"`
+	}{"bubble", b.size, b.header, b.hero, b.body, b.footer, b.action})
+}
+
+type CarouselContainer struct {
+	bubbles []*BubbleContainer
+}
+
+func Carousel(bubbles ...*BubbleContainer) *CarouselContainer {
+	return &CarouselContainer{bubbles: bubbles}
+}
+
+func (c *CarouselContainer) container() {}
+
+func (c *CarouselContainer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string             `json:"type"`
+		Contents []*BubbleContainer `json:"contents"`
+	}{"carousel", c.bubbles})
+}
+
+type Box struct {
+	layout          string
+	contents        []interface{}
+	spacing         string
+	margin          string
+	paddingAll      string
+	backgroundColor string
+	flex            *int
+	action          *Action
+}
+
+func newBox(layout string, n int) *Box {
+	return &Box{layout: layout, contents: make([]interface{}, 0, n)}
+}
+
+// VBox lays its children out vertically.
+func VBox(children ...BoxChild) *Box {
+	b := newBox("vertical", len(children))
+	for _, c := range children {
+		b.contents = append(b.contents, c)
+	}
+	return b
+}
+
+// HBox lays its children out horizontally.
+func HBox(children ...BoxChild) *Box {
+	b := newBox("horizontal", len(children))
+	for _, c := range children {
+		b.contents = append(b.contents, c)
+	}
+	return b
+}
+
+// Baseline lays texts and icons out horizontally on a common baseline.
+func Baseline(children ...BaselineChild) *Box {
+	b := newBox("baseline", len(children))
+	for _, c := range children {
+		b.contents = append(b.contents, c)
+	}
+	return b
+}
+
+func (b *Box) hero()     {}
+func (b *Box) boxChild() {}
+
+func (b *Box) Spacing(size string) *Box          { b.spacing = size; return b }
+func (b *Box) Margin(size string) *Box           { b.margin = size; return b }
+func (b *Box) Padding(size string) *Box          { b.paddingAll = size; return b }
+func (b *Box) BackgroundColor(color string) *Box { b.backgroundColor = color; return b }
+func (b *Box) Flex(n int) *Box                   { b.flex = &n; return b }
+func (b *Box) Action(a *Action) *Box             { b.action = a; return b }
+
+func (b *Box) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type            string        `json:"type"`
+		Layout          string        `json:"layout"`
+		Contents        []interface{} `json:"contents"`
+		Spacing         string        `json:"spacing,omitempty"`
+		Margin          string        `json:"margin,omitempty"`
+		PaddingAll      string        `json:"paddingAll,omitempty"`
+		BackgroundColor string        `json:"backgroundColor,omitempty"`
+		Flex            *int          `json:"flex,omitempty"`
+		Action          *Action       `json:"action,omitempty"`
+	}{"box", b.layout, b.contents, b.spacing, b.margin, b.paddingAll, b.backgroundColor, b.flex, b.action})
+}
+
+type TextComponent struct {
+	text   string
+	size   string
+	weight string
+	color  string
+	align  string
+	wrap   bool
+	flex   *int
+	spans  []*SpanComponent
+	action *Action
+}
+
+func Text(text string) *TextComponent {
+	return &TextComponent{text: text}
+}
+
+func (t *TextComponent) boxChild()      {}
+func (t *TextComponent) baselineChild() {}
+
+func (t *TextComponent) Size(size string) *TextComponent              { t.size = size; return t }
+func (t *TextComponent) Weight(weight string) *TextComponent          { t.weight = weight; return t }
+func (t *TextComponent) Color(color string) *TextComponent            { t.color = color; return t }
+func (t *TextComponent) Align(align string) *TextComponent            { t.align = align; return t }
+func (t *TextComponent) Wrap() *TextComponent                         { t.wrap = true; return t }
+func (t *TextComponent) Flex(n int) *TextComponent                    { t.flex = &n; return t }
+func (t *TextComponent) Spans(spans ...*SpanComponent) *TextComponent { t.spans = spans; return t }
+func (t *TextComponent) Action(a *Action) *TextComponent              { t.action = a; return t }
+
+func (t *TextComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string           `json:"type"`
+		Text     string           `json:"text"`
+		Size     string           `json:"size,omitempty"`
+		Weight   string           `json:"weight,omitempty"`
+		Color    string           `json:"color,omitempty"`
+		Align    string           `json:"align,omitempty"`
+		Wrap     bool             `json:"wrap,omitempty"`
+		Flex     *int             `json:"flex,omitempty"`
+		Contents []*SpanComponent `json:"contents,omitempty"`
+		Action   *Action          `json:"action,omitempty"`
+	}{"text", t.text, t.size, t.weight, t.color, t.align, t.wrap, t.flex, t.spans, t.action})
+}
+
+// SpanComponent is a differently styled part of a text.
+type SpanComponent struct {
+	text   string
+	size   string
+	weight string
+	color  string
+}
+
+func Span(text string) *SpanComponent {
+	return &SpanComponent{text: text}
+}
+
+func (s *SpanComponent) Size(size string) *SpanComponent     { s.size = size; return s }
+func (s *SpanComponent) Weight(weight string) *SpanComponent { s.weight = weight; return s }
+func (s *SpanComponent) Color(color string) *SpanComponent   { s.color = color; return s }
+
+func (s *SpanComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Text   string `json:"text"`
+		Size   string `json:"size,omitempty"`
+		Weight string `json:"weight,omitempty"`
+		Color  string `json:"color,omitempty"`
+	}{"span", s.text, s.size, s.weight, s.color})
+}
+
+type ImageComponent struct {
+	url         string
+	size        string
+	aspectRatio string
+	aspectMode  string
+	flex        *int
+	action      *Action
+}
+
+func Image(url string) *ImageComponent {
+	return &ImageComponent{url: url}
+}
+
+func (i *ImageComponent) hero()     {}
+func (i *ImageComponent) boxChild() {}
+
+func (i *ImageComponent) Size(size string) *ImageComponent         { i.size = size; return i }
+func (i *ImageComponent) AspectRatio(ratio string) *ImageComponent { i.aspectRatio = ratio; return i }
+func (i *ImageComponent) Cover() *ImageComponent                   { i.aspectMode = "cover"; return i }
+func (i *ImageComponent) Flex(n int) *ImageComponent               { i.flex = &n; return i }
+func (i *ImageComponent) Action(a *Action) *ImageComponent         { i.action = a; return i }
+
+func (i *ImageComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        string  `json:"type"`
+		URL         string  `json:"url"`
+		Size        string  `json:"size,omitempty"`
+		AspectRatio string  `json:"aspectRatio,omitempty"`
+		AspectMode  string  `json:"aspectMode,omitempty"`
+		Flex        *int    `json:"flex,omitempty"`
+		Action      *Action `json:"action,omitempty"`
+	}{"image", i.url, i.size, i.aspectRatio, i.aspectMode, i.flex, i.action})
+}
+
+// IconComponent is a small image which can only be put in a baseline box.
+type IconComponent struct {
+	url  string
+	size string
+}
+
+func Icon(url string) *IconComponent {
+	return &IconComponent{url: url}
+}
+
+func (i *IconComponent) baselineChild() {}
+
+func (i *IconComponent) Size(size string) *IconComponent { i.size = size; return i }
+
+func (i *IconComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+		Size string `json:"size,omitempty"`
+	}{"icon", i.url, i.size})
+}
+
+type ButtonComponent struct {
+	action *Action
+	style  string
+	height string
+	color  string
+}
+
+func Button(a *Action) *ButtonComponent {
+	return &ButtonComponent{action: a}
+}
+
+func (b *ButtonComponent) boxChild() {}
+
+func (b *ButtonComponent) Style(style string) *ButtonComponent   { b.style = style; return b }
+func (b *ButtonComponent) Height(height string) *ButtonComponent { b.height = height; return b }
+func (b *ButtonComponent) Color(color string) *ButtonComponent   { b.color = color; return b }
+
+func (b *ButtonComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string  `json:"type"`
+		Action *Action `json:"action"`
+		Style  string  `json:"style,omitempty"`
+		Height string  `json:"height,omitempty"`
+		Color  string  `json:"color,omitempty"`
+	}{"button", b.action, b.style, b.height, b.color})
+}
+
+type SeparatorComponent struct {
+	margin string
+}
+
+func Separator() *SeparatorComponent {
+	return &SeparatorComponent{}
+}
+
+func (s *SeparatorComponent) boxChild() {}
+
+func (s *SeparatorComponent) Margin(size string) *SeparatorComponent { s.margin = size; return s }
+
+func (s *SeparatorComponent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Margin string `json:"margin,omitempty"`
+	}{"separator", s.margin})
+}
+
+// Validate checks the constraints which the types can not express.
+func Validate(c Container) error {
+	switch v := c.(type) {
+	case *CarouselContainer:
+		if len(v.bubbles) == 0 || len(v.bubbles) > maxBubbles {
+			return fmt.Errorf("a flex carousel needs 1 to %d bubbles, got %d", maxBubbles, len(v.bubbles))
+		}
+	case *BubbleContainer:
+		if v.header == nil && v.hero == nil && v.body == nil && v.footer == nil {
+			return fmt.Errorf("a flex bubble needs a header, hero, body or footer")
+		}
+	}
+	return nil
+}
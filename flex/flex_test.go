@@ -0,0 +1,34 @@
+package flex
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBubble(t *testing.T) {
+	bubble := Bubble().
+		Hero(Image("https://example.com/cafe.png").Size(Full).Cover()).
+		Body(VBox(
+			Text("Brown Cafe").Weight(Bold).Size(XL),
+			Baseline(Icon("https://example.com/star.png"), Text("4.0").Size(SM)),
+		)).
+		Footer(VBox(Button(URIAction("Call", "tel:000")).Style(Link)))
+
+	b, err := json.Marshal(bubble)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"bubble",` +
+		`"hero":{"type":"image","url":"https://example.com/cafe.png","size":"full","aspectMode":"cover"},` +
+		`"body":{"type":"box","layout":"vertical","contents":[` +
+		`{"type":"text","text":"Brown Cafe","size":"xl","weight":"bold"},` +
+		`{"type":"box","layout":"baseline","contents":[{"type":"icon","url":"https://example.com/star.png"},{"type":"text","text":"4.0","size":"sm"}]}]},` +
+		`"footer":{"type":"box","layout":"vertical","contents":[{"type":"button","action":{"type":"uri","label":"Call","uri":"tel:000"},"style":"link"}]}}`
+	if string(b) != expected {
+		t.Errorf("unexpected bubble:\n%s", b)
+	}
+
+	if Validate(Carousel()) == nil {
+		t.Error("expect an empty carousel to be invalid")
+	}
+}
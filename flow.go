@@ -0,0 +1,174 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FlowAnyText is the key of FlowStep.Next which any text leads through.
+const FlowAnyText = "*"
+
+// FlowStep sends its messages and waits for the answer of the user. Next
+// maps the payloads of answers to the steps they lead to. A step without
+// Next ends its flow.
+type FlowStep struct {
+	Id       string            `json:"id"`
+	Messages []OutboundMessage `json:"messages"`
+	Next     map[string]string `json:"next,omitempty"`
+}
+
+// Flow is a dialog of steps, started by a payload or a text containing one
+// of its keywords. It starts from the first step.
+type Flow struct {
+	Id       string     `json:"id"`
+	Payload  string     `json:"payload,omitempty"`
+	Keywords []string   `json:"keywords,omitempty"`
+	Steps    []FlowStep `json:"steps"`
+}
+
+func (f *Flow) step(id string) *FlowStep {
+	for i := range f.Steps {
+		if f.Steps[i].Id == id {
+			return &f.Steps[i]
+		}
+	}
+	return nil
+}
+
+func (f *Flow) validate() error {
+	if f.Id == "" {
+		return fmt.Errorf("a flow has no id")
+	}
+	if len(f.Steps) == 0 {
+		return fmt.Errorf("flow %s has no step", f.Id)
+	}
+	if f.Payload == "" && len(f.Keywords) == 0 {
+		return fmt.Errorf("flow %s has no payload or keyword to start", f.Id)
+	}
+	ids := map[string]bool{}
+	for _, step := range f.Steps {
+		if step.Id == "" || ids[step.Id] {
+			return fmt.Errorf("flow %s has a step without a unique id: %q", f.Id, step.Id)
+		}
+		ids[step.Id] = true
+		if len(step.Messages) == 0 {
+			return fmt.Errorf("step %s of flow %s has no message", step.Id, f.Id)
+		}
+		if err := validateOutbound(step.Messages); err != nil {
+			return fmt.Errorf("step %s of flow %s: %s", step.Id, f.Id, err)
+		}
+	}
+	for _, step := range f.Steps {
+		for answer, next := range step.Next {
+			if !ids[next] {
+				return fmt.Errorf("answer %s of step %s of flow %s leads to an unknown step %s", answer, step.Id, f.Id, next)
+			}
+		}
+	}
+	return nil
+}
+
+// starts tells whether a message starts the flow.
+func (f *Flow) starts(msg Message) bool {
+	switch c := msg.Content.(type) {
+	case *CommandContent:
+		return f.Payload != "" && c.Payload == f.Payload
+	case *TextContent:
+		lower := strings.ToLower(c.Text)
+		for _, keyword := range f.Keywords {
+			if keyword != "" && strings.Contains(lower, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type flowSession struct {
+	flow string
+	step string
+}
+
+// FlowRunner is a handler which walks users through flows. The flows are
+// looked up on every message, so that reloaded flows take effect at once.
+// Users in a step which no longer exists start over.
+type FlowRunner struct {
+	sync.Mutex
+	flows    func() []Flow
+	sessions map[string]flowSession
+	// Fallback handles the messages which neither answer a step nor start
+	// a flow.
+	Fallback Handler
+}
+
+func NewFlowRunner(flows func() []Flow) *FlowRunner {
+	return &FlowRunner{flows: flows, sessions: map[string]flowSession{}}
+}
+
+// next returns the step an answer leads to in a session.
+func (r *FlowRunner) next(flows []Flow, session flowSession, msg Message) (flow *Flow, step *FlowStep) {
+	for i := range flows {
+		if flows[i].Id == session.flow {
+			flow = &flows[i]
+		}
+	}
+	if flow == nil {
+		return nil, nil
+	}
+	current := flow.step(session.step)
+	if current == nil {
+		return nil, nil
+	}
+	switch c := msg.Content.(type) {
+	case *CommandContent:
+		if id, ok := current.Next[c.Payload]; ok {
+			return flow, flow.step(id)
+		}
+	case *TextContent:
+		if id, ok := current.Next[FlowAnyText]; ok {
+			return flow, flow.step(id)
+		}
+	}
+	return nil, nil
+}
+
+func (r *FlowRunner) Handle(a Ambassador, msg Message) (err error) {
+	key := platformOf(a) + ":" + msg.chat()
+	flows := r.flows()
+
+	r.Lock()
+	session, ok := r.sessions[key]
+	r.Unlock()
+	var flow *Flow
+	var step *FlowStep
+	if ok {
+		flow, step = r.next(flows, session, msg)
+	}
+	if step == nil {
+		for i := range flows {
+			if flows[i].starts(msg) {
+				flow, step = &flows[i], &flows[i].Steps[0]
+				break
+			}
+		}
+	}
+	if step == nil {
+		if r.Fallback != nil {
+			return r.Fallback.Handle(a, msg)
+		}
+		return
+	}
+
+	r.Lock()
+	if len(step.Next) == 0 {
+		delete(r.sessions, key)
+	} else {
+		r.sessions[key] = flowSession{flow: flow.Id, step: step.Id}
+	}
+	r.Unlock()
+	if err = Messages(step.Messages...)(a); err != nil {
+		return
+	}
+	return a.Send(msg.ReplyTarget())
+}
@@ -0,0 +1,79 @@
+package ambassador
+
+import (
+	"sync"
+	"time"
+)
+
+// FreshnessWindow tells whether webhook events are recent enough to be
+// trusted, which protects against replays of correctly signed requests.
+// Replays within the window are told by their message ids, which are
+// remembered as long as their events are fresh.
+type FreshnessWindow struct {
+	sync.Mutex
+	// MaxAge is how old an event may be.
+	MaxAge time.Duration
+	// ClockSkew tolerates events a little in the future.
+	ClockSkew time.Duration
+	// FlagOnly keeps stale messages with Stale set instead of dropping them.
+	FlagOnly  bool
+	now       func() time.Time
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewFreshnessWindow(maxAge, clockSkew time.Duration) *FreshnessWindow {
+	return &FreshnessWindow{MaxAge: maxAge, ClockSkew: clockSkew, now: time.Now, seen: map[string]time.Time{}}
+}
+
+// Fresh checks the timestamp of a message, in milliseconds. Messages without
+// a timestamp are considered fresh.
+func (f *FreshnessWindow) Fresh(msg Message) bool {
+	if msg.Timestamp == 0 {
+		return true
+	}
+	t := time.Unix(0, msg.Timestamp*int64(time.Millisecond))
+	now := f.now()
+	return !t.Before(now.Add(-f.MaxAge-f.ClockSkew)) && !t.After(now.Add(f.ClockSkew))
+}
+
+// duplicate tells whether a message id was seen within the window, and
+// remembers it otherwise.
+func (f *FreshnessWindow) duplicate(msg Message) bool {
+	if msg.MessageId == "" {
+		return false
+	}
+	f.Lock()
+	defer f.Unlock()
+	now := f.now()
+	window := f.MaxAge + 2*f.ClockSkew
+	if now.Sub(f.lastSweep) > window {
+		for id, at := range f.seen {
+			if now.Sub(at) > window {
+				delete(f.seen, id)
+			}
+		}
+		f.lastSweep = now
+	}
+	key := msg.SenderId + ":" + msg.MessageId
+	if at, ok := f.seen[key]; ok && now.Sub(at) <= window {
+		return true
+	}
+	f.seen[key] = now
+	return false
+}
+
+// filter drops or flags stale and duplicate messages.
+func (f *FreshnessWindow) filter(messages []Message) []Message {
+	fresh := messages[:0]
+	for _, msg := range messages {
+		if !f.Fresh(msg) || f.duplicate(msg) {
+			if !f.FlagOnly {
+				continue
+			}
+			msg.Stale = true
+		}
+		fresh = append(fresh, msg)
+	}
+	return fresh
+}
@@ -0,0 +1,53 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreshnessWindow(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	f := NewFreshnessWindow(5*time.Minute, 30*time.Second)
+	f.now = func() time.Time { return now }
+	at := func(d time.Duration) int64 {
+		return now.Add(d).UnixNano() / int64(time.Millisecond)
+	}
+
+	cases := []struct {
+		name  string
+		msg   Message
+		fresh bool
+	}{
+		{"recent", Message{MessageId: "m1", Timestamp: at(-time.Minute)}, true},
+		{"within the skew", Message{MessageId: "m2", Timestamp: at(-5*time.Minute - 20*time.Second)}, true},
+		{"stale", Message{MessageId: "m3", Timestamp: at(-10 * time.Minute)}, false},
+		{"slightly ahead", Message{MessageId: "m4", Timestamp: at(20 * time.Second)}, true},
+		{"future-dated", Message{MessageId: "m5", Timestamp: at(time.Hour)}, false},
+		{"duplicate", Message{MessageId: "m1", Timestamp: at(-time.Minute)}, false},
+		{"same id of another sender", Message{SenderId: "u2", MessageId: "m1", Timestamp: at(-time.Minute)}, true},
+		{"without a timestamp", Message{MessageId: "m6"}, true},
+		{"without an id", Message{Timestamp: at(-time.Minute)}, true},
+		{"again without an id", Message{Timestamp: at(-time.Minute)}, true},
+	}
+	for _, c := range cases {
+		if fresh := len(f.filter([]Message{c.msg})) == 1; fresh != c.fresh {
+			t.Errorf("%s: expect fresh to be %v", c.name, c.fresh)
+		}
+	}
+
+	// a replay is stale by the time its id is forgotten
+	now = now.Add(7 * time.Minute)
+	if messages := f.filter([]Message{{MessageId: "m1", Timestamp: at(-8 * time.Minute)}}); len(messages) != 0 {
+		t.Errorf("expect a late replay to be dropped, got %+v", messages)
+	}
+	f.filter([]Message{{MessageId: "m9", Timestamp: at(0)}})
+	if _, ok := f.seen[":m9"]; !ok || len(f.seen) != 1 {
+		t.Errorf("expect the ids of stale events to be forgotten, got %v", f.seen)
+	}
+
+	f.FlagOnly = true
+	messages := f.filter([]Message{{MessageId: "m7", Timestamp: at(-time.Hour)}, {MessageId: "m8", Timestamp: at(0)}})
+	if len(messages) != 2 || !messages[0].Stale || messages[1].Stale {
+		t.Errorf("expect stale messages to be flagged, got %+v", messages)
+	}
+}
@@ -0,0 +1,110 @@
+package ambassador
+
+import (
+	"strings"
+	"time"
+)
+
+const FunnelPayloadPrefix = "AMBASSADOR_FUNNEL:"
+
+// AnalyticsEvent is an event of a user's journey through a bot.
+type AnalyticsEvent struct {
+	Name     string
+	SenderId string
+	Platform string
+	Funnel   string
+	Step     string
+	At       time.Time
+}
+
+// Analytics receives analytics events, e.g. to be forwarded to an analytics
+// service.
+type Analytics interface {
+	Track(e AnalyticsEvent)
+}
+
+type AnalyticsFunc func(e AnalyticsEvent)
+
+func (f AnalyticsFunc) Track(e AnalyticsEvent) {
+	f(e)
+}
+
+type funnelPayload struct {
+	Funnel  string `json:"f"`
+	Step    string `json:"s"`
+	Payload string `json:"p"`
+}
+
+// Funnel tags the payloads of a multi-step flow with the step they belong to,
+// so that the conversion through the flow can be measured by TrackFunnels.
+type Funnel struct {
+	Name  string
+	codec *PayloadCodec
+	// TTL expires tagged payloads. Tagged payloads never expire if it is
+	// zero.
+	TTL time.Duration
+}
+
+func NewFunnel(name string, codec *PayloadCodec) *Funnel {
+	return &Funnel{Name: name, codec: codec}
+}
+
+// Tag wraps a payload with a step of the funnel.
+func (f *Funnel) Tag(step, payload string) (string, error) {
+	encoded, err := f.codec.Encode(funnelPayload{Funnel: f.Name, Step: step, Payload: payload}, f.TTL)
+	if err != nil {
+		return "", err
+	}
+	return FunnelPayloadPrefix + encoded, nil
+}
+
+// Answers returns copies of the answers of a question with their payloads
+// tagged with a step of the funnel.
+func (f *Funnel) Answers(step string, answers []map[string]string) (tagged []map[string]string, err error) {
+	tagged = make([]map[string]string, 0, len(answers))
+	for _, answer := range answers {
+		t := map[string]string{}
+		for k, v := range answer {
+			t[k] = v
+		}
+		if payload, ok := answer["payload"]; ok {
+			if t["payload"], err = f.Tag(step, payload); err != nil {
+				return nil, err
+			}
+		}
+		tagged = append(tagged, t)
+	}
+	return
+}
+
+// TrackFunnels emits a "funnel_step" event when a tagged payload comes back
+// and restores the original payload, so that handlers and routes see the
+// payload they staged. Payloads which fail to verify are passed on as they
+// are.
+func TrackFunnels(codec *PayloadCodec, analytics Analytics) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			command, ok := msg.Content.(*CommandContent)
+			if !ok || !strings.HasPrefix(command.Payload, FunnelPayloadPrefix) {
+				return next.Handle(a, msg)
+			}
+			var p funnelPayload
+			if err := codec.Decode(strings.TrimPrefix(command.Payload, FunnelPayloadPrefix), &p); err != nil {
+				return next.Handle(a, msg)
+			}
+
+			analytics.Track(AnalyticsEvent{
+				Name:     "funnel_step",
+				SenderId: msg.SenderId,
+				Platform: platformOf(a),
+				Funnel:   p.Funnel,
+				Step:     p.Step,
+				At:       time.Now(),
+			})
+			restored := *command
+			restored.Payload = p.Payload
+			msg.Content = &restored
+			return next.Handle(a, msg)
+		})
+	}
+}
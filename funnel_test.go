@@ -0,0 +1,34 @@
+package ambassador
+
+import "testing"
+
+func TestTrackFunnels(t *testing.T) {
+	codec := NewPayloadCodec("secret")
+	funnel := NewFunnel("signup", codec)
+	answers, err := funnel.Answers("plan", []map[string]string{{"title": "Pro", "payload": "PLAN_PRO"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []AnalyticsEvent{}
+	router := NewRouter()
+	router.Use(TrackFunnels(codec, AnalyticsFunc(func(e AnalyticsEvent) {
+		events = append(events, e)
+	})))
+	routed := false
+	router.Payload("PLAN_PRO", HandlerFunc(func(a Ambassador, msg Message) error {
+		routed = true
+		return nil
+	}))
+
+	msg := Message{SenderId: "user", Content: &CommandContent{Payload: answers[0]["payload"]}}
+	if err := router.Handle(&recordAmbassador{}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if !routed {
+		t.Error("expect the original payload to be routed")
+	}
+	if len(events) != 1 || events[0].Funnel != "signup" || events[0].Step != "plan" || events[0].SenderId != "user" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.18
+
+package ambassador
+
+import (
+	"bytes"
+	"testing"
+)
+
+var fbSeeds = []string{
+	`{"object":"page","entry":[{"id":"1","time":1,"messaging":[{"sender":{"id":"u"},"recipient":{"id":"p"},"timestamp":1,"message":{"mid":"m","text":"hi"}}]}]}`,
+	`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u"},"message":{"attachments":[{"type":"location","payload":{"coordinates":{"lat":25.0,"long":121.5}}}]}}]}]}`,
+	`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u"},"postback":{"payload":"GET_STARTED","referral":{"ref":"abc","source":"SHORTLINK","type":"OPEN_THREAD"}}}]}]}`,
+	`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u"},"message":{"text":"x","quick_reply":{"payload":"AMBASSADOR_MORE_ANSWERS:1:3"}}}]}]}`,
+	`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u"},"messaging_feedback":{"feedback_screens":[{"screen_id":0,"questions":{"q":{"type":"csat","payload":"4","follow_up":{"type":"free_form","payload":"ok"}}}}]}}]}]}`,
+}
+
+var lineSeeds = []string{
+	`{"events":[{"replyToken":"r","type":"message","timestamp":1,"source":{"type":"user","userId":"u"},"message":{"id":"1","type":"text","text":"hi"}}]}`,
+	`{"events":[{"replyToken":"r","type":"message","source":{"type":"group","groupId":"g","userId":"u"},"message":{"id":"2","type":"location","latitude":25.0,"longitude":121.5}}]}`,
+	`{"events":[{"replyToken":"r","type":"postback","source":{"type":"user","userId":"u"},"postback":{"data":"BUY"}}]}`,
+	`{"events":[{"replyToken":"r","type":"follow","source":{"type":"user","userId":"u"}}]}`,
+}
+
+func FuzzFBTranslate(f *testing.F) {
+	for _, seed := range fbSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		NewFBAmbassador("token", nil).Translate(bytes.NewReader(payload))
+	})
+}
+
+func FuzzLineTranslate(f *testing.F) {
+	for _, seed := range lineSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		NewLineAmbassador("token", nil).Translate(bytes.NewReader(payload))
+	})
+}
@@ -0,0 +1,284 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// GenericSignatureHeader carries the hex HMAC-SHA256 of an outbound body by
+// the secret of a GenericAmbassador, prefixed with "sha256=".
+const GenericSignatureHeader = "X-Ambassador-Signature"
+
+// GenericInbound is the body of a request a custom channel posts to the
+// webhook. Every message has a type and the fields of the type:
+//
+//	{"type": "text", "text": "hi"}
+//	{"type": "command", "payload": "ORDER_NEW"}
+//	{"type": "location", "lat": 25.03, "lon": 121.56}
+//
+// Timestamps are in milliseconds.
+type GenericInbound struct {
+	Messages []GenericInboundMessage `json:"messages"`
+}
+
+type GenericInboundMessage struct {
+	Id        string  `json:"id"`
+	SenderId  string  `json:"sender_id"`
+	ChatId    string  `json:"chat_id,omitempty"`
+	ReplyTo   string  `json:"reply_to,omitempty"`
+	Timestamp int64   `json:"timestamp"`
+	Type      string  `json:"type"`
+	Text      string  `json:"text,omitempty"`
+	Payload   string  `json:"payload,omitempty"`
+	Lat       float64 `json:"lat,omitempty"`
+	Lon       float64 `json:"lon,omitempty"`
+}
+
+// GenericOutbound is the body posted to the url of a custom channel by Send.
+// Its messages are of the types "text", "question", "template" and
+// "typing", e.g.
+//
+//	{"type": "question", "text": "Size?", "answers": [{"title": "M", "payload": "SIZE_M"}]}
+//	{"type": "typing", "duration_ms": 1500}
+type GenericOutbound struct {
+	RecipientId string                   `json:"recipient_id"`
+	ReplyTo     string                   `json:"reply_to,omitempty"`
+	Messages    []GenericOutboundMessage `json:"messages"`
+}
+
+type GenericOutboundMessage struct {
+	Type     string           `json:"type"`
+	Text     string           `json:"text,omitempty"`
+	Answers  []GenericAnswer  `json:"answers,omitempty"`
+	Elements []GenericElement `json:"elements,omitempty"`
+	// DurationMs is how long a typing message shows the indicator before
+	// the next message. On turns the indicator on or off instead.
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	On         *bool `json:"on,omitempty"`
+}
+
+type GenericAnswer struct {
+	Title   string `json:"title"`
+	Payload string `json:"payload"`
+}
+
+type GenericElement struct {
+	Title    string          `json:"title"`
+	Text     string          `json:"text,omitempty"`
+	ImageUrl string          `json:"image_url,omitempty"`
+	ItemUrl  string          `json:"item_url,omitempty"`
+	Buttons  []GenericButton `json:"buttons,omitempty"`
+}
+
+// GenericButton is a button of an element. Its type is "url" or "postback",
+// whose data is a link or a payload.
+type GenericButton struct {
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Data  string `json:"data"`
+}
+
+// GenericAmbassador bridges a custom channel, e.g. an in-house chat widget,
+// by a stable JSON schema. The channel posts GenericInbound to the webhook
+// and receives GenericOutbound at its url.
+type GenericAmbassador struct {
+	sync.Mutex
+	url    string
+	secret string
+	// Name is the platform of the channel. It defaults to "generic".
+	Name         string
+	client       *http.Client
+	messages     []GenericOutboundMessage
+	lastMessages []interface{}
+	replyTo      string
+	correlation  string
+}
+
+// NewGenericAmbassador posts outbound messages to a url, signed by a secret
+// if it is not empty.
+func NewGenericAmbassador(url, secret string, client *http.Client) *GenericAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GenericAmbassador{url: url, secret: secret, Name: "generic", client: client}
+}
+
+func (g *GenericAmbassador) SetCorrelationId(id string) {
+	g.correlation = id
+}
+
+func (g *GenericAmbassador) Platform() string {
+	return g.Name
+}
+
+func (g *GenericAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var v GenericInbound
+	if err = decodeJSON(limitReader(r), &v); err != nil {
+		return
+	}
+	if len(v.Messages) > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
+
+	messages = make([]Message, 0, len(v.Messages))
+	for _, m := range v.Messages {
+		msg := Message{
+			SenderId:  m.SenderId,
+			ChatId:    m.ChatId,
+			MessageId: m.Id,
+			InReplyTo: m.ReplyTo,
+			Timestamp: m.Timestamp,
+		}
+		switch m.Type {
+		case "text":
+			msg.Content = &TextContent{Text: m.Text}
+		case "command":
+			msg.Content = &CommandContent{Payload: m.Payload}
+		case "location":
+			msg.Content = &LocationContent{Lat: m.Lat, Lon: m.Lon}
+		default:
+			return nil, fmt.Errorf("unknown generic message type: %s", m.Type)
+		}
+		messages = append(messages, msg)
+	}
+	return
+}
+
+func (g *GenericAmbassador) stage(m GenericOutboundMessage) {
+	g.Lock()
+	defer g.Unlock()
+	g.messages = append(g.messages, m)
+}
+
+func (g *GenericAmbassador) SendText(text string) (err error) {
+	g.stage(GenericOutboundMessage{Type: "text", Text: text})
+	return
+}
+
+func (g *GenericAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	m := GenericOutboundMessage{Type: "question", Text: text}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			m.Answers = append(m.Answers, GenericAnswer{Title: title, Payload: payload})
+		}
+	}
+	g.stage(m)
+	return
+}
+
+func (g *GenericAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	m := GenericOutboundMessage{Type: "template"}
+	for _, col := range colItems {
+		e := GenericElement{Title: col.Title, Text: col.Text, ImageUrl: col.ImageUrl, ItemUrl: col.ItemUrl}
+		for _, btn := range col.Buttons {
+			e.Buttons = append(e.Buttons, GenericButton{Label: btn.Label, Type: btn.Type, Data: btn.Data})
+		}
+		m.Elements = append(m.Elements, e)
+	}
+	g.stage(m)
+	return
+}
+
+// SendTyping stages a typing message turning the indicator on or off.
+func (g *GenericAmbassador) SendTyping(on bool) (err error) {
+	g.stage(GenericOutboundMessage{Type: "typing", On: &on})
+	return
+}
+
+// WithTyping stages a typing message for the channel to show before the
+// messages after it.
+func (g *GenericAmbassador) WithTyping(d time.Duration) (err error) {
+	g.stage(GenericOutboundMessage{Type: "typing", DurationMs: int64(d / time.Millisecond)})
+	return
+}
+
+// MarkRead is a no-op since the schema has no read receipts.
+func (g *GenericAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (g *GenericAmbassador) ReplyTo(messageId string) (err error) {
+	g.Lock()
+	defer g.Unlock()
+	g.replyTo = messageId
+	return
+}
+
+func (g *GenericAmbassador) cleanMessage() {
+	g.Lock()
+	defer g.Unlock()
+	g.lastMessages = make([]interface{}, 0, len(g.messages))
+	for _, m := range g.messages {
+		g.lastMessages = append(g.lastMessages, m)
+	}
+	g.messages = nil
+	g.replyTo = ""
+}
+
+func (g *GenericAmbassador) GetLastSent() []interface{} {
+	return g.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (g *GenericAmbassador) Discard() {
+	g.Lock()
+	last := g.lastMessages
+	g.Unlock()
+	g.cleanMessage()
+	g.Lock()
+	g.lastMessages = last
+	g.Unlock()
+}
+
+// GenericSignature is the value of GenericSignatureHeader of a body.
+func GenericSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts the staged messages to the url at once.
+func (g *GenericAmbassador) Send(recipientId string) (err error) {
+	defer g.cleanMessage()
+	if len(g.messages) == 0 {
+		return
+	}
+	b, err := jsonCodec.Marshal(&GenericOutbound{RecipientId: recipientId, ReplyTo: g.replyTo, Messages: g.messages})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", g.url, bytes.NewBuffer(b))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.secret != "" {
+		req.Header.Set(GenericSignatureHeader, GenericSignature(g.secret, b))
+	}
+	setCorrelationHeader(req, g.correlation)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to post to the generic channel. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
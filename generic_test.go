@@ -0,0 +1,61 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestGenericTranslate(t *testing.T) {
+	g := NewGenericAmbassador("https://widget.example.com/outbound", "", nil)
+	messages, err := g.Translate(strings.NewReader(`{"messages":[
+		{"id":"m1","sender_id":"u1","timestamp":1700000000000,"type":"text","text":"hi"},
+		{"id":"m2","sender_id":"u1","chat_id":"room1","reply_to":"o1","type":"command","payload":"SIZE_M"},
+		{"id":"m3","sender_id":"u1","type":"location","lat":25.03,"lon":121.56}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "hi" || messages[0].Timestamp != 1700000000000 {
+		t.Errorf("unexpected text: %+v", messages[0])
+	}
+	if command, ok := messages[1].Content.(*CommandContent); !ok || command.Payload != "SIZE_M" || messages[1].ChatId != "room1" || messages[1].InReplyTo != "o1" {
+		t.Errorf("unexpected command: %+v", messages[1])
+	}
+	if loc, ok := messages[2].Content.(*LocationContent); !ok || loc.Lon != 121.56 {
+		t.Errorf("unexpected location: %+v", messages[2])
+	}
+	if _, err := g.Translate(strings.NewReader(`{"messages":[{"type":"video"}]}`)); err == nil {
+		t.Error("expect an unknown type to fail")
+	}
+}
+
+func TestGenericSend(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	g := NewGenericAmbassador("https://widget.example.com/outbound", "secret", server.Client())
+
+	g.WithTyping(0)
+	g.AskQuestion("Size?", []map[string]string{{"title": "M", "payload": "SIZE_M"}})
+	g.SendTemplate([]Carousel{{Title: "Mug", Buttons: []CarouselButton{{Label: "Buy", Type: "postback", Data: "BUY"}}}})
+	g.ReplyTo("m1")
+	if err := g.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/outbound" {
+		t.Fatalf("expect one post to the url, got %+v", requests)
+	}
+	if sig := requests[0].Header.Get(GenericSignatureHeader); sig != GenericSignature("secret", requests[0].Body) {
+		t.Errorf("unexpected signature: %s", sig)
+	}
+	var out GenericOutbound
+	json.Unmarshal(requests[0].Body, &out)
+	if out.RecipientId != "u1" || out.ReplyTo != "m1" || len(out.Messages) != 3 {
+		t.Fatalf("unexpected body: %s", requests[0].Body)
+	}
+	if out.Messages[0].Type != "typing" || out.Messages[1].Answers[0].Payload != "SIZE_M" || out.Messages[2].Elements[0].Buttons[0].Data != "BUY" {
+		t.Errorf("unexpected messages: %s", requests[0].Body)
+	}
+}
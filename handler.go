@@ -0,0 +1,13 @@
+package ambassador
+
+// Handler processes an inbound message. Replies are staged on the
+// ambassador and sent by the handler.
+type Handler interface {
+	Handle(a Ambassador, msg Message) error
+}
+
+type HandlerFunc func(a Ambassador, msg Message) error
+
+func (f HandlerFunc) Handle(a Ambassador, msg Message) error {
+	return f(a, msg)
+}
@@ -0,0 +1,191 @@
+package ambassador
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	FBMeURI        = "https://graph.facebook.com/v2.6/me"
+	LineBotInfoURI = "https://api.line.me/v2/bot/info"
+)
+
+// Pinger is implemented by ambassadors which can validate their credentials
+// by a cheap API call.
+type Pinger interface {
+	Ping() (err error)
+}
+
+// Ping reads the page of the token, which fails once the token expires.
+func (a *FBAmbassador) Ping() (err error) {
+	req, _ := http.NewRequest("GET", FBMeURI+"?access_token="+url.QueryEscape(a.token), nil)
+	setCorrelationHeader(req, a.correlation)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fail to ping facebook. status: %s", resp.Status)
+	}
+	return
+}
+
+// Ping reads the info of the bot, which fails once the channel token
+// expires.
+func (l *LineAmbassador) Ping() (err error) {
+	return l.do("GET", LineBotInfoURI, nil, nil)
+}
+
+// Probe checks a channel end to end.
+type Probe func() error
+
+// PingProbe validates the credentials of an ambassador.
+func PingProbe(p Pinger) Probe {
+	return p.Ping
+}
+
+// CanaryProbe sends a canary message to a test recipient, which catches a
+// misconfigured channel that a ping does not, e.g. a page whose messaging
+// is blocked.
+func CanaryProbe(newAmbassador func() Ambassador, recipientId, text string) Probe {
+	return func() (err error) {
+		a := newAmbassador()
+		if err = a.SendText(text); err != nil {
+			return
+		}
+		return a.Send(recipientId)
+	}
+}
+
+// ProbeResult is the last result of a probe.
+type ProbeResult struct {
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Since is when the probe became healthy or unhealthy.
+	Since time.Time `json:"since"`
+}
+
+// Heartbeat runs probes periodically and exposes their results as metrics
+// and as a health endpoint, so that silently expired tokens are caught
+// before users notice.
+type Heartbeat struct {
+	sync.Mutex
+	Interval time.Duration
+	Metrics  Metrics
+	Logger   Logger
+	probes   map[string]Probe
+	results  map[string]ProbeResult
+}
+
+func NewHeartbeat(interval time.Duration) *Heartbeat {
+	return &Heartbeat{
+		Interval: interval,
+		probes:   map[string]Probe{},
+		results:  map[string]ProbeResult{},
+	}
+}
+
+// Add adds a probe by a name, e.g. "facebook".
+func (h *Heartbeat) Add(name string, probe Probe) {
+	h.Lock()
+	defer h.Unlock()
+	h.probes[name] = probe
+}
+
+// Tick runs every probe once.
+func (h *Heartbeat) Tick() {
+	h.Lock()
+	names := make([]string, 0, len(h.probes))
+	for name := range h.probes {
+		names = append(names, name)
+	}
+	h.Unlock()
+	sort.Strings(names)
+
+	metrics := h.Metrics
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	logger := h.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	for _, name := range names {
+		h.Lock()
+		probe := h.probes[name]
+		h.Unlock()
+
+		err := probe()
+		now := time.Now()
+		tags := map[string]string{"probe": name}
+		result := ProbeResult{Healthy: err == nil, CheckedAt: now, Since: now}
+		if err != nil {
+			result.Error = err.Error()
+			metrics.Gauge("ambassador.heartbeat.healthy", 0, tags)
+			logger.Printf("ambassador: heartbeat probe %s fails: %s", name, err)
+		} else {
+			metrics.Gauge("ambassador.heartbeat.healthy", 1, tags)
+		}
+
+		h.Lock()
+		if last, ok := h.results[name]; ok && last.Healthy == result.Healthy {
+			result.Since = last.Since
+		}
+		h.results[name] = result
+		h.Unlock()
+	}
+}
+
+// Results returns the last results of the probes by their names.
+func (h *Heartbeat) Results() map[string]ProbeResult {
+	h.Lock()
+	defer h.Unlock()
+	results := make(map[string]ProbeResult, len(h.results))
+	for name, r := range h.results {
+		results[name] = r
+	}
+	return results
+}
+
+// Healthy tells if every probe passed its last run.
+func (h *Heartbeat) Healthy() bool {
+	for _, r := range h.Results() {
+		if !r.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// Run runs the probes right away and then every interval until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context) error {
+	h.Tick()
+	ticker := time.NewTicker(h.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			h.Tick()
+		}
+	}
+}
+
+// ServeHTTP responds the results of the probes as JSON, with 503 if any of
+// them fails.
+func (h *Heartbeat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !h.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h.Results())
+}
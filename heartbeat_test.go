@@ -0,0 +1,38 @@
+package ambassador
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestHeartbeat(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+
+	expired := errors.New("token expired")
+	h := NewHeartbeat(0)
+	h.Logger = testLogger{t}
+	h.Add("facebook", PingProbe(NewFBAmbassador("token", server.Client())))
+	h.Add("canary", CanaryProbe(func() Ambassador { return NewFBAmbassador("token", server.Client()) }, "tester", "ping"))
+	h.Add("line", func() error { return expired })
+	h.Tick()
+
+	results := h.Results()
+	if !results["facebook"].Healthy || !results["canary"].Healthy || results["line"].Error != "token expired" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if requests := server.Requests(); len(requests) != 2 || requests[0].Path != "/v2.6/me/messages" || requests[1].Path != "/v2.6/me" {
+		t.Errorf("expect a canary message and a ping, got %+v", requests)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "token expired") {
+		t.Errorf("expect the failing probe to be served, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,68 @@
+package ambassador
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// recordAmbassador is an in-memory ambassador which records sent texts as
+// "recipient:text". Sends fail with fail if it is set.
+type recordAmbassador struct {
+	sync.Mutex
+	staged   []string
+	sent     []string
+	lastSent []interface{}
+	fail     error
+}
+
+func (r *recordAmbassador) Translate(io.Reader) ([]Message, error) { return nil, nil }
+
+func (r *recordAmbassador) AskQuestion(text string, answers []map[string]string) error {
+	return r.SendText(text)
+}
+
+func (r *recordAmbassador) SendText(text string) error {
+	r.Lock()
+	defer r.Unlock()
+	r.staged = append(r.staged, text)
+	return nil
+}
+
+func (r *recordAmbassador) GetLastSent() []interface{} {
+	r.Lock()
+	defer r.Unlock()
+	return r.lastSent
+}
+
+func (r *recordAmbassador) SendTemplate(elements interface{}) error {
+	return r.SendText("template")
+}
+
+func (r *recordAmbassador) SendTyping(on bool) error { return nil }
+
+func (r *recordAmbassador) WithTyping(d time.Duration) error { return nil }
+
+func (r *recordAmbassador) MarkRead(msg Message) error { return nil }
+
+func (r *recordAmbassador) Discard() {
+	r.Lock()
+	defer r.Unlock()
+	r.staged = nil
+}
+
+func (r *recordAmbassador) Send(recipientId string) error {
+	r.Lock()
+	defer r.Unlock()
+	if r.fail != nil {
+		r.staged = nil
+		return r.fail
+	}
+	r.lastSent = nil
+	for _, text := range r.staged {
+		r.sent = append(r.sent, recipientId+":"+text)
+		r.lastSent = append(r.lastSent, text)
+	}
+	r.staged = nil
+	return nil
+}
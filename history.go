@@ -0,0 +1,94 @@
+package ambassador
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+)
+
+// History caches the recent messages of the most recently active
+// conversations in memory, so that handlers can refer to what a user said
+// earlier without a persistence layer. It is a TranscriptStore and is
+// usually filled by the Transcript middleware.
+type History struct {
+	sync.Mutex
+	conversations   int
+	perConversation int
+	order           *list.List
+	entries         map[string]*list.Element
+}
+
+type conversationHistory struct {
+	userId  string
+	entries []*TranscriptEntry
+}
+
+// NewHistory returns a cache of up to perConversation messages for each of
+// up to conversations conversations. The least recently active conversation
+// is evicted first.
+func NewHistory(conversations, perConversation int) *History {
+	return &History{
+		conversations:   conversations,
+		perConversation: perConversation,
+		order:           list.New(),
+		entries:         map[string]*list.Element{},
+	}
+}
+
+func (h *History) Append(e *TranscriptEntry) error {
+	h.Lock()
+	defer h.Unlock()
+
+	el, ok := h.entries[e.UserId]
+	if ok {
+		h.order.MoveToFront(el)
+	} else {
+		el = h.order.PushFront(&conversationHistory{userId: e.UserId})
+		h.entries[e.UserId] = el
+		if h.order.Len() > h.conversations {
+			oldest := h.order.Back()
+			h.order.Remove(oldest)
+			delete(h.entries, oldest.Value.(*conversationHistory).userId)
+		}
+	}
+
+	c := el.Value.(*conversationHistory)
+	c.entries = append(c.entries, e)
+	if len(c.entries) > h.perConversation {
+		c.entries = c.entries[len(c.entries)-h.perConversation:]
+	}
+	return nil
+}
+
+// LastN returns the last n cached messages of a user, the oldest first.
+func (h *History) LastN(userId string, n int) []*TranscriptEntry {
+	h.Lock()
+	defer h.Unlock()
+
+	el, ok := h.entries[userId]
+	if !ok {
+		return nil
+	}
+	entries := el.Value.(*conversationHistory).entries
+	if n < len(entries) {
+		entries = entries[len(entries)-n:]
+	}
+	return append([]*TranscriptEntry{}, entries...)
+}
+
+// Query returns the cached entries matching a query, the oldest first.
+func (h *History) Query(q TranscriptQuery) ([]*TranscriptEntry, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	entries := []*TranscriptEntry{}
+	for _, el := range h.entries {
+		for _, e := range el.Value.(*conversationHistory).entries {
+			if q.match(e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries, nil
+}
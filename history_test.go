@@ -0,0 +1,35 @@
+package ambassador
+
+import (
+	"testing"
+)
+
+func TestHistoryLastN(t *testing.T) {
+	history := NewHistory(2, 3)
+	record := Transcript(TranscriptOptions{Store: history})
+	var earlier []*TranscriptEntry
+	h := record(HandlerFunc(func(a Ambassador, msg Message) error {
+		earlier = history.LastN(msg.SenderId, 2)
+		return nil
+	}))
+
+	for _, text := range []string{"one", "two", "three", "four"} {
+		h.Handle(&recordAmbassador{}, Message{SenderId: "u1", Content: &TextContent{Text: text}})
+	}
+	if len(earlier) != 2 || earlier[0].Text != "three" || earlier[1].Text != "four" {
+		t.Fatalf("expect the last two messages, got %+v", earlier)
+	}
+	if all := history.LastN("u1", 10); len(all) != 3 || all[0].Text != "two" {
+		t.Errorf("expect three messages kept, got %+v", all)
+	}
+
+	h.Handle(&recordAmbassador{}, Message{SenderId: "u2", Content: &TextContent{Text: "hi"}})
+	h.Handle(&recordAmbassador{}, Message{SenderId: "u1", Content: &TextContent{Text: "five"}})
+	h.Handle(&recordAmbassador{}, Message{SenderId: "u3", Content: &TextContent{Text: "hey"}})
+	if history.LastN("u2", 1) != nil {
+		t.Errorf("expect the least recently active conversation to be evicted")
+	}
+	if last := history.LastN("u1", 1); len(last) != 1 || last[0].Text != "five" {
+		t.Errorf("unexpected history of u1: %+v", last)
+	}
+}
@@ -0,0 +1,82 @@
+package ambassador
+
+import "time"
+
+// InlineQueryContent is a query typed after the username of the bot in any
+// chat, e.g. a Telegram inline query. It is answered by AnswerInlineQuery
+// rather than by sending messages.
+type InlineQueryContent struct {
+	Id    string
+	Query string
+	// Offset is the NextOffset of the previous answer when the user scrolls
+	// for more results.
+	Offset   string
+	Location *LocationContent
+}
+
+// InlineResult is a result of an inline query. Text is sent to the chat on
+// behalf of the user when the result is chosen.
+type InlineResult struct {
+	Id          string
+	Title       string
+	Description string
+	ImageUrl    string
+	Text        string
+	Buttons     []CarouselButton
+}
+
+type InlineAnswerOptions struct {
+	// NextOffset is passed back as the Offset of the query for more results.
+	// It is empty when there are no more results.
+	NextOffset string
+	// CacheTime is how long the platform may cache the results.
+	CacheTime time.Duration
+	// Personal keeps the cached results to the user who queried.
+	Personal bool
+}
+
+// InlineAnswerer is implemented by ambassadors which can answer inline
+// queries.
+type InlineAnswerer interface {
+	AnswerInlineQuery(queryId string, results []InlineResult, opts InlineAnswerOptions) (err error)
+}
+
+// AnswerInlineQuery answers an inline query if the platform supports it, or
+// returns ErrUnsupported.
+func AnswerInlineQuery(a Ambassador, queryId string, results []InlineResult, opts InlineAnswerOptions) error {
+	if i, ok := unwrap(a).(InlineAnswerer); ok {
+		return i.AnswerInlineQuery(queryId, results, opts)
+	}
+	return ErrUnsupported
+}
+
+// CallbackAnswer acknowledges a button press. An empty answer only stops the
+// loading indicator of the button.
+type CallbackAnswer struct {
+	Text string
+	// Alert shows the text in a dialog instead of a notification.
+	Alert bool
+	// Url is opened by the client, e.g. to start a game.
+	Url       string
+	CacheTime time.Duration
+}
+
+// CallbackAnswerer is implemented by ambassadors whose button presses have
+// to be answered, e.g. answerCallbackQuery of Telegram.
+type CallbackAnswerer interface {
+	AnswerCallback(callbackId string, answer CallbackAnswer) (err error)
+}
+
+// AnswerCallback answers the button press of a message. Messages which are
+// not button presses to be answered are ignored, so that handlers can answer
+// every command regardless of the platform.
+func AnswerCallback(a Ambassador, msg Message, answer CallbackAnswer) error {
+	c, ok := msg.Content.(*CommandContent)
+	if !ok || c.CallbackId == "" {
+		return nil
+	}
+	if ca, ok := unwrap(a).(CallbackAnswerer); ok {
+		return ca.AnswerCallback(c.CallbackId, answer)
+	}
+	return ErrUnsupported
+}
@@ -0,0 +1,66 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+// answeringAmbassador answers inline queries and button presses like
+// Telegram does.
+type answeringAmbassador struct {
+	*MockAmbassador
+	queryId   string
+	results   []InlineResult
+	opts      InlineAnswerOptions
+	callbacks map[string]CallbackAnswer
+}
+
+func (a *answeringAmbassador) AnswerInlineQuery(queryId string, results []InlineResult, opts InlineAnswerOptions) (err error) {
+	a.queryId, a.results, a.opts = queryId, results, opts
+	return
+}
+
+func (a *answeringAmbassador) AnswerCallback(callbackId string, answer CallbackAnswer) (err error) {
+	a.callbacks[callbackId] = answer
+	return
+}
+
+func TestAnswerInlineQuery(t *testing.T) {
+	a := &answeringAmbassador{MockAmbassador: NewMockAmbassador(), callbacks: map[string]CallbackAnswer{}}
+	results := []InlineResult{{Id: "1", Title: "Curry", Text: "I want curry"}}
+	opts := InlineAnswerOptions{NextOffset: "10", CacheTime: time.Minute, Personal: true}
+	if err := AnswerInlineQuery(&sendTracker{Ambassador: a}, "q1", results, opts); err != nil {
+		t.Fatal(err)
+	}
+	if a.queryId != "q1" || len(a.results) != 1 || a.results[0].Title != "Curry" || a.opts != opts {
+		t.Errorf("unexpected answer: %s %+v %+v", a.queryId, a.results, a.opts)
+	}
+	if err := AnswerInlineQuery(NewMockAmbassador(), "q1", results, opts); err != ErrUnsupported {
+		t.Errorf("expect an unsupported platform to be refused, got %v", err)
+	}
+}
+
+func TestAnswerCallback(t *testing.T) {
+	a := &answeringAmbassador{MockAmbassador: NewMockAmbassador(), callbacks: map[string]CallbackAnswer{}}
+	press := Message{Content: &CommandContent{Payload: "VOTE", CallbackId: "cb1"}}
+	if err := AnswerCallback(&sendTracker{Ambassador: a}, press, CallbackAnswer{Text: "Voted", Alert: true}); err != nil {
+		t.Fatal(err)
+	}
+	if answer, ok := a.callbacks["cb1"]; !ok || answer.Text != "Voted" || !answer.Alert {
+		t.Errorf("unexpected callback answers: %+v", a.callbacks)
+	}
+
+	typed := Message{Content: &CommandContent{Payload: "VOTE"}}
+	if err := AnswerCallback(a, typed, CallbackAnswer{}); err != nil {
+		t.Errorf("expect a typed command to be ignored, got %v", err)
+	}
+	if err := AnswerCallback(a, Message{Content: &TextContent{Text: "hi"}}, CallbackAnswer{}); err != nil {
+		t.Errorf("expect a text to be ignored, got %v", err)
+	}
+	if len(a.callbacks) != 1 {
+		t.Errorf("expect only the button press to be answered, got %+v", a.callbacks)
+	}
+	if err := AnswerCallback(NewMockAmbassador(), press, CallbackAnswer{}); err != ErrUnsupported {
+		t.Errorf("expect an unsupported platform to be refused, got %v", err)
+	}
+}
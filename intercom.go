@@ -0,0 +1,311 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IntercomBaseURI = "https://api.intercom.io/"
+	IntercomVersion = "2.10"
+)
+
+// IntercomPart is a part of a conversation, or its source.
+type IntercomPart struct {
+	Id        string `json:"id"`
+	PartType  string `json:"part_type"`
+	Body      string `json:"body"`
+	CreatedAt int64  `json:"created_at"`
+	Author    struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+	} `json:"author"`
+	ReplyOptions []struct {
+		Text     string `json:"text"`
+		UUID     string `json:"uuid"`
+		Selected bool   `json:"selected"`
+	} `json:"reply_options"`
+}
+
+// IntercomNotification is a webhook notification of a conversation topic,
+// e.g. "conversation.user.replied".
+type IntercomNotification struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	Data  struct {
+		Item struct {
+			Type              string       `json:"type"`
+			Id                string       `json:"id"`
+			CreatedAt         int64        `json:"created_at"`
+			Source            IntercomPart `json:"source"`
+			ConversationParts struct {
+				ConversationParts []IntercomPart `json:"conversation_parts"`
+			} `json:"conversation_parts"`
+		} `json:"item"`
+	} `json:"data"`
+}
+
+// intercomMessage is a staged reply, or a pause of WithTyping if it has no
+// body.
+type intercomMessage struct {
+	Body         string              `json:"body"`
+	ReplyOptions []map[string]string `json:"reply_options,omitempty"`
+	pause        time.Duration
+}
+
+// IntercomAmbassador reads conversation webhooks of Intercom and replies as
+// an admin, e.g. a bot teammate. The chat and the recipient of a message is
+// its conversation.
+type IntercomAmbassador struct {
+	sync.Mutex
+	token        string
+	adminId      string
+	client       *http.Client
+	messages     []intercomMessage
+	lastMessages []interface{}
+	correlation  string
+}
+
+func NewIntercomAmbassador(token, adminId string, client *http.Client) *IntercomAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &IntercomAmbassador{token: token, adminId: adminId, client: client}
+}
+
+func (i *IntercomAmbassador) SetCorrelationId(id string) {
+	i.correlation = id
+}
+
+func (i *IntercomAmbassador) Platform() string {
+	return "intercom"
+}
+
+var intercomBreaks = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "</p><p>", "\n")
+
+// intercomText turns the html body of a part into plain text.
+func intercomText(body string) string {
+	return strings.TrimSpace(stripTags(intercomBreaks.Replace(body)))
+}
+
+// Translate turns the parts written by users into messages. A selected
+// reply option comes back as a CommandContent of its payload.
+func (i *IntercomAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var n IntercomNotification
+	if err = decodeJSON(limitReader(r), &n); err != nil {
+		return
+	}
+	messages = []Message{}
+	item := n.Data.Item
+	if n.Type != "notification_event" || item.Type != "conversation" {
+		return
+	}
+
+	parts := item.ConversationParts.ConversationParts
+	if n.Topic == "conversation.user.created" {
+		source := item.Source
+		if source.CreatedAt == 0 {
+			source.CreatedAt = item.CreatedAt
+		}
+		parts = append([]IntercomPart{source}, parts...)
+	}
+	for _, part := range parts {
+		if part.Author.Type != "user" && part.Author.Type != "lead" {
+			continue
+		}
+		msg := Message{
+			SenderId:  part.Author.Id,
+			ChatId:    item.Id,
+			MessageId: part.Id,
+			Timestamp: part.CreatedAt * 1000,
+		}
+		for _, option := range part.ReplyOptions {
+			if option.Selected {
+				msg.Content = &CommandContent{Payload: option.UUID}
+			}
+		}
+		if msg.Content == nil {
+			text := intercomText(part.Body)
+			if text == "" {
+				continue
+			}
+			msg.Content = &TextContent{Text: text}
+		}
+		messages = append(messages, msg)
+	}
+	return
+}
+
+func (i *IntercomAmbassador) call(method, path string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest(method, IntercomBaseURI+path, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+i.token)
+	req.Header.Set("Intercom-Version", IntercomVersion)
+	setCorrelationHeader(req, i.correlation)
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to call intercom. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (i *IntercomAmbassador) stage(m intercomMessage) {
+	i.Lock()
+	defer i.Unlock()
+	i.messages = append(i.messages, m)
+}
+
+func intercomParagraphs(text string) string {
+	lines := strings.Split(html.EscapeString(text), "\n")
+	return "<p>" + strings.Join(lines, "</p><p>") + "</p>"
+}
+
+func (i *IntercomAmbassador) SendText(text string) (err error) {
+	i.stage(intercomMessage{Body: intercomParagraphs(text)})
+	return
+}
+
+// AskQuestion sends the answers as reply options, whose uuids are the
+// payloads of the answers.
+func (i *IntercomAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	options := []map[string]string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			options = append(options, map[string]string{"text": title, "uuid": payload})
+		}
+	}
+	i.stage(intercomMessage{Body: intercomParagraphs(text), ReplyOptions: options})
+	return
+}
+
+// SendTemplate sends every element as a reply with its image, title, text
+// and links. Postback buttons become reply options of the element.
+func (i *IntercomAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	for _, col := range colItems {
+		body := []string{}
+		if col.ImageUrl != "" {
+			body = append(body, fmt.Sprintf(`<img src="%s">`, html.EscapeString(col.ImageUrl)))
+		}
+		title := html.EscapeString(col.Title)
+		if col.ItemUrl != "" {
+			title = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(col.ItemUrl), title)
+		}
+		body = append(body, "<p><b>"+title+"</b></p>")
+		if col.Text != "" {
+			body = append(body, intercomParagraphs(col.Text))
+		}
+		options := []map[string]string{}
+		for _, btn := range col.Buttons {
+			switch btn.Type {
+			case "url":
+				body = append(body, fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(btn.Data), html.EscapeString(btn.Label)))
+			case "postback":
+				options = append(options, map[string]string{"text": btn.Label, "uuid": btn.Data})
+			}
+		}
+		m := intercomMessage{Body: strings.Join(body, "")}
+		if len(options) > 0 {
+			m.ReplyOptions = options
+		}
+		i.stage(m)
+	}
+	return
+}
+
+// SendTyping is a no-op since admins can not show typing by the API.
+func (i *IntercomAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the replies staged after it.
+func (i *IntercomAmbassador) WithTyping(d time.Duration) (err error) {
+	i.stage(intercomMessage{pause: d})
+	return
+}
+
+// MarkRead marks the conversation of a message as read.
+func (i *IntercomAmbassador) MarkRead(msg Message) (err error) {
+	return i.call("PUT", "conversations/"+url.PathEscape(msg.ChatId), map[string]interface{}{"read": true})
+}
+
+// ReplyTo is a no-op since conversations of Intercom have no threads.
+func (i *IntercomAmbassador) ReplyTo(messageId string) (err error) {
+	return
+}
+
+func (i *IntercomAmbassador) cleanMessage() {
+	i.Lock()
+	defer i.Unlock()
+	i.lastMessages = make([]interface{}, 0, len(i.messages))
+	for _, m := range i.messages {
+		if m.Body != "" {
+			i.lastMessages = append(i.lastMessages, m)
+		}
+	}
+	i.messages = nil
+}
+
+func (i *IntercomAmbassador) GetLastSent() []interface{} {
+	return i.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (i *IntercomAmbassador) Discard() {
+	i.Lock()
+	last := i.lastMessages
+	i.Unlock()
+	i.cleanMessage()
+	i.Lock()
+	i.lastMessages = last
+	i.Unlock()
+}
+
+// Send replies the staged messages to a conversation.
+func (i *IntercomAmbassador) Send(recipientId string) (err error) {
+	defer i.cleanMessage()
+	for _, m := range i.messages {
+		if m.Body == "" {
+			time.Sleep(m.pause)
+			continue
+		}
+		reply := map[string]interface{}{
+			"message_type": "comment",
+			"type":         "admin",
+			"admin_id":     i.adminId,
+			"body":         m.Body,
+		}
+		if m.ReplyOptions != nil {
+			reply["message_type"] = "quick_reply"
+			reply["reply_options"] = m.ReplyOptions
+		}
+		if err = i.call("POST", "conversations/"+url.PathEscape(recipientId)+"/reply", reply); err != nil {
+			return
+		}
+	}
+	return
+}
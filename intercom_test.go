@@ -0,0 +1,64 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestIntercomTranslate(t *testing.T) {
+	i := NewIntercomAmbassador("token", "admin1", nil)
+	messages, err := i.Translate(strings.NewReader(`{"type":"notification_event","topic":"conversation.user.replied",
+		"data":{"item":{"type":"conversation","id":"c1","conversation_parts":{"conversation_parts":[
+			{"id":"p1","part_type":"comment","body":"<p>Hi &amp; hello</p><p>there</p>","created_at":1700000000,"author":{"id":"u1","type":"user"}},
+			{"id":"p2","part_type":"quick_reply","created_at":1700000001,"author":{"id":"u1","type":"user"},
+				"reply_options":[{"text":"Yes","uuid":"YES"},{"text":"No","uuid":"NO","selected":true}]},
+			{"id":"p3","part_type":"comment","body":"<p>ours</p>","author":{"id":"admin1","type":"admin"}}]}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expect the parts of the user only, got %+v", messages)
+	}
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "Hi & hello\nthere" || messages[0].ChatId != "c1" || messages[0].Timestamp != 1700000000000 {
+		t.Errorf("unexpected text: %+v", messages[0])
+	}
+	if command, ok := messages[1].Content.(*CommandContent); !ok || command.Payload != "NO" {
+		t.Errorf("expect the selected option as a command, got %+v", messages[1].Content)
+	}
+}
+
+func TestIntercomSend(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	i := NewIntercomAmbassador("token", "admin1", server.Client())
+
+	i.SendText("Hello <there>")
+	i.AskQuestion("Solved?", []map[string]string{
+		{"title": "Yes", "payload": "YES"},
+		{"title": "No", "payload": "NO"},
+	})
+	if err := i.Send("c1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Path != "/conversations/c1/reply" || requests[0].Header.Get("Intercom-Version") == "" {
+		t.Fatalf("unexpected requests: %+v", requests)
+	}
+	var reply struct {
+		MessageType  string              `json:"message_type"`
+		AdminId      string              `json:"admin_id"`
+		Body         string              `json:"body"`
+		ReplyOptions []map[string]string `json:"reply_options"`
+	}
+	json.Unmarshal(requests[0].Body, &reply)
+	if reply.MessageType != "comment" || reply.AdminId != "admin1" || reply.Body != "<p>Hello &lt;there&gt;</p>" {
+		t.Errorf("unexpected reply: %s", requests[0].Body)
+	}
+	json.Unmarshal(requests[1].Body, &reply)
+	if reply.MessageType != "quick_reply" || len(reply.ReplyOptions) != 2 || reply.ReplyOptions[1]["uuid"] != "NO" {
+		t.Errorf("unexpected question: %s", requests[1].Body)
+	}
+}
@@ -0,0 +1,46 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// Codec encodes and decodes the JSON of webhook payloads and platform API
+// calls. It can be swapped for a faster implementation with the same
+// semantics as encoding/json, e.g. jsoniter.ConfigCompatibleWithStandardLibrary.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// jsonCodec is the codec used by ambassadors.
+var jsonCodec Codec = stdCodec{}
+
+// SetCodec replaces the JSON codec of ambassadors. A nil codec restores
+// encoding/json. It should be called before any ambassador is used.
+func SetCodec(c Codec) {
+	if c == nil {
+		c = stdCodec{}
+	}
+	jsonCodec = c
+}
+
+// decodeJSON decodes the JSON of a reader by the codec.
+func decodeJSON(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return jsonCodec.Unmarshal(b, v)
+}
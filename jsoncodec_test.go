@@ -0,0 +1,50 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+type countingCodec struct {
+	marshaled, unmarshaled int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshaled++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshaled++
+	return json.Unmarshal(data, v)
+}
+
+func TestSetCodec(t *testing.T) {
+	codec := &countingCodec{}
+	SetCodec(codec)
+	defer SetCodec(nil)
+
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	l := NewLineAmbassador("token", server.Client())
+	messages, err := l.Translate(strings.NewReader(`{"events":[{"type":"message","replyToken":"r1",
+		"source":{"type":"user","userId":"u1"},"message":{"type":"text","id":"m1","text":"hi"}}]}`))
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("unexpected messages: %+v %v", messages, err)
+	}
+	l.SendText("hello")
+	if err := l.Send("r1"); err != nil {
+		t.Fatal(err)
+	}
+	if codec.unmarshaled == 0 || codec.marshaled == 0 {
+		t.Errorf("expect the codec to be used by translate and send, got %+v", codec)
+	}
+
+	SetCodec(nil)
+	if _, ok := jsonCodec.(stdCodec); !ok {
+		t.Error("expect a nil codec to restore encoding/json")
+	}
+}
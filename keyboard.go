@@ -0,0 +1,48 @@
+package ambassador
+
+// KeyboardButton is a labeled button of a keyboard. The payload is sent back
+// as a CommandContent, or the label as a TextContent if it is empty.
+type KeyboardButton struct {
+	Label   string `json:"label"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// Keyboard is a grid of buttons which persists under the composer.
+type Keyboard struct {
+	Rows [][]KeyboardButton `json:"rows"`
+	// OneTime hides the keyboard after a button is pressed.
+	OneTime bool `json:"one_time,omitempty"`
+}
+
+// KeyboardSender is implemented by ambassadors with native keyboards.
+type KeyboardSender interface {
+	SendKeyboard(text string, kb Keyboard) (err error)
+}
+
+// answers flattens a keyboard into answers of a question.
+func (kb *Keyboard) answers() []map[string]string {
+	answers := []map[string]string{}
+	for _, row := range kb.Rows {
+		for _, btn := range row {
+			payload := btn.Payload
+			if payload == "" {
+				payload = btn.Label
+			}
+			answers = append(answers, map[string]string{
+				"content_type": "text",
+				"title":        btn.Label,
+				"payload":      payload,
+			})
+		}
+	}
+	return answers
+}
+
+// SendKeyboard stages a text with a keyboard. Platforms without keyboards
+// get a question with the buttons as answers instead.
+func SendKeyboard(a Ambassador, text string, kb Keyboard) error {
+	if ks, ok := unwrap(a).(KeyboardSender); ok {
+		return ks.SendKeyboard(text, kb)
+	}
+	return a.AskQuestion(text, kb.answers())
+}
@@ -0,0 +1,199 @@
+package ambassador
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultReplyLatencyBuckets are the upper bounds of the reply latency
+// histogram.
+var DefaultReplyLatencyBuckets = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// LatencySLO is an objective of replying in time, e.g. 99% of conversations
+// get the first reply within 3 seconds.
+type LatencySLO struct {
+	Name      string
+	Threshold time.Duration
+	// Objective is the ratio of replies to be within the threshold.
+	Objective float64
+}
+
+type ReplyLatencyOptions struct {
+	// Buckets default to DefaultReplyLatencyBuckets.
+	Buckets []time.Duration
+	SLOs    []LatencySLO
+	Metrics Metrics
+	// MaxWait is how long a conversation waits for a reply before it is
+	// counted as unanswered. It defaults to 10 minutes.
+	MaxWait time.Duration
+}
+
+// LatencyBucket is a cumulative bucket of a histogram, counting replies
+// within Le.
+type LatencyBucket struct {
+	Le    time.Duration
+	Count int64
+}
+
+// SLOStatus is how replies do against an objective.
+type SLOStatus struct {
+	LatencySLO
+	Good  int64
+	Total int64
+	// Compliance is the ratio of good replies, 1 if there is none.
+	Compliance float64
+	Breached   bool
+}
+
+type ReplyLatencySnapshot struct {
+	Buckets    []LatencyBucket
+	Count      int64
+	Sum        time.Duration
+	Unanswered int64
+	SLOs       []SLOStatus
+}
+
+// ReplyLatency measures the time from receiving the webhook of a message to
+// the first reply in its conversation. Later messages of a conversation
+// waiting for a reply do not restart the clock.
+type ReplyLatency struct {
+	sync.Mutex
+	opts       ReplyLatencyOptions
+	metrics    Metrics
+	counts     []int64
+	count      int64
+	sum        time.Duration
+	unanswered int64
+	good       []int64
+	pending    map[string]time.Time
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+func NewReplyLatency(opts ReplyLatencyOptions) *ReplyLatency {
+	if opts.Buckets == nil {
+		opts.Buckets = DefaultReplyLatencyBuckets
+	}
+	buckets := make([]time.Duration, len(opts.Buckets))
+	copy(buckets, opts.Buckets)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	opts.Buckets = buckets
+	if opts.MaxWait == 0 {
+		opts.MaxWait = 10 * time.Minute
+	}
+	metrics := opts.Metrics
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	return &ReplyLatency{
+		opts:    opts,
+		metrics: metrics,
+		counts:  make([]int64, len(buckets)),
+		good:    make([]int64, len(opts.SLOs)),
+		pending: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+// Track is a middleware which starts the clock of a conversation on an
+// inbound message, and stops it if the handler sends a reply.
+func (l *ReplyLatency) Track() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			platform := platformOf(a)
+			receivedAt := msg.ReceivedAt
+			if receivedAt.IsZero() {
+				receivedAt = l.now()
+			}
+			l.received(platform, msg.chat(), receivedAt)
+			err = next.Handle(a, msg)
+			if len(a.GetLastSent()) > 0 {
+				l.Replied(platform, msg.chat())
+			}
+			return
+		})
+	}
+}
+
+func (l *ReplyLatency) received(platform, chatId string, at time.Time) {
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) >= l.opts.MaxWait {
+		for key, since := range l.pending {
+			if now.Sub(since) > l.opts.MaxWait {
+				delete(l.pending, key)
+				l.unanswered++
+				l.metrics.Count("ambassador.reply.unanswered", 1, nil)
+			}
+		}
+		l.lastSweep = now
+	}
+	if _, ok := l.pending[platform+":"+chatId]; !ok {
+		l.pending[platform+":"+chatId] = at
+	}
+}
+
+// Replied stops the clock of a conversation, for replies sent out of
+// handlers, e.g. by an outbox.
+func (l *ReplyLatency) Replied(platform, chatId string) {
+	l.Lock()
+	defer l.Unlock()
+	since, ok := l.pending[platform+":"+chatId]
+	if !ok {
+		return
+	}
+	delete(l.pending, platform+":"+chatId)
+
+	latency := l.now().Sub(since)
+	l.count++
+	l.sum += latency
+	for i, le := range l.opts.Buckets {
+		if latency <= le {
+			l.counts[i]++
+		}
+	}
+
+	tags := map[string]string{"platform": platform}
+	l.metrics.Observe("ambassador.reply.latency", latency.Seconds(), tags)
+	for i, slo := range l.opts.SLOs {
+		sloTags := map[string]string{"platform": platform, "slo": slo.Name}
+		l.metrics.Count("ambassador.reply.slo.total", 1, sloTags)
+		if latency <= slo.Threshold {
+			l.good[i]++
+			l.metrics.Count("ambassador.reply.slo.good", 1, sloTags)
+		}
+		l.metrics.Gauge("ambassador.reply.slo.compliance", float64(l.good[i])/float64(l.count), map[string]string{"slo": slo.Name})
+	}
+}
+
+// Snapshot returns the histogram and the status of the objectives.
+func (l *ReplyLatency) Snapshot() (s ReplyLatencySnapshot) {
+	l.Lock()
+	defer l.Unlock()
+	s.Count = l.count
+	s.Sum = l.sum
+	s.Unanswered = l.unanswered
+	for i, le := range l.opts.Buckets {
+		s.Buckets = append(s.Buckets, LatencyBucket{Le: le, Count: l.counts[i]})
+	}
+	for i, slo := range l.opts.SLOs {
+		status := SLOStatus{LatencySLO: slo, Good: l.good[i], Total: l.count, Compliance: 1}
+		if l.count > 0 {
+			status.Compliance = float64(l.good[i]) / float64(l.count)
+		}
+		status.Breached = status.Compliance < slo.Objective
+		s.SLOs = append(s.SLOs, status)
+	}
+	return
+}
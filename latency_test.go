@@ -0,0 +1,41 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplyLatency(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	latency := NewReplyLatency(ReplyLatencyOptions{
+		Buckets: []time.Duration{time.Second, 5 * time.Second},
+		SLOs:    []LatencySLO{{Name: "fast", Threshold: 2 * time.Second, Objective: 0.9}},
+	})
+	latency.now = func() time.Time { return now }
+
+	router := NewRouter()
+	router.Use(latency.Track())
+	router.Payload("REPLY", HandlerFunc(func(a Ambassador, msg Message) error {
+		now = now.Add(time.Second)
+		a.SendText("hi")
+		return a.Send(msg.ReplyTarget())
+	}))
+
+	a := &recordAmbassador{}
+	received := now
+	router.Handle(a, Message{SenderId: "u1", ReceivedAt: received, Content: &TextContent{Text: "hello"}})
+	now = now.Add(2 * time.Second)
+	router.Handle(a, Message{SenderId: "u1", ReceivedAt: now, Content: &CommandContent{Payload: "REPLY"}})
+	router.Handle(a, Message{SenderId: "u2", ReceivedAt: now, Content: &CommandContent{Payload: "REPLY"}})
+
+	s := latency.Snapshot()
+	if s.Count != 2 || s.Sum != 4*time.Second {
+		t.Fatalf("expect the clock to run from the first unanswered message, got %+v", s)
+	}
+	if s.Buckets[0].Count != 1 || s.Buckets[1].Count != 2 {
+		t.Errorf("unexpected buckets: %+v", s.Buckets)
+	}
+	if slo := s.SLOs[0]; slo.Good != 1 || slo.Compliance != 0.5 || !slo.Breached {
+		t.Errorf("unexpected slo status: %+v", slo)
+	}
+}
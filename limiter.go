@@ -0,0 +1,134 @@
+package ambassador
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter blocks until a message may be sent.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// TokenBucket is a rate limiter within a process.
+type TokenBucket struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewTokenBucket allows rate sends per second with bursts of burst sends.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+func (b *TokenBucket) reserve() time.Duration {
+	b.Lock()
+	defer b.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	return sleep(ctx, b.reserve())
+}
+
+// RedisEvaler runs a lua script on redis. It is satisfied by a thin wrapper
+// around any redis client, e.g. with go-redis:
+//
+//	func (c wrapper) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return c.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisEvaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// redisTokenBucketScript takes a token and returns 0, or returns the
+// milliseconds to wait for the next token. The clock of redis is used so
+// that all replicas share the same time.
+const redisTokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`
+
+// RedisLimiter is a token bucket shared through redis, so that replicas
+// sending with the same page token stay within the rate limit together.
+type RedisLimiter struct {
+	client RedisEvaler
+	key    string
+	rate   float64
+	burst  int
+}
+
+func NewRedisLimiter(client RedisEvaler, key string, rate float64, burst int) *RedisLimiter {
+	return &RedisLimiter{client: client, key: key, rate: rate, burst: burst}
+}
+
+func (l *RedisLimiter) Wait(ctx context.Context) error {
+	for {
+		result, err := l.client.Eval(ctx, redisTokenBucketScript, []string{l.key}, l.rate, l.burst)
+		if err != nil {
+			return err
+		}
+		wait, ok := result.(int64)
+		if !ok {
+			return fmt.Errorf("unexpected result of the rate limit script: %v", result)
+		}
+		if wait <= 0 {
+			return nil
+		}
+		if err = sleep(ctx, time.Duration(wait)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,47 @@
+package ambassador
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	cases := []struct {
+		name  string
+		rate  float64
+		burst int
+		// elapsed is the time before each reservation.
+		elapsed []time.Duration
+		waits   []time.Duration
+	}{
+		{"burst", 1, 3, []time.Duration{0, 0, 0, 0}, []time.Duration{0, 0, 0, time.Second}},
+		{"refill", 2, 1, []time.Duration{0, 0, 500 * time.Millisecond}, []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond}},
+		{"capped by burst", 10, 2, []time.Duration{0, time.Hour, 0, 0, 0}, []time.Duration{0, 0, 0, 100 * time.Millisecond, 200 * time.Millisecond}},
+		{"debt", 1, 1, []time.Duration{0, 0, 0, 3 * time.Second}, []time.Duration{0, time.Second, 2 * time.Second, 0}},
+	}
+	for _, c := range cases {
+		now := time.Unix(1500000000, 0)
+		b := NewTokenBucket(c.rate, c.burst)
+		b.now = func() time.Time { return now }
+		b.last = now
+		for i, elapsed := range c.elapsed {
+			now = now.Add(elapsed)
+			if wait := b.reserve(); wait != c.waits[i] {
+				t.Errorf("%s: expect reservation %d to wait %s, got %s", c.name, i, c.waits[i], wait)
+			}
+		}
+	}
+}
+
+func TestTokenBucketWaitCancel(t *testing.T) {
+	b := NewTokenBucket(0.001, 1)
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expect the wait to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package ambassador
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// MaxPayloadSize is the largest webhook payload Translate reads.
+	MaxPayloadSize = 1 << 20
+	// MaxEventsPerPayload is the most events Translate accepts in a
+	// payload. Platforms batch far fewer events than this.
+	MaxEventsPerPayload = 1000
+)
+
+var (
+	ErrPayloadTooLarge = errors.New("ambassador: payload too large")
+	ErrTooManyEvents   = errors.New("ambassador: too many events in a payload")
+)
+
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+// limitReader returns a reader which fails with ErrPayloadTooLarge instead of
+// silently truncating a payload over MaxPayloadSize.
+func limitReader(r io.Reader) io.Reader {
+	return &limitedReader{r: r, n: MaxPayloadSize}
+}
+
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+	// A payload of exactly MaxPayloadSize reads one more byte to find
+	// its end.
+	if l.n < 0 {
+		return 0, ErrPayloadTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err = l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrPayloadTooLarge
+	}
+	return
+}
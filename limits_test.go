@@ -0,0 +1,31 @@
+package ambassador
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestTranslateLimits(t *testing.T) {
+	huge := `{"events":[` + strings.Repeat(`{"type":"follow"},`, MaxPayloadSize/18) + `{}]}`
+	if _, err := NewLineAmbassador("token", nil).Translate(strings.NewReader(huge)); err != ErrPayloadTooLarge {
+		t.Errorf("expect a too large payload, got %v", err)
+	}
+
+	events := `{"events":[` + strings.Repeat(`{},`, MaxEventsPerPayload) + `{}]}`
+	if _, err := NewLineAmbassador("token", nil).Translate(bytes.NewBufferString(events)); err != ErrTooManyEvents {
+		t.Errorf("expect too many events, got %v", err)
+	}
+}
+
+func TestLimitReader(t *testing.T) {
+	exact := bytes.Repeat([]byte("a"), MaxPayloadSize)
+	if b, err := ioutil.ReadAll(limitReader(bytes.NewReader(exact))); err != nil || len(b) != MaxPayloadSize {
+		t.Errorf("expect a payload of exactly MaxPayloadSize to be read, got %d bytes, %v", len(b), err)
+	}
+	over := append(exact, 'a')
+	if _, err := ioutil.ReadAll(limitReader(bytes.NewReader(over))); err != ErrPayloadTooLarge {
+		t.Errorf("expect a too large payload, got %v", err)
+	}
+}
@@ -6,13 +6,57 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
-const LineBotReplyURI = "https://api.line.me/v2/bot/message/reply"
+const (
+	LineBotReplyURI = "https://api.line.me/v2/bot/message/reply"
+	LineBotPushURI  = "https://api.line.me/v2/bot/message/push"
+
+	LineBotMulticastURI  = "https://api.line.me/v2/bot/message/multicast"
+	LineBotBroadcastURI  = "https://api.line.me/v2/bot/message/broadcast"
+	LineBotNarrowcastURI = "https://api.line.me/v2/bot/message/narrowcast"
+
+	LineBotLoadingURI    = "https://api.line.me/v2/bot/chat/loading/start"
+	LineBotMarkAsReadURI = "https://api.line.me/v2/bot/message/markAsRead"
+)
+
+// DefaultLineReplyTokenTTL is how long a reply token is trusted. LINE
+// expires reply tokens about a minute after an event.
+const DefaultLineReplyTokenTTL = 50 * time.Second
+
+const (
+	lineMaxActions            = 4
+	lineMaxMulticastReceivers = 500
+)
 
 type LineObject struct {
-	Events []LineEvent `json:"events"`
+	Events  []LineEvent `json:"events"`
+	invalid PartialError
+}
+
+// UnmarshalJSON skips the events which can not be decoded and keeps them to
+// be reported by Translate.
+func (o *LineObject) UnmarshalJSON(b []byte) (err error) {
+	var raw struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	if err = jsonCodec.Unmarshal(b, &raw); err != nil {
+		return
+	}
+	o.Events = make([]LineEvent, 0, len(raw.Events))
+	for _, event := range raw.Events {
+		var e LineEvent
+		if err := jsonCodec.Unmarshal(event, &e); err != nil {
+			o.invalid.add(event, err)
+			continue
+		}
+		e.raw = event
+		o.Events = append(o.Events, e)
+	}
+	return
 }
 
 type LineEvent struct {
@@ -22,11 +66,15 @@ type LineEvent struct {
 	Source     LineSource   `json:"source"`
 	Message    LineMessage  `json:"message"`
 	Postback   LinePostback `json:"postback"`
+	Things     *LineThings  `json:"things"`
+	raw        json.RawMessage
 }
 
 type LineSource struct {
-	Type   string `json:"type"`
-	UserId string `json:"userId"`
+	Type    string `json:"type"`
+	UserId  string `json:"userId"`
+	GroupId string `json:"groupId"`
+	RoomId  string `json:"roomId"`
 }
 
 type LineMessage struct {
@@ -34,31 +82,163 @@ type LineMessage struct {
 	Type string `json:"type"`
 	Text string `json:"text"`
 
+	QuotedMessageId string `json:"quotedMessageId"`
+
 	Title     string  `json:"title"`
 	Address   string  `json:"address"`
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+
+	PackageId string   `json:"packageId"`
+	StickerId string   `json:"stickerId"`
+	Keywords  []string `json:"keywords"`
 }
 
 type LinePostback struct {
-	Payload string `json:"data"`
+	Payload string             `json:"data"`
+	Params  LinePostbackParams `json:"params"`
+}
+
+type LinePostbackParams struct {
+	NewRichMenuAliasId string `json:"newRichMenuAliasId"`
+	Status             string `json:"status"`
+}
+
+// LineReplyToken is where a reply token leads to and when it was issued.
+type LineReplyToken struct {
+	To       string
+	IssuedAt time.Time
 }
 
+// LineReplyTokenStore remembers where reply tokens lead to, so that a reply
+// can fall back to a push message when its token is likely expired.
+// Ambassadors share a store because the ambassador replying is not always
+// the one which translated the event.
+type LineReplyTokenStore interface {
+	PutReplyToken(token string, t LineReplyToken) error
+	GetReplyToken(token string) (t LineReplyToken, ok bool, err error)
+	DeleteReplyToken(token string) error
+}
+
+// lineReplyTokenAge is how long a token is remembered, longer than it lasts.
+const lineReplyTokenAge = 2 * time.Minute
+
+// MemoryLineReplyTokenStore keeps reply tokens until they are long expired.
+type MemoryLineReplyTokenStore struct {
+	sync.Mutex
+	tokens    map[string]LineReplyToken
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLineReplyTokenStore() *MemoryLineReplyTokenStore {
+	return &MemoryLineReplyTokenStore{tokens: map[string]LineReplyToken{}, now: time.Now}
+}
+
+func (s *MemoryLineReplyTokenStore) PutReplyToken(token string, t LineReplyToken) error {
+	s.Lock()
+	defer s.Unlock()
+	// Sweeping once per token age keeps adding tokens cheap under load.
+	if now := s.now(); now.Sub(s.lastSweep) > lineReplyTokenAge {
+		for token, info := range s.tokens {
+			if now.Sub(info.IssuedAt) > lineReplyTokenAge {
+				delete(s.tokens, token)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.tokens[token] = t
+	return nil
+}
+
+func (s *MemoryLineReplyTokenStore) GetReplyToken(token string) (t LineReplyToken, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	t, ok = s.tokens[token]
+	return
+}
+
+func (s *MemoryLineReplyTokenStore) DeleteReplyToken(token string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.tokens, token)
+	return nil
+}
+
+// DefaultLineReplyTokenStore keeps the reply tokens of ambassadors without a
+// reply token store.
+var DefaultLineReplyTokenStore LineReplyTokenStore = NewMemoryLineReplyTokenStore()
+
 type LineAmbassador struct {
 	sync.Mutex
+	// Translators translate custom events, given the whole event. They are
+	// looked up by "event:<type>", e.g. "event:beacon", then by
+	// "message:<type>" for messages, e.g. "message:file", and by "postback"
+	// for postbacks, e.g. to decode a postback data schema.
+	Translators Translators
+	// ReplyTokenTTL is the age after which Send pushes messages instead of
+	// replying with a reply token. Zero means DefaultLineReplyTokenTTL.
+	ReplyTokenTTL time.Duration
+	// ReplyTokens remembers where reply tokens lead to. Without it, they
+	// are kept in the DefaultLineReplyTokenStore.
+	ReplyTokens LineReplyTokenStore
+	// Pages keeps paginated questions. Without it, they are kept in the
+	// DefaultPageStore.
+	Pages        PageStore
 	channelToken string
 	client       *http.Client
 	messages     []interface{}
 	lastMessages []interface{}
+	correlation  string
 }
 
+func (l *LineAmbassador) replyTokens() LineReplyTokenStore {
+	if l.ReplyTokens != nil {
+		return l.ReplyTokens
+	}
+	return DefaultLineReplyTokenStore
+}
+
+// addReplyToken remembers a reply token of an event. It is best effort: a
+// token which fails to be kept is replied to without a push fallback.
+func (l *LineAmbassador) addReplyToken(token, to string, timestamp int64) {
+	l.replyTokens().PutReplyToken(token, LineReplyToken{
+		To:       to,
+		IssuedAt: time.Unix(0, timestamp*int64(time.Millisecond)),
+	})
+}
+
+func (l *LineAmbassador) SetCorrelationId(id string) {
+	l.correlation = id
+}
+
+func (l *LineAmbassador) Platform() string {
+	return "line"
+}
+
+// Translate turns a webhook object into messages. Events which can not be
+// decoded are skipped and returned in a PartialError along with the other
+// messages.
 func (l *LineAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := readBody(r)
+	if err != nil {
+		return
+	}
+	defer releaseBody(body)
+	if l.Translators.empty() {
+		if texts, ok := translateLineText(l, body.Bytes()); ok {
+			return texts, nil
+		}
+	}
+
 	var v LineObject
-	d := json.NewDecoder(r)
-	err = d.Decode(&v)
+	err = jsonCodec.Unmarshal(body.Bytes(), &v)
 	if err != nil {
 		return
 	}
+	if len(v.Events)+len(v.invalid.Events) > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
 
 	messages = make([]Message, 0, 10)
 
@@ -66,10 +246,25 @@ func (l *LineAmbassador) Translate(r io.Reader) (messages []Message, err error)
 		msg := Message{
 			SenderId:   event.Source.UserId,
 			ReplyToken: event.ReplyToken,
+			ChatId:     event.Source.GroupId + event.Source.RoomId,
 			Timestamp:  event.Timestamp,
 		}
-		switch event.Type {
-		case "message":
+		if event.ReplyToken != "" {
+			l.addReplyToken(event.ReplyToken, msg.chat(), event.Timestamp)
+		}
+		content, err := l.translateCustom(event)
+		if err != nil {
+			v.invalid.add(event.raw, err)
+			continue
+		}
+		if event.Type == "message" {
+			msg.MessageId = event.Message.Id
+			msg.InReplyTo = event.Message.QuotedMessageId
+		}
+		switch {
+		case content != nil:
+			msg.Content = content
+		case event.Type == "message":
 			switch event.Message.Type {
 			case "location":
 				msg.Content = &LocationContent{
@@ -78,32 +273,71 @@ func (l *LineAmbassador) Translate(r io.Reader) (messages []Message, err error)
 				}
 			case "text":
 				msg.Content = &TextContent{Text: event.Message.Text}
+			case "sticker":
+				sentiment, _ := StickerSentiment(event.Message.PackageId, event.Message.StickerId, event.Message.Keywords)
+				msg.Content = &StickerContent{
+					PackageId: event.Message.PackageId,
+					StickerId: event.Message.StickerId,
+					Keywords:  event.Message.Keywords,
+					Sentiment: sentiment,
+				}
 			default:
 			}
-		case "postback":
-			msg.Content = &CommandContent{Payload: event.Postback.Payload}
+		case event.Type == "postback":
+			if aliasId := event.Postback.Params.NewRichMenuAliasId; aliasId != "" {
+				msg.Content = &RichMenuSwitchContent{
+					AliasId: aliasId,
+					Status:  event.Postback.Params.Status,
+					Data:    event.Postback.Payload,
+				}
+			} else if text, offset, page, ok := nextPage(l.Pages, event.Postback.Payload, lineMaxActions); ok {
+				msg.Content = &MoreAnswersContent{Text: text, Offset: offset, Answers: page}
+			} else {
+				msg.Content = &CommandContent{Payload: event.Postback.Payload}
+			}
+		case event.Type == "follow":
+			msg.Content = &FollowContent{}
+		case event.Type == "things":
+			if event.Things != nil {
+				msg.Content = event.Things.content()
+			}
 		default:
 		}
 		messages = append(messages, msg)
 	}
+	return messages, v.invalid.orNil()
+}
 
+func (l *LineAmbassador) translateCustom(event LineEvent) (content interface{}, err error) {
+	if content, err = l.Translators.translate("event:"+event.Type, event.raw); content != nil || err != nil {
+		return
+	}
+	switch event.Type {
+	case "message":
+		return l.Translators.translate("message:"+event.Message.Type, event.raw)
+	case "postback":
+		return l.Translators.translate("postback", event.raw)
+	}
 	return
 }
 
 func (l *LineAmbassador) sendReply(recipientId string, messages interface{}) (err error) {
-	payload := map[string]interface{}{
+	return l.post(LineBotReplyURI, map[string]interface{}{
 		"replyToken": recipientId,
-		"messages":   l.messages,
-	}
+		"messages":   messages,
+	})
+}
 
-	b, err := json.Marshal(payload)
+func (l *LineAmbassador) post(uri string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
 	if err != nil {
 		return
 	}
 
-	req, _ := http.NewRequest("POST", LineBotReplyURI, bytes.NewBuffer(b))
+	req, _ := http.NewRequest("POST", uri, bytes.NewBuffer(b))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+l.channelToken)
+	setCorrelationHeader(req, l.correlation)
 	resp, err := l.client.Do(req)
 	if err != nil {
 		return
@@ -116,19 +350,62 @@ func (l *LineAmbassador) sendReply(recipientId string, messages interface{}) (er
 		if err != nil {
 			return
 		}
-		err = fmt.Errorf("fail to reply a line message. status: %s, body: %s",
+		err = fmt.Errorf("fail to deliver a line message. status: %s, body: %s",
 			resp.Status, buffer.String())
 	}
 	return
 }
 
+// do calls an api of LINE and decodes the response into v if it is not nil.
+func (l *LineAmbassador) do(method, uri string, payload, v interface{}) (err error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := jsonCodec.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(b)
+	}
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+l.channelToken)
+	setCorrelationHeader(req, l.correlation)
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to %s %s. status: %s, body: %s", method, uri, resp.Status, buffer.String())
+	}
+	if v != nil {
+		return decodeJSON(resp.Body, v)
+	}
+	return
+}
+
+// AskQuestion sends a question with answers as postback buttons. Answers
+// beyond the four actions LINE allows are paginated behind a "More…" button.
 func (l *LineAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
-	actions := []map[string]string{}
-	var upperBound int
-	if upperBound = len(answers) - 4; upperBound < 0 {
-		upperBound = 0
+	page, err := paginate(l.Pages, text, answers, lineMaxActions)
+	if err != nil {
+		return
 	}
-	for _, answer := range answers[upperBound:] {
+	l.askQuestion(text, page)
+	return
+}
+
+func (l *LineAmbassador) askQuestion(text string, answers []map[string]string) {
+	actions := []map[string]string{}
+	for _, answer := range answers {
 		ansLabel, ok1 := answer["title"]
 		ansData, ok2 := answer["payload"]
 		if ok1 && ok2 {
@@ -155,7 +432,6 @@ func (l *LineAmbassador) AskQuestion(text string, answers []map[string]string) (
 	l.Lock()
 	defer l.Unlock()
 	l.messages = append(l.messages, question)
-	return
 }
 
 func (l *LineAmbassador) SendText(text string) (err error) {
@@ -224,20 +500,193 @@ func (l *LineAmbassador) SendTemplate(elements interface{}) (err error) {
 	return
 }
 
+// lineLoading is a staged loading animation. It is not a message, so it is
+// taken out before messages are delivered.
+type lineLoading struct {
+	seconds int
+	pause   time.Duration
+}
+
+// SendTyping shows the loading animation, which LINE only supports in one on
+// one chats of pushed messages. The animation stops when a message arrives,
+// so turning it off is a no-op.
+func (l *LineAmbassador) SendTyping(on bool) (err error) {
+	if !on {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, &lineLoading{seconds: 5})
+	return
+}
+
+// WithTyping shows the loading animation for a duration before pushing
+// messages.
+func (l *LineAmbassador) WithTyping(d time.Duration) (err error) {
+	// LINE only accepts multiples of 5 seconds up to 60 seconds.
+	seconds := (int(d/time.Second) + 4) / 5 * 5
+	if seconds < 5 {
+		seconds = 5
+	} else if seconds > 60 {
+		seconds = 60
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, &lineLoading{seconds: seconds, pause: d})
+	return
+}
+
+// outgoing splits the staged messages from the loading animation.
+func (l *LineAmbassador) outgoing() (messages []interface{}, loading *lineLoading) {
+	messages = make([]interface{}, 0, len(l.messages))
+	for _, m := range l.messages {
+		if ll, ok := m.(*lineLoading); ok {
+			if loading == nil || ll.pause > loading.pause {
+				loading = ll
+			}
+			continue
+		}
+		messages = append(messages, m)
+	}
+	return
+}
+
 func (l *LineAmbassador) GetLastSent() []interface{} {
 	return l.lastMessages
 }
 
+// Discard drops the staged messages without sending them.
+func (l *LineAmbassador) Discard() {
+	l.Lock()
+	last := l.lastMessages
+	l.Unlock()
+	l.cleanMessage()
+	l.Lock()
+	l.lastMessages = last
+	l.Unlock()
+}
+
+// Send replies the staged messages by a reply token. If the token is likely
+// expired, the messages are pushed to the chat of the token instead.
 func (l *LineAmbassador) Send(recipientId string) (err error) {
+	// A loading animation on its own is pushed to the chat of a reply
+	// token, so that the token is kept for the reply which follows.
+	if messages, _ := l.outgoing(); len(messages) == 0 {
+		to := recipientId
+		if info, ok, _ := l.replyTokens().GetReplyToken(recipientId); ok {
+			to = info.To
+		}
+		return l.Push(to)
+	}
+	ttl := l.ReplyTokenTTL
+	if ttl <= 0 {
+		ttl = DefaultLineReplyTokenTTL
+	}
+	// A token which fails to be looked up is replied to as it is.
+	if info, ok, _ := l.replyTokens().GetReplyToken(recipientId); ok {
+		l.replyTokens().DeleteReplyToken(recipientId)
+		if time.Since(info.IssuedAt) > ttl {
+			return l.Push(info.To)
+		}
+	}
+
 	defer l.cleanMessage()
-	err = l.sendReply(recipientId, l.messages)
+	messages, _ := l.outgoing()
+	err = l.sendReply(recipientId, messages)
 	if err != nil {
-		b, _ := json.Marshal(l.messages)
+		b, _ := jsonCodec.Marshal(l.messages)
 		return fmt.Errorf("%s, %s", err.Error(), b)
 	}
 	return
 }
 
+// Push sends the staged messages to a user, group or room without a reply
+// token.
+func (l *LineAmbassador) Push(to string) (err error) {
+	defer l.cleanMessage()
+	messages, loading := l.outgoing()
+	// Loading animations are only shown to users, whose ids start with U,
+	// rather than to groups and rooms.
+	if loading != nil && strings.HasPrefix(to, "U") {
+		err = l.post(LineBotLoadingURI, map[string]interface{}{
+			"chatId":         to,
+			"loadingSeconds": loading.seconds,
+		})
+		if err != nil {
+			return
+		}
+		time.Sleep(loading.pause)
+	}
+	if len(messages) == 0 {
+		return
+	}
+	err = l.post(LineBotPushURI, map[string]interface{}{
+		"to":       to,
+		"messages": messages,
+	})
+	if err != nil {
+		b, _ := jsonCodec.Marshal(l.messages)
+		return fmt.Errorf("%s, %s", err.Error(), b)
+	}
+	return
+}
+
+// MarkRead marks the messages of a user as read. LINE only shows read
+// receipts of bots whose chat mode is enabled.
+func (l *LineAmbassador) MarkRead(msg Message) (err error) {
+	return l.post(LineBotMarkAsReadURI, map[string]interface{}{
+		"chat": map[string]string{"userId": msg.SenderId},
+	})
+}
+
+// Broadcast sends the staged messages to all followers, an audience group
+// by narrowcast, or listed users by multicast. Every batch of listed users
+// is sent even if some fail, and the users of failed batches are returned
+// in DeliveryErrors.
+func (l *LineAmbassador) Broadcast(audience Audience) (err error) {
+	defer l.cleanMessage()
+	messages, _ := l.outgoing()
+
+	switch {
+	case audience.All:
+		return l.post(LineBotBroadcastURI, map[string]interface{}{
+			"messages": messages,
+		})
+	case audience.AudienceGroupId != 0:
+		return l.post(LineBotNarrowcastURI, map[string]interface{}{
+			"messages": messages,
+			"recipient": map[string]interface{}{
+				"type":            "audience",
+				"audienceGroupId": audience.AudienceGroupId,
+			},
+		})
+	case len(audience.Recipients) == 0:
+		return fmt.Errorf("no recipient to broadcast to")
+	}
+
+	errs := DeliveryErrors{}
+	for i := 0; i < len(audience.Recipients); i += lineMaxMulticastReceivers {
+		end := i + lineMaxMulticastReceivers
+		if end > len(audience.Recipients) {
+			end = len(audience.Recipients)
+		}
+		batchErr := l.post(LineBotMulticastURI, map[string]interface{}{
+			"to":       audience.Recipients[i:end],
+			"messages": messages,
+		})
+		if batchErr != nil {
+			for _, userId := range audience.Recipients[i:end] {
+				errs[userId] = batchErr
+			}
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
 func (l *LineAmbassador) cleanMessage() {
 	l.Lock()
 	defer l.Unlock()
@@ -250,7 +699,8 @@ func NewLineAmbassador(channelToken string, client *http.Client) *LineAmbassador
 		client = http.DefaultClient
 	}
 	return &LineAmbassador{
-		channelToken: channelToken,
-		client:       client,
+		ReplyTokenTTL: DefaultLineReplyTokenTTL,
+		channelToken:  channelToken,
+		client:        client,
 	}
 }
@@ -0,0 +1,51 @@
+package ambassador
+
+const LineBotAudienceUploadURI = "https://api-data.line.me/v2/bot/audienceGroup/upload"
+
+// lineMaxAudienceUpload is the most user ids one upload of an audience
+// group takes.
+const lineMaxAudienceUpload = 10000
+
+// CreateAudienceGroup uploads user ids as an audience group for narrowcast
+// messages. Ids over the limit of an upload are added to the group in
+// following uploads.
+func (l *LineAmbassador) CreateAudienceGroup(description string, userIds []string) (audienceGroupId int64, err error) {
+	chunk := func(ids []string) []map[string]string {
+		audiences := make([]map[string]string, 0, len(ids))
+		for _, id := range ids {
+			audiences = append(audiences, map[string]string{"id": id})
+		}
+		return audiences
+	}
+
+	end := len(userIds)
+	if end > lineMaxAudienceUpload {
+		end = lineMaxAudienceUpload
+	}
+	var created struct {
+		AudienceGroupId int64 `json:"audienceGroupId"`
+	}
+	err = l.do("POST", LineBotAudienceUploadURI, map[string]interface{}{
+		"description": description,
+		"audiences":   chunk(userIds[:end]),
+	}, &created)
+	if err != nil {
+		return
+	}
+	audienceGroupId = created.AudienceGroupId
+
+	for i := end; i < len(userIds); i += lineMaxAudienceUpload {
+		end := i + lineMaxAudienceUpload
+		if end > len(userIds) {
+			end = len(userIds)
+		}
+		err = l.do("PUT", LineBotAudienceUploadURI, map[string]interface{}{
+			"audienceGroupId": audienceGroupId,
+			"audiences":       chunk(userIds[i:end]),
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package ambassador
+
+import (
+	"strconv"
+
+	"github.com/lemonlatte/ambassador/flex"
+)
+
+// SendOrderStatus stages an order as a flex bubble listing its items and
+// total.
+func (l *LineAmbassador) SendOrderStatus(o Order) (err error) {
+	body := []flex.BoxChild{
+		flex.Text(o.Status).Weight(flex.Bold).Size(flex.XL),
+		flex.Text("Order #" + o.Id).Size(flex.XS).Color("#aaaaaa"),
+		flex.Separator().Margin(flex.MD),
+	}
+	for _, item := range o.Items {
+		body = append(body, flex.HBox(
+			flex.Text(item.Title).Size(flex.SM).Wrap().Flex(3),
+			flex.Text(strconv.Itoa(item.Quantity)+" × "+formatPrice(o.Currency, item.Price)).Size(flex.SM).Align("end").Flex(2),
+		))
+	}
+	body = append(body,
+		flex.Separator().Margin(flex.MD),
+		flex.HBox(
+			flex.Text("Total").Weight(flex.Bold),
+			flex.Text(formatPrice(o.Currency, o.Total)).Weight(flex.Bold).Align("end"),
+		),
+	)
+
+	bubble := flex.Bubble().Body(flex.VBox(body...).Spacing(flex.SM))
+	if o.Url != "" {
+		bubble.Footer(flex.VBox(flex.Button(flex.URIAction("View order", o.Url)).Style(flex.Link)))
+	}
+	return l.SendFlex("Order #"+o.Id+": "+o.Status, bubble)
+}
+
+// SendShippingUpdate stages a shipment as a flex bubble with its tracking
+// link.
+func (l *LineAmbassador) SendShippingUpdate(s Shipment) (err error) {
+	body := []flex.BoxChild{
+		flex.Text(s.Status).Weight(flex.Bold).Size(flex.XL),
+		flex.Text("Order #" + s.OrderId).Size(flex.XS).Color("#aaaaaa"),
+	}
+	for _, line := range s.details() {
+		body = append(body, flex.Text(line).Size(flex.SM).Wrap())
+	}
+
+	bubble := flex.Bubble().Body(flex.VBox(body...).Spacing(flex.SM))
+	if s.TrackingUrl != "" {
+		bubble.Footer(flex.VBox(flex.Button(flex.URIAction("Track package", s.TrackingUrl)).Style(flex.Primary)))
+	}
+	return l.SendFlex("Order #"+s.OrderId+": "+s.Status, bubble)
+}
@@ -0,0 +1,20 @@
+package ambassador
+
+import "github.com/lemonlatte/ambassador/flex"
+
+// SendFlex stages a flex message. The alt text is shown in notifications and
+// chat lists.
+func (l *LineAmbassador) SendFlex(altText string, c flex.Container) (err error) {
+	if err = flex.Validate(c); err != nil {
+		return
+	}
+	message := map[string]interface{}{
+		"type":     "flex",
+		"altText":  altText,
+		"contents": c,
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.messages = append(l.messages, message)
+	return
+}
@@ -0,0 +1,146 @@
+package ambassador
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	LineLIFFBaseURI      = "https://liff.line.me/"
+	LineIdTokenVerifyURI = "https://api.line.me/oauth2/v2.1/verify"
+)
+
+// LIFFStateParam is the query parameter of the signed state of a LIFF url,
+// which a page submits back along with its form.
+const LIFFStateParam = "ambassador_state"
+
+var ErrLIFFUserMismatch = errors.New("ambassador: liff user does not match the state")
+
+// LIFFState is the chat which a LIFF app is opened from, and what for.
+type LIFFState struct {
+	UserId string `json:"u"`
+	ChatId string `json:"c,omitempty"`
+	// Data is anything the bot passes to the app, e.g. a form id.
+	Data string `json:"d,omitempty"`
+}
+
+// LIFFIdToken is the verified id token of a LIFF user.
+type LIFFIdToken struct {
+	Issuer   string `json:"iss"`
+	UserId   string `json:"sub"`
+	Audience string `json:"aud"`
+	Expires  int64  `json:"exp"`
+	IssuedAt int64  `json:"iat"`
+	Name     string `json:"name"`
+	Picture  string `json:"picture"`
+	Email    string `json:"email"`
+}
+
+// LIFFFormContent is the content of a form submitted by a LIFF app, which
+// is correlated to the chat the app is opened from.
+type LIFFFormContent struct {
+	Data string
+	Form url.Values
+}
+
+// LIFF builds urls of a LIFF app with signed states, and turns the forms it
+// submits into messages of the users who open it.
+type LIFF struct {
+	liffId string
+	// channelId is the LINE Login channel of the app, which id tokens are
+	// issued to.
+	channelId string
+	codec     *PayloadCodec
+	client    *http.Client
+	// TTL is how long a state stays valid.
+	TTL time.Duration
+}
+
+func NewLIFF(liffId, channelId, secret string, client *http.Client) *LIFF {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &LIFF{liffId: liffId, channelId: channelId, codec: NewPayloadCodec(secret), client: client, TTL: time.Hour}
+}
+
+// URL is the url opening a path of the app with a signed state.
+func (l *LIFF) URL(path string, s LIFFState) (uri string, err error) {
+	signed, err := l.codec.Encode(&s, l.TTL)
+	if err != nil {
+		return
+	}
+	uri = LineLIFFBaseURI + l.liffId
+	if path = strings.Trim(path, "/"); path != "" {
+		uri += "/" + path
+	}
+	return uri + "?" + url.Values{LIFFStateParam: {signed}}.Encode(), nil
+}
+
+// VerifyIdToken verifies an id token got by liff.getIDToken() with LINE.
+func (l *LIFF) VerifyIdToken(idToken string) (t *LIFFIdToken, err error) {
+	form := url.Values{"id_token": {idToken}, "client_id": {l.channelId}}
+	resp, err := l.client.PostForm(LineIdTokenVerifyURI, form)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return nil, fmt.Errorf("fail to verify a liff id token. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	t = &LIFFIdToken{}
+	if err = decodeJSON(resp.Body, t); err != nil {
+		return nil, err
+	}
+	if t.UserId == "" {
+		return nil, fmt.Errorf("fail to verify a liff id token without a user")
+	}
+	return
+}
+
+// Submission turns a form submitted by the app into a message of the chat
+// the app is opened from. The form carries the state of the url and the id
+// token of the user, either as an "id_token" field or a bearer token, and
+// the user of the token must be the one of the state.
+func (l *LIFF) Submission(r *http.Request) (msg Message, err error) {
+	if err = r.ParseForm(); err != nil {
+		return
+	}
+	var s LIFFState
+	if err = l.codec.Decode(r.Form.Get(LIFFStateParam), &s); err != nil {
+		return
+	}
+	idToken := r.Form.Get("id_token")
+	if auth := r.Header.Get("Authorization"); idToken == "" && strings.HasPrefix(auth, "Bearer ") {
+		idToken = strings.TrimPrefix(auth, "Bearer ")
+	}
+	t, err := l.VerifyIdToken(idToken)
+	if err != nil {
+		return
+	}
+	if t.UserId != s.UserId {
+		return msg, ErrLIFFUserMismatch
+	}
+
+	form := url.Values{}
+	for k, v := range r.Form {
+		if k != LIFFStateParam && k != "id_token" {
+			form[k] = v
+		}
+	}
+	return Message{
+		SenderId:   s.UserId,
+		ChatId:     s.ChatId,
+		Timestamp:  time.Now().UnixNano() / int64(time.Millisecond),
+		ReceivedAt: time.Now(),
+		Content:    &LIFFFormContent{Data: s.Data, Form: form},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package ambassador
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestLIFFSubmission(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	l := NewLIFF("1234-abcd", "1234", "secret", server.Client())
+
+	uri, err := l.URL("/survey", LIFFState{UserId: "u1", ChatId: "g1", Data: "survey-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(uri)
+	if !strings.HasPrefix(uri, "https://liff.line.me/1234-abcd/survey?") || u.Query().Get(LIFFStateParam) == "" {
+		t.Fatalf("unexpected url: %s", uri)
+	}
+
+	submit := func(idToken string) (Message, error) {
+		form := url.Values{LIFFStateParam: {u.Query().Get(LIFFStateParam)}, "id_token": {idToken}, "rating": {"5"}}
+		r := httptest.NewRequest("POST", "https://bot.example.com/liff", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return l.Submission(r)
+	}
+
+	server.Fail("line.me", testutil.Sequence(&testutil.Failure{Body: `{"iss":"https://access.line.me","sub":"u1","aud":"1234"}`}))
+	msg, err := submit("token-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, ok := msg.Content.(*LIFFFormContent)
+	if !ok || msg.SenderId != "u1" || msg.ChatId != "g1" || content.Data != "survey-1" || content.Form.Get("rating") != "5" || content.Form.Get("id_token") != "" {
+		t.Errorf("unexpected submission: %+v %+v", msg, content)
+	}
+	verify, _ := url.ParseQuery(string(server.Requests()[0].Body))
+	if server.Requests()[0].Path != "/oauth2/v2.1/verify" || verify.Get("id_token") != "token-1" || verify.Get("client_id") != "1234" {
+		t.Errorf("unexpected verification: %+v", server.Requests()[0])
+	}
+
+	server.Fail("line.me", testutil.Sequence(&testutil.Failure{Body: `{"sub":"u2","aud":"1234"}`}))
+	if _, err := submit("token-2"); err != ErrLIFFUserMismatch {
+		t.Errorf("expect a token of another user to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package ambassador
+
+const (
+	LineBotQuotaURI            = "https://api.line.me/v2/bot/message/quota"
+	LineBotQuotaConsumptionURI = "https://api.line.me/v2/bot/message/quota/consumption"
+)
+
+// RemainingQuota returns how many messages can still be pushed this month.
+// Channels without a limit are not limited.
+func (l *LineAmbassador) RemainingQuota() (remaining int64, limited bool, err error) {
+	var quota struct {
+		Type  string `json:"type"`
+		Value int64  `json:"value"`
+	}
+	if err = l.do("GET", LineBotQuotaURI, nil, &quota); err != nil {
+		return
+	}
+	if quota.Type != "limited" {
+		return
+	}
+	var consumption struct {
+		TotalUsage int64 `json:"totalUsage"`
+	}
+	if err = l.do("GET", LineBotQuotaConsumptionURI, nil, &consumption); err != nil {
+		return
+	}
+	remaining = quota.Value - consumption.TotalUsage
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true, nil
+}
@@ -0,0 +1,70 @@
+package ambassador
+
+import "net/url"
+
+const (
+	LineBotRichMenuAliasURI     = "https://api.line.me/v2/bot/richmenu/alias"
+	LineBotRichMenuAliasListURI = "https://api.line.me/v2/bot/richmenu/alias/list"
+)
+
+// RichMenuSwitchContent is the content of a message when a user taps a rich
+// menu switch action and LINE switches the rich menu by its alias.
+type RichMenuSwitchContent struct {
+	AliasId string
+	// Status is "SUCCESS" or the reason the switch failed, e.g.
+	// "RICHMENU_ALIAS_ID_NOTFOUND".
+	Status string
+	Data   string
+}
+
+type RichMenuAlias struct {
+	AliasId    string `json:"richMenuAliasId"`
+	RichMenuId string `json:"richMenuId"`
+}
+
+// RichMenuSwitchAction is an action of a rich menu area which switches to
+// the rich menu of an alias, e.g. to implement tabs. The data comes back in
+// a RichMenuSwitchContent.
+func RichMenuSwitchAction(label, aliasId, data string) map[string]string {
+	return map[string]string{
+		"type":            "richmenuswitch",
+		"label":           label,
+		"richMenuAliasId": aliasId,
+		"data":            data,
+	}
+}
+
+// CreateRichMenuAlias gives a rich menu an alias which switch actions refer
+// to.
+func (l *LineAmbassador) CreateRichMenuAlias(aliasId, richMenuId string) (err error) {
+	return l.do("POST", LineBotRichMenuAliasURI, &RichMenuAlias{AliasId: aliasId, RichMenuId: richMenuId}, nil)
+}
+
+// UpdateRichMenuAlias points an alias to another rich menu, e.g. a new
+// revision of a tab.
+func (l *LineAmbassador) UpdateRichMenuAlias(aliasId, richMenuId string) (err error) {
+	return l.do("POST", LineBotRichMenuAliasURI+"/"+url.PathEscape(aliasId),
+		map[string]string{"richMenuId": richMenuId}, nil)
+}
+
+func (l *LineAmbassador) DeleteRichMenuAlias(aliasId string) (err error) {
+	return l.do("DELETE", LineBotRichMenuAliasURI+"/"+url.PathEscape(aliasId), nil, nil)
+}
+
+func (l *LineAmbassador) GetRichMenuAlias(aliasId string) (alias *RichMenuAlias, err error) {
+	alias = &RichMenuAlias{}
+	if err = l.do("GET", LineBotRichMenuAliasURI+"/"+url.PathEscape(aliasId), nil, alias); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (l *LineAmbassador) RichMenuAliases() (aliases []RichMenuAlias, err error) {
+	var list struct {
+		Aliases []RichMenuAlias `json:"aliases"`
+	}
+	if err = l.do("GET", LineBotRichMenuAliasListURI, nil, &list); err != nil {
+		return
+	}
+	return list.Aliases, nil
+}
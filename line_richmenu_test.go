@@ -0,0 +1,35 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestLineRichMenuAlias(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	l := NewLineAmbassador("token", server.Client())
+
+	if err := l.UpdateRichMenuAlias("tab-b", "richmenu-2"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/v2/bot/richmenu/alias/tab-b" ||
+		string(requests[0].Body) != `{"richMenuId":"richmenu-2"}` {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+
+	body := `{"events":[{"type":"postback","replyToken":"r1","timestamp":1500000000000,
+		"source":{"type":"user","userId":"u1"},
+		"postback":{"data":"TAB_B","params":{"newRichMenuAliasId":"tab-b","status":"SUCCESS"}}}]}`
+	messages, err := l.Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := messages[0].Content.(*RichMenuSwitchContent)
+	if !ok || c.AliasId != "tab-b" || c.Status != "SUCCESS" || c.Data != "TAB_B" {
+		t.Errorf("unexpected content: %+v", messages[0].Content)
+	}
+}
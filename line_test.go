@@ -0,0 +1,81 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestLineReplyTokenSweep(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryLineReplyTokenStore()
+	s.now = func() time.Time { return now }
+	s.PutReplyToken("old", LineReplyToken{To: "U1", IssuedAt: now.Add(-time.Hour)})
+	if len(s.tokens) != 1 {
+		t.Fatalf("expect the first put to sweep an empty store, got %d tokens", len(s.tokens))
+	}
+
+	s.PutReplyToken("new", LineReplyToken{To: "U2", IssuedAt: now.Add(time.Minute)})
+	if _, ok, _ := s.GetReplyToken("old"); !ok {
+		t.Error("expect tokens not to be swept more than once per token age")
+	}
+
+	now = now.Add(lineReplyTokenAge + time.Second)
+	s.PutReplyToken("newer", LineReplyToken{To: "U3", IssuedAt: now})
+	if _, ok, _ := s.GetReplyToken("old"); ok || len(s.tokens) != 2 {
+		t.Errorf("expect the stale token to be swept, got %+v", s.tokens)
+	}
+}
+
+func TestLineReplyTokenExpiry(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	tokens := NewMemoryLineReplyTokenStore()
+	now := time.Now()
+	event := func(token string, at time.Time) string {
+		return fmt.Sprintf(`{"events":[{"type":"message","replyToken":%q,"timestamp":%d,
+			"source":{"type":"user","userId":"U1"},"message":{"id":"m1","type":"text","text":"hi"}}]}`,
+			token, at.UnixNano()/int64(time.Millisecond))
+	}
+
+	cases := []struct {
+		token string
+		at    time.Time
+		ttl   time.Duration
+		path  string
+	}{
+		{"fresh", now, DefaultLineReplyTokenTTL, "/v2/bot/message/reply"},
+		{"expired", now.Add(-time.Minute), DefaultLineReplyTokenTTL, "/v2/bot/message/push"},
+		// an ambassador of a struct literal trusts tokens for the default TTL
+		{"zero-ttl", now.Add(-time.Second), 0, "/v2/bot/message/reply"},
+		{"short-ttl", now.Add(-time.Second), time.Millisecond, "/v2/bot/message/push"},
+	}
+	for _, c := range cases {
+		translator := &LineAmbassador{ReplyTokens: tokens}
+		messages, err := translator.Translate(strings.NewReader(event(c.token, c.at)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the reply is sent by another ambassador sharing the store
+		l := &LineAmbassador{ReplyTokens: tokens, ReplyTokenTTL: c.ttl, client: server.Client()}
+		l.SendText("hello")
+		if err := l.Send(messages[0].ReplyTarget()); err != nil {
+			t.Fatal(err)
+		}
+		requests := server.Requests()
+		r := requests[len(requests)-1]
+		if r.Path != c.path {
+			t.Errorf("expect %s to be sent by %s, got %s", c.token, c.path, r.Path)
+		}
+		if c.path == "/v2/bot/message/push" && !strings.Contains(string(r.Body), `"to":"U1"`) {
+			t.Errorf("expect %s to be pushed to the chat of the token, got %s", c.token, r.Body)
+		}
+		if _, ok, _ := tokens.GetReplyToken(c.token); ok {
+			t.Errorf("expect %s to be used once", c.token)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ambassador
+
+// ThingsLinkContent is the content of a LINE Things device being linked or
+// unlinked by a user.
+type ThingsLinkContent struct {
+	DeviceId string
+	Linked   bool
+}
+
+// ThingsScenarioContent is the result of an automatically executed LINE
+// Things scenario, e.g. a device trigger.
+type ThingsScenarioContent struct {
+	DeviceId      string
+	ScenarioId    string
+	Revision      int
+	StartTime     int64
+	EndTime       int64
+	ResultCode    string
+	ActionResults []ThingsActionResult
+	// BLENotificationPayload is the base64 encoded notification of the
+	// device which triggered the scenario.
+	BLENotificationPayload string
+	ErrorReason            string
+}
+
+type ThingsActionResult struct {
+	Type string `json:"type"`
+	// Data is base64 encoded data read from the device.
+	Data string `json:"data"`
+}
+
+type LineThings struct {
+	DeviceId string            `json:"deviceId"`
+	Type     string            `json:"type"`
+	Result   *LineThingsResult `json:"result"`
+}
+
+type LineThingsResult struct {
+	ScenarioId             string               `json:"scenarioId"`
+	Revision               int                  `json:"revision"`
+	StartTime              int64                `json:"startTime"`
+	EndTime                int64                `json:"endTime"`
+	ResultCode             string               `json:"resultCode"`
+	ActionResults          []ThingsActionResult `json:"actionResults"`
+	BLENotificationPayload string               `json:"bleNotificationPayload"`
+	ErrorReason            string               `json:"errorReason"`
+}
+
+func (t *LineThings) content() interface{} {
+	switch t.Type {
+	case "link", "unlink":
+		return &ThingsLinkContent{DeviceId: t.DeviceId, Linked: t.Type == "link"}
+	case "scenarioResult":
+		c := &ThingsScenarioContent{DeviceId: t.DeviceId}
+		if r := t.Result; r != nil {
+			c.ScenarioId = r.ScenarioId
+			c.Revision = r.Revision
+			c.StartTime = r.StartTime
+			c.EndTime = r.EndTime
+			c.ResultCode = r.ResultCode
+			c.ActionResults = r.ActionResults
+			c.BLENotificationPayload = r.BLENotificationPayload
+			c.ErrorReason = r.ErrorReason
+		}
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineTranslateThings(t *testing.T) {
+	body := `{"events":[
+		{"type":"things","timestamp":1547817848122,"source":{"type":"user","userId":"u1"},
+		 "things":{"deviceId":"d1","type":"link"}},
+		{"type":"things","timestamp":1547817848122,"source":{"type":"user","userId":"u1"},
+		 "things":{"deviceId":"d1","type":"scenarioResult","result":{"scenarioId":"s1","revision":2,
+		 "resultCode":"success","actionResults":[{"type":"binary","data":"/w=="}],"bleNotificationPayload":"AQ=="}}}
+	]}`
+	messages, err := NewLineAmbassador("token", nil).Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link, ok := messages[0].Content.(*ThingsLinkContent); !ok || !link.Linked || link.DeviceId != "d1" {
+		t.Errorf("unexpected link content: %+v", messages[0].Content)
+	}
+	scenario, ok := messages[1].Content.(*ThingsScenarioContent)
+	if !ok || scenario.ScenarioId != "s1" || scenario.ResultCode != "success" || len(scenario.ActionResults) != 1 {
+		t.Errorf("unexpected scenario content: %+v", messages[1].Content)
+	}
+}
@@ -0,0 +1,114 @@
+package ambassador
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	MessengerLinkBaseURI = "https://m.me/"
+	LineAddFriendBaseURI = "https://line.me/R/ti/p/"
+	LiffBaseURI          = "https://liff.line.me/"
+
+	maxMessengerRefLength = 250
+)
+
+// MessengerLink returns an m.me link of a page. The ref is delivered back in
+// a referral event, or in the postback of the get started button for new users.
+func MessengerLink(page, ref string) (link string, err error) {
+	if len(ref) > maxMessengerRefLength {
+		return "", fmt.Errorf("the ref of an m.me link can not exceed %d characters", maxMessengerRefLength)
+	}
+	link = MessengerLinkBaseURI + url.PathEscape(page)
+	if ref != "" {
+		link += "?" + url.Values{"ref": {ref}}.Encode()
+	}
+	return
+}
+
+// LineAddFriendLink returns the add friend link of a LINE official account
+// basic id such as @linedevelopers.
+func LineAddFriendLink(basicId string) string {
+	if !strings.HasPrefix(basicId, "@") {
+		basicId = "@" + basicId
+	}
+	return LineAddFriendBaseURI + url.PathEscape(basicId)
+}
+
+// LiffLink returns a LIFF URL which carries a ref for the LIFF app to report
+// where the user comes from.
+func LiffLink(liffId, ref string) string {
+	link := LiffBaseURI + url.PathEscape(liffId)
+	if ref != "" {
+		link += "?" + url.Values{"ref": {ref}}.Encode()
+	}
+	return link
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// DefaultTrackingParams are query parameters which LinkRewriter strips by
+// default.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "mc_eid"}
+
+// LinkRewriter strips and appends query parameters of the URLs in outbound
+// messages, so that every link is tagged the same way.
+type LinkRewriter struct {
+	// StripParams are parameter names to remove. A name ending with "*"
+	// matches a prefix.
+	StripParams  []string
+	AppendParams map[string]string
+}
+
+func NewLinkRewriter(appendParams map[string]string) *LinkRewriter {
+	return &LinkRewriter{
+		StripParams:  DefaultTrackingParams,
+		AppendParams: appendParams,
+	}
+}
+
+// RewriteURL rewrites a single URL. Invalid URLs are returned untouched.
+func (r *LinkRewriter) RewriteURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	query := u.Query()
+	for name := range query {
+		for _, pattern := range r.StripParams {
+			if name == pattern || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))) {
+				query.Del(name)
+			}
+		}
+	}
+	for name, value := range r.AppendParams {
+		query.Set(name, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Rewrite rewrites every URL in a text.
+func (r *LinkRewriter) Rewrite(text string) string {
+	return urlPattern.ReplaceAllStringFunc(text, r.RewriteURL)
+}
+
+// Apply rewrites the URLs in the text, carousels and url buttons of a
+// message.
+func (r *LinkRewriter) Apply(m *OutboundMessage) {
+	m.Text = r.Rewrite(m.Text)
+	for i := range m.Elements {
+		el := &m.Elements[i]
+		el.Text = r.Rewrite(el.Text)
+		if el.ItemUrl != "" {
+			el.ItemUrl = r.RewriteURL(el.ItemUrl)
+		}
+		for j := range el.Buttons {
+			if el.Buttons[j].Type == "url" {
+				el.Buttons[j].Data = r.RewriteURL(el.Buttons[j].Data)
+			}
+		}
+	}
+}
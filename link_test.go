@@ -0,0 +1,11 @@
+package ambassador
+
+import "testing"
+
+func TestLinkRewriter(t *testing.T) {
+	r := NewLinkRewriter(map[string]string{"ref": "bot"})
+	text := r.Rewrite("see https://example.com/a?utm_source=fb&id=3&fbclid=x now")
+	if expected := "see https://example.com/a?id=3&ref=bot now"; text != expected {
+		t.Errorf("expect %q, got %q", expected, text)
+	}
+}
@@ -0,0 +1,162 @@
+package ambassador
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// LintIssue is a platform constraint an outbound message violates, with the
+// degradation the ambassador applies when the message is sent anyway.
+type LintIssue struct {
+	Platform   string `json:"platform"`
+	Path       string `json:"path"`
+	Problem    string `json:"problem"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+func (i LintIssue) String() string {
+	s := fmt.Sprintf("%s: %s: %s", i.Platform, i.Path, i.Problem)
+	if i.Suggestion != "" {
+		s += " (" + i.Suggestion + ")"
+	}
+	return s
+}
+
+// platformLimits are the constraints of a platform on outbound messages.
+type platformLimits struct {
+	messagesPerReply int
+	textLength       int
+	answers          int
+	answerTitle      int
+	questionText     int
+	columns          int
+	columnTitle      int
+	columnText       int
+	buttons          int
+	buttonLabel      int
+}
+
+// LintPlatforms are the platforms Lint knows the constraints of.
+var LintPlatforms = []string{"facebook", "line"}
+
+var lintLimits = map[string]platformLimits{
+	"facebook": {
+		textLength:   2000,
+		answers:      fbMaxQuickReplies,
+		answerTitle:  20,
+		questionText: 2000,
+		columns:      10,
+		columnTitle:  80,
+		columnText:   80,
+		buttons:      3,
+		buttonLabel:  20,
+	},
+	"line": {
+		messagesPerReply: 5,
+		textLength:       5000,
+		answers:          lineMaxActions,
+		answerTitle:      20,
+		questionText:     160,
+		columns:          5,
+		columnTitle:      40,
+		columnText:       120,
+		buttons:          3,
+		buttonLabel:      20,
+	},
+}
+
+// Lint reports every constraint the messages violate on the platforms, or
+// on all known platforms if none is given. Nothing is sent.
+func Lint(messages []OutboundMessage, platforms ...string) (issues []LintIssue) {
+	if len(platforms) == 0 {
+		platforms = LintPlatforms
+	}
+	for _, platform := range platforms {
+		limits, ok := lintLimits[platform]
+		if !ok {
+			issues = append(issues, LintIssue{Platform: platform, Path: "-", Problem: "unknown platform"})
+			continue
+		}
+		l := linter{platform: platform, limits: limits}
+		l.lint(messages)
+		issues = append(issues, l.issues...)
+	}
+	return
+}
+
+type linter struct {
+	platform string
+	limits   platformLimits
+	issues   []LintIssue
+}
+
+func (l *linter) report(path, problem, suggestion string) {
+	l.issues = append(l.issues, LintIssue{Platform: l.platform, Path: path, Problem: problem, Suggestion: suggestion})
+}
+
+func (l *linter) length(path, s string, limit int, suggestion string) {
+	if n := utf8.RuneCountInString(s); limit > 0 && n > limit {
+		l.report(path, fmt.Sprintf("%d characters exceed the limit of %d", n, limit), suggestion)
+	}
+}
+
+func (l *linter) lint(messages []OutboundMessage) {
+	if max := l.limits.messagesPerReply; max > 0 && len(messages) > max {
+		l.report("messages", fmt.Sprintf("%d messages exceed the limit of %d per reply", len(messages), max), "split them into several sends")
+	}
+	for i, m := range messages {
+		path := fmt.Sprintf("messages[%d]", i)
+		switch m.Type {
+		case OutboundText:
+			l.length(path+".text", m.Text, l.limits.textLength, "the platform rejects the message, split the text")
+		case OutboundQuestion:
+			l.question(path, m.Text, m.Answers)
+		case OutboundKeyboard:
+			if m.Keyboard == nil {
+				l.report(path, "a keyboard message needs a keyboard", "")
+				continue
+			}
+			l.question(path, m.Text, m.Keyboard.answers())
+		case OutboundTemplate:
+			l.template(path, m.Elements)
+		default:
+			l.report(path+".type", fmt.Sprintf("unknown message type %q", m.Type), "")
+		}
+	}
+}
+
+func (l *linter) question(path, text string, answers []map[string]string) {
+	l.length(path+".text", text, l.limits.questionText, "the platform rejects the message, shorten the question")
+	if len(answers) > l.limits.answers {
+		l.report(path+".answers", fmt.Sprintf("%d answers exceed the limit of %d", len(answers), l.limits.answers),
+			fmt.Sprintf("answers are paginated with a %q answer", MoreAnswersTitle))
+	}
+	for i, answer := range answers {
+		l.length(fmt.Sprintf("%s.answers[%d].title", path, i), answer["title"], l.limits.answerTitle, "the platform truncates or rejects it")
+	}
+}
+
+func (l *linter) template(path string, elements []Carousel) {
+	if len(elements) == 0 {
+		l.report(path+".elements", "a template needs elements", "")
+	}
+	if len(elements) > l.limits.columns {
+		l.report(path+".elements", fmt.Sprintf("%d elements exceed the limit of %d", len(elements), l.limits.columns),
+			"split the elements into several templates")
+	}
+	for i, e := range elements {
+		ePath := fmt.Sprintf("%s.elements[%d]", path, i)
+		if e.Title == "" && e.Text == "" {
+			l.report(ePath, "an element needs a title or a text", "")
+		}
+		l.length(ePath+".title", e.Title, l.limits.columnTitle, "the platform rejects the message, shorten the title")
+		l.length(ePath+".text", e.Text, l.limits.columnText, "the platform rejects the message, shorten the text")
+		if len(e.Buttons) > l.limits.buttons {
+			l.report(ePath+".buttons", fmt.Sprintf("%d buttons exceed the limit of %d", len(e.Buttons), l.limits.buttons),
+				"the platform rejects the message, remove buttons")
+		}
+		for j, btn := range e.Buttons {
+			l.length(fmt.Sprintf("%s.buttons[%d].label", ePath, j), btn.Label, l.limits.buttonLabel, "the platform rejects the message, shorten the label")
+		}
+	}
+}
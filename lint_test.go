@@ -0,0 +1,31 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	answers := []map[string]string{}
+	for i := 0; i < 5; i++ {
+		answers = append(answers, map[string]string{"title": "answer", "payload": "ANSWER"})
+	}
+	messages := []OutboundMessage{
+		TextMessage("hello"),
+		QuestionMessage("pick one", answers),
+		TemplateMessage([]Carousel{{Title: strings.Repeat("t", 50), Text: "text"}}),
+	}
+
+	issues := Lint(messages, "facebook")
+	if len(issues) != 0 {
+		t.Errorf("expect no issue on facebook, got %v", issues)
+	}
+
+	issues = Lint(messages, "line")
+	if len(issues) != 2 {
+		t.Fatalf("expect 2 issues on line, got %v", issues)
+	}
+	if issues[0].Path != "messages[1].answers" || issues[1].Path != "messages[2].elements[0].title" {
+		t.Errorf("unexpected issues: %v", issues)
+	}
+}
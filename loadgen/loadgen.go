@@ -0,0 +1,197 @@
+// Package loadgen drives the outbox of the ambassador package against fake
+// platform API servers, to measure the throughput and latency of sending
+// for capacity planning.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+type Config struct {
+	// Platform is "facebook" or "line".
+	Platform string
+	// RPS is the rate of enqueued envelopes per second.
+	RPS        float64
+	Duration   time.Duration
+	Recipients int
+	// FlushInterval is how often the outbox is flushed.
+	FlushInterval time.Duration
+	// Server is a fake server to send to. A new one is started if it is nil.
+	Server *testutil.FakeServer
+}
+
+type Report struct {
+	Enqueued   int
+	Sent       int
+	Retried    int
+	Dropped    int
+	Elapsed    time.Duration
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("enqueued=%d sent=%d retried=%d dropped=%d throughput=%.1f/s p50=%s p90=%s p99=%s max=%s",
+		r.Enqueued, r.Sent, r.Retried, r.Dropped, r.Throughput, r.P50, r.P90, r.P99, r.Max)
+}
+
+const textPrefix = "loadgen "
+
+// Run enqueues text messages at the configured rate for the duration, keeps
+// flushing until every envelope is sent or dropped, and reports the latency
+// from enqueueing to the arrival at the fake server.
+func Run(ctx context.Context, cfg Config) (report *Report, err error) {
+	if cfg.RPS <= 0 || cfg.Duration <= 0 {
+		return nil, fmt.Errorf("loadgen needs a positive rate and duration")
+	}
+	if cfg.Recipients <= 0 {
+		cfg.Recipients = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 10 * time.Millisecond
+	}
+	server := cfg.Server
+	if server == nil {
+		server = testutil.NewFakeServer()
+		defer server.Close()
+	}
+
+	var a ambassador.Ambassador
+	switch cfg.Platform {
+	case "facebook", "":
+		a = ambassador.NewFBAmbassador("loadgen", server.Client())
+	case "line":
+		a = ambassador.NewLineAmbassador("loadgen", server.Client())
+	default:
+		return nil, fmt.Errorf("unknown platform: %s", cfg.Platform)
+	}
+
+	var mu sync.Mutex
+	enqueuedAt := map[int]time.Time{}
+	latencies := []time.Duration{}
+	server.OnRequest = func(r testutil.Request) {
+		seq, ok := sequenceOf(r.Body)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if at, ok := enqueuedAt[seq]; ok {
+			latencies = append(latencies, r.At.Sub(at))
+			delete(enqueuedAt, seq)
+		}
+	}
+
+	report = &Report{}
+	outbox := ambassador.NewOutbox(a, nil)
+	outbox.RetryDelay = cfg.FlushInterval
+	outbox.Reporter = ambassador.ErrorReporterFunc(func(error, ambassador.ErrorReport) {
+		mu.Lock()
+		report.Dropped++
+		mu.Unlock()
+	})
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := time.Duration(float64(time.Second) / cfg.RPS)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		deadline := time.After(cfg.Duration)
+		for seq := 0; ; seq++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-deadline:
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			enqueuedAt[seq] = time.Now()
+			report.Enqueued++
+			mu.Unlock()
+			recipient := "user-" + strconv.Itoa(seq%cfg.Recipients)
+			outbox.Schedule(recipient, time.Now(), ambassador.TextMessage(textPrefix+strconv.Itoa(seq)))
+		}
+	}()
+
+	flushing := true
+	for flushing {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-done:
+			flushing = false
+		case <-time.After(cfg.FlushInterval):
+		}
+		if errs, ok := outbox.Flush().(ambassador.DeliveryErrors); ok {
+			report.Retried += len(errs)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		pending := len(enqueuedAt) - report.Dropped
+		mu.Unlock()
+		if pending <= 0 {
+			break
+		}
+		time.Sleep(cfg.FlushInterval)
+		if errs, ok := outbox.Flush().(ambassador.DeliveryErrors); ok {
+			report.Retried += len(errs)
+		}
+	}
+
+	report.Elapsed = time.Since(start)
+	mu.Lock()
+	defer mu.Unlock()
+	report.Retried -= report.Dropped
+	report.Sent = len(latencies)
+	report.Throughput = float64(report.Sent) / report.Elapsed.Seconds()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	report.P50 = percentile(latencies, 0.5)
+	report.P90 = percentile(latencies, 0.9)
+	report.P99 = percentile(latencies, 0.99)
+	report.Max = percentile(latencies, 1)
+	return
+}
+
+func sequenceOf(body []byte) (seq int, ok bool) {
+	s := string(body)
+	i := strings.Index(s, textPrefix)
+	if i < 0 {
+		return
+	}
+	s = s[i+len(textPrefix):]
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(s)
+	}
+	seq, err := strconv.Atoi(s[:end])
+	return seq, err == nil
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
@@ -0,0 +1,23 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, platform := range []string{"facebook", "line"} {
+		report, err := Run(context.Background(), Config{
+			Platform: platform,
+			RPS:      100,
+			Duration: 200 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Enqueued == 0 || report.Sent != report.Enqueued || report.Dropped != 0 {
+			t.Errorf("%s: unexpected report: %s", platform, report)
+		}
+	}
+}
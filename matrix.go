@@ -0,0 +1,359 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// matrixMenus keeps the payloads of the last numbered options sent to each
+// room, since Matrix has no buttons.
+var matrixMenus = &numberedMenus{menus: map[string][]string{}}
+
+// MatrixEvent is a room event of a sync response or an application service
+// transaction.
+type MatrixEvent struct {
+	Type           string        `json:"type"`
+	RoomId         string        `json:"room_id"`
+	Sender         string        `json:"sender"`
+	EventId        string        `json:"event_id"`
+	OriginServerTs int64         `json:"origin_server_ts"`
+	Content        MatrixContent `json:"content"`
+}
+
+type MatrixContent struct {
+	MsgType   string `json:"msgtype"`
+	Body      string `json:"body"`
+	GeoUri    string `json:"geo_uri"`
+	RelatesTo *struct {
+		InReplyTo *struct {
+			EventId string `json:"event_id"`
+		} `json:"m.in_reply_to"`
+	} `json:"m.relates_to"`
+}
+
+// MatrixTransaction is a transaction pushed to an application service.
+type MatrixTransaction struct {
+	Events []MatrixEvent `json:"events"`
+}
+
+// MatrixSync is the part of a /sync response with the timelines of joined
+// rooms.
+type MatrixSync struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []MatrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// matrixMessage is a staged event content with the payloads of its numbered
+// options, or a pause of WithTyping if it has no content.
+type matrixMessage struct {
+	content  map[string]interface{}
+	payloads []string
+	pause    time.Duration
+}
+
+// MatrixAmbassador talks to rooms of a homeserver by the client-server API.
+// Events come from application service transactions or from the responses
+// of /sync relayed to the webhook. The chat of a message is its room.
+type MatrixAmbassador struct {
+	sync.Mutex
+	homeserver string
+	token      string
+	// UserId is the user of the bot, whose own events are skipped, e.g.
+	// "@bot:example.org".
+	UserId       string
+	client       *http.Client
+	messages     []matrixMessage
+	lastMessages []interface{}
+	replyTo      string
+	correlation  string
+}
+
+func NewMatrixAmbassador(homeserver, token string, client *http.Client) *MatrixAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &MatrixAmbassador{homeserver: strings.TrimSuffix(homeserver, "/"), token: token, client: client}
+}
+
+func (m *MatrixAmbassador) SetCorrelationId(id string) {
+	m.correlation = id
+}
+
+func (m *MatrixAmbassador) Platform() string {
+	return "matrix"
+}
+
+// parseGeoUri parses a "geo:lat,lon" uri of a location message.
+func parseGeoUri(uri string) (lat, lon float64, ok bool) {
+	coordinates := strings.SplitN(strings.TrimPrefix(uri, "geo:"), ";", 2)[0]
+	parts := strings.Split(coordinates, ",")
+	if !strings.HasPrefix(uri, "geo:") || len(parts) < 2 {
+		return
+	}
+	lat, err1 := strconv.ParseFloat(parts[0], 64)
+	lon, err2 := strconv.ParseFloat(parts[1], 64)
+	return lat, lon, err1 == nil && err2 == nil
+}
+
+// Translate turns the room messages of a transaction or a sync response into
+// messages.
+func (m *MatrixAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var v struct {
+		MatrixTransaction
+		MatrixSync
+	}
+	if err = decodeJSON(limitReader(r), &v); err != nil {
+		return
+	}
+	events := v.Events
+	for roomId, room := range v.Rooms.Join {
+		for _, e := range room.Timeline.Events {
+			e.RoomId = roomId
+			events = append(events, e)
+		}
+	}
+	if len(events) > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
+
+	messages = []Message{}
+	for _, e := range events {
+		if e.Type != "m.room.message" || e.Sender == m.UserId {
+			continue
+		}
+		msg := Message{
+			SenderId:  e.Sender,
+			ChatId:    e.RoomId,
+			MessageId: e.EventId,
+			Timestamp: e.OriginServerTs,
+		}
+		if rel := e.Content.RelatesTo; rel != nil && rel.InReplyTo != nil {
+			msg.InReplyTo = rel.InReplyTo.EventId
+		}
+		switch e.Content.MsgType {
+		case "m.text", "m.notice":
+			msg.Content = &TextContent{Text: e.Content.Body}
+			if payload, ok := matrixMenus.lookup(e.RoomId, e.Content.Body); ok {
+				msg.Content = &CommandContent{Payload: payload}
+			}
+		case "m.location":
+			lat, lon, ok := parseGeoUri(e.Content.GeoUri)
+			if !ok {
+				continue
+			}
+			msg.Content = &LocationContent{Lat: lat, Lon: lon}
+		default:
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return
+}
+
+func (m *MatrixAmbassador) call(method, path string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest(method, m.homeserver+path, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	setCorrelationHeader(req, m.correlation)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to call matrix. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (m *MatrixAmbassador) stage(content map[string]interface{}, payloads []string) {
+	m.Lock()
+	defer m.Unlock()
+	m.messages = append(m.messages, matrixMessage{content: content, payloads: payloads})
+}
+
+// formatted is the content of a message with a plain body and an html
+// body.
+func formatted(body, htmlBody string) map[string]interface{} {
+	return map[string]interface{}{
+		"msgtype":        "m.text",
+		"body":           body,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": htmlBody,
+	}
+}
+
+func (m *MatrixAmbassador) SendText(text string) (err error) {
+	m.stage(map[string]interface{}{"msgtype": "m.text", "body": text}, nil)
+	return
+}
+
+// AskQuestion numbers the answers as an ordered list. A number replied in
+// the room comes back as a CommandContent of its payload.
+func (m *MatrixAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	lines := []string{text}
+	items := []string{}
+	payloads := []string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			payloads = append(payloads, payload)
+			lines = append(lines, fmt.Sprintf("%d. %s", len(payloads), title))
+			items = append(items, "<li>"+html.EscapeString(title)+"</li>")
+		}
+	}
+	m.stage(formatted(strings.Join(lines, "\n"),
+		fmt.Sprintf("<p>%s</p><ol>%s</ol>", html.EscapeString(text), strings.Join(items, ""))), payloads)
+	return
+}
+
+// SendTemplate sends every element as a card-like formatted message with
+// its image, title, text and links.
+func (m *MatrixAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	for _, col := range colItems {
+		lines := []string{col.Title}
+		parts := []string{}
+		if col.ImageUrl != "" {
+			parts = append(parts, fmt.Sprintf(`<img src="%s" alt="%s">`, html.EscapeString(col.ImageUrl), html.EscapeString(col.Title)))
+		}
+		title := html.EscapeString(col.Title)
+		if col.ItemUrl != "" {
+			title = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(col.ItemUrl), title)
+		}
+		parts = append(parts, "<h4>"+title+"</h4>")
+		if col.Text != "" {
+			lines = append(lines, col.Text)
+			parts = append(parts, "<p>"+html.EscapeString(col.Text)+"</p>")
+		}
+		for _, btn := range col.Buttons {
+			if btn.Type == "url" {
+				lines = append(lines, btn.Label+": "+btn.Data)
+				parts = append(parts, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(btn.Data), html.EscapeString(btn.Label)))
+			}
+		}
+		m.stage(formatted(strings.Join(lines, "\n"), "<blockquote>"+strings.Join(parts, "")+"</blockquote>"), nil)
+	}
+	return
+}
+
+// SendTyping is a no-op since typing notifications are sent to a room. Use
+// WithTyping instead.
+func (m *MatrixAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping shows the bot typing in the room for a duration before the
+// messages staged after it are sent.
+func (m *MatrixAmbassador) WithTyping(d time.Duration) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.messages = append(m.messages, matrixMessage{pause: d})
+	return
+}
+
+// MarkRead sends a read receipt of a message.
+func (m *MatrixAmbassador) MarkRead(msg Message) (err error) {
+	return m.call("POST", "/_matrix/client/v3/rooms/"+url.PathEscape(msg.ChatId)+
+		"/receipt/m.read/"+url.PathEscape(msg.MessageId), map[string]string{})
+}
+
+// ReplyTo replies to an event in the room.
+func (m *MatrixAmbassador) ReplyTo(messageId string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.replyTo = messageId
+	return
+}
+
+func (m *MatrixAmbassador) cleanMessage() {
+	m.Lock()
+	defer m.Unlock()
+	m.lastMessages = make([]interface{}, 0, len(m.messages))
+	for _, msg := range m.messages {
+		if msg.content != nil {
+			m.lastMessages = append(m.lastMessages, msg.content)
+		}
+	}
+	m.messages = nil
+	m.replyTo = ""
+}
+
+func (m *MatrixAmbassador) GetLastSent() []interface{} {
+	return m.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (m *MatrixAmbassador) Discard() {
+	m.Lock()
+	last := m.lastMessages
+	m.Unlock()
+	m.cleanMessage()
+	m.Lock()
+	m.lastMessages = last
+	m.Unlock()
+}
+
+// Send sends the staged messages to a room as m.room.message events.
+func (m *MatrixAmbassador) Send(recipientId string) (err error) {
+	defer m.cleanMessage()
+	room := "/_matrix/client/v3/rooms/" + url.PathEscape(recipientId)
+	var payloads []string
+	for _, msg := range m.messages {
+		if msg.content == nil {
+			if m.UserId != "" {
+				err = m.call("PUT", room+"/typing/"+url.PathEscape(m.UserId), map[string]interface{}{
+					"typing":  true,
+					"timeout": int64(msg.pause / time.Millisecond),
+				})
+				if err != nil {
+					return
+				}
+			}
+			time.Sleep(msg.pause)
+			continue
+		}
+		content := msg.content
+		if m.replyTo != "" {
+			content["m.relates_to"] = map[string]interface{}{
+				"m.in_reply_to": map[string]string{"event_id": m.replyTo},
+			}
+		}
+		if err = m.call("PUT", room+"/send/m.room.message/"+newId(), content); err != nil {
+			return
+		}
+		if msg.payloads != nil {
+			payloads = msg.payloads
+		}
+	}
+	if payloads != nil {
+		matrixMenus.set(recipientId, payloads)
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestMatrixConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	m := NewMatrixAmbassador("https://matrix.example.org", "token", server.Client())
+	m.UserId = "@bot:example.org"
+
+	m.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := m.Send("!room:example.org"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || !strings.HasPrefix(requests[0].Path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/") {
+		t.Fatalf("expect a message sent to the room, got %+v", requests)
+	}
+	var content map[string]string
+	json.Unmarshal(requests[0].Body, &content)
+	if content["body"] != "Which size?\n1. Small\n2. Large" || content["formatted_body"] != "<p>Which size?</p><ol><li>Small</li><li>Large</li></ol>" {
+		t.Errorf("unexpected question: %s", requests[0].Body)
+	}
+
+	messages, err := m.Translate(strings.NewReader(`{"events":[
+		{"type":"m.room.message","room_id":"!room:example.org","sender":"@bot:example.org","event_id":"$0",
+			"content":{"msgtype":"m.text","body":"Which size?"}},
+		{"type":"m.room.message","room_id":"!room:example.org","sender":"@u1:example.org","event_id":"$1",
+			"origin_server_ts":1700000000000,"content":{"msgtype":"m.text","body":"2"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expect the message of the bot to be skipped, got %+v", messages)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].ChatId != "!room:example.org" {
+		t.Errorf("expect the number as its payload, got %+v", messages[0])
+	}
+
+	messages, _ = m.Translate(strings.NewReader(`{"next_batch":"s1","rooms":{"join":{"!other:example.org":{"timeline":{"events":[
+		{"type":"m.room.message","sender":"@u2:example.org","event_id":"$2",
+			"content":{"msgtype":"m.location","body":"here","geo_uri":"geo:25.03,121.56;u=10"}}]}}}}}`))
+	if loc, ok := messages[0].Content.(*LocationContent); !ok || loc.Lon != 121.56 || messages[0].ChatId != "!other:example.org" {
+		t.Errorf("unexpected location of a sync: %+v", messages[0])
+	}
+}
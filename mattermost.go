@@ -0,0 +1,330 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MattermostPayload is the request of an outgoing webhook, or of a button
+// of an interactive message, which carries the context of the button.
+type MattermostPayload struct {
+	Token     string `json:"token"`
+	TeamId    string `json:"team_id"`
+	ChannelId string `json:"channel_id"`
+	UserId    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	PostId    string `json:"post_id"`
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"`
+	Context   *struct {
+		Payload string `json:"payload"`
+	} `json:"context"`
+}
+
+// mattermostMessage is a staged post, or a pause of WithTyping if it is
+// empty.
+type mattermostMessage struct {
+	Message     string        `json:"message"`
+	Attachments []interface{} `json:"attachments,omitempty"`
+	pause       time.Duration
+}
+
+// MattermostAmbassador receives messages by an outgoing webhook and posts
+// as a bot account by the REST API. The chat of a message is its channel.
+type MattermostAmbassador struct {
+	sync.Mutex
+	server string
+	token  string
+	// ActionURL is where buttons of interactive messages post to, usually
+	// the webhook of the ambassador. Buttons are not sent without it.
+	ActionURL string
+	// WebhookToken rejects outgoing webhook requests with another token.
+	WebhookToken string
+	// UserId is the user of the bot, which is needed to show typing and to
+	// mark channels as viewed.
+	UserId       string
+	client       *http.Client
+	messages     []mattermostMessage
+	lastMessages []interface{}
+	rootId       string
+	correlation  string
+}
+
+func NewMattermostAmbassador(server, token string, client *http.Client) *MattermostAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &MattermostAmbassador{server: strings.TrimSuffix(server, "/"), token: token, client: client}
+}
+
+func (m *MattermostAmbassador) SetCorrelationId(id string) {
+	m.correlation = id
+}
+
+func (m *MattermostAmbassador) Platform() string {
+	return "mattermost"
+}
+
+// readMattermostPayload reads a JSON body, or the form of an outgoing
+// webhook.
+func readMattermostPayload(body []byte) (p MattermostPayload, err error) {
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = jsonCodec.Unmarshal(trimmed, &p)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return
+	}
+	p.Token = form.Get("token")
+	p.TeamId = form.Get("team_id")
+	p.ChannelId = form.Get("channel_id")
+	p.UserId = form.Get("user_id")
+	p.UserName = form.Get("user_name")
+	p.PostId = form.Get("post_id")
+	p.Text = form.Get("text")
+	p.Timestamp, _ = strconv.ParseInt(form.Get("timestamp"), 10, 64)
+	return
+}
+
+// Translate turns an outgoing webhook request into a text message, and a
+// button request into a CommandContent of its payload.
+func (m *MattermostAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := ioutil.ReadAll(limitReader(r))
+	if err != nil {
+		return
+	}
+	p, err := readMattermostPayload(body)
+	if err != nil {
+		return
+	}
+
+	messages = []Message{}
+	if p.UserId == "" {
+		return
+	}
+	msg := Message{
+		SenderId:  p.UserId,
+		ChatId:    p.ChannelId,
+		MessageId: p.PostId,
+		Timestamp: p.Timestamp,
+	}
+	if p.Context != nil {
+		msg.InReplyTo = p.PostId
+		msg.MessageId = ""
+		msg.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+		msg.Content = &CommandContent{Payload: p.Context.Payload}
+	} else {
+		if m.WebhookToken != "" && p.Token != m.WebhookToken {
+			return nil, ErrInvalidSignature
+		}
+		msg.Content = &TextContent{Text: p.Text}
+	}
+	messages = append(messages, msg)
+	return
+}
+
+func (m *MattermostAmbassador) call(path string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", m.server+path, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	setCorrelationHeader(req, m.correlation)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to call mattermost. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (m *MattermostAmbassador) stage(message string, attachments ...interface{}) {
+	m.Lock()
+	defer m.Unlock()
+	m.messages = append(m.messages, mattermostMessage{Message: message, Attachments: attachments})
+}
+
+// button is a button of an interactive message posting a payload to the
+// action url.
+func (m *MattermostAmbassador) button(id, label, payload string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"name": label,
+		"integration": map[string]interface{}{
+			"url":     m.ActionURL,
+			"context": map[string]string{"payload": payload},
+		},
+	}
+}
+
+func (m *MattermostAmbassador) SendText(text string) (err error) {
+	m.stage(text)
+	return
+}
+
+// AskQuestion renders answers as buttons of an interactive message, whose
+// payloads come back as CommandContent.
+func (m *MattermostAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	if m.ActionURL == "" {
+		return fmt.Errorf("mattermost buttons need an action url")
+	}
+	actions := []interface{}{}
+	for i, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			actions = append(actions, m.button(fmt.Sprintf("answer%d", i), title, payload))
+		}
+	}
+	m.stage(text, map[string]interface{}{"actions": actions})
+	return
+}
+
+// SendTemplate renders every element as a message attachment. Url buttons
+// become links since buttons of interactive messages can only post to the
+// action url.
+func (m *MattermostAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	attachments := []interface{}{}
+	for i, col := range colItems {
+		lines := []string{}
+		if col.Text != "" {
+			lines = append(lines, col.Text)
+		}
+		actions := []interface{}{}
+		for j, btn := range col.Buttons {
+			switch {
+			case btn.Type == "url":
+				lines = append(lines, fmt.Sprintf("[%s](%s)", btn.Label, btn.Data))
+			case btn.Type == "postback" && m.ActionURL != "":
+				actions = append(actions, m.button(fmt.Sprintf("element%dbutton%d", i, j), btn.Label, btn.Data))
+			}
+		}
+		attachment := map[string]interface{}{
+			"fallback":   col.Title,
+			"title":      col.Title,
+			"title_link": col.ItemUrl,
+			"text":       strings.Join(lines, "\n"),
+			"image_url":  col.ImageUrl,
+		}
+		if len(actions) > 0 {
+			attachment["actions"] = actions
+		}
+		attachments = append(attachments, attachment)
+	}
+	m.stage("", attachments...)
+	return
+}
+
+// SendTyping is a no-op since typing is shown in a channel. Use WithTyping
+// instead.
+func (m *MattermostAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping shows the bot typing in the channel for a duration before the
+// messages staged after it are posted, if the user of the bot is set.
+func (m *MattermostAmbassador) WithTyping(d time.Duration) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.messages = append(m.messages, mattermostMessage{pause: d})
+	return
+}
+
+// MarkRead marks the channel of a message as viewed by the bot.
+func (m *MattermostAmbassador) MarkRead(msg Message) (err error) {
+	if m.UserId == "" {
+		return
+	}
+	return m.call("/api/v4/channels/members/"+url.PathEscape(m.UserId)+"/view",
+		map[string]string{"channel_id": msg.ChatId})
+}
+
+// ReplyTo posts the staged messages in the thread of a post.
+func (m *MattermostAmbassador) ReplyTo(messageId string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.rootId = messageId
+	return
+}
+
+func (m *MattermostAmbassador) cleanMessage() {
+	m.Lock()
+	defer m.Unlock()
+	m.lastMessages = make([]interface{}, 0, len(m.messages))
+	for _, msg := range m.messages {
+		if msg.Message != "" || msg.Attachments != nil {
+			m.lastMessages = append(m.lastMessages, msg)
+		}
+	}
+	m.messages = nil
+	m.rootId = ""
+}
+
+func (m *MattermostAmbassador) GetLastSent() []interface{} {
+	return m.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (m *MattermostAmbassador) Discard() {
+	m.Lock()
+	last := m.lastMessages
+	m.Unlock()
+	m.cleanMessage()
+	m.Lock()
+	m.lastMessages = last
+	m.Unlock()
+}
+
+// Send posts the staged messages to a channel.
+func (m *MattermostAmbassador) Send(recipientId string) (err error) {
+	defer m.cleanMessage()
+	for _, msg := range m.messages {
+		if msg.Message == "" && msg.Attachments == nil {
+			if m.UserId != "" {
+				err = m.call("/api/v4/users/"+url.PathEscape(m.UserId)+"/typing",
+					map[string]string{"channel_id": recipientId})
+				if err != nil {
+					return
+				}
+			}
+			time.Sleep(msg.pause)
+			continue
+		}
+		post := map[string]interface{}{
+			"channel_id": recipientId,
+			"message":    msg.Message,
+		}
+		if msg.Attachments != nil {
+			post["props"] = map[string]interface{}{"attachments": msg.Attachments}
+		}
+		if m.rootId != "" {
+			post["root_id"] = m.rootId
+		}
+		if err = m.call("/api/v4/posts", post); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestMattermostConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	m := NewMattermostAmbassador("https://chat.example.com/", "bot-token", server.Client())
+	m.ActionURL = "https://bot.example.com/webhook"
+	m.WebhookToken = "hook-token"
+
+	messages, err := m.Translate(strings.NewReader("token=hook-token&channel_id=c1&user_id=u1&post_id=p1&text=hello&timestamp=1700000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "hello" || messages[0].ChatId != "c1" || messages[0].MessageId != "p1" {
+		t.Errorf("unexpected message: %+v", messages[0])
+	}
+	if _, err := m.Translate(strings.NewReader("token=other&channel_id=c1&user_id=u1&text=hello")); err != ErrInvalidSignature {
+		t.Errorf("expect a webhook of another token to be rejected, got %v", err)
+	}
+
+	m.ReplyTo("p1")
+	m.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := m.Send(messages[0].ReplyTarget()); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/api/v4/posts" || requests[0].Header.Get("Authorization") != "Bearer bot-token" {
+		t.Fatalf("expect a post, got %+v", requests)
+	}
+	var post struct {
+		ChannelId string `json:"channel_id"`
+		RootId    string `json:"root_id"`
+		Message   string `json:"message"`
+		Props     struct {
+			Attachments []struct {
+				Actions []struct {
+					Name        string `json:"name"`
+					Integration struct {
+						Url     string            `json:"url"`
+						Context map[string]string `json:"context"`
+					} `json:"integration"`
+				} `json:"actions"`
+			} `json:"attachments"`
+		} `json:"props"`
+	}
+	json.Unmarshal(requests[0].Body, &post)
+	actions := post.Props.Attachments[0].Actions
+	if post.ChannelId != "c1" || post.RootId != "p1" || len(actions) != 2 ||
+		actions[1].Name != "Large" || actions[1].Integration.Context["payload"] != "SIZE_L" || actions[1].Integration.Url != m.ActionURL {
+		t.Errorf("unexpected question: %s", requests[0].Body)
+	}
+
+	messages, _ = m.Translate(strings.NewReader(`{"user_id":"u1","channel_id":"c1","post_id":"p2","type":"button","context":{"payload":"SIZE_L"}}`))
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].InReplyTo != "p2" {
+		t.Errorf("expect the button as its payload, got %+v", messages[0])
+	}
+}
@@ -0,0 +1,72 @@
+package ambassador
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+)
+
+// MediaStore keeps the platform handles of uploaded media by key. GetHandle
+// returns an empty handle without an error when a key is unknown.
+type MediaStore interface {
+	GetHandle(key string) (handle string, err error)
+	PutHandle(key, handle string) error
+}
+
+type MemoryMediaStore struct {
+	sync.Mutex
+	handles map[string]string
+}
+
+func NewMemoryMediaStore() *MemoryMediaStore {
+	return &MemoryMediaStore{handles: map[string]string{}}
+}
+
+func (s *MemoryMediaStore) GetHandle(key string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.handles[key], nil
+}
+
+func (s *MemoryMediaStore) PutHandle(key, handle string) error {
+	s.Lock()
+	defer s.Unlock()
+	s.handles[key] = handle
+	return nil
+}
+
+// MediaCache uploads an asset once per platform and reuses its handle, e.g.
+// a facebook attachment id, for the same content afterwards.
+type MediaCache struct {
+	store MediaStore
+}
+
+func NewMediaCache(store MediaStore) *MediaCache {
+	if store == nil {
+		store = NewMemoryMediaStore()
+	}
+	return &MediaCache{store: store}
+}
+
+// Upload returns the cached handle of the content on a platform, or uploads
+// it by the uploader. The reader is read twice, once for hashing and once for
+// uploading.
+func (c *MediaCache) Upload(platform string, u Uploader, r io.ReadSeeker, filename, mimeType string) (handle string, err error) {
+	h := sha256.New()
+	if _, err = io.Copy(h, r); err != nil {
+		return
+	}
+	key := platform + ":" + hex.EncodeToString(h.Sum(nil))
+
+	if handle, err = c.store.GetHandle(key); err != nil || handle != "" {
+		return
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	if handle, err = u.Upload(r, filename, mimeType); err != nil {
+		return
+	}
+	return handle, c.store.PutHandle(key, handle)
+}
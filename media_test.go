@@ -0,0 +1,47 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestMediaCache(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	server.Fail("facebook", testutil.Sequence(
+		&testutil.Failure{Status: 500, Body: `{"error":{"message":"try again"}}`},
+		&testutil.Failure{Body: `{"attachment_id":"a1"}`},
+		&testutil.Failure{Body: `{"attachment_id":"a2"}`},
+	))
+	fb := NewFBAmbassador("token", server.Client())
+	cache := NewMediaCache(nil)
+	upload := func(content string) (string, error) {
+		return cache.Upload("facebook", fb, strings.NewReader(content), "logo.png", "image/png")
+	}
+
+	if _, err := upload("logo"); err == nil {
+		t.Fatal("expect a failed upload to fail")
+	}
+	for i := 0; i < 2; i++ {
+		if handle, err := upload("logo"); err != nil || handle != "a1" {
+			t.Fatalf("expect the handle of the logo, got %q, %v", handle, err)
+		}
+	}
+	if handle, _ := upload("banner"); handle != "a2" {
+		t.Errorf("expect other content to be uploaded, got %q", handle)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expect a failed upload not to be cached and a cached one not to be uploaded again, got %d uploads", len(requests))
+	}
+	if body := string(requests[1].Body); !strings.Contains(body, `filename="logo.png"`) || !strings.Contains(body, "\r\nlogo\r\n") {
+		t.Errorf("expect the whole content to be uploaded after hashing, got %s", body)
+	}
+
+	if handle, err := cache.Upload("line", fb, strings.NewReader("logo"), "logo.png", "image/png"); err != nil || handle == "a1" {
+		t.Errorf("expect handles to be cached per platform, got %q, %v", handle, err)
+	}
+}
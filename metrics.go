@@ -0,0 +1,15 @@
+package ambassador
+
+// Metrics receives the measurements of the package, e.g. to be exported to
+// prometheus or statsd.
+type Metrics interface {
+	Count(name string, delta int64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+	Observe(name string, value float64, tags map[string]string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Count(string, int64, map[string]string)     {}
+func (nopMetrics) Gauge(string, float64, map[string]string)   {}
+func (nopMetrics) Observe(string, float64, map[string]string) {}
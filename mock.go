@@ -0,0 +1,185 @@
+package ambassador
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MockUserId = "mock-user"
+
+// Types of the messages recorded by a MockAmbassador.
+const (
+	MockText     = "text"
+	MockQuestion = "question"
+	MockTemplate = "template"
+	MockKeyboard = "keyboard"
+	MockTyping   = "typing"
+)
+
+// MockMessage is a message sent by a bot through a MockAmbassador.
+type MockMessage struct {
+	Type     string
+	Text     string
+	Answers  []map[string]string
+	Elements []Carousel
+	Keyboard *Keyboard
+	// Typing is how long a typing indicator is shown by WithTyping.
+	Typing time.Duration
+}
+
+// String renders a message in one line for test failures.
+func (m MockMessage) String() string {
+	switch m.Type {
+	case MockQuestion:
+		titles := make([]string, 0, len(m.Answers))
+		for _, answer := range m.Answers {
+			titles = append(titles, answer["title"])
+		}
+		return fmt.Sprintf("question %q [%s]", m.Text, strings.Join(titles, ", "))
+	case MockTemplate:
+		titles := make([]string, 0, len(m.Elements))
+		for _, col := range m.Elements {
+			titles = append(titles, col.Title)
+		}
+		return fmt.Sprintf("template [%s]", strings.Join(titles, ", "))
+	case MockKeyboard:
+		buttons := 0
+		if m.Keyboard != nil {
+			for _, row := range m.Keyboard.Rows {
+				buttons += len(row)
+			}
+		}
+		return fmt.Sprintf("keyboard %q with %d buttons", m.Text, buttons)
+	case MockTyping:
+		return "typing " + m.Typing.String()
+	}
+	return fmt.Sprintf("text %q", m.Text)
+}
+
+// MockDelivery is the messages of a Send.
+type MockDelivery struct {
+	RecipientId string
+	Messages    []MockMessage
+}
+
+// MockAmbassador records what a bot sends instead of calling a platform, to
+// test handlers without any channel.
+type MockAmbassador struct {
+	sync.Mutex
+	// Name is the platform of the ambassador. It defaults to "mock".
+	Name string
+	// Err fails every Send if it is not nil.
+	Err          error
+	staged       []MockMessage
+	deliveries   []MockDelivery
+	lastMessages []interface{}
+}
+
+func NewMockAmbassador() *MockAmbassador {
+	return &MockAmbassador{Name: "mock"}
+}
+
+func (m *MockAmbassador) Platform() string {
+	return m.Name
+}
+
+// Translate is a no-op since messages are passed to handlers directly.
+func (m *MockAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	return
+}
+
+func (m *MockAmbassador) stage(msg MockMessage) {
+	m.Lock()
+	defer m.Unlock()
+	m.staged = append(m.staged, msg)
+}
+
+func (m *MockAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	m.stage(MockMessage{Type: MockQuestion, Text: text, Answers: answers})
+	return
+}
+
+func (m *MockAmbassador) SendText(text string) (err error) {
+	m.stage(MockMessage{Type: MockText, Text: text})
+	return
+}
+
+func (m *MockAmbassador) SendTemplate(elements interface{}) (err error) {
+	columns, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	m.stage(MockMessage{Type: MockTemplate, Elements: columns})
+	return
+}
+
+func (m *MockAmbassador) SendKeyboard(text string, kb Keyboard) (err error) {
+	m.stage(MockMessage{Type: MockKeyboard, Text: text, Keyboard: &kb})
+	return
+}
+
+// SendTyping records a typing indicator turned on, and ignores one turned
+// off.
+func (m *MockAmbassador) SendTyping(on bool) (err error) {
+	if on {
+		m.stage(MockMessage{Type: MockTyping})
+	}
+	return
+}
+
+func (m *MockAmbassador) WithTyping(d time.Duration) (err error) {
+	m.stage(MockMessage{Type: MockTyping, Typing: d})
+	return
+}
+
+func (m *MockAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (m *MockAmbassador) GetLastSent() []interface{} {
+	m.Lock()
+	defer m.Unlock()
+	return m.lastMessages
+}
+
+func (m *MockAmbassador) Send(recipientId string) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	staged := m.staged
+	m.staged = nil
+	if m.Err != nil {
+		return m.Err
+	}
+	m.lastMessages = make([]interface{}, 0, len(staged))
+	for _, msg := range staged {
+		m.lastMessages = append(m.lastMessages, msg)
+	}
+	m.deliveries = append(m.deliveries, MockDelivery{RecipientId: recipientId, Messages: staged})
+	return
+}
+
+// Deliveries returns what has been sent.
+func (m *MockAmbassador) Deliveries() []MockDelivery {
+	m.Lock()
+	defer m.Unlock()
+	return append([]MockDelivery(nil), m.deliveries...)
+}
+
+// Discard drops the staged messages without sending them.
+func (m *MockAmbassador) Discard() {
+	m.Lock()
+	defer m.Unlock()
+	m.staged = nil
+}
+
+// Reset forgets what has been sent.
+func (m *MockAmbassador) Reset() {
+	m.Lock()
+	defer m.Unlock()
+	m.staged = nil
+	m.deliveries = nil
+	m.lastMessages = nil
+}
@@ -0,0 +1,108 @@
+package ambassador
+
+import "strings"
+
+const NLPConfidenceThreshold = 0.8
+
+// NLP holds the built-in natural language annotations of a text message.
+type NLP struct {
+	Intents         []NLPIntent            `json:"intents,omitempty"`
+	Entities        map[string][]NLPEntity `json:"entities,omitempty"`
+	Traits          map[string][]NLPTrait  `json:"traits,omitempty"`
+	DetectedLocales []NLPLocale            `json:"detected_locales,omitempty"`
+}
+
+type NLPIntent struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+type NLPEntity struct {
+	Name       string      `json:"name"`
+	Role       string      `json:"role"`
+	Body       string      `json:"body"`
+	Confidence float64     `json:"confidence"`
+	Value      interface{} `json:"value"`
+	Grain      string      `json:"grain,omitempty"`
+	Type       string      `json:"type,omitempty"`
+}
+
+type NLPTrait struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+type NLPLocale struct {
+	Locale     string  `json:"locale"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Entity returns the most confident entity of a name, e.g. "datetime". Both
+// the wit$ prefixed names and the legacy names are looked up.
+func (n *NLP) Entity(name string) (entity NLPEntity, ok bool) {
+	if n == nil {
+		return
+	}
+	for key, entities := range n.Entities {
+		if key != name && strings.SplitN(strings.TrimPrefix(key, "wit$"), ":", 2)[0] != name {
+			continue
+		}
+		for _, e := range entities {
+			if !ok || e.Confidence > entity.Confidence {
+				entity, ok = e, true
+			}
+		}
+	}
+	return
+}
+
+// Trait returns the most confident value of a trait if its confidence is
+// above NLPConfidenceThreshold.
+func (n *NLP) Trait(name string) (value string, ok bool) {
+	if n == nil {
+		return
+	}
+	traits, found := n.Traits["wit$"+name]
+	if !found {
+		traits, found = n.Traits[name]
+	}
+	if !found {
+		// legacy annotations put traits in the entities.
+		for _, e := range n.Entities[name] {
+			if s, isString := e.Value.(string); isString {
+				traits = append(traits, NLPTrait{Value: s, Confidence: e.Confidence})
+			}
+		}
+	}
+
+	var best NLPTrait
+	for _, t := range traits {
+		if t.Confidence > best.Confidence {
+			best = t
+		}
+	}
+	if best.Confidence < NLPConfidenceThreshold {
+		return
+	}
+	return best.Value, true
+}
+
+// Sentiment returns positive, neutral or negative when it is detected.
+func (n *NLP) Sentiment() (sentiment string, ok bool) {
+	return n.Trait("sentiment")
+}
+
+func (n *NLP) IsGreeting() bool {
+	v, ok := n.Trait("greetings")
+	return ok && v == "true"
+}
+
+func (n *NLP) IsThanks() bool {
+	v, ok := n.Trait("thanks")
+	return ok && v == "true"
+}
+
+func (n *NLP) IsBye() bool {
+	v, ok := n.Trait("bye")
+	return ok && v == "true"
+}
@@ -0,0 +1,33 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFBTranslateNLP(t *testing.T) {
+	body := `{"object":"page","entry":[{"id":"1","time":1,"messaging":[{
+		"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1,
+		"message":{"mid":"m1","text":"hi tomorrow","nlp":{
+			"entities":{"wit$datetime:datetime":[{"name":"wit$datetime","body":"tomorrow","confidence":0.9,"value":"2020-01-02T00:00:00.000-08:00","grain":"day"}]},
+			"traits":{"wit$sentiment":[{"value":"positive","confidence":0.85}],"wit$greetings":[{"value":"true","confidence":0.99}]}
+		}}}]}]}`
+
+	messages, err := NewFBAmbassador("token", nil).Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, ok := messages[0].Content.(*TextContent)
+	if !ok {
+		t.Fatalf("unexpected content: %+v", messages[0].Content)
+	}
+	if s, ok := content.NLP.Sentiment(); !ok || s != "positive" {
+		t.Errorf("unexpected sentiment: %s", s)
+	}
+	if !content.NLP.IsGreeting() {
+		t.Error("expect a greeting")
+	}
+	if e, ok := content.NLP.Entity("datetime"); !ok || e.Body != "tomorrow" {
+		t.Errorf("unexpected datetime entity: %+v", e)
+	}
+}
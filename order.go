@@ -0,0 +1,114 @@
+package ambassador
+
+import (
+	"fmt"
+	"time"
+)
+
+type OrderItem struct {
+	Title    string
+	Subtitle string
+	Quantity int
+	// Price is the price of a unit.
+	Price    float64
+	ImageUrl string
+}
+
+// Order is a platform neutral description of an order.
+type Order struct {
+	Id            string
+	Status        string
+	RecipientName string
+	// Currency is an ISO 4217 code, e.g. "USD".
+	Currency      string
+	PaymentMethod string
+	Url           string
+	Items         []OrderItem
+	Total         float64
+	PlacedAt      time.Time
+}
+
+// Shipment is a platform neutral description of a shipment of an order.
+type Shipment struct {
+	OrderId           string
+	Status            string
+	Carrier           string
+	TrackingNumber    string
+	TrackingUrl       string
+	EstimatedDelivery time.Time
+}
+
+// CommerceSender is implemented by ambassadors with native templates of
+// orders and shipments.
+type CommerceSender interface {
+	SendOrderStatus(o Order) (err error)
+	SendShippingUpdate(s Shipment) (err error)
+}
+
+func formatPrice(currency string, amount float64) string {
+	return fmt.Sprintf("%s %.2f", currency, amount)
+}
+
+// summary is a line of the status and the total of an order.
+func (o *Order) summary() string {
+	quantity := 0
+	for _, item := range o.Items {
+		quantity += item.Quantity
+	}
+	return fmt.Sprintf("%s · %d items · %s", o.Status, quantity, formatPrice(o.Currency, o.Total))
+}
+
+func (o *Order) carousel() []Carousel {
+	col := Carousel{Title: "Order #" + o.Id, Text: o.summary(), ItemUrl: o.Url}
+	if len(o.Items) > 0 {
+		col.ImageUrl = o.Items[0].ImageUrl
+	}
+	if o.Url != "" {
+		col.Buttons = []CarouselButton{{Label: "View order", Type: "url", Data: o.Url}}
+	}
+	return []Carousel{col}
+}
+
+// details are the lines of the carrier, the tracking number and the
+// estimated delivery of a shipment.
+func (s *Shipment) details() (lines []string) {
+	if s.Carrier != "" {
+		lines = append(lines, s.Carrier+" "+s.TrackingNumber)
+	}
+	if !s.EstimatedDelivery.IsZero() {
+		lines = append(lines, "Arriving "+s.EstimatedDelivery.Format("Mon, Jan 2"))
+	}
+	return
+}
+
+func (s *Shipment) carousel() []Carousel {
+	col := Carousel{Title: fmt.Sprintf("Order #%s %s", s.OrderId, s.Status), ItemUrl: s.TrackingUrl}
+	for i, line := range s.details() {
+		if i > 0 {
+			col.Text += "\n"
+		}
+		col.Text += line
+	}
+	if s.TrackingUrl != "" {
+		col.Buttons = []CarouselButton{{Label: "Track package", Type: "url", Data: s.TrackingUrl}}
+	}
+	return []Carousel{col}
+}
+
+// SendOrderStatus stages the status of an order by the native template of
+// the platform, or by a generic template elsewhere.
+func SendOrderStatus(a Ambassador, o Order) error {
+	if c, ok := unwrap(a).(CommerceSender); ok {
+		return c.SendOrderStatus(o)
+	}
+	return a.SendTemplate(o.carousel())
+}
+
+// SendShippingUpdate stages a shipment with its tracking link by the native
+// template of the platform, or by a generic template elsewhere.
+func SendShippingUpdate(a Ambassador, s Shipment) error {
+	if c, ok := unwrap(a).(CommerceSender); ok {
+		return c.SendShippingUpdate(s)
+	}
+	return a.SendTemplate(s.carousel())
+}
@@ -0,0 +1,75 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestOrderTemplates(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	order := Order{
+		Id:       "1001",
+		Status:   "Paid",
+		Currency: "USD",
+		Url:      "https://shop.example.com/orders/1001",
+		Items:    []OrderItem{{Title: "Oolong", Quantity: 2, Price: 12.5}},
+		Total:    25,
+	}
+	shipment := Shipment{
+		OrderId:           "1001",
+		Status:            "Shipped",
+		Carrier:           "UPS",
+		TrackingNumber:    "1Z999",
+		TrackingUrl:       "https://ups.example.com/1Z999",
+		EstimatedDelivery: time.Date(2017, 7, 14, 0, 0, 0, 0, time.UTC),
+	}
+
+	fb := NewFBAmbassador("token", server.Client())
+	SendOrderStatus(fb, order)
+	SendShippingUpdate(fb, shipment)
+	if err := fb.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	var receipt struct {
+		Tag     string `json:"tag"`
+		Message struct {
+			Attachment struct {
+				Payload struct {
+					TemplateType string `json:"template_type"`
+					OrderNumber  string `json:"order_number"`
+				}
+			}
+		}
+	}
+	json.Unmarshal(requests[0].Body, &receipt)
+	if receipt.Tag != FBTagPostPurchaseUpdate || receipt.Message.Attachment.Payload.TemplateType != "receipt" ||
+		receipt.Message.Attachment.Payload.OrderNumber != "1001" {
+		t.Errorf("unexpected receipt: %s", requests[0].Body)
+	}
+	if !strings.Contains(string(requests[1].Body), "https://ups.example.com/1Z999") {
+		t.Errorf("expect the tracking link, got %s", requests[1].Body)
+	}
+
+	line := NewLineAmbassador("token", server.Client())
+	if err := SendOrderStatus(line, order); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendShippingUpdate(line, shipment); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &recordAmbassador{}
+	SendShippingUpdate(a, shipment)
+	if len(a.staged) != 1 || a.staged[0] != "template" {
+		t.Errorf("expect a generic template elsewhere, got %v", a.staged)
+	}
+	if text := shipment.carousel()[0].Text; text != "UPS 1Z999\nArriving Fri, Jul 14" {
+		t.Errorf("unexpected shipment details: %q", text)
+	}
+}
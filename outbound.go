@@ -0,0 +1,81 @@
+package ambassador
+
+import "fmt"
+
+const (
+	OutboundText     = "text"
+	OutboundQuestion = "question"
+	OutboundTemplate = "template"
+	OutboundKeyboard = "keyboard"
+)
+
+// OutboundMessage describes a message independently of an ambassador so
+// that it can be queued, stored and staged on any ambassador later.
+type OutboundMessage struct {
+	Type     string              `json:"type"`
+	Text     string              `json:"text,omitempty"`
+	Answers  []map[string]string `json:"answers,omitempty"`
+	Elements []Carousel          `json:"elements,omitempty"`
+	Keyboard *Keyboard           `json:"keyboard,omitempty"`
+	// InReplyTo threads the message under another message on platforms
+	// which support it, and is ignored elsewhere.
+	InReplyTo string `json:"in_reply_to,omitempty"`
+	// DisablePreview turns off URL previews on platforms which support it.
+	DisablePreview bool `json:"disable_preview,omitempty"`
+}
+
+func TextMessage(text string) OutboundMessage {
+	return OutboundMessage{Type: OutboundText, Text: text}
+}
+
+func QuestionMessage(text string, answers []map[string]string) OutboundMessage {
+	return OutboundMessage{Type: OutboundQuestion, Text: text, Answers: answers}
+}
+
+func TemplateMessage(elements []Carousel) OutboundMessage {
+	return OutboundMessage{Type: OutboundTemplate, Elements: elements}
+}
+
+func KeyboardMessage(text string, kb Keyboard) OutboundMessage {
+	return OutboundMessage{Type: OutboundKeyboard, Text: text, Keyboard: &kb}
+}
+
+// Stage stages the message on an ambassador.
+func (m *OutboundMessage) Stage(a Ambassador) (err error) {
+	if t, ok := unwrap(a).(Threader); ok && m.InReplyTo != "" {
+		if err = t.ReplyTo(m.InReplyTo); err != nil {
+			return
+		}
+	}
+	if p, ok := unwrap(a).(PreviewController); ok {
+		if err = p.SetLinkPreview(!m.DisablePreview); err != nil {
+			return
+		}
+	}
+	switch m.Type {
+	case OutboundText:
+		return a.SendText(m.Text)
+	case OutboundQuestion:
+		return a.AskQuestion(m.Text, m.Answers)
+	case OutboundTemplate:
+		return a.SendTemplate(m.Elements)
+	case OutboundKeyboard:
+		if m.Keyboard == nil {
+			return fmt.Errorf("a keyboard message needs a keyboard")
+		}
+		return SendKeyboard(a, m.Text, *m.Keyboard)
+	}
+	return fmt.Errorf("unknown outbound message type: %s", m.Type)
+}
+
+// Messages returns a builder which stages outbound messages in order.
+func Messages(messages ...OutboundMessage) MessageBuilder {
+	return func(a Ambassador) (err error) {
+		for i := range messages {
+			if err = messages[i].Stage(a); err != nil {
+				return
+			}
+		}
+		return
+	}
+}
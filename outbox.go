@@ -0,0 +1,356 @@
+package ambassador
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutboxInterval = time.Second
+	defaultOutboxBatch    = 100
+	defaultMaxAttempts    = 3
+	defaultRetryDelay     = 30 * time.Second
+)
+
+var ErrEnvelopeExpired = errors.New("ambassador: envelope expired before delivery")
+
+// Envelope is a queued delivery of messages to a recipient.
+type Envelope struct {
+	Id          string
+	RecipientId string
+	Messages    []OutboundMessage
+	SendAt      time.Time
+	Attempts    int
+	// Urgent envelopes are delivered regardless of the delivery window.
+	Urgent        bool
+	CorrelationId string
+	// ExpiresAt drops an envelope which can not be delivered in time. Zero
+	// means it never expires.
+	ExpiresAt time.Time
+	// Tag is the message tag of a send outside the messaging window.
+	Tag string
+	// Tenant is charged for the delivery when the outbox has a quota.
+	Tenant string
+	// OnResult is called with the final result of the envelope, after it is
+	// delivered or it fails permanently. It is kept in memory only, so it is
+	// lost by stores which persist envelopes elsewhere.
+	OnResult func(r SendResult) `json:"-"`
+}
+
+// SendResult is the final result of an envelope.
+type SendResult struct {
+	EnvelopeId  string
+	RecipientId string
+	Delivered   bool
+	// Err is why an envelope is not delivered, e.g. ErrEnvelopeExpired.
+	Err      error
+	Attempts int
+	At       time.Time
+}
+
+// Expired tells whether an envelope is stale at a given time.
+func (e *Envelope) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// OutboxStore keeps queued envelopes until they are delivered.
+type OutboxStore interface {
+	Put(e *Envelope) error
+	// Due returns envelopes which should be sent at or before now, the
+	// earliest first.
+	Due(now time.Time, limit int) ([]*Envelope, error)
+	Delete(id string) error
+}
+
+type MemoryOutboxStore struct {
+	sync.Mutex
+	envelopes map[string]*Envelope
+}
+
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{envelopes: map[string]*Envelope{}}
+}
+
+func (s *MemoryOutboxStore) Put(e *Envelope) error {
+	s.Lock()
+	defer s.Unlock()
+	s.envelopes[e.Id] = e
+	return nil
+}
+
+func (s *MemoryOutboxStore) Due(now time.Time, limit int) ([]*Envelope, error) {
+	s.Lock()
+	defer s.Unlock()
+	due := []*Envelope{}
+	for _, e := range s.envelopes {
+		if !e.SendAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(due[j].SendAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *MemoryOutboxStore) Delete(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.envelopes, id)
+	return nil
+}
+
+// Outbox delivers envelopes through an ambassador when they are due. Failed
+// deliveries are retried with a growing delay until MaxAttempts is reached.
+type Outbox struct {
+	sync.Mutex
+	a           Ambassador
+	store       OutboxStore
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	RetryDelay  time.Duration
+	// Window holds non-urgent envelopes until the window of their
+	// recipients opens.
+	Window *DeliveryWindow
+	// OnExpired is called with every envelope dropped for its expiry.
+	OnExpired func(e *Envelope)
+	// Limiter throttles deliveries, e.g. a RedisLimiter shared by replicas.
+	Limiter RateLimiter
+	// Reporter is told about envelopes which fail permanently.
+	Reporter ErrorReporter
+	// Quota drops envelopes of tenants which are over their budget.
+	Quota *Quota
+	// Policy drops envelopes which would be sent outside the messaging
+	// window of the platform without a tag.
+	Policy *MessagingPolicy
+	// DeadLetters keeps envelopes which fail permanently, so that they can
+	// be resent later.
+	DeadLetters DeadLetterStore
+	// OnResult is called with the final result of every envelope, besides
+	// the callback of the envelope.
+	OnResult func(e *Envelope, r SendResult)
+	now      func() time.Time
+}
+
+func NewOutbox(a Ambassador, store OutboxStore) *Outbox {
+	if store == nil {
+		store = NewMemoryOutboxStore()
+	}
+	return &Outbox{
+		a:           a,
+		store:       store,
+		Interval:    defaultOutboxInterval,
+		BatchSize:   defaultOutboxBatch,
+		MaxAttempts: defaultMaxAttempts,
+		RetryDelay:  defaultRetryDelay,
+		now:         time.Now,
+	}
+}
+
+// Enqueue queues an envelope. An envelope without SendAt is sent on the next
+// flush.
+func (o *Outbox) Enqueue(e *Envelope) (err error) {
+	if e.Id == "" {
+		e.Id = newId()
+	}
+	if e.SendAt.IsZero() {
+		e.SendAt = o.now()
+	}
+	return o.store.Put(e)
+}
+
+// ScheduleWithTTL queues messages to a recipient at a given time, which are
+// dropped if they can not be delivered within the ttl. A ttl of zero or less
+// never expires.
+func (o *Outbox) ScheduleWithTTL(recipientId string, at time.Time, ttl time.Duration, messages ...OutboundMessage) (e *Envelope, err error) {
+	e = &Envelope{RecipientId: recipientId, Messages: messages, SendAt: at}
+	if ttl > 0 {
+		e.ExpiresAt = at.Add(ttl)
+	}
+	if err = o.Enqueue(e); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Schedule queues messages to a recipient at a given time.
+func (o *Outbox) Schedule(recipientId string, at time.Time, messages ...OutboundMessage) (e *Envelope, err error) {
+	e = &Envelope{RecipientId: recipientId, Messages: messages, SendAt: at}
+	if err = o.Enqueue(e); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Cancel removes a queued envelope.
+func (o *Outbox) Cancel(id string) error {
+	return o.store.Delete(id)
+}
+
+// Flush delivers all due envelopes.
+func (o *Outbox) Flush() (err error) {
+	due, err := o.store.Due(o.now(), o.BatchSize)
+	if err != nil {
+		return
+	}
+
+	errs := DeliveryErrors{}
+	for _, e := range due {
+		if dispatchErr := o.dispatch(e); dispatchErr != nil {
+			errs[e.Id] = dispatchErr
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+func (o *Outbox) dispatch(e *Envelope) (err error) {
+	now := o.now()
+	if e.Expired(now) {
+		o.store.Delete(e.Id)
+		if o.OnExpired != nil {
+			o.OnExpired(e)
+		}
+		o.result(e, ErrEnvelopeExpired)
+		return ErrEnvelopeExpired
+	}
+
+	if o.Window != nil && !e.Urgent {
+		open, windowErr := o.Window.NextOpen(e.RecipientId, now)
+		if windowErr != nil {
+			return o.retry(e, fmt.Errorf("fail to find the delivery window: %s", windowErr))
+		}
+		if open.After(now) {
+			e.SendAt = open
+			return o.store.Put(e)
+		}
+	}
+
+	if o.Policy != nil {
+		if err = o.Policy.Check(platformOf(o.a), e.RecipientId, e.Tag); err != nil {
+			o.store.Delete(e.Id)
+			o.report(e, err)
+			return
+		}
+	}
+
+	if o.Limiter != nil {
+		if err = o.Limiter.Wait(context.Background()); err != nil {
+			return o.retry(e, fmt.Errorf("fail to wait for the rate limit: %s", err))
+		}
+	}
+
+	var reservation *Reservation
+	if o.Quota != nil {
+		if reservation, err = o.Quota.Reserve(e.Tenant, 1); err != nil {
+			o.store.Delete(e.Id)
+			o.report(e, err)
+			return
+		}
+	}
+
+	o.Lock()
+	if c, ok := o.a.(Correlator); ok {
+		c.SetCorrelationId(e.CorrelationId)
+	}
+	if t, ok := o.a.(Tagger); ok && e.Tag != "" {
+		t.SetMessageTag(e.Tag)
+	}
+	err = deliver(o.a, e.RecipientId, Messages(e.Messages...))
+	o.Unlock()
+
+	if err == nil {
+		o.result(e, nil)
+		return o.store.Delete(e.Id)
+	}
+	if reservation != nil {
+		o.Quota.Release(reservation)
+	}
+	return o.retry(e, err)
+}
+
+// retry backs off an envelope which fails to be delivered, and reports it
+// once it fails MaxAttempts times.
+func (o *Outbox) retry(e *Envelope, err error) error {
+	e.Attempts++
+	if e.Attempts >= o.MaxAttempts {
+		o.store.Delete(e.Id)
+		o.report(e, err)
+		return err
+	}
+	e.SendAt = o.now().Add(o.RetryDelay * time.Duration(e.Attempts))
+	o.store.Put(e)
+	return err
+}
+
+// result calls the result callbacks of an envelope.
+func (o *Outbox) result(e *Envelope, err error) {
+	if e.OnResult == nil && o.OnResult == nil {
+		return
+	}
+	r := SendResult{
+		EnvelopeId:  e.Id,
+		RecipientId: e.RecipientId,
+		Delivered:   err == nil,
+		Err:         err,
+		Attempts:    e.Attempts + 1,
+		At:          o.now(),
+	}
+	if err != nil {
+		r.Attempts = e.Attempts
+	}
+	if o.OnResult != nil {
+		o.OnResult(e, r)
+	}
+	if e.OnResult != nil {
+		e.OnResult(r)
+	}
+}
+
+// report reports an envelope which fails permanently.
+func (o *Outbox) report(e *Envelope, err error) {
+	o.result(e, err)
+	if o.DeadLetters != nil {
+		o.DeadLetters.Put(&DeadLetter{Envelope: e, Error: err.Error(), FailedAt: o.now()})
+	}
+	if o.Reporter == nil {
+		return
+	}
+	payload, _ := json.Marshal(e.Messages)
+	o.Reporter.Report(err, ErrorReport{
+		Platform:    platformOf(o.a),
+		RecipientId: e.RecipientId,
+		Payload:     summarize(payload),
+	})
+}
+
+// Run flushes the outbox periodically until the context is done.
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			o.Flush()
+		}
+	}
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,260 @@
+package ambassador
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCampaignRunner(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	a := &recordAmbassador{}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+
+	runner := NewCampaignRunner(&Campaign{
+		Id: "welcome",
+		Steps: []CampaignStep{
+			{Delay: time.Hour, Messages: []OutboundMessage{TextMessage("day 0")}},
+			{Delay: 20 * time.Hour, Messages: []OutboundMessage{TextMessage("day 1")}},
+			{Delay: 48 * time.Hour, Messages: []OutboundMessage{TextMessage("day 3")}},
+		},
+		ExitOn: func(msg Message) bool {
+			c, ok := msg.Content.(*CommandContent)
+			return ok && c.Payload == "BUY"
+		},
+		Window: 24 * time.Hour,
+	}, outbox)
+
+	if err := runner.Enroll("u1", now); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := outbox.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.sent) != 1 || a.sent[0] != "u1:day 0" {
+		t.Fatalf("unexpected deliveries: %+v", a.sent)
+	}
+
+	if exited, _ := runner.HandleMessage(Message{SenderId: "u1", Content: &CommandContent{Payload: "BUY"}}); !exited {
+		t.Fatal("expect the user to exit the campaign")
+	}
+	now = now.Add(100 * time.Hour)
+	outbox.Flush()
+	if len(a.sent) != 1 {
+		t.Errorf("expect no more deliveries, got %+v", a.sent)
+	}
+}
+
+func TestOutboxExpiry(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	a := &recordAmbassador{}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+
+	var expired []*Envelope
+	outbox.OnExpired = func(e *Envelope) { expired = append(expired, e) }
+
+	outbox.ScheduleWithTTL("u1", now, time.Minute, TextMessage("flash sale"))
+	outbox.Schedule("u1", now, TextMessage("newsletter"))
+	if e, _ := outbox.ScheduleWithTTL("u1", now, 0, TextMessage("receipt")); !e.ExpiresAt.IsZero() {
+		t.Errorf("expect a zero ttl never to expire, got %s", e.ExpiresAt)
+	}
+
+	now = now.Add(time.Hour)
+	err := outbox.Flush()
+	if errs, ok := err.(DeliveryErrors); !ok || len(errs) != 1 {
+		t.Fatalf("expect one expiry error, got %v", err)
+	}
+	if len(expired) != 1 || expired[0].Messages[0].Text != "flash sale" {
+		t.Errorf("unexpected expired envelopes: %+v", expired)
+	}
+	sort.Strings(a.sent)
+	if len(a.sent) != 2 || a.sent[0] != "u1:newsletter" || a.sent[1] != "u1:receipt" {
+		t.Errorf("unexpected deliveries: %+v", a.sent)
+	}
+}
+
+func TestOutboxQuota(t *testing.T) {
+	now := time.Date(2017, 7, 14, 12, 0, 0, 0, time.UTC)
+	a := &recordAmbassador{}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+	outbox.Quota = NewQuota(nil)
+	outbox.Quota.now = outbox.now
+	outbox.Quota.SetBudget("acme", Budget{Daily: 2, Monthly: 3})
+
+	send := func(text string) error {
+		outbox.Enqueue(&Envelope{RecipientId: "u1", Tenant: "acme", Messages: []OutboundMessage{TextMessage(text)}})
+		return outbox.Flush()
+	}
+	send("1")
+	send("2")
+	if errs, ok := send("3").(DeliveryErrors); !ok || len(errs) != 1 {
+		t.Fatal("expect the daily budget to be exceeded")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if err := send("4"); err != nil {
+		t.Fatal(err)
+	}
+	if errs, ok := send("5").(DeliveryErrors); !ok || len(errs) != 1 {
+		t.Fatal("expect the monthly budget to be exceeded")
+	}
+	for _, err := range send("6").(DeliveryErrors) {
+		if err != ErrQuotaExceeded {
+			t.Errorf("expect ErrQuotaExceeded, got %v", err)
+		}
+	}
+	if len(a.sent) != 3 {
+		t.Errorf("unexpected deliveries: %+v", a.sent)
+	}
+	if daily, monthly, _ := outbox.Quota.Usage("acme"); daily != 1 || monthly != 3 {
+		t.Errorf("unexpected usage: %d %d", daily, monthly)
+	}
+}
+
+func TestOutboxResend(t *testing.T) {
+	now := time.Date(2017, 7, 14, 12, 0, 0, 0, time.UTC)
+	a := &recordAmbassador{fail: errors.New("platform down")}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+	outbox.MaxAttempts = 1
+	outbox.DeadLetters = NewMemoryDeadLetterStore()
+
+	for _, recipient := range []string{"u1", "u2", "u3"} {
+		outbox.Enqueue(&Envelope{RecipientId: recipient, Messages: []OutboundMessage{TextMessage("sale")}})
+		outbox.Flush()
+		now = now.Add(time.Minute)
+	}
+
+	a.fail = nil
+	q := ResendQuery{Since: now.Add(-150 * time.Second), Recipients: []string{"u1", "u2", "u3"}}
+	if n, err := outbox.Resend(q); err != nil || n != 2 {
+		t.Fatalf("expect the two latest dead letters to be resent, got %d %v", n, err)
+	}
+	if n, _ := outbox.Resend(q); n != 0 {
+		t.Errorf("expect resent letters not to be resent again, got %d", n)
+	}
+	outbox.Flush()
+	sort.Strings(a.sent)
+	if len(a.sent) != 2 || a.sent[0] != "u2:sale" || a.sent[1] != "u3:sale" {
+		t.Errorf("unexpected deliveries: %v", a.sent)
+	}
+	if letters, _ := outbox.DeadLetters.List(ResendQuery{}); len(letters) != 1 || letters[0].Envelope.RecipientId != "u1" {
+		t.Errorf("expect u1 left in the dead letters, got %+v", letters)
+	}
+}
+
+func TestOutboxOnResult(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	a := &recordAmbassador{fail: errors.New("platform down")}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+	outbox.MaxAttempts = 2
+	outbox.RetryDelay = time.Minute
+
+	results := make(chan SendResult, 2)
+	all := []string{}
+	outbox.OnResult = func(e *Envelope, r SendResult) { all = append(all, e.RecipientId) }
+	outbox.Enqueue(&Envelope{RecipientId: "u1", Messages: []OutboundMessage{TextMessage("shipped")}, OnResult: func(r SendResult) { results <- r }})
+	outbox.Enqueue(&Envelope{RecipientId: "u2", Messages: []OutboundMessage{TextMessage("shipped")}, ExpiresAt: now.Add(30 * time.Second)})
+
+	outbox.Flush()
+	if len(results) != 0 || len(all) != 0 {
+		t.Fatal("expect no result before the retries conclude")
+	}
+	a.fail = nil
+	now = now.Add(time.Minute)
+	outbox.Flush()
+
+	r := <-results
+	if !r.Delivered || r.Err != nil || r.RecipientId != "u1" || r.Attempts != 2 {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expect results of both envelopes, got %v", all)
+	}
+
+	a.fail = errors.New("platform down")
+	var failed SendResult
+	outbox.Enqueue(&Envelope{RecipientId: "u3", OnResult: func(r SendResult) { failed = r }})
+	outbox.Flush()
+	now = now.Add(time.Minute)
+	outbox.Flush()
+	if failed.Delivered || failed.Err == nil || failed.Attempts != 2 {
+		t.Errorf("expect a permanent failure, got %+v", failed)
+	}
+}
+
+type failingProfileStore struct{}
+
+func (failingProfileStore) GetProfile(userId string) (*UserProfile, error) {
+	return nil, errors.New("profiles down")
+}
+
+func (failingProfileStore) PutProfile(p *UserProfile) error { return nil }
+
+func TestOutboxWindowError(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	a := &recordAmbassador{}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+	outbox.MaxAttempts = 2
+	outbox.RetryDelay = time.Minute
+	outbox.Window = &DeliveryWindow{Start: 0, End: 24 * time.Hour, Profiles: failingProfileStore{}}
+
+	var failed SendResult
+	e := &Envelope{RecipientId: "u1", Messages: []OutboundMessage{TextMessage("hi")}, OnResult: func(r SendResult) { failed = r }}
+	outbox.Enqueue(e)
+	if err := outbox.Flush(); err == nil {
+		t.Fatal("expect the window error to fail the flush")
+	}
+	if e.Attempts != 1 || !e.SendAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("expect the envelope to back off, got %d attempts at %s", e.Attempts, e.SendAt)
+	}
+	if due, _ := outbox.store.Due(now, 10); len(due) != 0 {
+		t.Errorf("expect the envelope not to be due again at once, got %d", len(due))
+	}
+
+	now = now.Add(time.Minute)
+	outbox.Flush()
+	if failed.Delivered || failed.Err == nil || failed.Attempts != 2 {
+		t.Errorf("expect a permanent failure, got %+v", failed)
+	}
+	if len(a.sent) != 0 {
+		t.Errorf("expect nothing delivered, got %v", a.sent)
+	}
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Wait(ctx context.Context) error { return errors.New("redis is down") }
+
+func TestOutboxLimiterError(t *testing.T) {
+	now := time.Date(2017, 7, 14, 12, 0, 0, 0, time.UTC)
+	a := &recordAmbassador{}
+	outbox := NewOutbox(a, nil)
+	outbox.now = func() time.Time { return now }
+	outbox.RetryDelay = time.Minute
+	outbox.Limiter = failingLimiter{}
+	outbox.Quota = NewQuota(nil)
+	outbox.Quota.now = outbox.now
+	outbox.Quota.SetBudget("acme", Budget{Daily: 1})
+
+	e := &Envelope{RecipientId: "u1", Tenant: "acme", Messages: []OutboundMessage{TextMessage("hi")}}
+	outbox.Enqueue(e)
+	if err := outbox.Flush(); err == nil {
+		t.Fatal("expect the limiter error to fail the flush")
+	}
+	if e.Attempts != 1 || !e.SendAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("expect the envelope to back off, got %d attempts at %s", e.Attempts, e.SendAt)
+	}
+	if daily, _, _ := outbox.Quota.Usage("acme"); daily != 0 {
+		t.Errorf("expect no quota to be used, got %d", daily)
+	}
+}
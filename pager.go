@@ -0,0 +1,137 @@
+package ambassador
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MoreAnswersPayloadPrefix = "AMBASSADOR_MORE_ANSWERS:"
+	MoreAnswersTitle         = "More…"
+
+	maxPagedQuestions = 1000
+)
+
+// MoreAnswersContent is the content of a message when a user asks for the
+// next page of a paginated question. Answers is the next page, which a
+// handler asks again by AskQuestion(Text, Answers) on its own ambassador.
+type MoreAnswersContent struct {
+	Text    string
+	Offset  int
+	Answers []map[string]string
+}
+
+// PagedQuestion is a question which has more answers than a platform
+// allows.
+type PagedQuestion struct {
+	Text    string
+	Answers []map[string]string
+}
+
+// PageStore keeps paginated questions by id so that their remaining answers
+// can be revealed page by page. Ambassadors which share a store can reveal
+// the pages of each other, e.g. when a webhook creates an ambassador per
+// request, and a persistent store keeps them across replicas.
+type PageStore interface {
+	PutQuestion(id string, q PagedQuestion) error
+	GetQuestion(id string) (q PagedQuestion, ok bool, err error)
+}
+
+// MemoryPageStore keeps the latest paginated questions up to a limit.
+type MemoryPageStore struct {
+	sync.Mutex
+	max       int
+	ids       []string
+	questions map[string]PagedQuestion
+}
+
+func NewMemoryPageStore(max int) *MemoryPageStore {
+	return &MemoryPageStore{max: max, questions: map[string]PagedQuestion{}}
+}
+
+func (s *MemoryPageStore) PutQuestion(id string, q PagedQuestion) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.questions[id]; !ok {
+		s.ids = append(s.ids, id)
+	}
+	s.questions[id] = q
+	for len(s.ids) > s.max {
+		delete(s.questions, s.ids[0])
+		s.ids = s.ids[1:]
+	}
+	return nil
+}
+
+func (s *MemoryPageStore) GetQuestion(id string) (q PagedQuestion, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	q, ok = s.questions[id]
+	return
+}
+
+// DefaultPageStore keeps the paginated questions of ambassadors without a
+// page store. It is shared by all of them, since a webhook creates an
+// ambassador per request, so the pages of a question are revealed by
+// another ambassador than the one which asked it.
+var DefaultPageStore PageStore = NewMemoryPageStore(maxPagedQuestions)
+
+func pageStore(s PageStore) PageStore {
+	if s != nil {
+		return s
+	}
+	return DefaultPageStore
+}
+
+// paginate returns the first page of answers. If all answers fit into the
+// limit, they are returned untouched.
+func paginate(s PageStore, text string, answers []map[string]string, limit int) (page []map[string]string, err error) {
+	if len(answers) <= limit {
+		return answers, nil
+	}
+
+	id := newId()
+	if err = pageStore(s).PutQuestion(id, PagedQuestion{Text: text, Answers: answers}); err != nil {
+		return nil, fmt.Errorf("fail to keep the pages of a question: %s", err)
+	}
+	return pageOf(id, answers, 0, limit), nil
+}
+
+// nextPage resolves a "More…" payload into the question text and its next page.
+// A payload which fails to be resolved is not ok, so that it reaches handlers
+// as a command.
+func nextPage(s PageStore, payload string, limit int) (text string, offset int, page []map[string]string, ok bool) {
+	if !strings.HasPrefix(payload, MoreAnswersPayloadPrefix) {
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(payload, MoreAnswersPayloadPrefix), ":", 2)
+	if len(parts) != 2 {
+		return
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return
+	}
+
+	q, found, err := pageStore(s).GetQuestion(parts[0])
+	if err != nil || !found || offset < 0 || offset >= len(q.Answers) {
+		return
+	}
+	return q.Text, offset, pageOf(parts[0], q.Answers, offset, limit), true
+}
+
+func pageOf(id string, answers []map[string]string, offset, limit int) []map[string]string {
+	if len(answers)-offset <= limit {
+		return answers[offset:]
+	}
+	next := offset + limit - 1
+	page := make([]map[string]string, 0, limit)
+	page = append(page, answers[offset:next]...)
+	return append(page, map[string]string{
+		"content_type": "text",
+		"title":        MoreAnswersTitle,
+		"payload":      fmt.Sprintf("%s%s:%d", MoreAnswersPayloadPrefix, id, next),
+	})
+}
@@ -0,0 +1,98 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestAnswerPager(t *testing.T) {
+	answers := []map[string]string{}
+	for i := 0; i < 6; i++ {
+		answers = append(answers, map[string]string{
+			"title":   fmt.Sprintf("answer %d", i),
+			"payload": fmt.Sprintf("ANSWER_%d", i),
+		})
+	}
+
+	page, err := paginate(nil, "question", answers, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 4 {
+		t.Fatalf("expect 4 answers in the first page, got %d", len(page))
+	}
+	if page[3]["title"] != MoreAnswersTitle {
+		t.Fatalf("expect the last answer to be a more button, got %+v", page[3])
+	}
+
+	text, offset, page, ok := nextPage(nil, page[3]["payload"], 4)
+	if !ok {
+		t.Fatal("fail to resolve the more payload")
+	}
+	if text != "question" || offset != 3 || len(page) != 3 {
+		t.Errorf("unexpected next page: %s %d %+v", text, offset, page)
+	}
+	if page[2]["payload"] != "ANSWER_5" {
+		t.Errorf("expect the last answer to be preserved, got %+v", page[2])
+	}
+
+	if _, _, _, ok := nextPage(nil, "ANSWER_1", 4); ok {
+		t.Error("a normal payload should not be resolved as a page")
+	}
+}
+
+func TestAnswerPagerStore(t *testing.T) {
+	answers := []map[string]string{}
+	for i := 0; i < 6; i++ {
+		answers = append(answers, map[string]string{"title": "answer", "payload": "ANSWER"})
+	}
+
+	store := NewMemoryPageStore(1)
+	page, _ := paginate(store, "question", answers, 4)
+	if _, _, _, ok := nextPage(store, page[3]["payload"], 4); !ok {
+		t.Error("expect a page to be revealed from the store which keeps it")
+	}
+	if _, _, _, ok := nextPage(nil, page[3]["payload"], 4); ok {
+		t.Error("expect the default store not to reveal a page kept by another store")
+	}
+	paginate(store, "another question", answers, 4)
+	if _, _, _, ok := nextPage(store, page[3]["payload"], 4); ok {
+		t.Error("expect the oldest question to be evicted beyond the limit of the store")
+	}
+}
+
+func TestAnswerPagerAcrossAmbassadors(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	answers := []map[string]string{}
+	for i := 0; i < fbMaxQuickReplies+2; i++ {
+		answers = append(answers, map[string]string{
+			"content_type": "text",
+			"title":        fmt.Sprintf("answer %d", i),
+			"payload":      fmt.Sprintf("ANSWER_%d", i),
+		})
+	}
+
+	asker := NewFBAmbassador("token", server.Client())
+	if err := asker.AskQuestion("question", answers); err != nil {
+		t.Fatal(err)
+	}
+	page := asker.messages[0].(map[string]interface{})["message"].(map[string]interface{})["quick_replies"].([]map[string]string)
+	more := page[len(page)-1]["payload"]
+
+	// A webhook translates the answer by another ambassador.
+	body := `{"object":"page","entry":[{"messaging":[{"sender":{"id":"u1"},"message":{"quick_reply":{"payload":"` + more + `"}}}]}]}`
+	messages, err := NewFBAmbassador("token", server.Client()).Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expect a message, got %+v", messages)
+	}
+	if c, ok := messages[0].Content.(*MoreAnswersContent); !ok || c.Text != "question" || c.Offset != fbMaxQuickReplies-1 || len(c.Answers) != 3 {
+		t.Errorf("expect the next page to be revealed by another ambassador, got %+v", messages[0].Content)
+	}
+}
@@ -0,0 +1,46 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// EventError is a failure to translate an event of a payload.
+type EventError struct {
+	// Payload is a summary of the event.
+	Payload string
+	Err     error
+}
+
+func (e EventError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err, e.Payload)
+}
+
+// PartialError is returned by Translate along with the messages of a
+// payload when some of its events can not be translated. The bad events are
+// skipped instead of dropping the whole batch.
+type PartialError struct {
+	Events []EventError
+}
+
+func (e *PartialError) Error() string {
+	errs := make([]string, 0, len(e.Events))
+	for _, event := range e.Events {
+		errs = append(errs, event.Err.Error())
+	}
+	return fmt.Sprintf("fail to translate %d events: %s", len(e.Events), strings.Join(errs, "; "))
+}
+
+func (e *PartialError) add(event json.RawMessage, err error) {
+	e.Events = append(e.Events, EventError{Payload: summarize(event), Err: err})
+}
+
+// orNil returns nil if no event failed, so that Translate can return it as
+// an error anyway.
+func (e *PartialError) orNil() error {
+	if e == nil || len(e.Events) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,151 @@
+package ambassador
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Message tags which allow messenger sends outside the 24 hour window.
+const (
+	FBTagConfirmedEventUpdate = "CONFIRMED_EVENT_UPDATE"
+	FBTagPostPurchaseUpdate   = "POST_PURCHASE_UPDATE"
+	FBTagAccountUpdate        = "ACCOUNT_UPDATE"
+	FBTagHumanAgent           = "HUMAN_AGENT"
+)
+
+// DefaultMessagingWindows are the windows after the last inbound message of
+// a user in which platforms allow untagged sends.
+var DefaultMessagingWindows = map[string]time.Duration{
+	"facebook": 24 * time.Hour,
+	"whatsapp": 24 * time.Hour,
+}
+
+// PolicyError is returned instead of sending messages which the platform
+// would reject for being outside its messaging window.
+type PolicyError struct {
+	Platform    string
+	RecipientId string
+	// LastInbound is zero if the user never sent a message.
+	LastInbound time.Time
+	Window      time.Duration
+}
+
+func (e *PolicyError) Error() string {
+	if e.LastInbound.IsZero() {
+		return fmt.Sprintf("ambassador: %s has not messaged on %s, a message tag is required", e.RecipientId, e.Platform)
+	}
+	return fmt.Sprintf("ambassador: the last message of %s on %s was at %s, out of the %s window, a message tag is required",
+		e.RecipientId, e.Platform, e.LastInbound.Format(time.RFC3339), e.Window)
+}
+
+// InteractionStore keeps the time of the last inbound message of users.
+type InteractionStore interface {
+	Touch(platform, userId string, at time.Time) error
+	LastInbound(platform, userId string) (at time.Time, ok bool, err error)
+}
+
+type MemoryInteractionStore struct {
+	sync.Mutex
+	last map[string]time.Time
+}
+
+func NewMemoryInteractionStore() *MemoryInteractionStore {
+	return &MemoryInteractionStore{last: map[string]time.Time{}}
+}
+
+func (s *MemoryInteractionStore) Touch(platform, userId string, at time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	if at.After(s.last[platform+":"+userId]) {
+		s.last[platform+":"+userId] = at
+	}
+	return nil
+}
+
+func (s *MemoryInteractionStore) LastInbound(platform, userId string) (at time.Time, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	at, ok = s.last[platform+":"+userId]
+	return
+}
+
+// MessagingPolicy tracks inbound messages and checks sends against the
+// messaging windows of platforms.
+type MessagingPolicy struct {
+	store InteractionStore
+	// Windows overrides DefaultMessagingWindows. Platforms without a window
+	// are not checked.
+	Windows map[string]time.Duration
+	now     func() time.Time
+}
+
+func NewMessagingPolicy(store InteractionStore) *MessagingPolicy {
+	if store == nil {
+		store = NewMemoryInteractionStore()
+	}
+	return &MessagingPolicy{store: store, Windows: DefaultMessagingWindows, now: time.Now}
+}
+
+// userAuthored tells whether a message is sent by a user, which opens the
+// messaging window, rather than a receipt, an echo or a platform event.
+func userAuthored(msg Message) bool {
+	switch c := msg.Content.(type) {
+	case *TextContent, *CommandContent, *MoreAnswersContent, *LocationContent,
+		*StickerContent, *ContactContent, *ProductContent:
+		return true
+	case *FBMessageContent:
+		return !c.IsEcho
+	}
+	return false
+}
+
+// Track is a middleware which records the messages authored by users, e.g.
+// texts, answers and attachments. Receipts, echoes and platform events do
+// not open the messaging window.
+func (p *MessagingPolicy) Track() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			if !userAuthored(msg) {
+				return next.Handle(a, msg)
+			}
+			at := msg.ReceivedAt
+			if at.IsZero() {
+				at = p.now()
+			}
+			p.store.Touch(platformOf(a), msg.SenderId, at)
+			return next.Handle(a, msg)
+		})
+	}
+}
+
+// Check returns a PolicyError if an untagged send to a recipient is outside
+// the messaging window of the platform.
+func (p *MessagingPolicy) Check(platform, recipientId string, tag string) (err error) {
+	window, ok := p.Windows[platform]
+	if !ok || tag != "" {
+		return
+	}
+	last, ok, err := p.store.LastInbound(platform, recipientId)
+	if err != nil {
+		return
+	}
+	if ok && p.now().Sub(last) <= window {
+		return
+	}
+	return &PolicyError{Platform: platform, RecipientId: recipientId, LastInbound: last, Window: window}
+}
+
+// Deliver checks a send against the policy, tags it on platforms which
+// support tags and delivers it.
+func (p *MessagingPolicy) Deliver(a Ambassador, recipientId, tag string, build MessageBuilder) (err error) {
+	if err = p.Check(platformOf(a), recipientId, tag); err != nil {
+		return
+	}
+	if t, ok := unwrap(a).(Tagger); ok && tag != "" {
+		if err = t.SetMessageTag(tag); err != nil {
+			return
+		}
+	}
+	return deliver(a, recipientId, build)
+}
@@ -0,0 +1,45 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestMessagingPolicy(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	a := NewFBAmbassador("test-token", server.Client())
+
+	now := time.Unix(1500000000, 0)
+	policy := NewMessagingPolicy(nil)
+	policy.now = func() time.Time { return now }
+	track := policy.Track()(HandlerFunc(func(Ambassador, Message) error { return nil }))
+	track.Handle(a, Message{SenderId: "u1", ReceivedAt: now, Content: &TextContent{Text: "hi"}})
+	track.Handle(a, Message{SenderId: "u2", ReceivedAt: now, Content: &ReadContent{Watermark: 1}})
+	track.Handle(a, Message{SenderId: "u2", ReceivedAt: now, Content: &FBMessageContent{Text: "echo", IsEcho: true}})
+
+	build := Messages(TextMessage("hello"))
+	if err := policy.Deliver(a, "u1", "", build); err != nil {
+		t.Fatalf("expect a send within the window, got %v", err)
+	}
+	if err := policy.Deliver(a, "u2", "", build); err == nil {
+		t.Fatal("expect a receipt or an echo not to open the window")
+	}
+
+	now = now.Add(25 * time.Hour)
+	err := policy.Deliver(a, "u1", "", build)
+	if _, ok := err.(*PolicyError); !ok {
+		t.Fatalf("expect a policy error, got %v", err)
+	}
+	if err := policy.Deliver(a, "u1", FBTagAccountUpdate, build); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 || !strings.Contains(string(requests[1].Body), `"tag":"ACCOUNT_UPDATE"`) {
+		t.Errorf("expect the second send to be tagged, got %+v", requests)
+	}
+}
@@ -0,0 +1,145 @@
+package ambassador
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lemonlatte/ambassador/flex"
+)
+
+const PollPayloadPrefix = "AMBASSADOR_POLL:"
+
+type PollResult struct {
+	Option string
+	Votes  int
+}
+
+// Poll asks a question via quick replies and tallies the answers per chat.
+// Every voter has one vote in a chat and voting again replaces it.
+type Poll struct {
+	sync.Mutex
+	Id       string
+	Question string
+	Options  []string
+	votes    map[string]map[string]int
+}
+
+func NewPoll(id, question string, options ...string) *Poll {
+	return &Poll{
+		Id:       id,
+		Question: question,
+		Options:  options,
+		votes:    map[string]map[string]int{},
+	}
+}
+
+// Ask stages the poll question on an ambassador.
+func (p *Poll) Ask(a Ambassador) (err error) {
+	answers := make([]map[string]string, 0, len(p.Options))
+	for i, option := range p.Options {
+		answers = append(answers, map[string]string{
+			"content_type": "text",
+			"title":        option,
+			"payload":      fmt.Sprintf("%s%s:%d", PollPayloadPrefix, p.Id, i),
+		})
+	}
+	return a.AskQuestion(p.Question, answers)
+}
+
+// Handle records a vote. It returns false if the message is not a vote of
+// this poll.
+func (p *Poll) Handle(msg Message) (handled bool) {
+	content, ok := msg.Content.(*CommandContent)
+	if !ok {
+		return
+	}
+	prefix := PollPayloadPrefix + p.Id + ":"
+	if !strings.HasPrefix(content.Payload, prefix) {
+		return
+	}
+	i, err := strconv.Atoi(strings.TrimPrefix(content.Payload, prefix))
+	if err != nil || i < 0 || i >= len(p.Options) {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	chatId := msg.chat()
+	if p.votes[chatId] == nil {
+		p.votes[chatId] = map[string]int{}
+	}
+	p.votes[chatId][msg.SenderId] = i
+	return true
+}
+
+// Tally returns the votes of every option in a chat.
+func (p *Poll) Tally(chatId string) (results []PollResult) {
+	counts := make([]int, len(p.Options))
+	p.Lock()
+	for _, i := range p.votes[chatId] {
+		counts[i]++
+	}
+	p.Unlock()
+
+	for i, option := range p.Options {
+		results = append(results, PollResult{Option: option, Votes: counts[i]})
+	}
+	return
+}
+
+// pollMaxCards is the most options whose results are shown as cards, which
+// is the most elements of a facebook generic template.
+const pollMaxCards = 10
+
+// SendResults stages a card with the current results of a chat: a flex
+// bubble on LINE, and a carousel of a card per option on facebook. Other
+// platforms, and polls with more options than cards, get plain text.
+func (p *Poll) SendResults(a Ambassador, chatId string) (err error) {
+	results := p.Tally(chatId)
+	var total int
+	for _, r := range results {
+		total += r.Votes
+	}
+	percent := func(r PollResult) int {
+		if total == 0 {
+			return 0
+		}
+		return r.Votes * 100 / total
+	}
+
+	switch v := unwrap(a).(type) {
+	case *LineAmbassador:
+		rows := make([]flex.BoxChild, 0, len(results))
+		for _, r := range results {
+			rows = append(rows, flex.HBox(
+				flex.Text(r.Option).Wrap().Flex(3),
+				flex.Text(fmt.Sprintf("%d (%d%%)", r.Votes, percent(r))).Align("end").Flex(2),
+			))
+		}
+		return v.SendFlex(p.Question, flex.Bubble().
+			Header(flex.VBox(flex.Text(p.Question).Weight("bold").Wrap())).
+			Body(flex.VBox(rows...).Spacing("sm")))
+	case *FBAmbassador:
+		if len(results) <= pollMaxCards {
+			cards := make([]Carousel, 0, len(results))
+			for _, r := range results {
+				cards = append(cards, Carousel{
+					Title: r.Option,
+					Text:  fmt.Sprintf("%d (%d%%)", r.Votes, percent(r)),
+				})
+			}
+			if err = v.SendText(p.Question); err != nil {
+				return
+			}
+			return v.SendTemplate(cards)
+		}
+	}
+
+	lines := make([]string, 0, len(results))
+	for _, r := range results {
+		lines = append(lines, fmt.Sprintf("%s: %d (%d%%)", r.Option, r.Votes, percent(r)))
+	}
+	return a.SendText(p.Question + "\n" + strings.Join(lines, "\n"))
+}
@@ -0,0 +1,61 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestPollResults(t *testing.T) {
+	poll := NewPoll("lunch", "Where to eat?", "Noodles", "Curry")
+	poll.Handle(Message{SenderId: "U1", ChatId: "C1", Content: &CommandContent{Payload: PollPayloadPrefix + "lunch:1"}})
+	poll.Handle(Message{SenderId: "U2", ChatId: "C1", Content: &CommandContent{Payload: PollPayloadPrefix + "lunch:1"}})
+	poll.Handle(Message{SenderId: "U1", ChatId: "C1", Content: &CommandContent{Payload: PollPayloadPrefix + "lunch:0"}})
+
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	line := NewLineAmbassador("token", server.Client())
+	if err := poll.SendResults(line, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := line.Send("reply-token"); err != nil {
+		t.Fatal(err)
+	}
+	var reply struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	json.Unmarshal(server.Requests()[0].Body, &reply)
+	card := string(server.Requests()[0].Body)
+	if len(reply.Messages) != 1 || reply.Messages[0]["type"] != "flex" || reply.Messages[0]["altText"] != "Where to eat?" ||
+		!strings.Contains(card, `"text":"Noodles"`) || !strings.Contains(card, `"text":"1 (50%)"`) {
+		t.Errorf("expect the results as a flex bubble, got %s", card)
+	}
+
+	fb := NewFBAmbassador("token", server.Client())
+	if err := poll.SendResults(fb, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fb.Send("U1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()[1:]
+	if len(requests) != 2 || !strings.Contains(string(requests[0].Body), `"text":"Where to eat?"`) {
+		t.Fatalf("expect the question and a carousel, got %+v", requests)
+	}
+	cards := string(requests[1].Body)
+	if !strings.Contains(cards, `"template_type":"generic"`) || !strings.Contains(cards, `"title":"Curry"`) ||
+		!strings.Contains(cards, `"subtitle":"1 (50%)"`) {
+		t.Errorf("expect a card per option, got %s", cards)
+	}
+
+	a := &recordAmbassador{}
+	if err := poll.SendResults(a, "C1"); err != nil {
+		t.Fatal(err)
+	}
+	a.Send("C1")
+	if expected := "C1:Where to eat?\nNoodles: 1 (50%)\nCurry: 1 (50%)"; len(a.sent) != 1 || a.sent[0] != expected {
+		t.Errorf("expect the results as a text elsewhere, got %v", a.sent)
+	}
+}
@@ -0,0 +1,72 @@
+package ambassador
+
+import (
+	"context"
+	"time"
+)
+
+const defaultPollErrorDelay = 5 * time.Second
+
+// PollFunc fetches the events after a cursor, waiting up to the long polling
+// timeout of the platform for new ones. It returns the events as a payload
+// the ambassador can translate, like a webhook body, and the cursor of the
+// next poll.
+type PollFunc func(ctx context.Context, cursor string) (payload []byte, next string, err error)
+
+// LongPoller receives messages by long polling instead of a webhook, so that
+// bots can run behind NAT or in development without a public URL.
+type LongPoller struct {
+	Poll          PollFunc
+	NewAmbassador func() Ambassador
+	Handler       Handler
+	// Cursor is where the next poll starts. It can be restored to resume
+	// polling after a restart.
+	Cursor string
+	// ErrorDelay is how long to wait before polling again after a failure.
+	ErrorDelay time.Duration
+	// Reporter is told about payloads which fail to be translated.
+	Reporter ErrorReporter
+	Logger   Logger
+}
+
+func NewLongPoller(poll PollFunc, newAmbassador func() Ambassador, h Handler) *LongPoller {
+	return &LongPoller{
+		Poll:          poll,
+		NewAmbassador: newAmbassador,
+		Handler:       h,
+		ErrorDelay:    defaultPollErrorDelay,
+		Logger:        stdLogger{},
+	}
+}
+
+// Serve runs the poller with a handler instead of its own.
+func (p *LongPoller) Serve(ctx context.Context, h Handler) error {
+	p.Handler = h
+	return p.Run(ctx)
+}
+
+// Run polls and handles messages one by one until the context is done.
+func (p *LongPoller) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		payload, next, err := p.Poll(ctx, p.Cursor)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			p.Logger.Printf("ambassador: fail to poll: %s", err)
+			if err = sleep(ctx, p.ErrorDelay); err != nil {
+				return err
+			}
+			continue
+		}
+		p.Cursor = next
+		if len(payload) == 0 {
+			continue
+		}
+
+		handlePayload(p.NewAmbassador, p.Handler, payload, p.Reporter, p.Logger)
+	}
+}
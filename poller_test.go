@@ -0,0 +1,39 @@
+package ambassador
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLongPoller(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	payload := []byte(`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u1"},"timestamp":1500000000000,"message":{"mid":"m1","text":"hi"}}]}]}`)
+	cursors := []string{}
+	poll := func(ctx context.Context, cursor string) ([]byte, string, error) {
+		cursors = append(cursors, cursor)
+		if cursor == "" {
+			return payload, "1", nil
+		}
+		<-ctx.Done()
+		return nil, cursor, ctx.Err()
+	}
+
+	var texts []string
+	poller := NewLongPoller(poll, func() Ambassador { return NewFBAmbassador("test-token", nil) },
+		HandlerFunc(func(a Ambassador, msg Message) error {
+			texts = append(texts, msg.Content.(*TextContent).Text)
+			cancel()
+			return nil
+		}))
+	if err := poller.Run(ctx); err != context.Canceled {
+		t.Fatalf("expect the poller to stop by the context, got %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "hi" {
+		t.Errorf("unexpected messages: %v", texts)
+	}
+	if poller.Cursor != "1" {
+		t.Errorf("expect the cursor to advance, got %q", poller.Cursor)
+	}
+}
@@ -0,0 +1,64 @@
+package ambassador
+
+import (
+	"sync"
+	"time"
+)
+
+type UserProfile struct {
+	Id        string
+	FirstName string
+	LastName  string
+	Locale    string
+	// TimeZone is an IANA time zone name. UTCOffset in hours is used when it
+	// is empty, e.g. the timezone field of a facebook profile.
+	TimeZone  string
+	UTCOffset float64
+	// FetchedAt is when the profile was fetched from the platform.
+	FetchedAt time.Time
+}
+
+// Location returns the time zone of a user, or nil if it is unknown.
+func (p *UserProfile) Location() *time.Location {
+	if p == nil {
+		return nil
+	}
+	if p.TimeZone != "" {
+		if loc, err := time.LoadLocation(p.TimeZone); err == nil {
+			return loc
+		}
+	}
+	if p.UTCOffset != 0 {
+		return time.FixedZone("", int(p.UTCOffset*3600))
+	}
+	return nil
+}
+
+// ProfileStore keeps user profiles. GetProfile returns nil without an error
+// when a profile is unknown.
+type ProfileStore interface {
+	GetProfile(userId string) (*UserProfile, error)
+	PutProfile(p *UserProfile) error
+}
+
+type MemoryProfileStore struct {
+	sync.Mutex
+	profiles map[string]*UserProfile
+}
+
+func NewMemoryProfileStore() *MemoryProfileStore {
+	return &MemoryProfileStore{profiles: map[string]*UserProfile{}}
+}
+
+func (s *MemoryProfileStore) GetProfile(userId string) (*UserProfile, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.profiles[userId], nil
+}
+
+func (s *MemoryProfileStore) PutProfile(p *UserProfile) error {
+	s.Lock()
+	defer s.Unlock()
+	s.profiles[p.Id] = p
+	return nil
+}
@@ -0,0 +1,203 @@
+package ambassador
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("ambassador: quota exceeded")
+
+// Budget limits how many deliveries a tenant can make in a day and a month.
+// Zero means unlimited.
+type Budget struct {
+	Daily   int64 `json:"daily,omitempty"`
+	Monthly int64 `json:"monthly,omitempty"`
+}
+
+// PlatformQuota is implemented by ambassadors whose platform limits sends,
+// e.g. the monthly message quota of LINE.
+type PlatformQuota interface {
+	RemainingQuota() (remaining int64, limited bool, err error)
+}
+
+// QuotaStore counts the usage of tenants, e.g. in redis to be shared by
+// replicas.
+type QuotaStore interface {
+	// Incr adds n to a counter and returns the new value.
+	Incr(key string, n int64) (int64, error)
+}
+
+type MemoryQuotaStore struct {
+	sync.Mutex
+	counters map[string]int64
+}
+
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counters: map[string]int64{}}
+}
+
+func (s *MemoryQuotaStore) Incr(key string, n int64) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.counters[key] += n
+	return s.counters[key], nil
+}
+
+type platformRemaining struct {
+	remaining int64
+	limited   bool
+}
+
+// Quota enforces the budgets of tenants. Counters are kept per calendar day
+// and month in Location.
+type Quota struct {
+	sync.Mutex
+	store    QuotaStore
+	budgets  map[string]Budget
+	platform map[string]platformRemaining
+	// Default is the budget of tenants without their own.
+	Default  Budget
+	Location *time.Location
+	Metrics  Metrics
+	now      func() time.Time
+}
+
+func NewQuota(store QuotaStore) *Quota {
+	if store == nil {
+		store = NewMemoryQuotaStore()
+	}
+	return &Quota{
+		store:    store,
+		budgets:  map[string]Budget{},
+		platform: map[string]platformRemaining{},
+		Location: time.UTC,
+		Metrics:  nopMetrics{},
+		now:      time.Now,
+	}
+}
+
+func (q *Quota) SetBudget(tenant string, b Budget) {
+	q.Lock()
+	defer q.Unlock()
+	q.budgets[tenant] = b
+}
+
+func (q *Quota) budget(tenant string) Budget {
+	q.Lock()
+	defer q.Unlock()
+	if b, ok := q.budgets[tenant]; ok {
+		return b
+	}
+	return q.Default
+}
+
+// Refresh fetches the remaining platform quota of a tenant, so that sends
+// stop before the platform rejects them. It should be called periodically.
+func (q *Quota) Refresh(tenant string, p PlatformQuota) (err error) {
+	remaining, limited, err := p.RemainingQuota()
+	if err != nil {
+		return
+	}
+	q.Lock()
+	q.platform[tenant] = platformRemaining{remaining: remaining, limited: limited}
+	q.Unlock()
+	if limited {
+		q.Metrics.Gauge("ambassador.quota.platform_remaining", float64(remaining), map[string]string{"tenant": tenant})
+	}
+	return
+}
+
+func (q *Quota) keys(tenant string) (daily, monthly string) {
+	now := q.now().In(q.Location)
+	return "quota:" + tenant + ":" + now.Format("2006-01-02"), "quota:" + tenant + ":" + now.Format("2006-01")
+}
+
+// Reservation is a count of deliveries of a tenant made by Reserve, which
+// Release gives back to the periods it was counted in.
+type Reservation struct {
+	Tenant   string
+	N        int64
+	daily    string
+	monthly  string
+	platform bool
+}
+
+// Reserve counts n deliveries of a tenant, or returns ErrQuotaExceeded
+// without counting them if they would exceed the budget or the platform
+// quota.
+func (q *Quota) Reserve(tenant string, n int64) (r *Reservation, err error) {
+	tags := map[string]string{"tenant": tenant}
+
+	q.Lock()
+	p, ok := q.platform[tenant]
+	if ok && p.limited {
+		if p.remaining < n {
+			q.Unlock()
+			q.Metrics.Count("ambassador.quota.exceeded", 1, tags)
+			return nil, ErrQuotaExceeded
+		}
+		p.remaining -= n
+		q.platform[tenant] = p
+	}
+	q.Unlock()
+
+	b := q.budget(tenant)
+	daily, monthly := q.keys(tenant)
+	r = &Reservation{Tenant: tenant, N: n, daily: daily, monthly: monthly, platform: ok && p.limited}
+	counters := []struct {
+		key   string
+		limit int64
+	}{{daily, b.Daily}, {monthly, b.Monthly}}
+
+	for i, c := range counters {
+		used, incrErr := q.store.Incr(c.key, n)
+		if incrErr == nil && (c.limit <= 0 || used <= c.limit) {
+			continue
+		}
+		for _, counted := range counters[:i] {
+			q.store.Incr(counted.key, -n)
+		}
+		if incrErr == nil {
+			q.store.Incr(c.key, -n)
+			incrErr = ErrQuotaExceeded
+			q.Metrics.Count("ambassador.quota.exceeded", 1, tags)
+		}
+		if r.platform {
+			q.releasePlatform(tenant, n)
+		}
+		return nil, incrErr
+	}
+	q.Metrics.Count("ambassador.quota.used", n, tags)
+	return
+}
+
+func (q *Quota) releasePlatform(tenant string, n int64) {
+	q.Lock()
+	defer q.Unlock()
+	if p, ok := q.platform[tenant]; ok && p.limited {
+		p.remaining += n
+		q.platform[tenant] = p
+	}
+}
+
+// Release gives back the deliveries of a reservation which were not made,
+// to the day and the month they were reserved in.
+func (q *Quota) Release(r *Reservation) {
+	q.store.Incr(r.daily, -r.N)
+	q.store.Incr(r.monthly, -r.N)
+	if r.platform {
+		q.releasePlatform(r.Tenant, r.N)
+	}
+	q.Metrics.Count("ambassador.quota.used", -r.N, map[string]string{"tenant": r.Tenant})
+}
+
+// Usage returns the deliveries of a tenant of today and this month.
+func (q *Quota) Usage(tenant string) (daily, monthly int64, err error) {
+	dailyKey, monthlyKey := q.keys(tenant)
+	if daily, err = q.store.Incr(dailyKey, 0); err != nil {
+		return
+	}
+	monthly, err = q.store.Incr(monthlyKey, 0)
+	return
+}
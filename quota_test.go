@@ -0,0 +1,31 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuotaReleaseAcrossMidnight(t *testing.T) {
+	now := time.Date(2017, 7, 31, 23, 59, 0, 0, time.UTC)
+	q := NewQuota(nil)
+	q.now = func() time.Time { return now }
+	q.SetBudget("acme", Budget{Daily: 1})
+
+	r, err := q.Reserve("acme", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	q.Release(r)
+
+	if daily, monthly, _ := q.Usage("acme"); daily != 0 || monthly != 0 {
+		t.Errorf("expect nothing counted in the new day and month, got %d %d", daily, monthly)
+	}
+	if _, err := q.Reserve("acme", 1); err != nil {
+		t.Errorf("expect the budget of the new day to be untouched, got %v", err)
+	}
+	now = now.Add(-2 * time.Minute)
+	if daily, _, _ := q.Usage("acme"); daily != 0 {
+		t.Errorf("expect the reservation to be given back to its day, got %d", daily)
+	}
+}
@@ -0,0 +1,59 @@
+package ambassador
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// Logger is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+type RecoverOptions struct {
+	Logger   Logger
+	Reporter ErrorReporter
+	// Apology is sent to the user when a handler panics, if it is set.
+	Apology string
+}
+
+// Recover recovers panics of handlers. A panic is logged, reported and
+// turned into a handled message, so the webhook does not fail and get
+// redelivered.
+func Recover(opts RecoverOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				stack := debug.Stack()
+				CorrelationLogger(logger, msg.CorrelationId).Printf("ambassador: panic while handling a message from %s: %v\n%s", msg.SenderId, v, stack)
+				if opts.Reporter != nil {
+					opts.Reporter.Report(&PanicError{Value: v}, ErrorReport{
+						Platform:    platformOf(a),
+						RecipientId: msg.SenderId,
+						Stack:       stack,
+					})
+				}
+				if opts.Apology != "" {
+					if err = a.SendText(opts.Apology); err == nil {
+						err = a.Send(msg.ReplyTarget())
+					}
+				}
+			}()
+			return next.Handle(a, msg)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package ambassador
+
+import "fmt"
+
+const maxPayloadSummary = 512
+
+// ErrorReport is the context of a failure reported to an ErrorReporter.
+type ErrorReport struct {
+	Platform    string
+	Tenant      string
+	RecipientId string
+	// Payload is a summary of the payload being processed, truncated to a
+	// few hundred bytes.
+	Payload string
+	// Stack is set when the failure is a panic.
+	Stack []byte
+}
+
+// ErrorReporter sends failures to an error tracker, e.g. sentry. It is
+// called when a delivery fails permanently and when a webhook can not be
+// translated.
+type ErrorReporter interface {
+	Report(err error, report ErrorReport)
+}
+
+type ErrorReporterFunc func(err error, report ErrorReport)
+
+func (f ErrorReporterFunc) Report(err error, report ErrorReport) {
+	f(err, report)
+}
+
+// Platformer is implemented by ambassadors to name their platform.
+type Platformer interface {
+	Platform() string
+}
+
+func platformOf(a Ambassador) string {
+	if p, ok := unwrap(a).(Platformer); ok {
+		return p.Platform()
+	}
+	return fmt.Sprintf("%T", a)
+}
+
+func summarize(payload []byte) string {
+	if len(payload) > maxPayloadSummary {
+		return string(payload[:maxPayloadSummary]) + "..."
+	}
+	return string(payload)
+}
+
+// PanicError wraps a recovered panic.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
@@ -0,0 +1,112 @@
+package ambassador
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeadLetter is an envelope which failed permanently.
+type DeadLetter struct {
+	Envelope *Envelope
+	Error    string
+	FailedAt time.Time
+}
+
+// ResendQuery selects dead letters by when they failed and by their
+// recipients. Zero fields match all.
+type ResendQuery struct {
+	Since      time.Time
+	Until      time.Time
+	Recipients []string
+}
+
+func (q *ResendQuery) match(d *DeadLetter) bool {
+	if !q.Since.IsZero() && d.FailedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !d.FailedAt.Before(q.Until) {
+		return false
+	}
+	if len(q.Recipients) == 0 {
+		return true
+	}
+	for _, r := range q.Recipients {
+		if r == d.Envelope.RecipientId {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetterStore keeps dead letters by the ids of their envelopes.
+type DeadLetterStore interface {
+	Put(d *DeadLetter) error
+	// List returns the dead letters matching a query, the earliest failure
+	// first.
+	List(q ResendQuery) ([]*DeadLetter, error)
+	Delete(id string) error
+}
+
+type MemoryDeadLetterStore struct {
+	sync.Mutex
+	letters map[string]*DeadLetter
+}
+
+func NewMemoryDeadLetterStore() *MemoryDeadLetterStore {
+	return &MemoryDeadLetterStore{letters: map[string]*DeadLetter{}}
+}
+
+func (s *MemoryDeadLetterStore) Put(d *DeadLetter) error {
+	s.Lock()
+	defer s.Unlock()
+	s.letters[d.Envelope.Id] = d
+	return nil
+}
+
+func (s *MemoryDeadLetterStore) List(q ResendQuery) ([]*DeadLetter, error) {
+	s.Lock()
+	defer s.Unlock()
+	letters := []*DeadLetter{}
+	for _, d := range s.letters {
+		if q.match(d) {
+			letters = append(letters, d)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].FailedAt.Before(letters[j].FailedAt) })
+	return letters, nil
+}
+
+func (s *MemoryDeadLetterStore) Delete(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.letters, id)
+	return nil
+}
+
+// Resend queues the dead letters matching a query again, e.g. after an
+// outage of a platform. Envelopes keep their ids, so resending twice, or
+// while a resent envelope is still queued, does not deliver it twice. Their
+// expiry is kept too, so stale messages are still dropped.
+func (o *Outbox) Resend(q ResendQuery) (n int, err error) {
+	if o.DeadLetters == nil {
+		return
+	}
+	letters, err := o.DeadLetters.List(q)
+	if err != nil {
+		return
+	}
+	for _, d := range letters {
+		e := d.Envelope
+		e.Attempts = 0
+		e.SendAt = o.now()
+		if err = o.store.Put(e); err != nil {
+			return
+		}
+		if err = o.DeadLetters.Delete(e.Id); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
@@ -0,0 +1,147 @@
+package ambassador
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const defaultCompactionInterval = time.Hour
+
+// RetentionPolicy bounds what stores keep in long running deployments. Zero
+// fields keep everything.
+type RetentionPolicy struct {
+	// MaxAge drops records older than it.
+	MaxAge time.Duration
+	// MaxPerTenant keeps only the latest records of each tenant.
+	MaxPerTenant int
+}
+
+// Compactor is implemented by stores which can drop records by a retention
+// policy, e.g. transcripts and dead letters. Queued envelopes are not
+// compacted, since every one of them must get a final result; they are
+// bounded by Envelope.ExpiresAt instead, which reports them as expired.
+type Compactor interface {
+	Compact(p RetentionPolicy, now time.Time) (removed int, err error)
+}
+
+// retained is a record of a store with its tenant and time.
+type retained struct {
+	key    interface{}
+	tenant string
+	at     time.Time
+}
+
+// expired returns the keys of the records a policy drops.
+func (p RetentionPolicy) expired(records []retained, now time.Time) (keys []interface{}) {
+	kept := map[string][]retained{}
+	for _, r := range records {
+		if p.MaxAge > 0 && now.Sub(r.at) > p.MaxAge {
+			keys = append(keys, r.key)
+			continue
+		}
+		kept[r.tenant] = append(kept[r.tenant], r)
+	}
+	if p.MaxPerTenant <= 0 {
+		return
+	}
+	for _, records := range kept {
+		if len(records) <= p.MaxPerTenant {
+			continue
+		}
+		sort.SliceStable(records, func(i, j int) bool { return records[i].at.After(records[j].at) })
+		for _, r := range records[p.MaxPerTenant:] {
+			keys = append(keys, r.key)
+		}
+	}
+	return
+}
+
+// Compact drops the entries a policy does not retain.
+func (s *MemoryTranscriptStore) Compact(p RetentionPolicy, now time.Time) (removed int, err error) {
+	s.Lock()
+	defer s.Unlock()
+	records := make([]retained, len(s.entries))
+	for i, e := range s.entries {
+		records[i] = retained{key: e, tenant: e.Tenant, at: e.At}
+	}
+	drop := map[interface{}]bool{}
+	for _, key := range p.expired(records, now) {
+		drop[key] = true
+	}
+	if len(drop) == 0 {
+		return
+	}
+	entries := make([]*TranscriptEntry, 0, len(s.entries)-len(drop))
+	for _, e := range s.entries {
+		if !drop[e] {
+			entries = append(entries, e)
+		}
+	}
+	s.entries = entries
+	return len(drop), nil
+}
+
+// Compact drops the dead letters a policy does not retain by when they
+// failed.
+func (s *MemoryDeadLetterStore) Compact(p RetentionPolicy, now time.Time) (removed int, err error) {
+	s.Lock()
+	defer s.Unlock()
+	records := make([]retained, 0, len(s.letters))
+	for id, d := range s.letters {
+		records = append(records, retained{key: id, tenant: d.Envelope.Tenant, at: d.FailedAt})
+	}
+	for _, key := range p.expired(records, now) {
+		delete(s.letters, key.(string))
+		removed++
+	}
+	return
+}
+
+// Compaction applies a retention policy to stores periodically.
+type Compaction struct {
+	Policy   RetentionPolicy
+	Stores   []Compactor
+	Interval time.Duration
+	Logger   Logger
+	now      func() time.Time
+}
+
+func NewCompaction(p RetentionPolicy, stores ...Compactor) *Compaction {
+	return &Compaction{
+		Policy:   p,
+		Stores:   stores,
+		Interval: defaultCompactionInterval,
+		Logger:   stdLogger{},
+		now:      time.Now,
+	}
+}
+
+// Compact compacts every store once. A store which fails does not stop the
+// others, and the last error is returned.
+func (c *Compaction) Compact() (removed int, err error) {
+	now := c.now()
+	for _, s := range c.Stores {
+		n, compactErr := s.Compact(c.Policy, now)
+		removed += n
+		if compactErr != nil {
+			c.Logger.Printf("ambassador: fail to compact a store: %s", compactErr)
+			err = compactErr
+		}
+	}
+	return
+}
+
+// Run compacts the stores periodically until the context is done.
+func (c *Compaction) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			c.Compact()
+		}
+	}
+}
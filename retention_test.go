@@ -0,0 +1,37 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompaction(t *testing.T) {
+	now := time.Date(2017, 7, 14, 12, 0, 0, 0, time.UTC)
+	transcripts := NewMemoryTranscriptStore()
+	for i, tenant := range []string{"acme", "acme", "acme", "globex", "acme"} {
+		transcripts.Append(&TranscriptEntry{At: now.Add(-time.Duration(i) * time.Hour), Tenant: tenant, Text: tenant})
+	}
+	letters := NewMemoryDeadLetterStore()
+	letters.Put(&DeadLetter{Envelope: &Envelope{Id: "dead"}, FailedAt: now.Add(-25 * time.Hour)})
+
+	c := NewCompaction(RetentionPolicy{MaxAge: 3*time.Hour + time.Minute, MaxPerTenant: 2}, transcripts, letters)
+	c.now = func() time.Time { return now }
+	removed, err := c.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the 4h old entry is too old, and the 2h old one is over the quota of acme
+	if removed != 3 {
+		t.Errorf("expect 3 records removed, got %d", removed)
+	}
+	entries, _ := transcripts.Query(TranscriptQuery{})
+	if len(entries) != 3 || !entries[0].At.Equal(now.Add(-3*time.Hour)) || entries[0].Tenant != "globex" {
+		t.Errorf("unexpected retained entries: %+v", entries)
+	}
+	if _, ok := interface{}(NewMemoryOutboxStore()).(Compactor); ok {
+		t.Error("expect pending envelopes never to be compacted without a result")
+	}
+	if remaining, _ := letters.List(ResendQuery{}); len(remaining) != 0 {
+		t.Errorf("expect the old dead letter removed, got %+v", remaining)
+	}
+}
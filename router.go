@@ -0,0 +1,191 @@
+package ambassador
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Middleware wraps a handler, e.g. to recover panics.
+type Middleware func(Handler) Handler
+
+type prefixRoute struct {
+	prefix  string
+	handler Handler
+}
+
+// Router dispatches messages to handlers by their payloads or the types of
+// their contents.
+type Router struct {
+	sync.RWMutex
+	middlewares []Middleware
+	payloads    map[string]Handler
+	prefixes    []prefixRoute
+	contents    map[reflect.Type]Handler
+	fallback    Handler
+	locks       *ConversationLocks
+}
+
+func NewRouter() *Router {
+	return &Router{
+		payloads: map[string]Handler{},
+		contents: map[reflect.Type]Handler{},
+	}
+}
+
+// Use adds middlewares which wrap every dispatch, the first one outermost.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.Lock()
+	defer r.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// Payload routes commands by payload. A payload ending with "*" matches a
+// prefix.
+func (r *Router) Payload(payload string, h Handler) {
+	r.Lock()
+	defer r.Unlock()
+	if strings.HasSuffix(payload, "*") {
+		r.prefixes = append(r.prefixes, prefixRoute{strings.TrimSuffix(payload, "*"), h})
+		return
+	}
+	r.payloads[payload] = h
+}
+
+// Content routes messages by the type of a sample content, e.g.
+// &LocationContent{}.
+func (r *Router) Content(sample interface{}, h Handler) {
+	r.Lock()
+	defer r.Unlock()
+	r.contents[reflect.TypeOf(sample)] = h
+}
+
+// Text routes text messages.
+func (r *Router) Text(h Handler) {
+	r.Content(&TextContent{}, h)
+}
+
+// Default handles messages which match no route.
+func (r *Router) Default(h Handler) {
+	r.Lock()
+	defer r.Unlock()
+	r.fallback = h
+}
+
+// SerializeConversations makes the messages of a conversation handled one
+// at a time, so that two rapid messages of a user see consistent sessions.
+// Different conversations are handled in parallel only by different
+// ambassadors, e.g. one per message as a Webhook creates them, since
+// handlers sharing an ambassador would send the messages staged by each
+// other, so messages handled by one ambassador are also serialized. Messages
+// which must also keep their order are better dispatched by an
+// OrderedProcessor.
+func (r *Router) SerializeConversations() {
+	r.Lock()
+	defer r.Unlock()
+	if r.locks == nil {
+		r.locks = NewConversationLocks()
+	}
+}
+
+func (r *Router) route(msg Message) Handler {
+	r.RLock()
+	defer r.RUnlock()
+	if c, ok := msg.Content.(*CommandContent); ok {
+		if h, ok := r.payloads[c.Payload]; ok {
+			return h
+		}
+		for _, route := range r.prefixes {
+			if strings.HasPrefix(c.Payload, route.prefix) {
+				return route.handler
+			}
+		}
+	}
+	if h, ok := r.contents[reflect.TypeOf(msg.Content)]; ok {
+		return h
+	}
+	return r.fallback
+}
+
+func (r *Router) Handle(a Ambassador, msg Message) error {
+	var h Handler = HandlerFunc(func(a Ambassador, msg Message) error {
+		if h := r.route(msg); h != nil {
+			return h.Handle(a, msg)
+		}
+		return nil
+	})
+
+	r.RLock()
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	locks := r.locks
+	r.RUnlock()
+	if locks != nil {
+		defer locks.Acquire(platformOf(a) + ":" + msg.chat())()
+		if key, ok := ambassadorKey(a); ok {
+			defer locks.Acquire(key)()
+		}
+	}
+	return h.Handle(a, msg)
+}
+
+// ambassadorKey identifies the ambassador behind any wrappers by its
+// address, since the messages staged on it are shared by its handlers.
+func ambassadorKey(a Ambassador) (key string, ok bool) {
+	v := reflect.ValueOf(unwrap(a))
+	if v.Kind() != reflect.Ptr {
+		return
+	}
+	return fmt.Sprintf("ambassador:%x", v.Pointer()), true
+}
+
+type conversationLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// ConversationLocks are mutexes by conversation, which are freed once no
+// one holds or waits for them.
+type ConversationLocks struct {
+	sync.Mutex
+	locks map[string]*conversationLock
+}
+
+func NewConversationLocks() *ConversationLocks {
+	return &ConversationLocks{locks: map[string]*conversationLock{}}
+}
+
+// Acquire locks a conversation and returns the func to unlock it.
+func (c *ConversationLocks) Acquire(key string) (release func()) {
+	c.Lock()
+	l, ok := c.locks[key]
+	if !ok {
+		l = &conversationLock{}
+		c.locks[key] = l
+	}
+	l.waiters++
+	c.Unlock()
+
+	l.Mutex.Lock()
+	return func() {
+		l.Mutex.Unlock()
+		c.Lock()
+		defer c.Unlock()
+		if l.waiters--; l.waiters == 0 {
+			delete(c.locks, key)
+		}
+	}
+}
+
+// Serialize is a middleware which handles the messages of a conversation
+// one at a time, for handlers which are not run by a Router.
+func (c *ConversationLocks) Serialize() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) error {
+			defer c.Acquire(platformOf(a) + ":" + msg.chat())()
+			return next.Handle(a, msg)
+		})
+	}
+}
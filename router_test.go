@@ -0,0 +1,96 @@
+package ambassador
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouterRecover(t *testing.T) {
+	r := NewRouter()
+	r.Use(Recover(RecoverOptions{Logger: testLogger{t}, Apology: "sorry"}))
+	r.Payload("BUY_*", HandlerFunc(func(a Ambassador, msg Message) error {
+		panic("out of stock")
+	}))
+	r.Text(HandlerFunc(func(a Ambassador, msg Message) error {
+		a.SendText("echo")
+		return a.Send(msg.SenderId)
+	}))
+
+	a := &recordAmbassador{}
+	if err := r.Handle(a, Message{SenderId: "u1", Content: &TextContent{Text: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Handle(a, Message{SenderId: "u1", Content: &CommandContent{Payload: "BUY_1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.sent) != 2 || a.sent[0] != "u1:echo" || a.sent[1] != "u1:sorry" {
+		t.Errorf("unexpected deliveries: %v", a.sent)
+	}
+}
+
+func TestRouterSerializeConversations(t *testing.T) {
+	r := NewRouter()
+	r.SerializeConversations()
+	var mu sync.Mutex
+	running, maxRunning := map[string]int{}, map[string]int{}
+	total, maxTotal := 0, 0
+	r.Text(HandlerFunc(func(a Ambassador, msg Message) error {
+		mu.Lock()
+		running[msg.SenderId]++
+		total++
+		if running[msg.SenderId] > maxRunning[msg.SenderId] {
+			maxRunning[msg.SenderId] = running[msg.SenderId]
+		}
+		if total > maxTotal {
+			maxTotal = total
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running[msg.SenderId]--
+		total--
+		mu.Unlock()
+		return nil
+	}))
+
+	handle := func(newAmbassador func() Ambassador) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			for _, user := range []string{"u1", "u2"} {
+				wg.Add(1)
+				go func(user string) {
+					defer wg.Done()
+					r.Handle(newAmbassador(), Message{SenderId: user, Content: &TextContent{Text: "hi"}})
+				}(user)
+			}
+		}
+		wg.Wait()
+	}
+
+	handle(func() Ambassador { return &recordAmbassador{} })
+	if maxRunning["u1"] != 1 || maxRunning["u2"] != 1 {
+		t.Errorf("expect a conversation to be handled one at a time, got %v", maxRunning)
+	}
+	if maxTotal != 2 {
+		t.Errorf("expect conversations to be handled in parallel, got %d at most", maxTotal)
+	}
+
+	maxTotal = 0
+	shared := &recordAmbassador{}
+	handle(func() Ambassador { return &sendTracker{Ambassador: shared} })
+	if maxTotal != 1 {
+		t.Errorf("expect the handlers of a shared ambassador to be serialized, got %d at most", maxTotal)
+	}
+	if len(r.locks.locks) != 0 {
+		t.Errorf("expect the locks to be freed, got %d", len(r.locks.locks))
+	}
+}
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Printf(format string, v ...interface{}) {
+	l.t.Logf(format, v...)
+}
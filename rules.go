@@ -0,0 +1,169 @@
+package ambassador
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule replies canned messages to texts which contain one of its keywords,
+// match its pattern or are annotated with its intent.
+type Rule struct {
+	Name     string            `json:"name"`
+	Keywords []string          `json:"keywords,omitempty"`
+	Pattern  string            `json:"pattern,omitempty"`
+	Intent   string            `json:"intent,omitempty"`
+	Reply    []OutboundMessage `json:"reply"`
+}
+
+// RuleStore loads the rules of a RulesEngine, e.g. from a file or a database
+// managed by non-developers.
+type RuleStore interface {
+	LoadRules() ([]Rule, error)
+}
+
+type RuleStoreFunc func() ([]Rule, error)
+
+func (f RuleStoreFunc) LoadRules() ([]Rule, error) {
+	return f()
+}
+
+// FileRuleStore loads rules from a JSON file of a rule array.
+type FileRuleStore string
+
+func (path FileRuleStore) LoadRules() (rules []Rule, err error) {
+	b, err := ioutil.ReadFile(string(path))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(b, &rules)
+	return
+}
+
+type compiledRule struct {
+	Rule
+	keywords []string
+	pattern  *regexp.Regexp
+}
+
+func compileRule(rule Rule) (c compiledRule, err error) {
+	c = compiledRule{Rule: rule}
+	for _, keyword := range rule.Keywords {
+		if keyword != "" {
+			c.keywords = append(c.keywords, strings.ToLower(keyword))
+		}
+	}
+	if rule.Pattern != "" {
+		if c.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+			return c, fmt.Errorf("fail to compile the pattern of rule %s: %s", rule.Name, err)
+		}
+	}
+	if len(rule.Reply) == 0 {
+		return c, fmt.Errorf("rule %s has no reply", rule.Name)
+	}
+	return
+}
+
+func (r *compiledRule) match(text string, nlp *NLP) bool {
+	if r.Intent != "" && nlp != nil {
+		for _, intent := range nlp.Intents {
+			if intent.Name == r.Intent && intent.Confidence >= NLPConfidenceThreshold {
+				return true
+			}
+		}
+	}
+	lower := strings.ToLower(text)
+	for _, keyword := range r.keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return r.pattern != nil && r.pattern.MatchString(text)
+}
+
+// RulesEngine is a handler which answers texts by the first matching rule,
+// and passes other messages to its fallback handler.
+type RulesEngine struct {
+	sync.Mutex
+	store RuleStore
+	rules []compiledRule
+	// Fallback handles the messages no rule matches.
+	Fallback Handler
+	// Interval is how often Run reloads the rules.
+	Interval time.Duration
+	Logger   Logger
+}
+
+func NewRulesEngine(store RuleStore) *RulesEngine {
+	return &RulesEngine{store: store, Interval: time.Minute, Logger: stdLogger{}}
+}
+
+// Reload loads the rules from the store. The loaded rules are kept if any
+// of the new rules is invalid.
+func (e *RulesEngine) Reload() (err error) {
+	rules, err := e.store.LoadRules()
+	if err != nil {
+		return fmt.Errorf("fail to load rules: %s", err)
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		c, err := compileRule(rule)
+		if err != nil {
+			return err
+		}
+		compiled = append(compiled, c)
+	}
+
+	e.Lock()
+	e.rules = compiled
+	e.Unlock()
+	return
+}
+
+// Run reloads the rules periodically until the context is done.
+func (e *RulesEngine) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := e.Reload(); err != nil {
+				e.Logger.Printf("ambassador: %s", err)
+			}
+		}
+	}
+}
+
+// Match returns the first rule matching a text.
+func (e *RulesEngine) Match(text string, nlp *NLP) (rule Rule, ok bool) {
+	e.Lock()
+	defer e.Unlock()
+	for i := range e.rules {
+		if e.rules[i].match(text, nlp) {
+			return e.rules[i].Rule, true
+		}
+	}
+	return
+}
+
+func (e *RulesEngine) Handle(a Ambassador, msg Message) (err error) {
+	if text, ok := msg.Content.(*TextContent); ok {
+		if rule, ok := e.Match(text.Text, text.NLP); ok {
+			if err = Messages(rule.Reply...)(a); err != nil {
+				return
+			}
+			return a.Send(msg.ReplyTarget())
+		}
+	}
+	if e.Fallback != nil {
+		return e.Fallback.Handle(a, msg)
+	}
+	return
+}
@@ -0,0 +1,47 @@
+package ambassador
+
+import "testing"
+
+func TestRulesEngine(t *testing.T) {
+	rules := []Rule{
+		{Name: "hours", Keywords: []string{"Opening Hours"}, Reply: []OutboundMessage{TextMessage("9 to 5")}},
+		{Name: "order", Pattern: `^#\d+$`, Reply: []OutboundMessage{TextMessage("looking up your order")}},
+		{Name: "refund", Intent: "refund", Reply: []OutboundMessage{TextMessage("refunds take 3 days")}},
+	}
+	engine := NewRulesEngine(RuleStoreFunc(func() ([]Rule, error) { return rules, nil }))
+	if err := engine.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	fallback := 0
+	engine.Fallback = HandlerFunc(func(a Ambassador, msg Message) error {
+		fallback++
+		return nil
+	})
+
+	a := &recordAmbassador{}
+	texts := []*TextContent{
+		{Text: "what are your opening hours?"},
+		{Text: "#123"},
+		{Text: "money back", NLP: &NLP{Intents: []NLPIntent{{Name: "refund", Confidence: 0.9}}}},
+		{Text: "hello"},
+	}
+	for _, text := range texts {
+		if err := engine.Handle(a, Message{SenderId: "user", Content: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.sent) != 3 || a.sent[0] != "user:9 to 5" || a.sent[2] != "user:refunds take 3 days" {
+		t.Errorf("unexpected replies: %v", a.sent)
+	}
+	if fallback != 1 {
+		t.Errorf("expect a message to fall back, got %d", fallback)
+	}
+
+	rules = []Rule{{Name: "broken", Pattern: "(", Reply: []OutboundMessage{TextMessage("x")}}}
+	if err := engine.Reload(); err == nil {
+		t.Error("expect an invalid pattern to fail reloading")
+	}
+	if _, ok := engine.Match("#1", nil); !ok {
+		t.Error("expect the previous rules to be kept")
+	}
+}
@@ -0,0 +1,229 @@
+package ambassador
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Segment is a named list of users per platform, e.g. imported from a CRM.
+type Segment struct {
+	Id   string
+	Name string
+	// Members are the user ids of the segment by platform.
+	Members map[string][]string
+	// AudienceGroupId is the LINE audience group synced from the LINE
+	// members, if any.
+	AudienceGroupId int64
+	UpdatedAt       time.Time
+}
+
+// SegmentMember is a row of an imported or exported segment.
+type SegmentMember struct {
+	Platform string `json:"platform"`
+	UserId   string `json:"user_id"`
+}
+
+type SegmentStore interface {
+	Save(s *Segment) error
+	Get(id string) (s *Segment, ok bool, err error)
+	Delete(id string) error
+}
+
+type MemorySegmentStore struct {
+	sync.Mutex
+	segments map[string]*Segment
+}
+
+func NewMemorySegmentStore() *MemorySegmentStore {
+	return &MemorySegmentStore{segments: map[string]*Segment{}}
+}
+
+func (s *MemorySegmentStore) Save(seg *Segment) error {
+	s.Lock()
+	defer s.Unlock()
+	s.segments[seg.Id] = seg
+	return nil
+}
+
+func (s *MemorySegmentStore) Get(id string) (seg *Segment, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	seg, ok = s.segments[id]
+	return
+}
+
+func (s *MemorySegmentStore) Delete(id string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.segments, id)
+	return nil
+}
+
+// ReadSegmentMembers reads members as CSV rows of "platform,user_id", with
+// an optional header, or as a JSON array of SegmentMember.
+func ReadSegmentMembers(r io.Reader, format string) (members []SegmentMember, err error) {
+	switch format {
+	case ExportJSON:
+		err = decodeJSON(r, &members)
+		return
+	case ExportCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 2
+		cr.TrimLeadingSpace = true
+		var rows [][]string
+		if rows, err = cr.ReadAll(); err != nil {
+			return
+		}
+		for i, row := range rows {
+			if i == 0 && row[0] == "platform" {
+				continue
+			}
+			members = append(members, SegmentMember{Platform: row[0], UserId: row[1]})
+		}
+		return
+	}
+	return nil, fmt.Errorf("unknown segment format: %s", format)
+}
+
+// SegmentManager imports segments and resolves them into audiences.
+type SegmentManager struct {
+	store SegmentStore
+	now   func() time.Time
+}
+
+func NewSegmentManager(store SegmentStore) *SegmentManager {
+	if store == nil {
+		store = NewMemorySegmentStore()
+	}
+	return &SegmentManager{store: store, now: time.Now}
+}
+
+// Import reads the members of a segment and replaces the stored segment of
+// the same id. Blank and duplicated ids are skipped.
+func (m *SegmentManager) Import(id, name string, r io.Reader, format string) (s *Segment, err error) {
+	members, err := ReadSegmentMembers(r, format)
+	if err != nil {
+		return
+	}
+	s = &Segment{Id: id, Name: name, Members: map[string][]string{}, UpdatedAt: m.now()}
+	seen := map[SegmentMember]bool{}
+	for _, member := range members {
+		member.Platform = strings.TrimSpace(member.Platform)
+		member.UserId = strings.TrimSpace(member.UserId)
+		if member.Platform == "" || member.UserId == "" || seen[member] {
+			continue
+		}
+		seen[member] = true
+		s.Members[member.Platform] = append(s.Members[member.Platform], member.UserId)
+	}
+	return s, m.store.Save(s)
+}
+
+// Export writes the members of a segment in the format Import reads.
+func (m *SegmentManager) Export(w io.Writer, id, format string) (err error) {
+	s, err := m.segment(id)
+	if err != nil {
+		return
+	}
+	platforms := make([]string, 0, len(s.Members))
+	for platform := range s.Members {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	members := []SegmentMember{}
+	for _, platform := range platforms {
+		for _, userId := range s.Members[platform] {
+			members = append(members, SegmentMember{Platform: platform, UserId: userId})
+		}
+	}
+
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(members)
+	case ExportCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"platform", "user_id"})
+		for _, member := range members {
+			cw.Write([]string{member.Platform, member.UserId})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown segment format: %s", format)
+}
+
+func (m *SegmentManager) segment(id string) (s *Segment, err error) {
+	s, ok, err := m.store.Get(id)
+	if err != nil {
+		return
+	}
+	if !ok {
+		return nil, fmt.Errorf("segment %s is not found", id)
+	}
+	return
+}
+
+// SyncLineAudience uploads the LINE members of a segment as an audience
+// group, so that broadcasts to the segment on LINE are narrowcast.
+func (m *SegmentManager) SyncLineAudience(l *LineAmbassador, id string) (err error) {
+	s, err := m.segment(id)
+	if err != nil {
+		return
+	}
+	if len(s.Members["line"]) == 0 {
+		return fmt.Errorf("segment %s has no line member", id)
+	}
+	description := s.Name
+	if description == "" {
+		description = s.Id
+	}
+	if s.AudienceGroupId, err = l.CreateAudienceGroup(description, s.Members["line"]); err != nil {
+		return
+	}
+	return m.store.Save(s)
+}
+
+// Audience selects the members of a segment on a platform. On LINE, the
+// synced audience group is narrowcast to. Other platforms fan out to the
+// listed members.
+func (m *SegmentManager) Audience(id, platform string) AudienceSelector {
+	return func() (audience Audience, err error) {
+		s, err := m.segment(id)
+		if err != nil {
+			return
+		}
+		audience.Recipients = s.Members[platform]
+		if platform == "line" {
+			audience.AudienceGroupId = s.AudienceGroupId
+		}
+		return
+	}
+}
+
+// Enroll enrolls the members of a segment on a platform in a campaign. The
+// users who fail to enroll, e.g. enrolled already, are returned as
+// DeliveryErrors.
+func (m *SegmentManager) Enroll(r *CampaignRunner, id, platform string, lastInbound func(userId string) time.Time) (err error) {
+	s, err := m.segment(id)
+	if err != nil {
+		return
+	}
+	errs := DeliveryErrors{}
+	for _, userId := range s.Members[platform] {
+		if e := r.Enroll(userId, lastInbound(userId)); e != nil {
+			errs[userId] = e
+		}
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
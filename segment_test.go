@@ -0,0 +1,63 @@
+package ambassador
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestSegmentImportExport(t *testing.T) {
+	m := NewSegmentManager(nil)
+	s, err := m.Import("vip", "VIP", strings.NewReader("platform,user_id\nfacebook,u1\nline,U1\nfacebook, u2\nfacebook,u1\n"), ExportCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Members["facebook"]) != 2 || s.Members["facebook"][1] != "u2" || len(s.Members["line"]) != 1 {
+		t.Errorf("unexpected members: %+v", s.Members)
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := m.Export(buffer, "vip", ExportJSON); err != nil {
+		t.Fatal(err)
+	}
+	var members []SegmentMember
+	json.Unmarshal(buffer.Bytes(), &members)
+	if len(members) != 3 || members[0] != (SegmentMember{"facebook", "u1"}) || members[2] != (SegmentMember{"line", "U1"}) {
+		t.Errorf("unexpected export: %s", buffer.String())
+	}
+	if _, err := m.Import("copy", "", buffer, ExportJSON); err != nil {
+		t.Errorf("expect an export to be imported back: %s", err)
+	}
+
+	a := &recordAmbassador{}
+	audience, err := m.Audience("vip", "facebook")()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Broadcast(a, audience, Messages(TextMessage("sale")), nil); err != nil || len(a.sent) != 2 {
+		t.Errorf("expect the members to be fanned out to, got %v, %v", err, a.sent)
+	}
+}
+
+func TestSegmentLineAudience(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	server.Fail("line.me", testutil.Sequence(&testutil.Failure{Body: `{"audienceGroupId":42}`}))
+	l := NewLineAmbassador("token", server.Client())
+
+	m := NewSegmentManager(nil)
+	m.Import("vip", "VIP", strings.NewReader(`[{"platform":"line","user_id":"U1"},{"platform":"line","user_id":"U2"}]`), ExportJSON)
+	if err := m.SyncLineAudience(l, "vip"); err != nil {
+		t.Fatal(err)
+	}
+	if requests := server.Requests(); len(requests) != 1 || requests[0].Path != "/v2/bot/audienceGroup/upload" {
+		t.Errorf("unexpected requests: %+v", requests)
+	}
+	audience, _ := m.Audience("vip", "line")()
+	if audience.AudienceGroupId != 42 {
+		t.Errorf("expect the synced audience group, got %+v", audience)
+	}
+}
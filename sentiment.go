@@ -0,0 +1,94 @@
+package ambassador
+
+import "strings"
+
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// StickerContent is the content of a sticker. Sentiment is a coarse
+// sentiment of the sticker if it is known.
+type StickerContent struct {
+	PackageId string
+	StickerId string
+	// Keywords describe LINE stickers, e.g. "Happy" or "Sad".
+	Keywords  []string
+	Sentiment string
+}
+
+// StickerSentiments maps stickers to sentiments by "packageId/stickerId",
+// or by "packageId" for a whole package. Bots can add their own stickers.
+var StickerSentiments = map[string]string{
+	// the thumbs up of messenger
+	"/369239263222822": SentimentPositive,
+	"/369239343222814": SentimentPositive,
+	"/369239383222810": SentimentPositive,
+}
+
+var keywordSentiments = map[string]string{
+	"happy": SentimentPositive, "joy": SentimentPositive, "love": SentimentPositive,
+	"laugh": SentimentPositive, "thanks": SentimentPositive, "thank you": SentimentPositive,
+	"ok": SentimentPositive, "good": SentimentPositive, "yes": SentimentPositive,
+	"celebrate": SentimentPositive, "cheer": SentimentPositive, "like": SentimentPositive,
+	"sad": SentimentNegative, "cry": SentimentNegative, "angry": SentimentNegative,
+	"upset": SentimentNegative, "sorry": SentimentNegative, "no": SentimentNegative,
+	"bad": SentimentNegative, "tired": SentimentNegative, "shock": SentimentNegative,
+	"hmm": SentimentNeutral, "bye": SentimentNeutral, "hello": SentimentNeutral,
+	"hi": SentimentNeutral, "sleep": SentimentNeutral,
+}
+
+var emojiSentiments = map[rune]string{
+	'😀': SentimentPositive, '😃': SentimentPositive, '😄': SentimentPositive, '😁': SentimentPositive,
+	'😆': SentimentPositive, '😂': SentimentPositive, '🤣': SentimentPositive, '😊': SentimentPositive,
+	'😍': SentimentPositive, '🥰': SentimentPositive, '😘': SentimentPositive, '👍': SentimentPositive,
+	'👏': SentimentPositive, '🙏': SentimentPositive, '🎉': SentimentPositive, '❤': SentimentPositive,
+	'😢': SentimentNegative, '😭': SentimentNegative, '😞': SentimentNegative, '😠': SentimentNegative,
+	'😡': SentimentNegative, '👎': SentimentNegative, '💔': SentimentNegative, '😤': SentimentNegative,
+	'😩': SentimentNegative, '😫': SentimentNegative, '🙁': SentimentNegative, '☹': SentimentNegative,
+	'😐': SentimentNeutral, '😶': SentimentNeutral, '🤔': SentimentNeutral, '👋': SentimentNeutral,
+}
+
+// StickerSentiment classifies a sticker by StickerSentiments, then by its
+// keywords.
+func StickerSentiment(packageId, stickerId string, keywords []string) (sentiment string, ok bool) {
+	if sentiment, ok = StickerSentiments[packageId+"/"+stickerId]; ok {
+		return
+	}
+	if sentiment, ok = StickerSentiments[packageId]; ok && packageId != "" {
+		return
+	}
+	counts := map[string]int{}
+	for _, keyword := range keywords {
+		if s, found := keywordSentiments[strings.ToLower(keyword)]; found {
+			counts[s]++
+		}
+	}
+	return dominant(counts)
+}
+
+// EmojiSentiment classifies a text by the emoji in it. Texts without known
+// emoji are not classified.
+func EmojiSentiment(text string) (sentiment string, ok bool) {
+	counts := map[string]int{}
+	for _, r := range text {
+		if s, found := emojiSentiments[r]; found {
+			counts[s]++
+		}
+	}
+	return dominant(counts)
+}
+
+func dominant(counts map[string]int) (sentiment string, ok bool) {
+	positive, negative := counts[SentimentPositive], counts[SentimentNegative]
+	switch {
+	case positive > negative:
+		return SentimentPositive, true
+	case negative > positive:
+		return SentimentNegative, true
+	case positive+negative+counts[SentimentNeutral] > 0:
+		return SentimentNeutral, true
+	}
+	return
+}
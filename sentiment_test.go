@@ -0,0 +1,27 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStickerSentiment(t *testing.T) {
+	body := `{"events":[{"type":"message","replyToken":"r1","timestamp":1500000000000,
+		"source":{"type":"user","userId":"u1"},
+		"message":{"id":"m1","type":"sticker","packageId":"446","stickerId":"1988","keywords":["Happy","Joy","Hmm"]}}]}`
+	messages, err := NewLineAmbassador("token", nil).Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sticker, ok := messages[0].Content.(*StickerContent)
+	if !ok || sticker.StickerId != "1988" || sticker.Sentiment != SentimentPositive {
+		t.Errorf("unexpected sticker: %+v", messages[0].Content)
+	}
+
+	if s, _ := EmojiSentiment("ok 😭😭 👍"); s != SentimentNegative {
+		t.Errorf("expect a negative sentiment, got %q", s)
+	}
+	if _, ok := EmojiSentiment("no emoji"); ok {
+		t.Error("expect a text without emoji not to be classified")
+	}
+}
@@ -0,0 +1,69 @@
+package ambassador
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// ShardKey returns the shard of a conversation among n shards. Publishing
+// inbound messages to a queue partitioned by it keeps the messages of a
+// user on the same partition, and therefore in order.
+func ShardKey(conversationId string, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(conversationId))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// ShardKey returns the shard of the conversation of a message.
+func (m *Message) ShardKey(shards int) int {
+	return ShardKey(m.chat(), shards)
+}
+
+// OrderedProcessor handles messages concurrently across conversations but
+// sequentially within a conversation, by a worker per shard.
+type OrderedProcessor struct {
+	queues []chan Message
+	handle func(Message)
+	wg     sync.WaitGroup
+}
+
+func NewOrderedProcessor(shards, buffer int, handle func(Message)) *OrderedProcessor {
+	if shards < 1 {
+		shards = 1
+	}
+	p := &OrderedProcessor{
+		queues: make([]chan Message, shards),
+		handle: handle,
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan Message, buffer)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *OrderedProcessor) work(queue chan Message) {
+	defer p.wg.Done()
+	for msg := range queue {
+		p.handle(msg)
+	}
+}
+
+// Dispatch queues a message to the worker of its shard. It blocks while the
+// queue of the shard is full.
+func (p *OrderedProcessor) Dispatch(msg Message) {
+	p.queues[msg.ShardKey(len(p.queues))] <- msg
+}
+
+// Close stops accepting messages and waits for queued messages to be
+// handled.
+func (p *OrderedProcessor) Close() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+}
@@ -0,0 +1,29 @@
+package ambassador
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestOrderedProcessor(t *testing.T) {
+	var mu sync.Mutex
+	seen := map[string][]int64{}
+	p := NewOrderedProcessor(4, 10, func(msg Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[msg.SenderId] = append(seen[msg.SenderId], msg.Timestamp)
+	})
+	for i := int64(0); i < 100; i++ {
+		p.Dispatch(Message{SenderId: fmt.Sprintf("u%d", i%7), Timestamp: i})
+	}
+	p.Close()
+
+	for userId, timestamps := range seen {
+		for i := 1; i < len(timestamps); i++ {
+			if timestamps[i] < timestamps[i-1] {
+				t.Fatalf("messages of %s are out of order: %v", userId, timestamps)
+			}
+		}
+	}
+}
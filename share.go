@@ -0,0 +1,43 @@
+package ambassador
+
+// ContactContent is the contact cards a user shares.
+type ContactContent struct {
+	Contacts []Contact
+}
+
+type Contact struct {
+	Name         string
+	FirstName    string
+	LastName     string
+	Organization string
+	Phones       []ContactPhone
+	Emails       []string
+}
+
+type ContactPhone struct {
+	Number string
+	Type   string
+	// UserId is the user of the number on the platform, e.g. a wa_id, if
+	// the number has an account.
+	UserId string
+}
+
+// ProductContent is the products of a catalog a user shares or asks about,
+// or the cart a user sends. Fields a platform does not share are zero.
+type ProductContent struct {
+	CatalogId string
+	Products  []Product
+	// Text is what the user writes along with the products.
+	Text string
+}
+
+type Product struct {
+	Id         string
+	RetailerId string
+	Title      string
+	Subtitle   string
+	ImageUrl   string
+	Quantity   int
+	Price      float64
+	Currency   string
+}
@@ -0,0 +1,55 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWhatsAppShares(t *testing.T) {
+	w := NewWhatsAppAmbassador("pn1", "token", nil)
+	messages, err := w.Translate(strings.NewReader(`{"object":"whatsapp_business_account","entry":[{"id":"b1","changes":[
+		{"field":"messages","value":{"metadata":{"phone_number_id":"pn1"},
+		"messages":[
+			{"from":"886900000000","id":"wamid.1","timestamp":"1700000000","type":"contacts","contacts":[
+				{"name":{"formatted_name":"Amy Lin","first_name":"Amy","last_name":"Lin"},"org":{"company":"Acme"},
+				"phones":[{"phone":"+886 911 111 111","type":"CELL","wa_id":"886911111111"}],"emails":[{"email":"amy@example.com","type":"WORK"}]}]},
+			{"from":"886900000000","id":"wamid.2","timestamp":"1700000001","type":"text","text":{"body":"in stock?"},
+				"context":{"from":"886922222222","id":"wamid.p","referred_product":{"catalog_id":"cat1","product_retailer_id":"sku-1"}}},
+			{"from":"886900000000","id":"wamid.3","timestamp":"1700000002","type":"order","order":{"catalog_id":"cat1","text":"asap",
+				"product_items":[{"product_retailer_id":"sku-1","quantity":2,"item_price":9.5,"currency":"USD"}]}}]}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contact, ok := messages[0].Content.(*ContactContent)
+	if !ok || len(contact.Contacts) != 1 {
+		t.Fatalf("expect a contact, got %+v", messages[0].Content)
+	}
+	if c := contact.Contacts[0]; c.Name != "Amy Lin" || c.Organization != "Acme" || c.Phones[0].UserId != "886911111111" || c.Emails[0] != "amy@example.com" {
+		t.Errorf("unexpected contact: %+v", c)
+	}
+	if p, ok := messages[1].Content.(*ProductContent); !ok || p.CatalogId != "cat1" || p.Products[0].RetailerId != "sku-1" || p.Text != "in stock?" {
+		t.Errorf("expect a question about a product, got %+v", messages[1].Content)
+	}
+	if p, ok := messages[2].Content.(*ProductContent); !ok || p.Text != "asap" || p.Products[0].Quantity != 2 || p.Products[0].Price != 9.5 {
+		t.Errorf("expect a cart, got %+v", messages[2].Content)
+	}
+}
+
+func TestFBProductShare(t *testing.T) {
+	a := NewFBAmbassador("token", nil)
+	messages, err := a.Translate(strings.NewReader(`{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1700000000000,"message":{"mid":"m1",
+			"attachments":[{"type":"template","payload":{"product":{"elements":[
+				{"id":"123","retailer_id":"sku-1","image_url":"https://example.com/a.png","title":"Mug","subtitle":"$9.50"}]}}}]}},
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"timestamp":1700000000001,"message":{"mid":"m2",
+			"attachments":[{"type":"template","payload":{"template_type":"generic"}}]}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := messages[0].Content.(*ProductContent); !ok || p.Products[0].Id != "123" || p.Products[0].RetailerId != "sku-1" || p.Products[0].Title != "Mug" {
+		t.Errorf("expect a shared product, got %+v", messages[0].Content)
+	}
+	if _, ok := messages[1].Content.(*FBMessageContent); !ok {
+		t.Errorf("expect other templates to stay raw, got %+v", messages[1].Content)
+	}
+}
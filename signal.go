@@ -0,0 +1,399 @@
+package ambassador
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signalPollTimeout is how long a receive call of the gateway waits for new
+// messages, in seconds.
+const signalPollTimeout = 30
+
+// signalMenus keeps the payloads of the last numbered options sent to each
+// conversation, since Signal has no buttons.
+var signalMenus = &numberedMenus{menus: map[string][]string{}}
+
+// SignalEnvelope is a message received by a signal-cli REST gateway.
+type SignalEnvelope struct {
+	Source      string `json:"source"`
+	SourceUuid  string `json:"sourceUuid"`
+	Timestamp   int64  `json:"timestamp"`
+	DataMessage *struct {
+		Timestamp   int64              `json:"timestamp"`
+		Message     string             `json:"message"`
+		Attachments []SignalAttachment `json:"attachments"`
+		GroupInfo   *struct {
+			GroupId string `json:"groupId"`
+		} `json:"groupInfo"`
+		Quote *struct {
+			Id int64 `json:"id"`
+		} `json:"quote"`
+	} `json:"dataMessage"`
+	ReceiptMessage *struct {
+		IsDelivery bool    `json:"isDelivery"`
+		IsRead     bool    `json:"isRead"`
+		Timestamps []int64 `json:"timestamps"`
+	} `json:"receiptMessage"`
+}
+
+type SignalAttachment struct {
+	Id          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+}
+
+// SignalMessageContent is the content of a message with attachments. The
+// attachments can be downloaded from the gateway by their ids.
+type SignalMessageContent struct {
+	Text        string
+	Attachments []SignalAttachment
+}
+
+type signalReceived struct {
+	Envelope SignalEnvelope `json:"envelope"`
+	Account  string         `json:"account"`
+}
+
+// signalMessage is a staged message with the payloads of its numbered
+// options, or a pause of WithTyping if it is empty.
+type signalMessage struct {
+	Message     string   `json:"message,omitempty"`
+	Attachments []string `json:"base64_attachments,omitempty"`
+	payloads    []string
+	pause       time.Duration
+}
+
+// SignalAmbassador talks to Signal users through a signal-cli REST gateway
+// registered with a phone number, so that no cloud platform is involved.
+// Messages are received by the webhook of the gateway in json-rpc mode or by
+// polling it. The message id of a message is its timestamp, and the chat of
+// a group message is "group." followed by the group id.
+type SignalAmbassador struct {
+	sync.Mutex
+	gateway      string
+	number       string
+	client       *http.Client
+	messages     []signalMessage
+	lastMessages []interface{}
+	correlation  string
+}
+
+// NewSignalAmbassador returns an ambassador sending from a number registered
+// to a gateway, e.g. "http://localhost:8080".
+func NewSignalAmbassador(gateway, number string, client *http.Client) *SignalAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SignalAmbassador{gateway: strings.TrimSuffix(gateway, "/"), number: number, client: client}
+}
+
+func (s *SignalAmbassador) SetCorrelationId(id string) {
+	s.correlation = id
+}
+
+func (s *SignalAmbassador) Platform() string {
+	return "signal"
+}
+
+// Translate turns the envelopes of a receive response, or the one of a
+// json-rpc notification pushed to the webhook, into messages.
+func (s *SignalAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := ioutil.ReadAll(limitReader(r))
+	if err != nil {
+		return
+	}
+	received := []signalReceived{}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = jsonCodec.Unmarshal(trimmed, &received)
+	} else {
+		var notification struct {
+			signalReceived
+			Params *signalReceived `json:"params"`
+		}
+		err = jsonCodec.Unmarshal(trimmed, &notification)
+		if notification.Params != nil {
+			received = append(received, *notification.Params)
+		} else {
+			received = append(received, notification.signalReceived)
+		}
+	}
+	if err != nil {
+		return
+	}
+	if len(received) > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
+
+	messages = []Message{}
+	for _, rcv := range received {
+		e := rcv.Envelope
+		sender := e.Source
+		if sender == "" {
+			sender = e.SourceUuid
+		}
+		if sender == "" {
+			continue
+		}
+		msg := Message{
+			SenderId:    sender,
+			RecipientId: rcv.Account,
+			MessageId:   strconv.FormatInt(e.Timestamp, 10),
+			Timestamp:   e.Timestamp,
+		}
+		switch {
+		case e.DataMessage != nil:
+			d := e.DataMessage
+			if d.GroupInfo != nil {
+				msg.ChatId = "group." + d.GroupInfo.GroupId
+			}
+			if d.Quote != nil {
+				msg.InReplyTo = strconv.FormatInt(d.Quote.Id, 10)
+			}
+			switch {
+			case len(d.Attachments) > 0:
+				msg.Content = &SignalMessageContent{Text: d.Message, Attachments: d.Attachments}
+			case d.Message != "":
+				msg.Content = &TextContent{Text: d.Message}
+				if payload, ok := signalMenus.lookup(msg.chat(), d.Message); ok {
+					msg.Content = &CommandContent{Payload: payload}
+				}
+			default:
+				continue
+			}
+		case e.ReceiptMessage != nil:
+			var watermark int64
+			ids := []string{}
+			for _, ts := range e.ReceiptMessage.Timestamps {
+				ids = append(ids, strconv.FormatInt(ts, 10))
+				if ts > watermark {
+					watermark = ts
+				}
+			}
+			switch {
+			case e.ReceiptMessage.IsRead:
+				msg.Content = &ReadContent{Watermark: watermark}
+			case e.ReceiptMessage.IsDelivery:
+				msg.Content = &DeliveryContent{MessageIds: ids, Watermark: watermark}
+			default:
+				continue
+			}
+		default:
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return
+}
+
+// Poll receives the messages waiting in the gateway. It is a PollFunc for a
+// LongPoller, and the cursor is not used.
+func (s *SignalAmbassador) Poll(ctx context.Context, cursor string) (payload []byte, next string, err error) {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/v1/receive/%s?timeout=%d",
+		s.gateway, url.PathEscape(s.number), signalPollTimeout), nil)
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return nil, cursor, fmt.Errorf("fail to receive signal messages. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	payload, err = ioutil.ReadAll(resp.Body)
+	if bytes.Equal(bytes.TrimSpace(payload), []byte("[]")) {
+		payload = nil
+	}
+	return payload, cursor, err
+}
+
+func (s *SignalAmbassador) call(method, path string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest(method, s.gateway+path, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	setCorrelationHeader(req, s.correlation)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to call the signal gateway. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+func (s *SignalAmbassador) stage(m signalMessage) {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, m)
+}
+
+func (s *SignalAmbassador) SendText(text string) (err error) {
+	s.stage(signalMessage{Message: text})
+	return
+}
+
+// SendAttachment sends a file with an optional caption. The file is read as
+// a whole since the gateway takes attachments encoded in base64.
+func (s *SignalAmbassador) SendAttachment(r io.Reader, filename, mimeType, caption string) (err error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	attachment := fmt.Sprintf("data:%s;filename=%s;base64,%s", mimeType, filename, base64.StdEncoding.EncodeToString(b))
+	s.stage(signalMessage{Message: caption, Attachments: []string{attachment}})
+	return
+}
+
+// AskQuestion numbers the answers after the question.
+func (s *SignalAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	lines := []string{text}
+	payloads := []string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			payloads = append(payloads, payload)
+			lines = append(lines, fmt.Sprintf("%d. %s", len(payloads), title))
+		}
+	}
+	s.stage(signalMessage{Message: strings.Join(lines, "\n"), payloads: payloads})
+	return
+}
+
+// SendTemplate numbers the elements with their text and url. Replying the
+// number of an element chooses its first postback button.
+func (s *SignalAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	lines := []string{}
+	payloads := []string{}
+	for i, col := range colItems {
+		line := fmt.Sprintf("%d. %s", i+1, col.Title)
+		if col.Text != "" {
+			line += ": " + col.Text
+		}
+		link, payload := col.ItemUrl, ""
+		for _, btn := range col.Buttons {
+			switch {
+			case btn.Type == "url" && link == "":
+				link = btn.Data
+			case btn.Type == "postback" && payload == "":
+				payload = btn.Data
+			}
+		}
+		if link != "" {
+			line += " " + link
+		}
+		lines = append(lines, line)
+		payloads = append(payloads, payload)
+	}
+	s.stage(signalMessage{Message: strings.Join(lines, "\n"), payloads: payloads})
+	return
+}
+
+// SendTyping is a no-op since typing indicators are sent to a recipient. Use
+// WithTyping instead.
+func (s *SignalAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping shows the bot typing for a duration before the messages staged
+// after it are sent.
+func (s *SignalAmbassador) WithTyping(d time.Duration) (err error) {
+	s.stage(signalMessage{pause: d})
+	return
+}
+
+// MarkRead sends a read receipt of a message to its sender.
+func (s *SignalAmbassador) MarkRead(msg Message) (err error) {
+	return s.call("POST", "/v1/receipts/"+url.PathEscape(s.number), map[string]interface{}{
+		"receipt_type": "read",
+		"recipient":    msg.SenderId,
+		"timestamp":    msg.Timestamp,
+	})
+}
+
+func (s *SignalAmbassador) cleanMessage() {
+	s.Lock()
+	defer s.Unlock()
+	s.lastMessages = make([]interface{}, 0, len(s.messages))
+	for _, m := range s.messages {
+		if m.Message != "" || len(m.Attachments) > 0 {
+			s.lastMessages = append(s.lastMessages, m)
+		}
+	}
+	s.messages = nil
+}
+
+func (s *SignalAmbassador) GetLastSent() []interface{} {
+	return s.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (s *SignalAmbassador) Discard() {
+	s.Lock()
+	last := s.lastMessages
+	s.Unlock()
+	s.cleanMessage()
+	s.Lock()
+	s.lastMessages = last
+	s.Unlock()
+}
+
+// Send sends the staged messages to a number, a uuid or a "group." chat.
+func (s *SignalAmbassador) Send(recipientId string) (err error) {
+	defer s.cleanMessage()
+	typing := "/v1/typing-indicator/" + url.PathEscape(s.number)
+	var payloads []string
+	for _, m := range s.messages {
+		if m.Message == "" && len(m.Attachments) == 0 {
+			if err = s.call("PUT", typing, map[string]string{"recipient": recipientId}); err != nil {
+				return
+			}
+			time.Sleep(m.pause)
+			if err = s.call("DELETE", typing, map[string]string{"recipient": recipientId}); err != nil {
+				return
+			}
+			continue
+		}
+		err = s.call("POST", "/v2/send", map[string]interface{}{
+			"number":             s.number,
+			"recipients":         []string{recipientId},
+			"message":            m.Message,
+			"base64_attachments": m.Attachments,
+		})
+		if err != nil {
+			return
+		}
+		if m.payloads != nil {
+			payloads = m.payloads
+		}
+	}
+	if payloads != nil {
+		signalMenus.set(recipientId, payloads)
+	}
+	return
+}
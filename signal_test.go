@@ -0,0 +1,65 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestSignalConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	s := NewSignalAmbassador("http://signal:8080", "+15550000000", server.Client())
+
+	s.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	s.SendAttachment(strings.NewReader("png"), "size.png", "image/png", "Size chart")
+	if err := s.Send("+886900000000"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Path != "/v2/send" {
+		t.Fatalf("expect two messages, got %+v", requests)
+	}
+	var sent struct {
+		Number      string   `json:"number"`
+		Recipients  []string `json:"recipients"`
+		Message     string   `json:"message"`
+		Attachments []string `json:"base64_attachments"`
+	}
+	json.Unmarshal(requests[0].Body, &sent)
+	if sent.Message != "Which size?\n1. Small\n2. Large" || sent.Number != "+15550000000" || sent.Recipients[0] != "+886900000000" {
+		t.Errorf("unexpected question: %s", requests[0].Body)
+	}
+	json.Unmarshal(requests[1].Body, &sent)
+	if sent.Message != "Size chart" || len(sent.Attachments) != 1 || sent.Attachments[0] != "data:image/png;filename=size.png;base64,cG5n" {
+		t.Errorf("unexpected attachment: %s", requests[1].Body)
+	}
+
+	messages, err := s.Translate(strings.NewReader(`[
+		{"envelope":{"source":"+886900000000","timestamp":1700000000000,"dataMessage":{"message":"2"}},"account":"+15550000000"},
+		{"envelope":{"source":"+886900000000","timestamp":1700000001000,"receiptMessage":{"isDelivery":true,"timestamps":[1699999999000]}},"account":"+15550000000"},
+		{"envelope":{"source":"+886900000000","timestamp":1700000002000,"typingMessage":{"action":"STARTED"}},"account":"+15550000000"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expect typing to be skipped, got %+v", messages)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].MessageId != "1700000000000" {
+		t.Errorf("expect the number as its payload, got %+v", messages[0])
+	}
+	if delivery, ok := messages[1].Content.(*DeliveryContent); !ok || delivery.MessageIds[0] != "1699999999000" {
+		t.Errorf("unexpected receipt: %+v", messages[1])
+	}
+
+	messages, _ = s.Translate(strings.NewReader(`{"jsonrpc":"2.0","method":"receive","params":{"envelope":{
+		"sourceUuid":"u-1","timestamp":1700000003000,"dataMessage":{"message":"hi","groupInfo":{"groupId":"g1"}}},"account":"+15550000000"}}`))
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "hi" || messages[0].ChatId != "group.g1" || messages[0].SenderId != "u-1" {
+		t.Errorf("unexpected message of a notification: %+v", messages[0])
+	}
+}
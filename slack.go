@@ -0,0 +1,417 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SlackPostMessageURI = "https://slack.com/api/chat.postMessage"
+	SlackDeleteURI      = "https://slack.com/api/chat.delete"
+	SlackReactionsURI   = "https://slack.com/api/reactions.add"
+)
+
+const slackMaxActions = 25
+
+type SlackPayload struct {
+	Type      string      `json:"type"`
+	Challenge string      `json:"challenge"`
+	EventTime int64       `json:"event_time"`
+	Event     *SlackEvent `json:"event"`
+
+	// fields of interactive payloads
+	User      SlackUser      `json:"user"`
+	Channel   SlackChannel   `json:"channel"`
+	Container SlackContainer `json:"container"`
+	Actions   []SlackAction  `json:"actions"`
+}
+
+type SlackEvent struct {
+	Type     string `json:"type"`
+	SubType  string `json:"subtype"`
+	User     string `json:"user"`
+	BotId    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Channel  string `json:"channel"`
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts"`
+}
+
+type SlackUser struct {
+	Id string `json:"id"`
+}
+
+type SlackChannel struct {
+	Id string `json:"id"`
+}
+
+type SlackContainer struct {
+	MessageTs string `json:"message_ts"`
+	ThreadTs  string `json:"thread_ts"`
+}
+
+type SlackAction struct {
+	ActionId string `json:"action_id"`
+	Value    string `json:"value"`
+}
+
+// slackMessage is a staged message, or a pause of WithTyping if it has no
+// blocks.
+type slackMessage struct {
+	Text   string        `json:"text"`
+	Blocks []interface{} `json:"blocks"`
+	pause  time.Duration
+}
+
+// SlackAmbassador talks to Slack workspaces by the Events API and renders
+// messages as Block Kit blocks posted by chat.postMessage.
+type SlackAmbassador struct {
+	sync.Mutex
+	token        string
+	client       *http.Client
+	messages     []slackMessage
+	lastMessages []interface{}
+	threadTs     string
+	unfurl       *bool
+	correlation  string
+}
+
+func NewSlackAmbassador(token string, client *http.Client) *SlackAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SlackAmbassador{token: token, client: client}
+}
+
+func (s *SlackAmbassador) SetCorrelationId(id string) {
+	s.correlation = id
+}
+
+func (s *SlackAmbassador) Platform() string {
+	return "slack"
+}
+
+// readSlackPayload reads an Events API body, or the form encoded payload of
+// interactive components.
+func readSlackPayload(body []byte) (p SlackPayload, err error) {
+	if bytes.HasPrefix(body, []byte("payload=")) {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return p, err
+		}
+		body = []byte(values.Get("payload"))
+	}
+	err = jsonCodec.Unmarshal(body, &p)
+	return
+}
+
+// Challenge answers the url verification request of the Events API.
+func (s *SlackAmbassador) Challenge(body []byte) (response []byte, ok bool) {
+	p, err := readSlackPayload(body)
+	if err != nil || p.Type != "url_verification" {
+		return
+	}
+	return []byte(p.Challenge), true
+}
+
+// Translate turns an Events API callback or a block action into messages.
+// Messages of bots, including this one, are skipped.
+func (s *SlackAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := ioutil.ReadAll(limitReader(r))
+	if err != nil {
+		return
+	}
+	p, err := readSlackPayload(body)
+	if err != nil {
+		return
+	}
+
+	messages = []Message{}
+	switch p.Type {
+	case "event_callback":
+		e := p.Event
+		if e == nil || e.BotId != "" || e.User == "" {
+			return
+		}
+		switch e.Type {
+		case "message", "app_mention":
+			if e.SubType != "" {
+				return
+			}
+			messages = append(messages, Message{
+				SenderId:  e.User,
+				ChatId:    e.Channel,
+				MessageId: e.Ts,
+				InReplyTo: e.ThreadTs,
+				Timestamp: p.EventTime * 1000,
+				Content:   &TextContent{Text: e.Text},
+			})
+		}
+	case "block_actions":
+		if len(p.Actions) > MaxEventsPerPayload {
+			return nil, ErrTooManyEvents
+		}
+		for _, action := range p.Actions {
+			messages = append(messages, Message{
+				SenderId:  p.User.Id,
+				ChatId:    p.Channel.Id,
+				InReplyTo: p.Container.ThreadTs,
+				Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+				Content:   &CommandContent{Payload: action.Value},
+			})
+		}
+	}
+	return
+}
+
+func (s *SlackAmbassador) call(uri string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", uri, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	setCorrelationHeader(req, s.correlation)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	buffer := &bytes.Buffer{}
+	io.Copy(buffer, resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fail to call slack. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	var result struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	jsonCodec.Unmarshal(buffer.Bytes(), &result)
+	if !result.Ok && result.Error != "" {
+		return fmt.Errorf("fail to call slack: %s", result.Error)
+	}
+	return
+}
+
+func (s *SlackAmbassador) stage(text string, blocks ...interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, slackMessage{Text: text, Blocks: blocks})
+}
+
+func slackSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}
+}
+
+func slackButton(label, actionId, value, url string) map[string]interface{} {
+	button := map[string]interface{}{
+		"type":      "button",
+		"text":      map[string]string{"type": "plain_text", "text": label},
+		"action_id": actionId,
+	}
+	if value != "" {
+		button["value"] = value
+	}
+	if url != "" {
+		button["url"] = url
+	}
+	return button
+}
+
+func (s *SlackAmbassador) SendText(text string) (err error) {
+	s.stage(text, slackSection(text))
+	return
+}
+
+// AskQuestion renders answers as buttons whose values come back as
+// CommandContent.
+func (s *SlackAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	buttons := []interface{}{}
+	for i, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if len(buttons) == slackMaxActions {
+			break
+		}
+		buttons = append(buttons, slackButton(title, fmt.Sprintf("answer_%d", i), payload, ""))
+	}
+	s.stage(text, slackSection(text), map[string]interface{}{"type": "actions", "elements": buttons})
+	return
+}
+
+// SendTemplate renders every element as a section with its image and url
+// buttons, separated by dividers.
+func (s *SlackAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	blocks := []interface{}{}
+	titles := []string{}
+	for i, col := range colItems {
+		if i > 0 {
+			blocks = append(blocks, map[string]string{"type": "divider"})
+		}
+		titles = append(titles, col.Title)
+		section := slackSection(fmt.Sprintf("*%s*\n%s", col.Title, col.Text))
+		if col.ImageUrl != "" {
+			section["accessory"] = map[string]string{
+				"type":      "image",
+				"image_url": col.ImageUrl,
+				"alt_text":  col.Title,
+			}
+		}
+		blocks = append(blocks, section)
+
+		buttons := []interface{}{}
+		for j, btn := range col.Buttons {
+			if btn.Type == "url" {
+				buttons = append(buttons, slackButton(btn.Label, fmt.Sprintf("link_%d_%d", i, j), "", btn.Data))
+			}
+		}
+		if len(buttons) == 0 && col.ItemUrl != "" {
+			buttons = append(buttons, slackButton("Open", fmt.Sprintf("link_%d", i), "", col.ItemUrl))
+		}
+		if len(buttons) > 0 {
+			blocks = append(blocks, map[string]interface{}{"type": "actions", "elements": buttons})
+		}
+	}
+	s.stage(strings.Join(titles, ", "), blocks...)
+	return
+}
+
+// SendTyping is a no-op since bots can not show typing indicators in Slack.
+func (s *SlackAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (s *SlackAmbassador) WithTyping(d time.Duration) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, slackMessage{pause: d})
+	return
+}
+
+// MarkRead is a no-op since bots have no read receipts in Slack.
+func (s *SlackAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+// ReplyTo posts the staged messages in the thread of a message.
+func (s *SlackAmbassador) ReplyTo(messageId string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.threadTs = messageId
+	return
+}
+
+func (s *SlackAmbassador) SetLinkPreview(enabled bool) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.unfurl = &enabled
+	return
+}
+
+// splitSlackRef splits a message reference of "channel:ts".
+func splitSlackRef(ref string) (channel, ts string, err error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("a slack message is referred by channel:ts, got %s", ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+// DeleteMessage deletes a message of the bot referred by "channel:ts".
+func (s *SlackAmbassador) DeleteMessage(messageId string) (err error) {
+	channel, ts, err := splitSlackRef(messageId)
+	if err != nil {
+		return
+	}
+	return s.call(SlackDeleteURI, map[string]string{"channel": channel, "ts": ts})
+}
+
+// React adds a reaction, e.g. "thumbsup", to a message referred by
+// "channel:ts".
+func (s *SlackAmbassador) React(messageId, emoji string) (err error) {
+	channel, ts, err := splitSlackRef(messageId)
+	if err != nil {
+		return
+	}
+	return s.call(SlackReactionsURI, map[string]string{
+		"channel":   channel,
+		"timestamp": ts,
+		"name":      strings.Trim(emoji, ":"),
+	})
+}
+
+func (s *SlackAmbassador) cleanMessage() {
+	s.Lock()
+	defer s.Unlock()
+	s.lastMessages = make([]interface{}, 0, len(s.messages))
+	for _, m := range s.messages {
+		s.lastMessages = append(s.lastMessages, m)
+	}
+	s.messages = nil
+	s.threadTs = ""
+	s.unfurl = nil
+}
+
+func (s *SlackAmbassador) GetLastSent() []interface{} {
+	return s.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (s *SlackAmbassador) Discard() {
+	s.Lock()
+	last := s.lastMessages
+	s.Unlock()
+	s.cleanMessage()
+	s.Lock()
+	s.lastMessages = last
+	s.Unlock()
+}
+
+// Send posts the staged messages to a channel, or to the app home of a user
+// by the user id.
+func (s *SlackAmbassador) Send(recipientId string) (err error) {
+	defer s.cleanMessage()
+	for _, m := range s.messages {
+		if m.Blocks == nil {
+			time.Sleep(m.pause)
+			continue
+		}
+		payload := map[string]interface{}{
+			"channel": recipientId,
+			"text":    m.Text,
+			"blocks":  m.Blocks,
+		}
+		if s.threadTs != "" {
+			payload["thread_ts"] = s.threadTs
+		}
+		if s.unfurl != nil {
+			payload["unfurl_links"] = *s.unfurl
+			payload["unfurl_media"] = *s.unfurl
+		}
+		if err = s.call(SlackPostMessageURI, payload); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,73 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlackSignatureHeader carries "v0=" and the hex HMAC-SHA256 by the signing
+// secret of "v0:", the SlackTimestampHeader, ":" and the body of a request.
+const (
+	SlackSignatureHeader = "X-Slack-Signature"
+	SlackTimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// SlackMaxSignatureAge is how old a signed request may be, so that a
+// captured request can not be replayed later.
+const SlackMaxSignatureAge = 5 * time.Minute
+
+// SlackSignature is the value of SlackSignatureHeader of a body signed at a
+// timestamp in seconds.
+func SlackSignature(signingSecret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySlackSignature checks the SlackSignatureHeader of a body and that it
+// was signed within SlackMaxSignatureAge of now, and returns
+// ErrInvalidSignature otherwise.
+func VerifySlackSignature(signingSecret, signature, timestamp string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > SlackMaxSignatureAge || age < -SlackMaxSignatureAge {
+		return ErrInvalidSignature
+	}
+	if !strings.HasPrefix(signature, "v0=") ||
+		!hmac.Equal([]byte(signature), []byte(SlackSignature(signingSecret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifySlack rejects requests without a valid SlackSignatureHeader before
+// passing them to next, e.g. a Webhook, so that forged events and
+// interactions never reach Translate. The url verification of the Events API
+// is signed too, so it is answered by the webhook after the check.
+func VerifySlack(signingSecret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+		if err != nil {
+			http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		err = VerifySlackSignature(signingSecret, r.Header.Get(SlackSignatureHeader),
+			r.Header.Get(SlackTimestampHeader), body, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
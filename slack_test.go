@@ -0,0 +1,87 @@
+package ambassador
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlackChallenge(t *testing.T) {
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewSlackAmbassador("token", nil) },
+		Handler:       HandlerFunc(func(Ambassador, Message) error { return nil }),
+	}
+	w := httptest.NewRecorder()
+	wh.ServeHTTP(w, httptest.NewRequest("POST", "/slack",
+		strings.NewReader(`{"token":"x","challenge":"3eZbrw1aB","type":"url_verification"}`)))
+	if w.Body.String() != "3eZbrw1aB" {
+		t.Errorf("expect the challenge to be echoed, got %q", w.Body.String())
+	}
+}
+
+func TestVerifySlack(t *testing.T) {
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewSlackAmbassador("token", nil) },
+		Handler:       HandlerFunc(func(Ambassador, Message) error { return nil }),
+	}
+	handler := VerifySlack("signing-secret", wh)
+	body := `{"token":"x","challenge":"3eZbrw1aB","type":"url_verification"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	cases := []struct {
+		timestamp, signature string
+		status               int
+	}{
+		{now, SlackSignature("signing-secret", now, []byte(body)), http.StatusOK},
+		{now, SlackSignature("another-secret", now, []byte(body)), http.StatusUnauthorized},
+		{now, "", http.StatusUnauthorized},
+		{old, SlackSignature("signing-secret", old, []byte(body)), http.StatusUnauthorized},
+		{"", SlackSignature("signing-secret", "", []byte(body)), http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/slack", strings.NewReader(body))
+		req.Header.Set(SlackTimestampHeader, c.timestamp)
+		req.Header.Set(SlackSignatureHeader, c.signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("expect %d for %q signed at %q, got %d", c.status, c.signature, c.timestamp, w.Code)
+		}
+		if c.status == http.StatusOK && w.Body.String() != "3eZbrw1aB" {
+			t.Errorf("expect the signed challenge to be answered, got %q", w.Body.String())
+		}
+	}
+}
+
+func TestSlackTranslate(t *testing.T) {
+	s := NewSlackAmbassador("token", nil)
+	messages, err := s.Translate(strings.NewReader(`{"type":"event_callback","event_time":1500000000,
+		"event":{"type":"message","user":"U1","text":"hi","channel":"C1","ts":"1500000000.000100"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ReplyTarget() != "C1" || messages[0].Content.(*TextContent).Text != "hi" {
+		t.Errorf("unexpected messages: %+v", messages)
+	}
+
+	messages, _ = s.Translate(strings.NewReader(`{"type":"event_callback",
+		"event":{"type":"message","bot_id":"B1","text":"echo","channel":"C1"}}`))
+	if len(messages) != 0 {
+		t.Errorf("expect bot messages to be skipped, got %+v", messages)
+	}
+
+	form := "payload=" + url.QueryEscape(`{"type":"block_actions","user":{"id":"U1"},"channel":{"id":"C1"},
+		"actions":[{"action_id":"answer_0","value":"ANSWER_A"}]}`)
+	messages, err = s.Translate(strings.NewReader(form))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].Content.(*CommandContent).Payload != "ANSWER_A" {
+		t.Errorf("unexpected block actions: %+v", messages)
+	}
+}
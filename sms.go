@@ -0,0 +1,305 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TwilioBaseURI = "https://api.twilio.com/2010-04-01/Accounts/"
+
+// smsMaxLength is the longest body Twilio accepts in a message.
+const smsMaxLength = 1600
+
+// smsMenus keeps the payloads of the last numbered options sent to each
+// phone number, so that a number replied is translated into its payload.
+var smsMenus = &numberedMenus{menus: map[string][]string{}}
+
+type numberedMenus struct {
+	sync.Mutex
+	menus map[string][]string
+}
+
+func (m *numberedMenus) set(phone string, payloads []string) {
+	m.Lock()
+	defer m.Unlock()
+	if len(payloads) == 0 {
+		delete(m.menus, phone)
+		return
+	}
+	m.menus[phone] = payloads
+}
+
+func (m *numberedMenus) lookup(phone, text string) (payload string, ok bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), ".")))
+	if err != nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	payloads := m.menus[phone]
+	if n < 1 || n > len(payloads) || payloads[n-1] == "" {
+		return
+	}
+	return payloads[n-1], true
+}
+
+// smsMessage is a staged text with the payloads of its numbered options, or
+// a pause of WithTyping if it has no text.
+type smsMessage struct {
+	Body     string `json:"body"`
+	payloads []string
+	pause    time.Duration
+}
+
+// SMSAmbassador reaches users by SMS through Twilio. Questions and templates
+// degrade into numbered texts, and a number replied comes back as a
+// CommandContent of its payload.
+type SMSAmbassador struct {
+	sync.Mutex
+	accountSid   string
+	authToken    string
+	from         string
+	client       *http.Client
+	messages     []smsMessage
+	lastMessages []interface{}
+	correlation  string
+}
+
+// NewSMSAmbassador returns an ambassador sending from a Twilio number of an
+// account.
+func NewSMSAmbassador(accountSid, authToken, from string, client *http.Client) *SMSAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &SMSAmbassador{accountSid: accountSid, authToken: authToken, from: from, client: client}
+}
+
+func (s *SMSAmbassador) SetCorrelationId(id string) {
+	s.correlation = id
+}
+
+func (s *SMSAmbassador) Platform() string {
+	return "sms"
+}
+
+// Translate turns the form of an incoming message webhook into a message.
+func (s *SMSAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	body, err := ioutil.ReadAll(limitReader(r))
+	if err != nil {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return
+	}
+
+	messages = []Message{}
+	from := form.Get("From")
+	if from == "" {
+		return
+	}
+	msg := Message{
+		SenderId:    from,
+		RecipientId: form.Get("To"),
+		MessageId:   form.Get("MessageSid"),
+		Timestamp:   time.Now().UnixNano() / int64(time.Millisecond),
+		Content:     &TextContent{Text: form.Get("Body")},
+	}
+	if payload, ok := smsMenus.lookup(from, form.Get("Body")); ok {
+		msg.Content = &CommandContent{Payload: payload}
+	}
+	messages = append(messages, msg)
+	return
+}
+
+func (s *SMSAmbassador) stage(body string, payloads []string) {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, smsMessage{Body: body, payloads: payloads})
+}
+
+func (s *SMSAmbassador) SendText(text string) (err error) {
+	s.stage(text, nil)
+	return
+}
+
+// AskQuestion numbers the answers after the question.
+func (s *SMSAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	lines := []string{text}
+	payloads := []string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			payloads = append(payloads, payload)
+			lines = append(lines, fmt.Sprintf("%d. %s", len(payloads), title))
+		}
+	}
+	s.stage(strings.Join(lines, "\n"), payloads)
+	return
+}
+
+// SendTemplate numbers the elements with their text and url. Replying the
+// number of an element chooses its first postback button.
+func (s *SMSAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	lines := []string{}
+	payloads := []string{}
+	for i, col := range colItems {
+		line := fmt.Sprintf("%d. %s", i+1, col.Title)
+		if col.Text != "" {
+			line += ": " + col.Text
+		}
+		link, payload := col.ItemUrl, ""
+		for _, btn := range col.Buttons {
+			switch {
+			case btn.Type == "url" && link == "":
+				link = btn.Data
+			case btn.Type == "postback" && payload == "":
+				payload = btn.Data
+			}
+		}
+		if link != "" {
+			line += " " + link
+		}
+		lines = append(lines, line)
+		payloads = append(payloads, payload)
+	}
+	s.stage(strings.Join(lines, "\n"), payloads)
+	return
+}
+
+// SendTyping is a no-op since SMS has no typing indicator.
+func (s *SMSAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (s *SMSAmbassador) WithTyping(d time.Duration) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.messages = append(s.messages, smsMessage{pause: d})
+	return
+}
+
+// MarkRead is a no-op since SMS has no read receipts.
+func (s *SMSAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (s *SMSAmbassador) post(to, body string) (err error) {
+	form := url.Values{"To": {to}, "From": {s.from}, "Body": {body}}
+	req, _ := http.NewRequest("POST", TwilioBaseURI+s.accountSid+"/Messages.json", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.accountSid, s.authToken)
+	setCorrelationHeader(req, s.correlation)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to send a sms. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	return
+}
+
+// splitSMS splits a body into the longest messages Twilio accepts.
+func splitSMS(body string) (parts []string) {
+	runes := []rune(body)
+	for len(runes) > smsMaxLength {
+		parts = append(parts, string(runes[:smsMaxLength]))
+		runes = runes[smsMaxLength:]
+	}
+	return append(parts, string(runes))
+}
+
+func (s *SMSAmbassador) cleanMessage() {
+	s.Lock()
+	defer s.Unlock()
+	s.lastMessages = make([]interface{}, 0, len(s.messages))
+	for _, m := range s.messages {
+		if m.Body != "" {
+			s.lastMessages = append(s.lastMessages, m)
+		}
+	}
+	s.messages = nil
+}
+
+func (s *SMSAmbassador) GetLastSent() []interface{} {
+	return s.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (s *SMSAmbassador) Discard() {
+	s.Lock()
+	last := s.lastMessages
+	s.Unlock()
+	s.cleanMessage()
+	s.Lock()
+	s.lastMessages = last
+	s.Unlock()
+}
+
+// Send texts the staged messages to a phone number. The options of the last
+// numbered message replace the ones the number was given before.
+func (s *SMSAmbassador) Send(recipientId string) (err error) {
+	defer s.cleanMessage()
+	var payloads []string
+	for _, m := range s.messages {
+		if m.Body == "" {
+			time.Sleep(m.pause)
+			continue
+		}
+		for _, part := range splitSMS(m.Body) {
+			if err = s.post(recipientId, part); err != nil {
+				return
+			}
+		}
+		if m.payloads != nil {
+			payloads = m.payloads
+		}
+	}
+	if payloads != nil {
+		smsMenus.set(recipientId, payloads)
+	}
+	return
+}
+
+// TwilioSignature is the X-Twilio-Signature of a webhook request to a full
+// url with form parameters.
+func TwilioSignature(authToken, uri string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	data := uri
+	for _, k := range keys {
+		for _, v := range params[k] {
+			data += k + v
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package ambassador
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestSMSNumberedQuestion(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	s := NewSMSAmbassador("AC1", "secret", "+15550000000", server.Client())
+
+	s.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := s.Send("+886900000000"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/2010-04-01/Accounts/AC1/Messages.json" {
+		t.Fatalf("expect one message, got %+v", requests)
+	}
+	form, _ := url.ParseQuery(string(requests[0].Body))
+	if form.Get("Body") != "Which size?\n1. Small\n2. Large" || form.Get("From") != "+15550000000" {
+		t.Errorf("unexpected message: %v", form)
+	}
+
+	messages, err := s.Translate(strings.NewReader("From=%2B886900000000&To=%2B15550000000&Body=2&MessageSid=SM1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" {
+		t.Errorf("expect the number as its payload, got %+v", messages[0])
+	}
+	messages, _ = s.Translate(strings.NewReader("From=%2B886900000000&Body=3"))
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "3" {
+		t.Errorf("expect a number out of the options as a text, got %+v", messages[0])
+	}
+}
+
+func TestTwilioSignature(t *testing.T) {
+	// the example of the security documents of Twilio
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	signature := TwilioSignature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", params)
+	if signature != "0/KCTR6DLpKmkAf8muzZqo1nDgQ=" {
+		t.Errorf("unexpected signature: %s", signature)
+	}
+}
@@ -0,0 +1,47 @@
+package ambassador
+
+import (
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+// TestOutboundSnapshots pins the wire format of outbound payloads. Run
+// `go test -run TestOutboundSnapshots -update` to accept intended changes.
+func TestOutboundSnapshots(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+
+	build := Messages(
+		TextMessage("hello"),
+		QuestionMessage("pick one", []map[string]string{
+			{"content_type": "text", "title": "A", "payload": "ANSWER_A"},
+			{"content_type": "text", "title": "B", "payload": "ANSWER_B"},
+		}),
+		TemplateMessage([]Carousel{{
+			Title:    "item",
+			Text:     "an item",
+			ImageUrl: "https://example.com/item.png",
+			ItemUrl:  "https://example.com/item",
+			Buttons: []CarouselButton{
+				{Label: "open", Type: "url", Data: "https://example.com/item"},
+			},
+		}}),
+	)
+
+	ambassadors := map[string]Ambassador{
+		"facebook": NewFBAmbassador("test-token", server.Client()),
+		"line":     NewLineAmbassador("test-token", server.Client()),
+		"slack":    NewSlackAmbassador("test-token", server.Client()),
+	}
+	for _, platform := range []string{"facebook", "line", "slack"} {
+		a := ambassadors[platform]
+		if err := build(a); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Send("recipient"); err != nil {
+			t.Fatal(err)
+		}
+		server.GoldenRequests(t, "outbound_"+platform)
+	}
+}
@@ -0,0 +1,134 @@
+package ambassador
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Source delivers inbound messages to a handler until the context is done,
+// so that handlers do not depend on how events arrive: webhooks, long
+// polling or a queue.
+type Source interface {
+	Serve(ctx context.Context, h Handler) error
+}
+
+// Serve serves the sources concurrently with a handler. It returns when all
+// sources stop, with the error of the first source which stops. The other
+// sources are stopped when one stops.
+func Serve(ctx context.Context, h Handler, sources ...Source) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var wg sync.WaitGroup
+	for _, s := range sources {
+		wg.Add(1)
+		go func(s Source) {
+			defer wg.Done()
+			serveErr := s.Serve(ctx, h)
+			once.Do(func() {
+				err = serveErr
+				cancel()
+			})
+		}(s)
+	}
+	wg.Wait()
+	return
+}
+
+// handlePayload translates a payload and handles its messages one by one.
+// Errors are logged, and the last one is returned.
+func handlePayload(newAmbassador func() Ambassador, h Handler, payload []byte, reporter ErrorReporter, logger Logger) (err error) {
+	correlationId := newId()
+	messages, err := translate(withCorrelation(newAmbassador(), correlationId), payload, reporter)
+	if err != nil {
+		CorrelationLogger(logger, correlationId).Printf("ambassador: fail to translate a payload: %s", err)
+		return
+	}
+	receivedAt := time.Now()
+	for _, msg := range messages {
+		msg.CorrelationId = correlationId
+		msg.ReceivedAt = receivedAt
+		if handleErr := h.Handle(withCorrelation(newAmbassador(), correlationId), msg); handleErr != nil {
+			CorrelationLogger(logger, correlationId).Printf("ambassador: fail to handle a message: %s", handleErr)
+			err = handleErr
+		}
+	}
+	return
+}
+
+// WebhookSource serves a webhook over http.
+type WebhookSource struct {
+	Addr    string
+	Path    string
+	Webhook *Webhook
+	// ShutdownTimeout is how long requests in flight are waited for.
+	ShutdownTimeout time.Duration
+}
+
+func (s *WebhookSource) Serve(ctx context.Context, h Handler) error {
+	wh := *s.Webhook
+	wh.Handler = h
+	mux := http.NewServeMux()
+	mux.Handle(s.Path, &wh)
+	server := &http.Server{Addr: s.Addr, Handler: mux}
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.ListenAndServe() }()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	return ctx.Err()
+}
+
+// ReceiveFunc receives a webhook payload from a queue, e.g. pushed there by
+// a separate ingestion service. The payload is acknowledged by ack with the
+// result of handling it.
+type ReceiveFunc func(ctx context.Context) (payload []byte, ack func(err error), err error)
+
+// QueueSource consumes webhook payloads from a queue.
+type QueueSource struct {
+	Receive       ReceiveFunc
+	NewAmbassador func() Ambassador
+	// ErrorDelay is how long to wait before receiving again after a
+	// failure.
+	ErrorDelay time.Duration
+	Reporter   ErrorReporter
+	Logger     Logger
+}
+
+func NewQueueSource(receive ReceiveFunc, newAmbassador func() Ambassador) *QueueSource {
+	return &QueueSource{
+		Receive:       receive,
+		NewAmbassador: newAmbassador,
+		ErrorDelay:    defaultPollErrorDelay,
+		Logger:        stdLogger{},
+	}
+}
+
+func (s *QueueSource) Serve(ctx context.Context, h Handler) error {
+	for {
+		payload, ack, err := s.Receive(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			s.Logger.Printf("ambassador: fail to receive from the queue: %s", err)
+			if err = sleep(ctx, s.ErrorDelay); err != nil {
+				return err
+			}
+			continue
+		}
+		err = handlePayload(s.NewAmbassador, h, payload, s.Reporter, s.Logger)
+		if ack != nil {
+			ack(err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package ambassador
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQueueSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	queue := make(chan []byte, 2)
+	queue <- []byte(`{"object":"page","entry":[{"messaging":[{"sender":{"id":"u1"},"timestamp":1500000000000,"message":{"mid":"m1","text":"hi"}}]}]}`)
+	queue <- []byte(`not json`)
+	acks := []error{}
+	receive := func(ctx context.Context) ([]byte, func(error), error) {
+		select {
+		case payload := <-queue:
+			return payload, func(err error) {
+				acks = append(acks, err)
+				if len(acks) == 2 {
+					cancel()
+				}
+			}, nil
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	texts := []string{}
+	source := NewQueueSource(receive, func() Ambassador { return NewFBAmbassador("test-token", nil) })
+	source.Logger = testLogger{t}
+	err := Serve(ctx, HandlerFunc(func(a Ambassador, msg Message) error {
+		texts = append(texts, msg.Content.(*TextContent).Text)
+		return nil
+	}), source)
+	if err != context.Canceled {
+		t.Fatalf("expect the source to stop by the context, got %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "hi" {
+		t.Errorf("unexpected messages: %v", texts)
+	}
+	if len(acks) != 2 || acks[0] != nil || acks[1] == nil {
+		t.Errorf("expect the malformed payload to be nacked, got %v", acks)
+	}
+}
@@ -0,0 +1,509 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// DynamoDBCaller calls an operation of DynamoDB, e.g. "PutItem", with the
+// JSON input and output of its HTTP API.
+type DynamoDBCaller interface {
+	Call(ctx context.Context, operation string, input, output interface{}) error
+}
+
+// DynamoDBError is an error returned by DynamoDB.
+type DynamoDBError struct {
+	StatusCode int
+	// Type is the short type of the error, e.g.
+	// "ConditionalCheckFailedException".
+	Type    string
+	Message string
+}
+
+func (e *DynamoDBError) Error() string {
+	return fmt.Sprintf("dynamodb %s: %s", e.Type, e.Message)
+}
+
+// DynamoDB calls the HTTP API of DynamoDB with requests signed by Signature
+// Version 4.
+type DynamoDB struct {
+	region          string
+	accessKeyId     string
+	secretAccessKey string
+	// SessionToken is the token of temporary credentials.
+	SessionToken string
+	// Endpoint defaults to the endpoint of the region.
+	Endpoint string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewDynamoDB(region, accessKeyId, secretAccessKey string, client *http.Client) *DynamoDB {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &DynamoDB{
+		region:          region,
+		accessKeyId:     accessKeyId,
+		secretAccessKey: secretAccessKey,
+		Endpoint:        "https://dynamodb." + region + ".amazonaws.com/",
+		client:          client,
+		now:             time.Now,
+	}
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// signV4 signs a request with its headers and body by Signature Version 4.
+func signV4(req *http.Request, body []byte, region, service, accessKeyId, secretAccessKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyId+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func (d *DynamoDB) Call(ctx context.Context, operation string, input, output interface{}) (err error) {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", d.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "DynamoDB_20120810."+operation)
+	if d.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", d.SessionToken)
+	}
+	signV4(req, body, d.region, "dynamodb", d.accessKeyId, d.secretAccessKey, d.now())
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		json.Unmarshal(buffer.Bytes(), &e)
+		if e.Message == "" {
+			e.Message = buffer.String()
+		}
+		return &DynamoDBError{StatusCode: resp.StatusCode, Type: e.Type[strings.LastIndex(e.Type, "#")+1:], Message: e.Message}
+	}
+	if output != nil {
+		return json.NewDecoder(resp.Body).Decode(output)
+	}
+	return
+}
+
+// attribute is an attribute value of DynamoDB.
+type attribute map[string]string
+
+func str(s string) attribute {
+	return attribute{"S": s}
+}
+
+func num(n int64) attribute {
+	return attribute{"N": strconv.FormatInt(n, 10)}
+}
+
+type item map[string]attribute
+
+func (i item) int(name string) int64 {
+	n, _ := strconv.ParseInt(i[name]["N"], 10, 64)
+	return n
+}
+
+func isConditionFailed(err error) bool {
+	e, ok := err.(*DynamoDBError)
+	return ok && e.Type == "ConditionalCheckFailedException"
+}
+
+// query returns all items of a query, following its pages.
+func query(db DynamoDBCaller, input map[string]interface{}) (items []item, err error) {
+	for {
+		var output struct {
+			Items            []item `json:"Items"`
+			LastEvaluatedKey item   `json:"LastEvaluatedKey"`
+		}
+		if err = db.Call(context.Background(), "Query", input, &output); err != nil {
+			return
+		}
+		items = append(items, output.Items...)
+		if output.LastEvaluatedKey == nil {
+			return
+		}
+		if limit, ok := input["Limit"].(int); ok && len(items) >= limit {
+			return
+		}
+		input["ExclusiveStartKey"] = output.LastEvaluatedKey
+	}
+}
+
+// The DynamoDB stores share a table whose partition key is a string "pk"
+// and sort key is a string "sk".
+
+// DynamoDBOutboxStore keeps envelopes under one partition sorted by their
+// send times, and a pointer item per envelope to find them by id. Due
+// leases the items it returns by conditional updates, so that replicas
+// flushing at once never get the same envelopes.
+type DynamoDBOutboxStore struct {
+	db    DynamoDBCaller
+	table string
+	// Lease is how long the envelopes returned by Due are held from other
+	// replicas. They are due again after it unless they are put or deleted,
+	// e.g. when the replica delivering them dies.
+	Lease time.Duration
+}
+
+func NewDynamoDBOutboxStore(db DynamoDBCaller, table string) *DynamoDBOutboxStore {
+	return &DynamoDBOutboxStore{db: db, table: table, Lease: DefaultLease}
+}
+
+func dueKey(at time.Time, id string) string {
+	ms := millis(at)
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%020d#%s", ms, id)
+}
+
+func (s *DynamoDBOutboxStore) pointer(id string) (sk string, ok bool, err error) {
+	var output struct {
+		Item item `json:"Item"`
+	}
+	err = s.db.Call(context.Background(), "GetItem", map[string]interface{}{
+		"TableName":      s.table,
+		"Key":            item{"pk": str("outbox#" + id), "sk": str("outbox")},
+		"ConsistentRead": true,
+	}, &output)
+	if err != nil || output.Item == nil {
+		return
+	}
+	return output.Item["due"]["S"], true, nil
+}
+
+func (s *DynamoDBOutboxStore) Put(e *ambassador.Envelope) (err error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	sk := dueKey(e.SendAt, e.Id)
+	old, ok, err := s.pointer(e.Id)
+	if err != nil {
+		return
+	}
+	if ok && old != sk {
+		err = s.db.Call(ctx, "DeleteItem", map[string]interface{}{
+			"TableName": s.table,
+			"Key":       item{"pk": str("outbox"), "sk": str(old)},
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+	err = s.db.Call(ctx, "PutItem", map[string]interface{}{
+		"TableName": s.table,
+		"Item":      item{"pk": str("outbox"), "sk": str(sk), "envelope": str(string(b))},
+	}, nil)
+	if err != nil {
+		return
+	}
+	return s.db.Call(ctx, "PutItem", map[string]interface{}{
+		"TableName": s.table,
+		"Item":      item{"pk": str("outbox#" + e.Id), "sk": str("outbox"), "due": str(sk)},
+	}, nil)
+}
+
+func (s *DynamoDBOutboxStore) Due(now time.Time, limit int) (due []*ambassador.Envelope, err error) {
+	input := map[string]interface{}{
+		"TableName":              s.table,
+		"KeyConditionExpression": "pk = :pk AND sk < :until",
+		"FilterExpression":       "attribute_not_exists(lease_until) OR lease_until <= :now",
+		"ExpressionAttributeValues": item{
+			":pk": str("outbox"),
+			// "$" sorts right after the "#" of the keys at now
+			":until": str(fmt.Sprintf("%020d$", millis(now))),
+			":now":   num(millis(now)),
+		},
+		"ConsistentRead": true,
+	}
+	if limit > 0 {
+		input["Limit"] = limit
+	}
+	items, err := query(s.db, input)
+	if err != nil {
+		return
+	}
+	// pages of a query may add up to more items than the limit, which are
+	// left unclaimed for the next flush
+	lease := now.Add(s.Lease)
+	claimed := make([]item, 0, len(items))
+	due = make([]*ambassador.Envelope, 0, len(items))
+	for _, i := range items {
+		if limit > 0 && len(due) == limit {
+			break
+		}
+		claimErr := s.db.Call(context.Background(), "UpdateItem", map[string]interface{}{
+			"TableName":           s.table,
+			"Key":                 item{"pk": i["pk"], "sk": i["sk"]},
+			"UpdateExpression":    "SET lease_until = :lease",
+			"ConditionExpression": "attribute_exists(sk) AND (attribute_not_exists(lease_until) OR lease_until <= :now)",
+			"ExpressionAttributeValues": item{
+				":lease": num(millis(lease)),
+				":now":   num(millis(now)),
+			},
+		}, nil)
+		// claimed by another replica, or deleted
+		if isConditionFailed(claimErr) {
+			continue
+		}
+		if claimErr != nil {
+			s.release(claimed, lease)
+			return nil, claimErr
+		}
+		claimed = append(claimed, i)
+		e := &ambassador.Envelope{}
+		if err = json.Unmarshal([]byte(i["envelope"]["S"]), e); err != nil {
+			s.release(claimed, lease)
+			return nil, err
+		}
+		due = append(due, e)
+	}
+	return
+}
+
+// release gives up the leases of items claimed by a Due which fails, so
+// that they are due again at once instead of after the lease. An item whose
+// release fails is due again once its lease ends.
+func (s *DynamoDBOutboxStore) release(items []item, lease time.Time) {
+	for _, i := range items {
+		s.db.Call(context.Background(), "UpdateItem", map[string]interface{}{
+			"TableName":                 s.table,
+			"Key":                       item{"pk": i["pk"], "sk": i["sk"]},
+			"UpdateExpression":          "REMOVE lease_until",
+			"ConditionExpression":       "lease_until = :lease",
+			"ExpressionAttributeValues": item{":lease": num(millis(lease))},
+		}, nil)
+	}
+}
+
+func (s *DynamoDBOutboxStore) Delete(id string) (err error) {
+	ctx := context.Background()
+	sk, ok, err := s.pointer(id)
+	if err != nil || !ok {
+		return
+	}
+	err = s.db.Call(ctx, "DeleteItem", map[string]interface{}{
+		"TableName": s.table,
+		"Key":       item{"pk": str("outbox"), "sk": str(sk)},
+	}, nil)
+	if err != nil {
+		return
+	}
+	return s.db.Call(ctx, "DeleteItem", map[string]interface{}{
+		"TableName": s.table,
+		"Key":       item{"pk": str("outbox#" + id), "sk": str("outbox")},
+	}, nil)
+}
+
+// DynamoDBSubscriptionStore keeps an item per subscription under the
+// partition of the topic, and another under the partition of the user.
+type DynamoDBSubscriptionStore struct {
+	db    DynamoDBCaller
+	table string
+}
+
+func NewDynamoDBSubscriptionStore(db DynamoDBCaller, table string) *DynamoDBSubscriptionStore {
+	return &DynamoDBSubscriptionStore{db: db, table: table}
+}
+
+func (s *DynamoDBSubscriptionStore) Subscribe(topic, userId string) (err error) {
+	ctx := context.Background()
+	err = s.db.Call(ctx, "PutItem", map[string]interface{}{
+		"TableName": s.table,
+		"Item":      item{"pk": str("topic#" + topic), "sk": str("user#" + userId)},
+	}, nil)
+	if err != nil {
+		return
+	}
+	return s.db.Call(ctx, "PutItem", map[string]interface{}{
+		"TableName": s.table,
+		"Item":      item{"pk": str("user#" + userId), "sk": str("topic#" + topic)},
+	}, nil)
+}
+
+func (s *DynamoDBSubscriptionStore) Unsubscribe(topic, userId string) (err error) {
+	ctx := context.Background()
+	err = s.db.Call(ctx, "DeleteItem", map[string]interface{}{
+		"TableName": s.table,
+		"Key":       item{"pk": str("topic#" + topic), "sk": str("user#" + userId)},
+	}, nil)
+	if err != nil {
+		return
+	}
+	return s.db.Call(ctx, "DeleteItem", map[string]interface{}{
+		"TableName": s.table,
+		"Key":       item{"pk": str("user#" + userId), "sk": str("topic#" + topic)},
+	}, nil)
+}
+
+func (s *DynamoDBSubscriptionStore) list(pk, prefix string) (values []string, err error) {
+	items, err := query(s.db, map[string]interface{}{
+		"TableName":                 s.table,
+		"KeyConditionExpression":    "pk = :pk AND begins_with(sk, :prefix)",
+		"ExpressionAttributeValues": item{":pk": str(pk), ":prefix": str(prefix)},
+	})
+	if err != nil {
+		return
+	}
+	values = make([]string, 0, len(items))
+	for _, i := range items {
+		values = append(values, strings.TrimPrefix(i["sk"]["S"], prefix))
+	}
+	return
+}
+
+func (s *DynamoDBSubscriptionStore) Subscribers(topic string) ([]string, error) {
+	return s.list("topic#"+topic, "user#")
+}
+
+func (s *DynamoDBSubscriptionStore) Topics(userId string) ([]string, error) {
+	return s.list("user#"+userId, "topic#")
+}
+
+type DynamoDBInteractionStore struct {
+	db    DynamoDBCaller
+	table string
+}
+
+func NewDynamoDBInteractionStore(db DynamoDBCaller, table string) *DynamoDBInteractionStore {
+	return &DynamoDBInteractionStore{db: db, table: table}
+}
+
+func (s *DynamoDBInteractionStore) key(platform, userId string) item {
+	return item{"pk": str("interaction#" + platform + ":" + userId), "sk": str("interaction")}
+}
+
+func (s *DynamoDBInteractionStore) Touch(platform, userId string, at time.Time) (err error) {
+	err = s.db.Call(context.Background(), "UpdateItem", map[string]interface{}{
+		"TableName":                 s.table,
+		"Key":                       s.key(platform, userId),
+		"UpdateExpression":          "SET #at = :at",
+		"ConditionExpression":       "attribute_not_exists(#at) OR #at < :at",
+		"ExpressionAttributeNames":  map[string]string{"#at": "at"},
+		"ExpressionAttributeValues": item{":at": num(millis(at))},
+	}, nil)
+	if isConditionFailed(err) {
+		return nil
+	}
+	return
+}
+
+func (s *DynamoDBInteractionStore) LastInbound(platform, userId string) (at time.Time, ok bool, err error) {
+	var output struct {
+		Item item `json:"Item"`
+	}
+	err = s.db.Call(context.Background(), "GetItem", map[string]interface{}{
+		"TableName": s.table,
+		"Key":       s.key(platform, userId),
+	}, &output)
+	if err != nil || output.Item == nil {
+		return
+	}
+	return time.Unix(0, output.Item.int("at")*int64(time.Millisecond)), true, nil
+}
+
+type DynamoDBQuotaStore struct {
+	db    DynamoDBCaller
+	table string
+}
+
+func NewDynamoDBQuotaStore(db DynamoDBCaller, table string) *DynamoDBQuotaStore {
+	return &DynamoDBQuotaStore{db: db, table: table}
+}
+
+func (s *DynamoDBQuotaStore) Incr(key string, n int64) (value int64, err error) {
+	var output struct {
+		Attributes item `json:"Attributes"`
+	}
+	err = s.db.Call(context.Background(), "UpdateItem", map[string]interface{}{
+		"TableName":                 s.table,
+		"Key":                       item{"pk": str("quota#" + key), "sk": str("quota")},
+		"UpdateExpression":          "ADD #v :n",
+		"ExpressionAttributeNames":  map[string]string{"#v": "value"},
+		"ExpressionAttributeValues": item{":n": num(n)},
+		"ReturnValues":              "UPDATED_NEW",
+	}, &output)
+	if err != nil {
+		return
+	}
+	return output.Attributes.int("value"), nil
+}
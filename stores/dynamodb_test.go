@@ -0,0 +1,254 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla of the Signature Version 4 test suite
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	signV4(req, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != expected {
+		t.Errorf("unexpected authorization: %s", auth)
+	}
+}
+
+func TestDynamoDBCall(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	db := NewDynamoDB("ap-northeast-1", "AKID", "secret", server.Client())
+
+	server.Fail("dynamodb", testutil.Sequence(&testutil.Failure{
+		Status: 400,
+		Body:   `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"}`,
+	}))
+	err := db.Call(context.Background(), "PutItem", map[string]interface{}{"TableName": "t"}, nil)
+	if !isConditionFailed(err) {
+		t.Errorf("expect a conditional check failure, got %v", err)
+	}
+	r := server.Requests()[0]
+	if r.Host != "dynamodb.ap-northeast-1.amazonaws.com" || r.Header.Get("X-Amz-Target") != "DynamoDB_20120810.PutItem" ||
+		!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		t.Errorf("unexpected request: %+v", r)
+	}
+}
+
+// fakeDynamoDB runs the operations of the stores on a table in memory.
+type fakeDynamoDB struct {
+	sync.Mutex
+	items map[string]item
+	// page splits queries into pages of a size regardless of their limits,
+	// like the pages of DynamoDB when its filters drop items.
+	page int
+	// failLease fails the nth lease from one with an internal error.
+	failLease int
+	leases    int
+}
+
+func (d *fakeDynamoDB) Call(ctx context.Context, operation string, input, output interface{}) error {
+	d.Lock()
+	defer d.Unlock()
+	b, _ := json.Marshal(input)
+	var in struct {
+		Key                       item
+		Item                      item
+		KeyConditionExpression    string
+		ExpressionAttributeValues item
+		UpdateExpression          string
+		FilterExpression          string
+		Limit                     int
+		ExclusiveStartKey         item
+	}
+	json.Unmarshal(b, &in)
+	result := map[string]interface{}{}
+	switch operation {
+	case "PutItem":
+		d.items[in.Item["pk"]["S"]+"|"+in.Item["sk"]["S"]] = in.Item
+	case "GetItem":
+		if i, ok := d.items[in.Key["pk"]["S"]+"|"+in.Key["sk"]["S"]]; ok {
+			result["Item"] = i
+		}
+	case "DeleteItem":
+		delete(d.items, in.Key["pk"]["S"]+"|"+in.Key["sk"]["S"])
+	case "Query":
+		values := in.ExpressionAttributeValues
+		items := []item{}
+		for _, i := range d.items {
+			if i["pk"]["S"] != values[":pk"]["S"] {
+				continue
+			}
+			if until, ok := values[":until"]; ok && i["sk"]["S"] >= until["S"] {
+				continue
+			}
+			if prefix, ok := values[":prefix"]; ok && !strings.HasPrefix(i["sk"]["S"], prefix["S"]) {
+				continue
+			}
+			if _, ok := i["lease_until"]; ok && in.FilterExpression != "" && i.int("lease_until") > values.int(":now") {
+				continue
+			}
+			items = append(items, i)
+		}
+		sort.Slice(items, func(a, b int) bool { return items[a]["sk"]["S"] < items[b]["sk"]["S"] })
+		if d.page > 0 {
+			for len(items) > 0 && in.ExclusiveStartKey != nil && items[0]["sk"]["S"] <= in.ExclusiveStartKey["sk"]["S"] {
+				items = items[1:]
+			}
+			if len(items) > d.page {
+				items = items[:d.page]
+				result["LastEvaluatedKey"] = item{"pk": items[d.page-1]["pk"], "sk": items[d.page-1]["sk"]}
+			}
+		} else if in.Limit > 0 && len(items) > in.Limit {
+			items = items[:in.Limit]
+		}
+		result["Items"] = items
+	case "UpdateItem":
+		key := in.Key["pk"]["S"] + "|" + in.Key["sk"]["S"]
+		i, ok := d.items[key]
+		if strings.HasPrefix(in.UpdateExpression, "SET lease_until") {
+			if d.leases++; d.leases == d.failLease {
+				return &DynamoDBError{StatusCode: 500, Type: "InternalServerError"}
+			}
+			values := item(in.ExpressionAttributeValues)
+			if _, leased := i["lease_until"]; !ok || leased && i.int("lease_until") > values.int(":now") {
+				return &DynamoDBError{StatusCode: 400, Type: "ConditionalCheckFailedException"}
+			}
+			i["lease_until"] = values[":lease"]
+			return nil
+		}
+		if in.UpdateExpression == "REMOVE lease_until" {
+			if ok && i.int("lease_until") == item(in.ExpressionAttributeValues).int(":lease") {
+				delete(i, "lease_until")
+			}
+			return nil
+		}
+		if !ok {
+			i = item{"pk": in.Key["pk"], "sk": in.Key["sk"]}
+		}
+		if strings.HasPrefix(in.UpdateExpression, "ADD") {
+			i["value"] = num(i.int("value") + item(in.ExpressionAttributeValues).int(":n"))
+			result["Attributes"] = item{"value": i["value"]}
+		} else {
+			at := item(in.ExpressionAttributeValues).int(":at")
+			if _, ok := i["at"]; ok && i.int("at") >= at {
+				return &DynamoDBError{StatusCode: 400, Type: "ConditionalCheckFailedException"}
+			}
+			i["at"] = num(at)
+		}
+		d.items[key] = i
+	}
+	if output != nil {
+		b, _ := json.Marshal(result)
+		return json.Unmarshal(b, output)
+	}
+	return nil
+}
+
+func TestDynamoDBOutboxStore(t *testing.T) {
+	var s ambassador.OutboxStore = NewDynamoDBOutboxStore(&fakeDynamoDB{items: map[string]item{}}, "ambassador")
+	now := time.Unix(1700000000, 0)
+	s.Put(&ambassador.Envelope{Id: "e2", SendAt: now.Add(-time.Minute)})
+	s.Put(&ambassador.Envelope{Id: "e1", SendAt: now.Add(-time.Hour), Messages: []ambassador.OutboundMessage{ambassador.TextMessage("hi")}})
+	s.Put(&ambassador.Envelope{Id: "e3", SendAt: now})
+	// a retry moves an envelope later
+	s.Put(&ambassador.Envelope{Id: "e3", SendAt: now.Add(time.Minute), Attempts: 1})
+
+	due, err := s.Due(now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Id != "e1" || due[0].Messages[0].Text != "hi" || due[1].Id != "e2" {
+		t.Errorf("unexpected due envelopes: %+v", due)
+	}
+	if due, _ := s.Due(now, 10); len(due) != 0 {
+		t.Errorf("expect leased envelopes not to be due again, got %+v", due)
+	}
+	s.Delete("e1")
+	if due, _ := s.Due(now.Add(time.Hour), 10); len(due) != 2 || due[0].Id != "e2" || due[1].Attempts != 1 {
+		t.Errorf("unexpected due envelopes after a delete: %+v", due)
+	}
+}
+
+func TestDynamoDBOutboxStoreLimit(t *testing.T) {
+	db := &fakeDynamoDB{items: map[string]item{}, page: 2}
+	s := NewDynamoDBOutboxStore(db, "ambassador")
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		s.Put(&ambassador.Envelope{Id: fmt.Sprintf("e%d", i), SendAt: now.Add(time.Duration(i-10) * time.Second)})
+	}
+
+	// two pages of two items exceed a limit of three
+	due, err := s.Due(now, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 3 || due[2].Id != "e2" {
+		t.Fatalf("unexpected due envelopes: %+v", due)
+	}
+	if due, _ := s.Due(now, 3); len(due) != 2 || due[0].Id != "e3" {
+		t.Errorf("expect the envelopes beyond the limit not to be leased, got %+v", due)
+	}
+
+	db.failLease = db.leases + 2
+	later := now.Add(2 * DefaultLease)
+	if _, err := s.Due(later, 3); err == nil {
+		t.Fatal("expect a failed lease to fail")
+	}
+	if due, _ := s.Due(later, 10); len(due) != 5 {
+		t.Errorf("expect the envelopes claimed before a failure to be released, got %d", len(due))
+	}
+}
+
+func TestDynamoDBOutboxStoreReplicas(t *testing.T) {
+	db := &fakeDynamoDB{items: map[string]item{}}
+	now := time.Unix(1700000000, 0)
+	setup := NewDynamoDBOutboxStore(db, "ambassador")
+	for i := 0; i < 100; i++ {
+		setup.Put(&ambassador.Envelope{Id: fmt.Sprintf("e%d", i), SendAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+	testReplicas(t, 100, func() ambassador.OutboxStore { return NewDynamoDBOutboxStore(db, "ambassador") }, now)
+}
+
+func TestDynamoDBStores(t *testing.T) {
+	db := &fakeDynamoDB{items: map[string]item{}}
+	var subscriptions ambassador.SubscriptionStore = NewDynamoDBSubscriptionStore(db, "ambassador")
+	subscriptions.Subscribe("news", "u2")
+	subscriptions.Subscribe("news", "u1")
+	subscriptions.Subscribe("deals", "u1")
+	subscriptions.Unsubscribe("news", "u2")
+	if users, _ := subscriptions.Subscribers("news"); len(users) != 1 || users[0] != "u1" {
+		t.Errorf("unexpected subscribers: %v", users)
+	}
+	if topics, _ := subscriptions.Topics("u1"); len(topics) != 2 || topics[0] != "deals" {
+		t.Errorf("unexpected topics: %v", topics)
+	}
+
+	var interactions ambassador.InteractionStore = NewDynamoDBInteractionStore(db, "ambassador")
+	at := time.Unix(1700000000, 0)
+	interactions.Touch("facebook", "u1", at)
+	if err := interactions.Touch("facebook", "u1", at.Add(-time.Hour)); err != nil {
+		t.Errorf("expect an older touch to be ignored, got %v", err)
+	}
+	if last, ok, err := interactions.LastInbound("facebook", "u1"); err != nil || !ok || !last.Equal(at) {
+		t.Errorf("expect the latest inbound time, got %s, %v, %v", last, ok, err)
+	}
+
+	var quota ambassador.QuotaStore = NewDynamoDBQuotaStore(db, "ambassador")
+	quota.Incr("t1:2023-11", 2)
+	if n, err := quota.Incr("t1:2023-11", 3); err != nil || n != 5 {
+		t.Errorf("expect the counter to add up, got %d, %v", n, err)
+	}
+}
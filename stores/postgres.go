@@ -0,0 +1,201 @@
+package stores
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// PostgresSchema creates the tables of the Postgres stores.
+const PostgresSchema = `
+CREATE TABLE IF NOT EXISTS ambassador_outbox (
+	id          TEXT PRIMARY KEY,
+	send_at     TIMESTAMPTZ NOT NULL,
+	envelope    JSONB NOT NULL,
+	lease_until TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS ambassador_outbox_send_at ON ambassador_outbox (send_at);
+CREATE TABLE IF NOT EXISTS ambassador_subscriptions (
+	topic   TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	PRIMARY KEY (topic, user_id)
+);
+CREATE INDEX IF NOT EXISTS ambassador_subscriptions_user_id ON ambassador_subscriptions (user_id);
+CREATE TABLE IF NOT EXISTS ambassador_interactions (
+	platform     TEXT NOT NULL,
+	user_id      TEXT NOT NULL,
+	last_inbound TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (platform, user_id)
+);
+CREATE TABLE IF NOT EXISTS ambassador_quota (
+	key   TEXT PRIMARY KEY,
+	value BIGINT NOT NULL
+);
+`
+
+// MigratePostgres creates the tables of the Postgres stores if they do not
+// exist. The statements are run one by one since not every driver runs many
+// in one call.
+func MigratePostgres(db *sql.DB) (err error) {
+	for _, stmt := range strings.Split(PostgresSchema, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		if _, err = db.Exec(stmt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// PostgresOutboxStore keeps envelopes as JSON. Due leases the rows it
+// returns, skipping the rows locked by other replicas, so that replicas
+// flushing at once never get the same envelopes.
+type PostgresOutboxStore struct {
+	db *sql.DB
+	// Lease is how long the envelopes returned by Due are held from other
+	// replicas. They are due again after it unless they are put or deleted,
+	// e.g. when the replica delivering them dies.
+	Lease time.Duration
+}
+
+func NewPostgresOutboxStore(db *sql.DB) *PostgresOutboxStore {
+	return &PostgresOutboxStore{db: db, Lease: DefaultLease}
+}
+
+func (s *PostgresOutboxStore) Put(e *ambassador.Envelope) (err error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, err = s.db.Exec(`INSERT INTO ambassador_outbox (id, send_at, envelope) VALUES ($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET send_at = EXCLUDED.send_at, envelope = EXCLUDED.envelope, lease_until = NULL`, e.Id, e.SendAt, string(b))
+	return
+}
+
+func (s *PostgresOutboxStore) Due(now time.Time, limit int) (due []*ambassador.Envelope, err error) {
+	query := `UPDATE ambassador_outbox SET lease_until = $2 WHERE id IN (
+SELECT id FROM ambassador_outbox WHERE send_at <= $1 AND (lease_until IS NULL OR lease_until <= $1)
+ORDER BY send_at`
+	args := []interface{}{now, now.Add(s.Lease)}
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+	query += ` FOR UPDATE SKIP LOCKED) RETURNING envelope`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	due = []*ambassador.Envelope{}
+	for rows.Next() {
+		var b []byte
+		if err = rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		e := &ambassador.Envelope{}
+		if err = json.Unmarshal(b, e); err != nil {
+			return nil, err
+		}
+		due = append(due, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(due, func(i, j int) bool { return due[i].SendAt.Before(due[j].SendAt) })
+	return
+}
+
+func (s *PostgresOutboxStore) Delete(id string) (err error) {
+	_, err = s.db.Exec(`DELETE FROM ambassador_outbox WHERE id = $1`, id)
+	return
+}
+
+type PostgresSubscriptionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSubscriptionStore(db *sql.DB) *PostgresSubscriptionStore {
+	return &PostgresSubscriptionStore{db: db}
+}
+
+func (s *PostgresSubscriptionStore) Subscribe(topic, userId string) (err error) {
+	_, err = s.db.Exec(`INSERT INTO ambassador_subscriptions (topic, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, topic, userId)
+	return
+}
+
+func (s *PostgresSubscriptionStore) Unsubscribe(topic, userId string) (err error) {
+	_, err = s.db.Exec(`DELETE FROM ambassador_subscriptions WHERE topic = $1 AND user_id = $2`, topic, userId)
+	return
+}
+
+func (s *PostgresSubscriptionStore) column(query, arg string) (values []string, err error) {
+	rows, err := s.db.Query(query, arg)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	values = []string{}
+	for rows.Next() {
+		var v string
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+func (s *PostgresSubscriptionStore) Subscribers(topic string) ([]string, error) {
+	return s.column(`SELECT user_id FROM ambassador_subscriptions WHERE topic = $1 ORDER BY user_id`, topic)
+}
+
+func (s *PostgresSubscriptionStore) Topics(userId string) ([]string, error) {
+	return s.column(`SELECT topic FROM ambassador_subscriptions WHERE user_id = $1 ORDER BY topic`, userId)
+}
+
+type PostgresInteractionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresInteractionStore(db *sql.DB) *PostgresInteractionStore {
+	return &PostgresInteractionStore{db: db}
+}
+
+func (s *PostgresInteractionStore) Touch(platform, userId string, at time.Time) (err error) {
+	_, err = s.db.Exec(`INSERT INTO ambassador_interactions (platform, user_id, last_inbound) VALUES ($1, $2, $3)
+ON CONFLICT (platform, user_id) DO UPDATE SET last_inbound = GREATEST(ambassador_interactions.last_inbound, EXCLUDED.last_inbound)`,
+		platform, userId, at)
+	return
+}
+
+func (s *PostgresInteractionStore) LastInbound(platform, userId string) (at time.Time, ok bool, err error) {
+	err = s.db.QueryRow(`SELECT last_inbound FROM ambassador_interactions WHERE platform = $1 AND user_id = $2`,
+		platform, userId).Scan(&at)
+	if err == sql.ErrNoRows {
+		return at, false, nil
+	}
+	return at, err == nil, err
+}
+
+type PostgresQuotaStore struct {
+	db *sql.DB
+}
+
+func NewPostgresQuotaStore(db *sql.DB) *PostgresQuotaStore {
+	return &PostgresQuotaStore{db: db}
+}
+
+func (s *PostgresQuotaStore) Incr(key string, n int64) (value int64, err error) {
+	err = s.db.QueryRow(`INSERT INTO ambassador_quota (key, value) VALUES ($1, $2)
+ON CONFLICT (key) DO UPDATE SET value = ambassador_quota.value + EXCLUDED.value RETURNING value`, key, n).Scan(&value)
+	return
+}
@@ -0,0 +1,208 @@
+package stores
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// fakePostgres runs the statements of the Postgres stores in memory, by
+// the tables they start with.
+type fakePostgres struct {
+	sync.Mutex
+	statements   []string
+	outbox       map[string]*fakeOutboxRow
+	interactions map[string]time.Time
+	quota        map[string]int64
+}
+
+func (d *fakePostgres) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d}, nil
+}
+
+type fakeOutboxRow struct {
+	sendAt     time.Time
+	envelope   string
+	leaseUntil time.Time
+}
+
+type fakeConn struct {
+	db *fakePostgres
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c.db, query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("transactions are not supported")
+}
+
+type fakeStmt struct {
+	db    *fakePostgres
+	query string
+}
+
+func (s *fakeStmt) Close() error { return nil }
+
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, err := s.Query(args)
+	return driver.RowsAffected(1), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.Lock()
+	defer db.Unlock()
+	db.statements = append(db.statements, s.query)
+	rows := &fakeRows{}
+	switch {
+	case strings.HasPrefix(s.query, "CREATE"):
+	case strings.HasPrefix(s.query, "INSERT INTO ambassador_outbox"):
+		db.outbox[args[0].(string)] = &fakeOutboxRow{sendAt: args[1].(time.Time), envelope: args[2].(string)}
+	case strings.HasPrefix(s.query, "UPDATE ambassador_outbox SET lease_until"):
+		now := args[0].(time.Time)
+		ids := []string{}
+		for id, row := range db.outbox {
+			if !row.sendAt.After(now) && !row.leaseUntil.After(now) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return db.outbox[ids[i]].sendAt.Before(db.outbox[ids[j]].sendAt) })
+		if len(args) == 3 && len(ids) > int(args[2].(int64)) {
+			ids = ids[:args[2].(int64)]
+		}
+		// RETURNING in no particular order
+		sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+		for _, id := range ids {
+			db.outbox[id].leaseUntil = args[1].(time.Time)
+			rows.values = append(rows.values, []driver.Value{[]byte(db.outbox[id].envelope)})
+		}
+	case strings.HasPrefix(s.query, "DELETE FROM ambassador_outbox"):
+		delete(db.outbox, args[0].(string))
+	case strings.HasPrefix(s.query, "INSERT INTO ambassador_interactions"):
+		key := args[0].(string) + ":" + args[1].(string)
+		if at := args[2].(time.Time); at.After(db.interactions[key]) {
+			db.interactions[key] = at
+		}
+	case strings.HasPrefix(s.query, "SELECT last_inbound FROM ambassador_interactions"):
+		if at, ok := db.interactions[args[0].(string)+":"+args[1].(string)]; ok {
+			rows.values = append(rows.values, []driver.Value{at})
+		}
+	case strings.HasPrefix(s.query, "INSERT INTO ambassador_quota"):
+		db.quota[args[0].(string)] += args[1].(int64)
+		rows.values = append(rows.values, []driver.Value{db.quota[args[0].(string)]})
+	default:
+		return nil, fmt.Errorf("unexpected statement: %s", s.query)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newFakePostgres(t *testing.T) (*sql.DB, *fakePostgres) {
+	d := &fakePostgres{
+		outbox:       map[string]*fakeOutboxRow{},
+		interactions: map[string]time.Time{},
+		quota:        map[string]int64{},
+	}
+	name := "fakepg-" + t.Name()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+func TestPostgresOutboxStore(t *testing.T) {
+	db, fake := newFakePostgres(t)
+	if err := MigratePostgres(db); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.statements) != 6 {
+		t.Errorf("expect the schema to run statement by statement, got %d", len(fake.statements))
+	}
+
+	var s ambassador.OutboxStore = NewPostgresOutboxStore(db)
+	now := time.Unix(1700000000, 0)
+	s.Put(&ambassador.Envelope{Id: "e2", SendAt: now.Add(-time.Minute)})
+	s.Put(&ambassador.Envelope{Id: "e1", SendAt: now.Add(-time.Hour), Messages: []ambassador.OutboundMessage{ambassador.TextMessage("hi")}})
+	s.Put(&ambassador.Envelope{Id: "e3", SendAt: now.Add(time.Minute)})
+	due, err := s.Due(now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Id != "e1" || due[0].Messages[0].Text != "hi" {
+		t.Errorf("unexpected due envelopes: %+v", due)
+	}
+	if due, _ := s.Due(now, 10); len(due) != 0 {
+		t.Errorf("expect leased envelopes not to be due again, got %+v", due)
+	}
+	s.Delete("e1")
+	s.Put(&ambassador.Envelope{Id: "e2", SendAt: now.Add(-time.Second), Attempts: 1})
+	if due, _ := s.Due(now, 1); len(due) != 1 || due[0].Id != "e2" || due[0].Attempts != 1 {
+		t.Errorf("expect a put envelope to be released, got %+v", due)
+	}
+	if due, _ := s.Due(now.Add(DefaultLease+time.Minute), 10); len(due) != 2 || due[0].Id != "e2" || due[1].Id != "e3" {
+		t.Errorf("expect envelopes to be due again after their leases, got %+v", due)
+	}
+}
+
+func TestPostgresOutboxStoreReplicas(t *testing.T) {
+	db, _ := newFakePostgres(t)
+	now := time.Unix(1700000000, 0)
+	setup := NewPostgresOutboxStore(db)
+	for i := 0; i < 100; i++ {
+		setup.Put(&ambassador.Envelope{Id: fmt.Sprintf("e%d", i), SendAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+	testReplicas(t, 100, func() ambassador.OutboxStore { return NewPostgresOutboxStore(db) }, now)
+}
+
+func TestPostgresStores(t *testing.T) {
+	db, _ := newFakePostgres(t)
+
+	var interactions ambassador.InteractionStore = NewPostgresInteractionStore(db)
+	at := time.Unix(1700000000, 0)
+	interactions.Touch("facebook", "u1", at)
+	interactions.Touch("facebook", "u1", at.Add(-time.Hour))
+	if last, ok, err := interactions.LastInbound("facebook", "u1"); err != nil || !ok || !last.Equal(at) {
+		t.Errorf("expect the latest inbound time, got %s, %v, %v", last, ok, err)
+	}
+	if _, ok, err := interactions.LastInbound("facebook", "u2"); ok || err != nil {
+		t.Errorf("expect no inbound time of an unknown user, got %v", err)
+	}
+
+	var quota ambassador.QuotaStore = NewPostgresQuotaStore(db)
+	quota.Incr("t1:2023-11", 2)
+	if n, err := quota.Incr("t1:2023-11", 3); err != nil || n != 5 {
+		t.Errorf("expect the counter to add up, got %d, %v", n, err)
+	}
+}
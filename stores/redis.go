@@ -0,0 +1,296 @@
+// Package stores implements the stores of the ambassador package on Redis,
+// Postgres and DynamoDB, so that outboxes, subscriptions, messaging windows
+// and quotas can be shared by replicas. The outbox stores lease the
+// envelopes they return as due, so that each envelope is delivered by one
+// replica.
+//
+// The package does not depend on any client library. Redis is reached by a
+// thin wrapper of any redis client, Postgres by database/sql with a driver
+// of choice, and DynamoDB by its HTTP API.
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// RedisDoer runs a redis command. It is satisfied by a thin wrapper around
+// any redis client, which returns a nil reply as nil, e.g. with go-redis:
+//
+//	func (c wrapper) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+//		v, err := c.Client.Do(ctx, args...).Result()
+//		if err == redis.Nil {
+//			return nil, nil
+//		}
+//		return v, err
+//	}
+type RedisDoer interface {
+	Do(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+func redisStrings(v interface{}) (s []string, err error) {
+	if v == nil {
+		return
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected redis reply: %v", v)
+	}
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			s = append(s, item)
+		case []byte:
+			s = append(s, string(item))
+		case nil:
+			s = append(s, "")
+		default:
+			return nil, fmt.Errorf("unexpected redis reply: %v", item)
+		}
+	}
+	return
+}
+
+func redisInt(v interface{}) (n int64, err error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected redis reply: %v", v)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// DefaultLease is how long an outbox store claims the envelopes returned by
+// Due for the replica which is delivering them.
+const DefaultLease = 5 * time.Minute
+
+// redisClaimScript returns the due envelopes and moves them to the leased
+// set until the lease ends, after putting the envelopes whose leases ended
+// back. KEYS are the due and leased sets, and ARGV are now, the limit and
+// the end of the lease.
+const redisClaimScript = `
+local expired = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, id in ipairs(expired) do
+	redis.call('ZREM', KEYS[2], id)
+	redis.call('ZADD', KEYS[1], ARGV[1], id)
+end
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('ZADD', KEYS[2], ARGV[3], id)
+end
+return ids
+`
+
+// RedisOutboxStore keeps envelopes in a hash and their send times in a
+// sorted set. Due claims the envelopes it returns by a script, so that
+// replicas flushing at once never get the same envelopes.
+type RedisOutboxStore struct {
+	client RedisDoer
+	prefix string
+	// Lease is how long the envelopes returned by Due are held from other
+	// replicas. They are due again after it unless they are put or deleted,
+	// e.g. when the replica delivering them dies.
+	Lease time.Duration
+}
+
+// NewRedisOutboxStore keeps envelopes under keys starting with a prefix,
+// e.g. "ambassador:outbox".
+func NewRedisOutboxStore(client RedisDoer, prefix string) *RedisOutboxStore {
+	return &RedisOutboxStore{client: client, prefix: prefix, Lease: DefaultLease}
+}
+
+func (s *RedisOutboxStore) Put(e *ambassador.Envelope) (err error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	if _, err = s.client.Do(ctx, "HSET", s.prefix+":envelopes", e.Id, string(b)); err != nil {
+		return
+	}
+	if _, err = s.client.Do(ctx, "ZADD", s.prefix+":due", millis(e.SendAt), e.Id); err != nil {
+		return
+	}
+	_, err = s.client.Do(ctx, "ZREM", s.prefix+":leased", e.Id)
+	return
+}
+
+func (s *RedisOutboxStore) Due(now time.Time, limit int) (due []*ambassador.Envelope, err error) {
+	ctx := context.Background()
+	if limit <= 0 {
+		limit = -1
+	}
+	v, err := s.client.Do(ctx, "EVAL", redisClaimScript, 2, s.prefix+":due", s.prefix+":leased",
+		millis(now), limit, millis(now.Add(s.Lease)))
+	if err != nil {
+		return
+	}
+	ids, err := redisStrings(v)
+	if err != nil || len(ids) == 0 {
+		return
+	}
+	args := []interface{}{"HMGET", s.prefix + ":envelopes"}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if v, err = s.client.Do(ctx, args...); err != nil {
+		return
+	}
+	values, err := redisStrings(v)
+	if err != nil {
+		return
+	}
+	due = make([]*ambassador.Envelope, 0, len(values))
+	for _, value := range values {
+		// an envelope deleted between the two commands
+		if value == "" {
+			continue
+		}
+		e := &ambassador.Envelope{}
+		if err = json.Unmarshal([]byte(value), e); err != nil {
+			return nil, err
+		}
+		due = append(due, e)
+	}
+	return
+}
+
+func (s *RedisOutboxStore) Delete(id string) (err error) {
+	ctx := context.Background()
+	if _, err = s.client.Do(ctx, "ZREM", s.prefix+":due", id); err != nil {
+		return
+	}
+	if _, err = s.client.Do(ctx, "ZREM", s.prefix+":leased", id); err != nil {
+		return
+	}
+	_, err = s.client.Do(ctx, "HDEL", s.prefix+":envelopes", id)
+	return
+}
+
+// RedisSubscriptionStore keeps a set of users per topic and a set of topics
+// per user.
+type RedisSubscriptionStore struct {
+	client RedisDoer
+	prefix string
+}
+
+func NewRedisSubscriptionStore(client RedisDoer, prefix string) *RedisSubscriptionStore {
+	return &RedisSubscriptionStore{client: client, prefix: prefix}
+}
+
+func (s *RedisSubscriptionStore) Subscribe(topic, userId string) (err error) {
+	ctx := context.Background()
+	if _, err = s.client.Do(ctx, "SADD", s.prefix+":topic:"+topic, userId); err != nil {
+		return
+	}
+	_, err = s.client.Do(ctx, "SADD", s.prefix+":user:"+userId, topic)
+	return
+}
+
+func (s *RedisSubscriptionStore) Unsubscribe(topic, userId string) (err error) {
+	ctx := context.Background()
+	if _, err = s.client.Do(ctx, "SREM", s.prefix+":topic:"+topic, userId); err != nil {
+		return
+	}
+	_, err = s.client.Do(ctx, "SREM", s.prefix+":user:"+userId, topic)
+	return
+}
+
+func (s *RedisSubscriptionStore) members(key string) (members []string, err error) {
+	v, err := s.client.Do(context.Background(), "SMEMBERS", key)
+	if err != nil {
+		return
+	}
+	if members, err = redisStrings(v); err != nil {
+		return
+	}
+	if members == nil {
+		members = []string{}
+	}
+	sort.Strings(members)
+	return
+}
+
+func (s *RedisSubscriptionStore) Subscribers(topic string) ([]string, error) {
+	return s.members(s.prefix + ":topic:" + topic)
+}
+
+func (s *RedisSubscriptionStore) Topics(userId string) ([]string, error) {
+	return s.members(s.prefix + ":user:" + userId)
+}
+
+// RedisInteractionStore keeps the last inbound times of users in a sorted
+// set. It needs redis 6.2 or later for ZADD GT.
+type RedisInteractionStore struct {
+	client RedisDoer
+	key    string
+}
+
+func NewRedisInteractionStore(client RedisDoer, key string) *RedisInteractionStore {
+	return &RedisInteractionStore{client: client, key: key}
+}
+
+func (s *RedisInteractionStore) Touch(platform, userId string, at time.Time) (err error) {
+	_, err = s.client.Do(context.Background(), "ZADD", s.key, "GT", millis(at), platform+":"+userId)
+	return
+}
+
+func (s *RedisInteractionStore) LastInbound(platform, userId string) (at time.Time, ok bool, err error) {
+	v, err := s.client.Do(context.Background(), "ZSCORE", s.key, platform+":"+userId)
+	if err != nil || v == nil {
+		return
+	}
+	var ms float64
+	switch v := v.(type) {
+	case float64:
+		ms = v
+	case string:
+		if ms, err = strconv.ParseFloat(v, 64); err != nil {
+			return
+		}
+	default:
+		return at, false, fmt.Errorf("unexpected redis reply: %v", v)
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), true, nil
+}
+
+// RedisQuotaStore keeps counters as redis integers.
+type RedisQuotaStore struct {
+	client RedisDoer
+	prefix string
+	// TTL expires a counter after it is created, e.g. a bit over a month
+	// for the monthly counters of quotas. Counters never expire if it is
+	// zero.
+	TTL time.Duration
+}
+
+func NewRedisQuotaStore(client RedisDoer, prefix string) *RedisQuotaStore {
+	return &RedisQuotaStore{client: client, prefix: prefix}
+}
+
+func (s *RedisQuotaStore) Incr(key string, n int64) (value int64, err error) {
+	ctx := context.Background()
+	v, err := s.client.Do(ctx, "INCRBY", s.prefix+":"+key, n)
+	if err != nil {
+		return
+	}
+	if value, err = redisInt(v); err != nil {
+		return
+	}
+	if s.TTL > 0 && value == n {
+		_, err = s.client.Do(ctx, "PEXPIRE", s.prefix+":"+key, int64(s.TTL/time.Millisecond))
+	}
+	return
+}
@@ -0,0 +1,359 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador"
+)
+
+// fakeRedis runs the commands used by the stores in memory.
+type fakeRedis struct {
+	sync.Mutex
+	hashes  map[string]map[string]string
+	zsets   map[string]map[string]float64
+	sets    map[string]map[string]bool
+	ints    map[string]int64
+	expires map[string]int64
+	// now is the time of the TIME command, which is the current time if it
+	// is zero.
+	now time.Time
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		hashes:  map[string]map[string]string{},
+		zsets:   map[string]map[string]float64{},
+		sets:    map[string]map[string]bool{},
+		ints:    map[string]int64{},
+		expires: map[string]int64{},
+	}
+}
+
+func (r *fakeRedis) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	r.Lock()
+	defer r.Unlock()
+	s := make([]string, len(args))
+	for i, arg := range args {
+		s[i] = fmt.Sprint(arg)
+	}
+	return r.do(s...)
+}
+
+func (r *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s := []interface{}{"EVAL", script, len(keys)}
+	for _, key := range keys {
+		s = append(s, key)
+	}
+	return r.Do(ctx, append(s, args...)...)
+}
+
+// eval runs the known scripts by the commands they call, atomically like
+// redis does.
+func (r *fakeRedis) eval(script string, keys, argv []string) (interface{}, error) {
+	switch script {
+	case redisClaimScript:
+		v, _ := r.do("ZRANGEBYSCORE", keys[1], "-inf", argv[0])
+		for _, id := range v.([]interface{}) {
+			r.do("ZREM", keys[1], id.(string))
+			r.do("ZADD", keys[0], argv[0], id.(string))
+		}
+		v, _ = r.do("ZRANGEBYSCORE", keys[0], "-inf", argv[0], "LIMIT", "0", argv[1])
+		for _, id := range v.([]interface{}) {
+			r.do("ZREM", keys[0], id.(string))
+			r.do("ZADD", keys[1], argv[2], id.(string))
+		}
+		return v, nil
+	}
+	// The token bucket script of ambassador.RedisLimiter is not exported,
+	// so it is told by the clock it reads.
+	if strings.Contains(script, "redis.call('TIME')") {
+		rate, _ := strconv.ParseFloat(argv[0], 64)
+		burst, _ := strconv.ParseFloat(argv[1], 64)
+		t, _ := r.do("TIME")
+		sec, _ := strconv.ParseInt(t.([]interface{})[0].(string), 10, 64)
+		usec, _ := strconv.ParseInt(t.([]interface{})[1].(string), 10, 64)
+		now := float64(sec*1000 + usec/1000)
+		v, _ := r.do("HMGET", keys[0], "tokens", "ts")
+		state := v.([]interface{})
+		tokens, ts := burst, now
+		if state[0] != nil {
+			tokens, _ = strconv.ParseFloat(state[0].(string), 64)
+		}
+		if state[1] != nil {
+			ts, _ = strconv.ParseFloat(state[1].(string), 64)
+		}
+		tokens = math.Min(burst, tokens+(now-ts)*rate/1000)
+		var wait int64
+		if tokens >= 1 {
+			tokens--
+		} else {
+			wait = int64(math.Ceil((1 - tokens) * 1000 / rate))
+		}
+		r.do("HSET", keys[0], "tokens", strconv.FormatFloat(tokens, 'f', -1, 64))
+		r.do("HSET", keys[0], "ts", strconv.FormatFloat(now, 'f', -1, 64))
+		r.do("PEXPIRE", keys[0], strconv.FormatInt(int64(math.Ceil(burst*1000/rate))+1000, 10))
+		return wait, nil
+	}
+	return nil, fmt.Errorf("unknown script")
+}
+
+func (r *fakeRedis) do(s ...string) (interface{}, error) {
+	var key string
+	if len(s) > 1 {
+		key = s[1]
+	}
+	switch s[0] {
+	case "EVAL":
+		n, _ := strconv.Atoi(s[2])
+		return r.eval(s[1], s[3:3+n], s[3+n:])
+	case "HSET":
+		if r.hashes[key] == nil {
+			r.hashes[key] = map[string]string{}
+		}
+		r.hashes[key][s[2]] = s[3]
+		return int64(1), nil
+	case "HMGET":
+		values := []interface{}{}
+		for _, field := range s[2:] {
+			if v, ok := r.hashes[key][field]; ok {
+				values = append(values, v)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values, nil
+	case "HDEL":
+		delete(r.hashes[key], s[2])
+		return int64(1), nil
+	case "ZADD":
+		if r.zsets[key] == nil {
+			r.zsets[key] = map[string]float64{}
+		}
+		gt := s[2] == "GT"
+		if gt {
+			s = append(s[:2], s[3:]...)
+		}
+		score, _ := strconv.ParseFloat(s[2], 64)
+		if old, ok := r.zsets[key][s[3]]; !gt || !ok || score > old {
+			r.zsets[key][s[3]] = score
+		}
+		return int64(1), nil
+	case "ZRANGEBYSCORE":
+		max, _ := strconv.ParseFloat(s[3], 64)
+		members := []string{}
+		for member, score := range r.zsets[key] {
+			if score <= max {
+				members = append(members, member)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return r.zsets[key][members[i]] < r.zsets[key][members[j]] })
+		if len(s) == 7 {
+			if limit, _ := strconv.Atoi(s[6]); limit >= 0 && len(members) > limit {
+				members = members[:limit]
+			}
+		}
+		values := []interface{}{}
+		for _, member := range members {
+			values = append(values, member)
+		}
+		return values, nil
+	case "ZREM":
+		delete(r.zsets[key], s[2])
+		return int64(1), nil
+	case "ZSCORE":
+		score, ok := r.zsets[key][s[2]]
+		if !ok {
+			return nil, nil
+		}
+		return strconv.FormatFloat(score, 'f', -1, 64), nil
+	case "SADD", "SREM":
+		if r.sets[key] == nil {
+			r.sets[key] = map[string]bool{}
+		}
+		if s[0] == "SADD" {
+			r.sets[key][s[2]] = true
+		} else {
+			delete(r.sets[key], s[2])
+		}
+		return int64(1), nil
+	case "SMEMBERS":
+		values := []interface{}{}
+		for member := range r.sets[key] {
+			values = append(values, member)
+		}
+		return values, nil
+	case "INCRBY":
+		n, _ := strconv.ParseInt(s[2], 10, 64)
+		r.ints[key] += n
+		return r.ints[key], nil
+	case "TIME":
+		now := r.now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		return []interface{}{strconv.FormatInt(now.Unix(), 10), strconv.Itoa(now.Nanosecond() / 1000)}, nil
+	case "PEXPIRE":
+		r.expires[key], _ = strconv.ParseInt(s[2], 10, 64)
+		return int64(1), nil
+	}
+	return nil, fmt.Errorf("unknown command %s", s[0])
+}
+
+func TestRedisOutboxStore(t *testing.T) {
+	var s ambassador.OutboxStore = NewRedisOutboxStore(newFakeRedis(), "test:outbox")
+	now := time.Unix(1700000000, 0)
+	s.Put(&ambassador.Envelope{Id: "e2", RecipientId: "u2", SendAt: now.Add(-time.Minute)})
+	s.Put(&ambassador.Envelope{Id: "e1", RecipientId: "u1", SendAt: now.Add(-time.Hour), Messages: []ambassador.OutboundMessage{ambassador.TextMessage("hi")}})
+	s.Put(&ambassador.Envelope{Id: "e3", RecipientId: "u3", SendAt: now.Add(time.Minute)})
+
+	due, err := s.Due(now, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Id != "e1" || due[0].Messages[0].Text != "hi" || due[1].Id != "e2" {
+		t.Errorf("unexpected due envelopes: %+v", due)
+	}
+	if due, _ := s.Due(now, 10); len(due) != 0 {
+		t.Errorf("expect claimed envelopes not to be due again, got %+v", due)
+	}
+
+	// e1 fails and is put back for a retry, e2 is held until its lease ends
+	later := now.Add(DefaultLease + time.Second)
+	s.Put(&ambassador.Envelope{Id: "e1", RecipientId: "u1", SendAt: now.Add(30 * time.Second), Attempts: 1})
+	if due, _ := s.Due(now.Add(2*time.Minute), 1); len(due) != 1 || due[0].Id != "e1" || due[0].Attempts != 1 {
+		t.Errorf("expect a retried envelope to be due, got %+v", due)
+	}
+	s.Delete("e1")
+	if due, _ := s.Due(later, 10); len(due) != 2 || due[0].Id != "e3" || due[1].Id != "e2" {
+		t.Errorf("expect an envelope to be due again after its lease, got %+v", due)
+	}
+}
+
+func TestRedisOutboxStoreReplicas(t *testing.T) {
+	client := newFakeRedis()
+	now := time.Unix(1700000000, 0)
+	setup := NewRedisOutboxStore(client, "test:outbox")
+	for i := 0; i < 100; i++ {
+		setup.Put(&ambassador.Envelope{Id: fmt.Sprintf("e%d", i), SendAt: now.Add(-time.Duration(i) * time.Second)})
+	}
+	testReplicas(t, 100, func() ambassador.OutboxStore { return NewRedisOutboxStore(client, "test:outbox") }, now)
+}
+
+// testReplicas flushes stores of replicas at once, and checks that every
+// envelope is returned once.
+func testReplicas(t *testing.T, n int, store func() ambassador.OutboxStore, now time.Time) {
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(s ambassador.OutboxStore) {
+			defer wg.Done()
+			for {
+				due, err := s.Due(now, 7)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(due) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, e := range due {
+					seen[e.Id]++
+				}
+				mu.Unlock()
+			}
+		}(store())
+	}
+	wg.Wait()
+	if len(seen) != n {
+		t.Errorf("expect %d envelopes, got %d", n, len(seen))
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("expect %s to be returned once, got %d", id, count)
+		}
+	}
+}
+
+func TestRedisStores(t *testing.T) {
+	client := newFakeRedis()
+	var subscriptions ambassador.SubscriptionStore = NewRedisSubscriptionStore(client, "test:sub")
+	subscriptions.Subscribe("news", "u2")
+	subscriptions.Subscribe("news", "u1")
+	subscriptions.Subscribe("deals", "u1")
+	subscriptions.Unsubscribe("news", "u2")
+	if users, _ := subscriptions.Subscribers("news"); len(users) != 1 || users[0] != "u1" {
+		t.Errorf("unexpected subscribers: %v", users)
+	}
+	if topics, _ := subscriptions.Topics("u1"); len(topics) != 2 || topics[0] != "deals" {
+		t.Errorf("unexpected topics: %v", topics)
+	}
+
+	var interactions ambassador.InteractionStore = NewRedisInteractionStore(client, "test:interactions")
+	at := time.Unix(1700000000, 0)
+	interactions.Touch("facebook", "u1", at)
+	interactions.Touch("facebook", "u1", at.Add(-time.Hour))
+	if last, ok, err := interactions.LastInbound("facebook", "u1"); err != nil || !ok || !last.Equal(at) {
+		t.Errorf("expect the latest inbound time, got %s, %v, %v", last, ok, err)
+	}
+	if _, ok, _ := interactions.LastInbound("facebook", "u2"); ok {
+		t.Error("expect no inbound time of an unknown user")
+	}
+
+	quota := NewRedisQuotaStore(client, "test:quota")
+	quota.TTL = time.Hour
+	quota.Incr("t1:2023-11", 2)
+	if n, _ := quota.Incr("t1:2023-11", 3); n != 5 {
+		t.Errorf("expect the counter to add up, got %d", n)
+	}
+	if client.expires["test:quota:t1:2023-11"] != int64(time.Hour/time.Millisecond) {
+		t.Errorf("expect a new counter to expire, got %v", client.expires)
+	}
+}
+
+func TestRedisLimiter(t *testing.T) {
+	client := newFakeRedis()
+	client.now = time.Unix(1700000000, 0)
+	// two replicas share a bucket of 2 tokens refilled once per second
+	replicas := []*ambassador.RedisLimiter{
+		ambassador.NewRedisLimiter(client, "test:limit", 1, 2),
+		ambassador.NewRedisLimiter(client, "test:limit", 1, 2),
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		elapsed time.Duration
+		replica int
+		allowed bool
+	}{
+		{0, 0, true},
+		{0, 1, true},
+		{0, 0, false},
+		{500 * time.Millisecond, 1, false},
+		{500 * time.Millisecond, 1, true},
+		{10 * time.Second, 0, true},
+		{0, 1, true},
+		{0, 0, false},
+	}
+	for i, c := range cases {
+		client.now = client.now.Add(c.elapsed)
+		err := replicas[c.replica].Wait(cancelled)
+		if allowed := err == nil; allowed != c.allowed {
+			t.Errorf("expect send %d to be allowed %v, got %v", i, c.allowed, err)
+		}
+	}
+	if client.expires["test:limit"] != 3000 {
+		t.Errorf("expect the bucket to expire once refilled, got %d", client.expires["test:limit"])
+	}
+}
@@ -0,0 +1,250 @@
+package ambassador
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	DefaultStopKeywords  = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "CANCEL", "END", "QUIT"}
+	DefaultStartKeywords = []string{"START", "SUBSCRIBE", "UNSTOP"}
+)
+
+// SubscriptionStore keeps the topics which users subscribe to.
+type SubscriptionStore interface {
+	Subscribe(topic, userId string) error
+	Unsubscribe(topic, userId string) error
+	Subscribers(topic string) ([]string, error)
+	Topics(userId string) ([]string, error)
+}
+
+type MemorySubscriptionStore struct {
+	sync.Mutex
+	topics map[string]map[string]bool
+}
+
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{topics: map[string]map[string]bool{}}
+}
+
+func (s *MemorySubscriptionStore) Subscribe(topic, userId string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.topics[topic] == nil {
+		s.topics[topic] = map[string]bool{}
+	}
+	s.topics[topic][userId] = true
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Unsubscribe(topic, userId string) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.topics[topic], userId)
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Subscribers(topic string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	users := make([]string, 0, len(s.topics[topic]))
+	for userId := range s.topics[topic] {
+		users = append(users, userId)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+func (s *MemorySubscriptionStore) Topics(userId string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	topics := []string{}
+	for topic, users := range s.topics {
+		if users[userId] {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics, nil
+}
+
+// DeliveryErrors collects delivery errors by recipient or envelope id.
+type DeliveryErrors map[string]error
+
+func (e DeliveryErrors) Error() string {
+	return fmt.Sprintf("fail to deliver %d messages", len(e))
+}
+
+// optedOutTopic is a reserved topic of the users who opt out of every topic
+// by a bare stop keyword. Their subscriptions are kept, so that a start
+// keyword opts them back in.
+const optedOutTopic = "ambassador:opted-out"
+
+// SubscriptionManager manages topic subscriptions of users. It understands
+// compliant opt-out keywords such as STOP, and only publishes messages of a
+// topic to the users who still subscribe to it.
+type SubscriptionManager struct {
+	store         SubscriptionStore
+	StopKeywords  []string
+	StartKeywords []string
+	// Confirmations sent after a user opts out or opts in by a keyword.
+	StopReply  string
+	StartReply string
+	// Limiter paces publishing on platforms without bulk endpoints, where
+	// messages are sent to subscribers one by one.
+	Limiter RateLimiter
+}
+
+func NewSubscriptionManager(store SubscriptionStore) *SubscriptionManager {
+	if store == nil {
+		store = NewMemorySubscriptionStore()
+	}
+	return &SubscriptionManager{
+		store:         store,
+		StopKeywords:  DefaultStopKeywords,
+		StartKeywords: DefaultStartKeywords,
+		StopReply:     "You have been unsubscribed.",
+		StartReply:    "You have been subscribed.",
+	}
+}
+
+func (m *SubscriptionManager) Subscribe(topic, userId string) error {
+	return m.store.Subscribe(topic, userId)
+}
+
+func (m *SubscriptionManager) Unsubscribe(topic, userId string) error {
+	return m.store.Unsubscribe(topic, userId)
+}
+
+// UnsubscribeAll removes every subscription of a user for good.
+func (m *SubscriptionManager) UnsubscribeAll(userId string) (err error) {
+	topics, err := m.store.Topics(userId)
+	if err != nil {
+		return
+	}
+	for _, topic := range topics {
+		if topic == optedOutTopic {
+			continue
+		}
+		if err = m.store.Unsubscribe(topic, userId); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// OptOut stops publishing any topic to a user until the user opts in
+// again. The subscriptions of the user are kept.
+func (m *SubscriptionManager) OptOut(userId string) error {
+	return m.store.Subscribe(optedOutTopic, userId)
+}
+
+// OptIn reverses an OptOut.
+func (m *SubscriptionManager) OptIn(userId string) error {
+	return m.store.Unsubscribe(optedOutTopic, userId)
+}
+
+// Subscribers returns the users subscribing to a topic, except those who
+// opt out of every topic.
+func (m *SubscriptionManager) Subscribers(topic string) (users []string, err error) {
+	users, err = m.store.Subscribers(topic)
+	if err != nil || len(users) == 0 {
+		return
+	}
+	optedOut, err := m.store.Subscribers(optedOutTopic)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[string]bool, len(optedOut))
+	for _, userId := range optedOut {
+		excluded[userId] = true
+	}
+	subscribed := users[:0]
+	for _, userId := range users {
+		if !excluded[userId] {
+			subscribed = append(subscribed, userId)
+		}
+	}
+	return subscribed, nil
+}
+
+// HandleKeyword handles "STOP", "STOP <topic>", "START" and "START <topic>"
+// style messages and platform opt-out events. A bare STOP opts a user out of
+// every topic and a bare START opts the user back in. A START of a topic
+// also opts the user back in, since it is the latest wish of the user. A
+// confirmation is staged on the ambassador when a keyword is handled.
+func (m *SubscriptionManager) HandleKeyword(a Ambassador, msg Message) (handled bool, err error) {
+	var text string
+	switch content := msg.Content.(type) {
+	case *OptOutContent:
+		return true, m.OptOut(msg.SenderId)
+	case *TextContent:
+		text = content.Text
+	default:
+		return
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) > 2 {
+		return
+	}
+	keyword := strings.ToUpper(fields[0])
+	var topic string
+	if len(fields) == 2 {
+		topic = fields[1]
+	}
+
+	var reply string
+	switch {
+	case containsKeyword(m.StopKeywords, keyword):
+		if topic == "" {
+			err = m.OptOut(msg.SenderId)
+		} else {
+			err = m.store.Unsubscribe(topic, msg.SenderId)
+		}
+		reply = m.StopReply
+	case containsKeyword(m.StartKeywords, keyword):
+		if topic != "" {
+			err = m.store.Subscribe(topic, msg.SenderId)
+		}
+		if err == nil {
+			err = m.OptIn(msg.SenderId)
+		}
+		reply = m.StartReply
+	default:
+		return
+	}
+	if err == nil && reply != "" {
+		err = a.SendText(reply)
+	}
+	return true, err
+}
+
+// Publish sends messages built by a builder to every subscriber of a topic,
+// through the bulk endpoints of the platform if possible. Sent is the number
+// of subscribers which the messages are not known to fail to reach.
+func (m *SubscriptionManager) Publish(a Ambassador, topic string, build MessageBuilder) (sent int, err error) {
+	users, err := m.Subscribers(topic)
+	if err != nil || len(users) == 0 {
+		return
+	}
+	err = Broadcast(a, Audience{Recipients: users}, build, m.Limiter)
+	switch errs := err.(type) {
+	case nil:
+		sent = len(users)
+	case DeliveryErrors:
+		sent = len(users) - len(errs)
+	}
+	return
+}
+
+func containsKeyword(keywords []string, keyword string) bool {
+	for _, k := range keywords {
+		if k == keyword {
+			return true
+		}
+	}
+	return false
+}
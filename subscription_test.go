@@ -0,0 +1,102 @@
+package ambassador
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestSubscriptionKeywords(t *testing.T) {
+	m := NewSubscriptionManager(nil)
+	m.Subscribe("deals", "u1")
+	m.Subscribe("news", "u1")
+	m.Subscribe("deals", "u2")
+	a := &recordAmbassador{}
+	keyword := func(userId, text string) {
+		if handled, err := m.HandleKeyword(a, Message{SenderId: userId, Content: &TextContent{Text: text}}); !handled || err != nil {
+			t.Fatalf("expect %q to be handled, got %v", text, err)
+		}
+	}
+	subscribers := func(topic string) []string {
+		users, err := m.Subscribers(topic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return users
+	}
+
+	keyword("u1", "stop")
+	if users := subscribers("deals"); !reflect.DeepEqual(users, []string{"u2"}) || len(subscribers("news")) != 0 {
+		t.Errorf("expect a bare stop to opt out of every topic, got %v", users)
+	}
+	keyword("u1", "START")
+	if users := subscribers("deals"); !reflect.DeepEqual(users, []string{"u1", "u2"}) || len(subscribers("news")) != 1 {
+		t.Errorf("expect a bare start to reverse a bare stop, got %v", users)
+	}
+	keyword("u1", "STOP deals")
+	if len(subscribers("deals")) != 1 || len(subscribers("news")) != 1 {
+		t.Error("expect a stop of a topic to only leave the topic")
+	}
+	keyword("u1", "START deals")
+	if len(subscribers("deals")) != 2 {
+		t.Error("expect a start of a topic to subscribe to it")
+	}
+
+	if handled, _ := m.HandleKeyword(a, Message{SenderId: "u2", Content: &OptOutContent{}}); !handled || len(subscribers("deals")) != 1 {
+		t.Error("expect a platform opt out to opt out of every topic")
+	}
+	if handled, _ := m.HandleKeyword(a, Message{SenderId: "u2", Content: &TextContent{Text: "stop by later"}}); handled {
+		t.Error("expect a sentence not to be a keyword")
+	}
+	a.Send("u1")
+	if len(a.sent) != 4 || a.sent[0] != "u1:"+m.StopReply || a.sent[1] != "u1:"+m.StartReply {
+		t.Errorf("unexpected confirmations: %v", a.sent)
+	}
+}
+
+func TestSubscriptionPublish(t *testing.T) {
+	m := NewSubscriptionManager(nil)
+	for _, userId := range []string{"U1", "U2", "U3"} {
+		m.Subscribe("deals", userId)
+	}
+	m.OptOut("U3")
+
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	sent, err := m.Publish(NewLineAmbassador("token", server.Client()), "deals", Messages(TextMessage("sale")))
+	if err != nil || sent != 2 {
+		t.Fatalf("expect 2 subscribers to be sent to, got %d, %v", sent, err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/v2/bot/message/multicast" || !strings.Contains(string(requests[0].Body), `"to":["U1","U2"]`) {
+		t.Errorf("expect one multicast to the subscribers who did not opt out, got %+v", requests)
+	}
+
+	// a builder failing halfway for U1 must not leak into the messages of U2
+	builds := 0
+	build := func(a Ambassador) error {
+		builds++
+		a.SendText("part")
+		if builds == 1 {
+			return errors.New("fail to render")
+		}
+		return a.SendText("rest")
+	}
+	a := &recordAmbassador{}
+	sent, err = m.Publish(a, "deals", build)
+	if errs, ok := err.(DeliveryErrors); !ok || len(errs) != 1 || errs["U1"] == nil || sent != 1 {
+		t.Errorf("expect U1 to fail, got %d, %v", sent, err)
+	}
+	if !reflect.DeepEqual(a.sent, []string{"U2:part", "U2:rest"}) {
+		t.Errorf("expect the staged messages of a failed build to be discarded, got %v", a.sent)
+	}
+
+	fb := NewFBAmbassador("token", server.Client())
+	builds = 0
+	if _, err := m.Publish(fb, "deals", build); err == nil || len(fb.messages) != 0 {
+		t.Errorf("expect a failed bulk build to be discarded, got %v, %+v", err, fb.messages)
+	}
+}
@@ -0,0 +1,354 @@
+package ambassador
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SurveyPayloadPrefix = "AMBASSADOR_SURVEY:"
+
+const (
+	SurveyChoice = "choice"
+	SurveyText   = "text"
+	SurveyRating = "rating"
+)
+
+const defaultRatingScale = 5
+
+// DefaultSurveyIdleTimeout is how long a survey waits for the next answer of
+// a user before it is abandoned.
+const DefaultSurveyIdleTimeout = 24 * time.Hour
+
+type SurveyQuestion struct {
+	Id      string
+	Type    string
+	Text    string
+	Choices []string
+	Scale   int
+}
+
+func (q *SurveyQuestion) options() []string {
+	switch q.Type {
+	case SurveyChoice:
+		return q.Choices
+	case SurveyRating:
+		scale := q.Scale
+		if scale <= 0 {
+			scale = defaultRatingScale
+		}
+		options := make([]string, 0, scale)
+		for i := 1; i <= scale; i++ {
+			options = append(options, strconv.Itoa(i))
+		}
+		return options
+	}
+	return nil
+}
+
+type Survey struct {
+	Id        string
+	Questions []SurveyQuestion
+	// Completion is sent once the last question is answered.
+	Completion string
+}
+
+type SurveyResponse struct {
+	SurveyId    string
+	UserId      string
+	Answers     map[string]string
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// SurveyStore keeps completed survey responses.
+type SurveyStore interface {
+	SaveResponse(r *SurveyResponse) error
+	Responses(surveyId string) ([]*SurveyResponse, error)
+}
+
+type MemorySurveyStore struct {
+	sync.Mutex
+	responses map[string][]*SurveyResponse
+}
+
+func NewMemorySurveyStore() *MemorySurveyStore {
+	return &MemorySurveyStore{responses: map[string][]*SurveyResponse{}}
+}
+
+func (s *MemorySurveyStore) SaveResponse(r *SurveyResponse) error {
+	s.Lock()
+	defer s.Unlock()
+	s.responses[r.SurveyId] = append(s.responses[r.SurveyId], r)
+	return nil
+}
+
+func (s *MemorySurveyStore) Responses(surveyId string) ([]*SurveyResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*SurveyResponse{}, s.responses[surveyId]...), nil
+}
+
+type surveySession struct {
+	response *SurveyResponse
+	current  int
+	activeAt time.Time
+}
+
+// SurveyRunner asks the questions of a survey one by one and records the
+// answers. Questions are staged on the ambassador and are delivered when the
+// caller sends them.
+type SurveyRunner struct {
+	sync.Mutex
+	Survey *Survey
+	// IdleTimeout abandons the survey of a user who does not answer in
+	// time, without saving the response.
+	IdleTimeout time.Duration
+	store       SurveyStore
+	sessions    map[string]*surveySession
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+func NewSurveyRunner(survey *Survey, store SurveyStore) *SurveyRunner {
+	if store == nil {
+		store = NewMemorySurveyStore()
+	}
+	return &SurveyRunner{
+		Survey:      survey,
+		IdleTimeout: DefaultSurveyIdleTimeout,
+		store:       store,
+		sessions:    map[string]*surveySession{},
+		now:         time.Now,
+	}
+}
+
+// session returns the session of a user unless it is abandoned. It must be
+// called with the lock held.
+func (r *SurveyRunner) session(userId string, now time.Time) (session *surveySession, ok bool) {
+	if now.Sub(r.lastSweep) > r.IdleTimeout {
+		for id, s := range r.sessions {
+			if now.Sub(s.activeAt) > r.IdleTimeout {
+				delete(r.sessions, id)
+			}
+		}
+		r.lastSweep = now
+	}
+	session, ok = r.sessions[userId]
+	if ok && now.Sub(session.activeAt) > r.IdleTimeout {
+		delete(r.sessions, userId)
+		return nil, false
+	}
+	return
+}
+
+// Start begins the survey for a user by asking the first question.
+func (r *SurveyRunner) Start(a Ambassador, userId string) (err error) {
+	if len(r.Survey.Questions) == 0 {
+		return fmt.Errorf("survey %s has no question", r.Survey.Id)
+	}
+	now := r.now()
+	r.Lock()
+	r.session(userId, now)
+	r.sessions[userId] = &surveySession{
+		response: &SurveyResponse{
+			SurveyId:  r.Survey.Id,
+			UserId:    userId,
+			Answers:   map[string]string{},
+			StartedAt: now,
+		},
+		activeAt: now,
+	}
+	r.Unlock()
+	return r.ask(a, &r.Survey.Questions[0])
+}
+
+// InProgress tells whether a user is answering the survey.
+func (r *SurveyRunner) InProgress(userId string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.session(userId, r.now())
+	return ok
+}
+
+// Handle records the answer in a message and asks the next question. It
+// returns false if the message is not an answer of the survey.
+func (r *SurveyRunner) Handle(a Ambassador, msg Message) (handled bool, err error) {
+	now := r.now()
+	r.Lock()
+	session, ok := r.session(msg.SenderId, now)
+	if !ok {
+		r.Unlock()
+		return
+	}
+	question := &r.Survey.Questions[session.current]
+	answer, ok := r.answerOf(question, msg)
+	if !ok {
+		r.Unlock()
+		return
+	}
+
+	session.response.Answers[question.Id] = answer
+	session.current++
+	session.activeAt = now
+	next := session.current
+	done := next >= len(r.Survey.Questions)
+	if done {
+		delete(r.sessions, msg.SenderId)
+		session.response.CompletedAt = now
+	}
+	r.Unlock()
+
+	if !done {
+		return true, r.ask(a, &r.Survey.Questions[next])
+	}
+	if err = r.store.SaveResponse(session.response); err != nil {
+		return true, err
+	}
+	if r.Survey.Completion != "" {
+		err = a.SendText(r.Survey.Completion)
+	}
+	return true, err
+}
+
+func (r *SurveyRunner) ask(a Ambassador, q *SurveyQuestion) (err error) {
+	options := q.options()
+	if len(options) == 0 {
+		return a.SendText(q.Text)
+	}
+	answers := make([]map[string]string, 0, len(options))
+	for i, option := range options {
+		answers = append(answers, map[string]string{
+			"content_type": "text",
+			"title":        option,
+			"payload":      fmt.Sprintf("%s%s:%s:%d", SurveyPayloadPrefix, r.Survey.Id, q.Id, i),
+		})
+	}
+	return a.AskQuestion(q.Text, answers)
+}
+
+func (r *SurveyRunner) answerOf(q *SurveyQuestion, msg Message) (answer string, ok bool) {
+	options := q.options()
+	switch content := msg.Content.(type) {
+	case *CommandContent:
+		prefix := fmt.Sprintf("%s%s:%s:", SurveyPayloadPrefix, r.Survey.Id, q.Id)
+		if !strings.HasPrefix(content.Payload, prefix) {
+			return
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(content.Payload, prefix))
+		if err != nil || i < 0 || i >= len(options) {
+			return
+		}
+		return options[i], true
+	case *TextContent:
+		if len(options) == 0 {
+			return content.Text, true
+		}
+		for _, option := range options {
+			if strings.EqualFold(strings.TrimSpace(content.Text), option) {
+				return option, true
+			}
+		}
+	}
+	return
+}
+
+type SurveyQuestionResult struct {
+	QuestionId string
+	Type       string
+	Count      int
+	// Tally counts answers of choice and rating questions.
+	Tally map[string]int `json:",omitempty"`
+	// Average is the mean score of a rating question.
+	Average float64  `json:",omitempty"`
+	Texts   []string `json:",omitempty"`
+}
+
+type SurveyResults struct {
+	SurveyId  string
+	Responses int
+	Questions []SurveyQuestionResult
+}
+
+// Results aggregates all stored responses of the survey.
+func (r *SurveyRunner) Results() (results *SurveyResults, err error) {
+	responses, err := r.store.Responses(r.Survey.Id)
+	if err != nil {
+		return
+	}
+
+	results = &SurveyResults{SurveyId: r.Survey.Id, Responses: len(responses)}
+	for _, q := range r.Survey.Questions {
+		result := SurveyQuestionResult{QuestionId: q.Id, Type: q.Type}
+		var sum int
+		for _, response := range responses {
+			answer, ok := response.Answers[q.Id]
+			if !ok {
+				continue
+			}
+			result.Count++
+			switch q.Type {
+			case SurveyChoice, SurveyRating:
+				if result.Tally == nil {
+					result.Tally = map[string]int{}
+				}
+				result.Tally[answer]++
+				score, _ := strconv.Atoi(answer)
+				sum += score
+			default:
+				result.Texts = append(result.Texts, answer)
+			}
+		}
+		if q.Type == SurveyRating && result.Count > 0 {
+			result.Average = float64(sum) / float64(result.Count)
+		}
+		results.Questions = append(results.Questions, result)
+	}
+	return
+}
+
+// ExportJSON writes the aggregated results as JSON.
+func (r *SurveyRunner) ExportJSON(w io.Writer) (err error) {
+	results, err := r.Results()
+	if err != nil {
+		return
+	}
+	return json.NewEncoder(w).Encode(results)
+}
+
+// ExportCSV writes every response as a row with a column per question.
+func (r *SurveyRunner) ExportCSV(w io.Writer) (err error) {
+	responses, err := r.store.Responses(r.Survey.Id)
+	if err != nil {
+		return
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"user_id", "started_at", "completed_at"}
+	for _, q := range r.Survey.Questions {
+		header = append(header, q.Id)
+	}
+	if err = cw.Write(header); err != nil {
+		return
+	}
+	for _, response := range responses {
+		row := []string{
+			response.UserId,
+			response.StartedAt.Format(time.RFC3339),
+			response.CompletedAt.Format(time.RFC3339),
+		}
+		for _, q := range r.Survey.Questions {
+			row = append(row, response.Answers[q.Id])
+		}
+		if err = cw.Write(row); err != nil {
+			return
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
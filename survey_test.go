@@ -0,0 +1,87 @@
+package ambassador
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSurveyRunner(t *testing.T) {
+	survey := &Survey{
+		Id: "s1",
+		Questions: []SurveyQuestion{
+			{Id: "color", Type: SurveyChoice, Text: "Favorite color?", Choices: []string{"red", "blue"}},
+			{Id: "score", Type: SurveyRating, Text: "How do you like us?"},
+			{Id: "comment", Type: SurveyText, Text: "Anything else?"},
+		},
+		Completion: "thanks",
+	}
+	runner := NewSurveyRunner(survey, nil)
+	a := NewFBAmbassador("token", nil)
+
+	if err := runner.Start(a, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []interface{}{
+		&CommandContent{Payload: SurveyPayloadPrefix + "s1:color:1"},
+		&TextContent{Text: "4"},
+		&TextContent{Text: "great"},
+	} {
+		handled, err := runner.Handle(a, Message{SenderId: "u1", Content: content})
+		if err != nil || !handled {
+			t.Fatalf("fail to handle %+v: %v", content, err)
+		}
+	}
+	if runner.InProgress("u1") {
+		t.Error("expect the survey to be completed")
+	}
+
+	results, err := runner.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Responses != 1 || results.Questions[0].Tally["blue"] != 1 || results.Questions[1].Average != 4 {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := runner.ExportCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "blue,4,great") {
+		t.Errorf("unexpected csv: %s", buf.String())
+	}
+}
+
+func TestSurveyIdleTimeout(t *testing.T) {
+	survey := &Survey{
+		Id: "s1",
+		Questions: []SurveyQuestion{
+			{Id: "first", Type: SurveyText, Text: "First?"},
+			{Id: "second", Type: SurveyText, Text: "Second?"},
+		},
+	}
+	now := time.Unix(1500000000, 0)
+	runner := NewSurveyRunner(survey, nil)
+	runner.now = func() time.Time { return now }
+	a := &recordAmbassador{}
+
+	runner.Start(a, "u1")
+	runner.Start(a, "u2")
+	now = now.Add(20 * time.Hour)
+	if handled, _ := runner.Handle(a, Message{SenderId: "u2", Content: &TextContent{Text: "yes"}}); !handled {
+		t.Fatal("expect an answer in time to be handled")
+	}
+
+	now = now.Add(5 * time.Hour)
+	if handled, _ := runner.Handle(a, Message{SenderId: "u1", Content: &TextContent{Text: "late"}}); handled {
+		t.Error("expect an idle survey to be abandoned")
+	}
+	if !runner.InProgress("u2") {
+		t.Error("expect a survey answered recently to be kept")
+	}
+	if len(runner.sessions) != 1 {
+		t.Errorf("expect idle sessions to be swept, got %d", len(runner.sessions))
+	}
+}
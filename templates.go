@@ -0,0 +1,116 @@
+package ambassador
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrTemplateNotApproved = errors.New("ambassador: template not approved")
+
+type TemplateStatus string
+
+const (
+	// TemplateUnreviewed is the status of templates which need no review.
+	TemplateUnreviewed TemplateStatus = ""
+	TemplatePending    TemplateStatus = "pending"
+	TemplateApproved   TemplateStatus = "approved"
+	TemplateRejected   TemplateStatus = "rejected"
+)
+
+// MessageTemplate is a registered copy of the bot with its review status.
+type MessageTemplate struct {
+	Id         string
+	Status     TemplateStatus
+	Messages   []OutboundMessage
+	ReviewedBy string
+	ReviewedAt time.Time
+}
+
+// TemplateHook is called before a template is staged. A hook blocks the
+// template by returning an error.
+type TemplateHook func(t MessageTemplate) error
+
+// RequireApproval blocks templates which are not approved.
+func RequireApproval(t MessageTemplate) error {
+	if t.Status != TemplateApproved {
+		return ErrTemplateNotApproved
+	}
+	return nil
+}
+
+// TemplateRegistry keeps message templates by id, e.g. for legal review of
+// the copy of a bot before it is sent.
+type TemplateRegistry struct {
+	sync.Mutex
+	templates map[string]MessageTemplate
+	hooks     []TemplateHook
+}
+
+func NewTemplateRegistry(hooks ...TemplateHook) *TemplateRegistry {
+	return &TemplateRegistry{templates: map[string]MessageTemplate{}, hooks: hooks}
+}
+
+// Register adds or updates a template with an initial status. An update
+// can not approve a reviewed template by itself.
+func (r *TemplateRegistry) Register(id string, status TemplateStatus, messages ...OutboundMessage) {
+	r.Lock()
+	defer r.Unlock()
+	if old, ok := r.templates[id]; ok && old.Status != TemplateUnreviewed && status == TemplateApproved {
+		status = TemplatePending
+	}
+	r.templates[id] = MessageTemplate{Id: id, Status: status, Messages: messages}
+}
+
+// Review sets the status of a template on behalf of a reviewer.
+func (r *TemplateRegistry) Review(id string, status TemplateStatus, reviewer string) (err error) {
+	r.Lock()
+	defer r.Unlock()
+	t, ok := r.templates[id]
+	if !ok {
+		return fmt.Errorf("no template registered for %s", id)
+	}
+	t.Status = status
+	t.ReviewedBy = reviewer
+	t.ReviewedAt = time.Now()
+	r.templates[id] = t
+	return
+}
+
+// Get returns a template by its id.
+func (r *TemplateRegistry) Get(id string) (t MessageTemplate, ok bool) {
+	r.Lock()
+	defer r.Unlock()
+	t, ok = r.templates[id]
+	return
+}
+
+// Pending returns the ids of templates waiting for review.
+func (r *TemplateRegistry) Pending() (ids []string) {
+	r.Lock()
+	defer r.Unlock()
+	for id, t := range r.templates {
+		if t.Status == TemplatePending {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+// Builder returns a builder which stages a template after all hooks let it
+// through.
+func (r *TemplateRegistry) Builder(id string) MessageBuilder {
+	return func(a Ambassador) (err error) {
+		t, ok := r.Get(id)
+		if !ok {
+			return fmt.Errorf("no template registered for %s", id)
+		}
+		for _, hook := range r.hooks {
+			if err = hook(t); err != nil {
+				return
+			}
+		}
+		return Messages(t.Messages...)(a)
+	}
+}
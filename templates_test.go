@@ -0,0 +1,29 @@
+package ambassador
+
+import "testing"
+
+func TestTemplateApproval(t *testing.T) {
+	registry := NewTemplateRegistry(RequireApproval)
+	registry.Register("welcome", TemplatePending, TextMessage("Welcome!"))
+
+	a := &recordAmbassador{}
+	if err := registry.Builder("welcome")(a); err != ErrTemplateNotApproved {
+		t.Fatalf("expect a pending template to be blocked, got %v", err)
+	}
+	if ids := registry.Pending(); len(ids) != 1 || ids[0] != "welcome" {
+		t.Errorf("unexpected pending templates: %v", ids)
+	}
+
+	registry.Review("welcome", TemplateApproved, "legal")
+	if err := registry.Builder("welcome")(a); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.staged) != 1 || a.staged[0] != "Welcome!" {
+		t.Errorf("unexpected staged messages: %v", a.staged)
+	}
+
+	registry.Register("welcome", TemplateApproved, TextMessage("Welcome back!"))
+	if tpl, _ := registry.Get("welcome"); tpl.Status != TemplatePending {
+		t.Errorf("expect an updated template to be reviewed again, got %s", tpl.Status)
+	}
+}
@@ -0,0 +1,171 @@
+package ambassador
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Tenant is a customer of a shared deployment with its own page, channel or
+// account on a platform.
+type Tenant struct {
+	Id       string
+	Platform string
+	// ChannelId is the page, channel or account id of the tenant on the
+	// platform, which messages to the tenant are addressed to.
+	ChannelId string
+	Token     string
+}
+
+// Tags returns the dimensions of a tenant attached to its metrics and logs.
+func (t *Tenant) Tags() map[string]string {
+	return map[string]string{
+		"tenant":   t.Id,
+		"platform": t.Platform,
+		"channel":  t.ChannelId,
+	}
+}
+
+type tenantMetrics struct {
+	metrics Metrics
+	tags    map[string]string
+}
+
+func (m tenantMetrics) with(tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(m.tags)+len(tags))
+	for k, v := range m.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (m tenantMetrics) Count(name string, delta int64, tags map[string]string) {
+	m.metrics.Count(name, delta, m.with(tags))
+}
+
+func (m tenantMetrics) Gauge(name string, value float64, tags map[string]string) {
+	m.metrics.Gauge(name, value, m.with(tags))
+}
+
+func (m tenantMetrics) Observe(name string, value float64, tags map[string]string) {
+	m.metrics.Observe(name, value, m.with(tags))
+}
+
+type tenantLogger struct {
+	logger Logger
+	prefix string
+}
+
+func (l tenantLogger) Printf(format string, v ...interface{}) {
+	l.logger.Printf(l.prefix+format, v...)
+}
+
+type tenantReporter struct {
+	reporter ErrorReporter
+	tenant   string
+}
+
+func (r tenantReporter) Report(err error, report ErrorReport) {
+	if report.Tenant == "" {
+		report.Tenant = r.tenant
+	}
+	r.reporter.Report(err, report)
+}
+
+// TenantScope is the tenant of a webhook with the metrics, logger and error
+// reporter of the registry tagged by the tenant.
+type TenantScope struct {
+	Tenant   Tenant
+	Metrics  Metrics
+	Logger   Logger
+	Reporter ErrorReporter
+}
+
+// TenantRegistry keeps the tenants of a shared deployment and scopes the
+// metrics, logs and error reports of each tenant, so that they can be
+// broken down per customer.
+type TenantRegistry struct {
+	sync.Mutex
+	Client   *http.Client
+	Metrics  Metrics
+	Logger   Logger
+	Reporter ErrorReporter
+	tenants  map[string]Tenant
+}
+
+func NewTenantRegistry() *TenantRegistry {
+	return &TenantRegistry{tenants: map[string]Tenant{}}
+}
+
+func (r *TenantRegistry) Register(t Tenant) {
+	r.Lock()
+	defer r.Unlock()
+	r.tenants[t.Id] = t
+}
+
+func (r *TenantRegistry) Get(id string) (t Tenant, ok bool) {
+	r.Lock()
+	defer r.Unlock()
+	t, ok = r.tenants[id]
+	return
+}
+
+// Scope returns the tagged metrics, logger and reporter of a tenant.
+func (r *TenantRegistry) Scope(id string) (s *TenantScope, err error) {
+	t, ok := r.Get(id)
+	if !ok {
+		return nil, fmt.Errorf("no tenant registered for %s", id)
+	}
+	s = &TenantScope{Tenant: t, Metrics: nopMetrics{}, Logger: stdLogger{}}
+	if r.Metrics != nil {
+		s.Metrics = tenantMetrics{metrics: r.Metrics, tags: t.Tags()}
+	}
+	logger := r.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	s.Logger = tenantLogger{
+		logger: logger,
+		prefix: fmt.Sprintf("[tenant=%s platform=%s channel=%s] ", t.Id, t.Platform, t.ChannelId),
+	}
+	if r.Reporter != nil {
+		s.Reporter = tenantReporter{reporter: r.Reporter, tenant: t.Id}
+	}
+	return
+}
+
+// Webhook returns the webhook of a tenant. Ambassadors are made by the token
+// of the tenant, translation failures are reported with the tenant, and
+// handled messages are counted with the tags of the tenant.
+func (r *TenantRegistry) Webhook(id string, h Handler) (wh *Webhook, err error) {
+	s, err := r.Scope(id)
+	if err != nil {
+		return
+	}
+	return &Webhook{
+		NewAmbassador: func() Ambassador {
+			return New(s.Tenant.Platform, s.Tenant.Token, r.Client)
+		},
+		Handler:  s.Middleware()(h),
+		Reporter: s.Reporter,
+	}, nil
+}
+
+// Middleware counts handled and failed messages of the tenant and logs
+// failures with the tenant.
+func (s *TenantScope) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			if err = next.Handle(a, msg); err != nil {
+				s.Metrics.Count("ambassador.tenant.failed", 1, nil)
+				CorrelationLogger(s.Logger, msg.CorrelationId).Printf("ambassador: fail to handle a message from %s: %s", msg.SenderId, err)
+				return
+			}
+			s.Metrics.Count("ambassador.tenant.handled", 1, nil)
+			return
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package ambassador
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recordMetrics struct {
+	counts []string
+}
+
+func (m *recordMetrics) Count(name string, delta int64, tags map[string]string) {
+	m.counts = append(m.counts, fmt.Sprintf("%s tenant=%s channel=%s", name, tags["tenant"], tags["channel"]))
+}
+
+func (m *recordMetrics) Gauge(string, float64, map[string]string)   {}
+func (m *recordMetrics) Observe(string, float64, map[string]string) {}
+
+type lineLogger struct {
+	lines []string
+}
+
+func (l *lineLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestTenantScope(t *testing.T) {
+	metrics := &recordMetrics{}
+	logger := &lineLogger{}
+	var reported ErrorReport
+	registry := NewTenantRegistry()
+	registry.Metrics = metrics
+	registry.Logger = logger
+	registry.Reporter = ErrorReporterFunc(func(err error, report ErrorReport) { reported = report })
+	registry.Register(Tenant{Id: "acme", Platform: "facebook", ChannelId: "page1", Token: "token"})
+
+	if _, err := registry.Scope("nobody"); err == nil {
+		t.Errorf("expect an unknown tenant to fail")
+	}
+	s, err := registry.Scope("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.Middleware()(HandlerFunc(func(a Ambassador, msg Message) error {
+		return errors.New("boom")
+	}))
+	h.Handle(&recordAmbassador{}, Message{SenderId: "u1"})
+	s.Reporter.Report(errors.New("boom"), ErrorReport{Platform: "facebook"})
+
+	if len(metrics.counts) != 1 || metrics.counts[0] != "ambassador.tenant.failed tenant=acme channel=page1" {
+		t.Errorf("unexpected metrics: %v", metrics.counts)
+	}
+	if len(logger.lines) != 1 || !strings.HasPrefix(logger.lines[0], "[tenant=acme platform=facebook channel=page1] ") {
+		t.Errorf("unexpected logs: %v", logger.lines)
+	}
+	if reported.Tenant != "acme" {
+		t.Errorf("expect reports tagged by the tenant, got %+v", reported)
+	}
+}
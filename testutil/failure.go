@@ -0,0 +1,154 @@
+package testutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Failure is how a fake server answers a request instead of succeeding.
+type Failure struct {
+	// Status is the status code. Zero means 200, e.g. with a malformed body.
+	Status int
+	Header http.Header
+	Body   string
+	// Delay holds the response, e.g. to trip the timeout of a client.
+	Delay time.Duration
+}
+
+// Scenario decides how the nth request matching it is answered, counting
+// from zero. A nil failure lets the request succeed.
+type Scenario func(n int, r Request) *Failure
+
+// RateLimitBurst rejects count requests from the start one with the rate
+// limit error of the platform of each request.
+func RateLimitBurst(start, count int, retryAfter time.Duration) Scenario {
+	return func(n int, r Request) *Failure {
+		if n < start || n >= start+count {
+			return nil
+		}
+		return RateLimited(r.Host, retryAfter)
+	}
+}
+
+// Intermittent answers every nth request with a status, e.g. a 502 of a
+// flaky load balancer.
+func Intermittent(every, status int) Scenario {
+	return func(n int, r Request) *Failure {
+		if every <= 0 || (n+1)%every != 0 {
+			return nil
+		}
+		return &Failure{Status: status, Body: `<html><body><h1>` + strconv.Itoa(status) + ` ` + http.StatusText(status) + `</h1></body></html>`,
+			Header: http.Header{"Content-Type": {"text/html"}}}
+	}
+}
+
+// Slow delays every response.
+func Slow(d time.Duration) Scenario {
+	return func(n int, r Request) *Failure {
+		return &Failure{Delay: d, Body: successBody(r.Host)}
+	}
+}
+
+// Malformed answers every request with a truncated JSON body.
+func Malformed() Scenario {
+	return func(n int, r Request) *Failure {
+		body := successBody(r.Host)
+		return &Failure{Body: body[:len(body)/2]}
+	}
+}
+
+// Sequence answers the requests with the failures in order, and lets the
+// requests after them succeed. A nil failure in between succeeds too.
+func Sequence(failures ...*Failure) Scenario {
+	return func(n int, r Request) *Failure {
+		if n < len(failures) {
+			return failures[n]
+		}
+		return nil
+	}
+}
+
+// RateLimited is the rate limit error a platform answers a host with.
+func RateLimited(host string, retryAfter time.Duration) *Failure {
+	f := &Failure{Status: http.StatusTooManyRequests, Header: http.Header{}}
+	if retryAfter > 0 {
+		f.Header.Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+	}
+	switch {
+	case strings.Contains(host, "facebook"):
+		// facebook throttles with a 400 and an error code in the body
+		f.Status = http.StatusBadRequest
+		f.Body = `{"error":{"message":"(#4) Application request limit reached","type":"OAuthException","code":4,"fbtrace_id":"fake"}}`
+	case strings.Contains(host, "line.me"):
+		f.Body = `{"message":"The API rate limit has been exceeded. Try again later."}`
+	case strings.Contains(host, "slack.com"):
+		f.Body = `{"ok":false,"error":"ratelimited"}`
+	case strings.Contains(host, "discord"):
+		f.Body = `{"message":"You are being rate limited.","retry_after":` + strconv.FormatFloat(retryAfter.Seconds(), 'f', -1, 64) + `,"global":false}`
+	default:
+		f.Body = `{"error":"rate limited"}`
+	}
+	return f
+}
+
+type scenario struct {
+	host string
+	run  Scenario
+	n    int
+}
+
+// Fail answers the requests to hosts containing a string, e.g.
+// "facebook", by a scenario. An empty host matches every request. The
+// scenario of the latest matching call is used, and it counts only the
+// requests it matches.
+func (s *FakeServer) Fail(host string, sc Scenario) {
+	s.Lock()
+	defer s.Unlock()
+	s.scenarios = append(s.scenarios, &scenario{host: host, run: sc})
+}
+
+// Recover removes all scenarios, so that every request succeeds again.
+func (s *FakeServer) Recover() {
+	s.Lock()
+	defer s.Unlock()
+	s.scenarios = nil
+}
+
+// failure returns the failure of a request by the scenarios.
+func (s *FakeServer) failure(r Request) *Failure {
+	s.Lock()
+	defer s.Unlock()
+	for i := len(s.scenarios) - 1; i >= 0; i-- {
+		sc := s.scenarios[i]
+		if strings.Contains(r.Host, sc.host) {
+			n := sc.n
+			sc.n++
+			return sc.run(n, r)
+		}
+	}
+	return nil
+}
+
+func (f *Failure) write(w http.ResponseWriter, r *http.Request) {
+	if f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for k, v := range f.Header {
+		w.Header()[k] = v
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(f.Body))
+}
@@ -0,0 +1,61 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Snapshot renders the requests received by the server, with JSON bodies
+// indented, so the wire format of outbound payloads can be compared.
+func Snapshot(requests []Request) []byte {
+	var b bytes.Buffer
+	for _, r := range requests {
+		b.WriteString(r.Method + " " + r.Host + r.Path + "\n")
+		var indented bytes.Buffer
+		if json.Indent(&indented, r.Body, "", "  ") == nil {
+			b.Write(indented.Bytes())
+		} else {
+			b.Write(r.Body)
+		}
+		b.WriteString("\n\n")
+	}
+	return b.Bytes()
+}
+
+// Golden compares got with testdata/<name>.golden. When tests are run with
+// -update, the golden file is rewritten instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("fail to read golden file, run with -update to create it: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match its golden file, run with -update if the change is intended:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
+
+// GoldenRequests snapshots the requests received by the server since the
+// last reset, compares them with a golden file and resets the server.
+func (s *FakeServer) GoldenRequests(t testing.TB, name string) {
+	t.Helper()
+	Golden(t, name, Snapshot(s.Requests()))
+	s.Reset()
+}
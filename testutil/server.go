@@ -0,0 +1,114 @@
+// Package testutil provides fake platform API servers for testing bots and
+// the ambassador package without reaching real platforms.
+package testutil
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is a request received by a fake server.
+type Request struct {
+	Method string
+	// Host is the platform host the request was sent to, e.g.
+	// graph.facebook.com.
+	Host   string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   []byte
+	At     time.Time
+}
+
+// FakeServer answers every platform API call with a successful response and
+// records the requests. Failures can be simulated by scenarios.
+type FakeServer struct {
+	*httptest.Server
+	sync.Mutex
+	requests []Request
+	// OnRequest is called with every request before it is answered.
+	OnRequest func(r Request)
+	scenarios []*scenario
+}
+
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *FakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	req := Request{
+		Method: r.Method,
+		Host:   r.Host,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header,
+		Body:   body,
+		At:     time.Now(),
+	}
+	s.Lock()
+	s.requests = append(s.requests, req)
+	onRequest := s.OnRequest
+	s.Unlock()
+	if onRequest != nil {
+		onRequest(req)
+	}
+
+	if f := s.failure(req); f != nil {
+		f.write(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(successBody(req.Host)))
+}
+
+func successBody(host string) string {
+	switch {
+	case strings.Contains(host, "facebook"):
+		return `{"recipient_id":"fake-recipient","message_id":"mid.fake"}`
+	case strings.Contains(host, "login.microsoftonline.com"):
+		return `{"token_type":"Bearer","access_token":"fake-token","expires_in":3600}`
+	}
+	return `{}`
+}
+
+// Client returns a client which sends requests to any host to the fake
+// server, so ambassadors can be used with their real endpoints.
+func (s *FakeServer) Client() *http.Client {
+	return &http.Client{Transport: &rewriteTransport{target: s.URL}}
+}
+
+// Requests returns the recorded requests in order.
+func (s *FakeServer) Requests() []Request {
+	s.Lock()
+	defer s.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// Reset forgets the recorded requests.
+func (s *FakeServer) Reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.requests = nil
+}
+
+type rewriteTransport struct {
+	target string
+}
+
+func (t *rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	target, err := http.NewRequest(r.Method, t.target+r.URL.RequestURI(), r.Body)
+	if err != nil {
+		return nil, err
+	}
+	target.Header = r.Header
+	target.Host = r.URL.Host
+	// keep the context, so that timeouts of clients cancel slow responses
+	return http.DefaultTransport.RoundTrip(target.WithContext(r.Context()))
+}
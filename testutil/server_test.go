@@ -0,0 +1,79 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeServer(t *testing.T) {
+	s := NewFakeServer()
+	defer s.Close()
+
+	resp, err := s.Client().Post("https://graph.facebook.com/v2.6/me/messages?access_token=x",
+		"application/json", strings.NewReader(`{"message":{"text":"hi"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	requests := s.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expect a request, got %d", len(requests))
+	}
+	r := requests[0]
+	if r.Host != "graph.facebook.com" || r.Path != "/v2.6/me/messages" || r.Query != "access_token=x" {
+		t.Errorf("unexpected request: %+v", r)
+	}
+}
+
+func TestFakeServerScenarios(t *testing.T) {
+	s := NewFakeServer()
+	defer s.Close()
+	post := func(uri string) (status int, body string) {
+		resp, err := s.Client().Post(uri, "application/json", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	s.Fail("line.me", RateLimitBurst(1, 2, 3*time.Second))
+	s.Fail("facebook", Intermittent(2, http.StatusBadGateway))
+	statuses := []int{}
+	for i := 0; i < 4; i++ {
+		status, _ := post("https://api.line.me/v2/bot/message/push")
+		statuses = append(statuses, status)
+	}
+	if fmt.Sprint(statuses) != "[200 429 429 200]" {
+		t.Errorf("unexpected burst: %v", statuses)
+	}
+	if status, _ := post("https://graph.facebook.com/v2.6/me/messages"); status != 200 {
+		t.Errorf("expect the first fb request to succeed, got %d", status)
+	}
+	if status, _ := post("https://graph.facebook.com/v2.6/me/messages"); status != http.StatusBadGateway {
+		t.Errorf("expect the second fb request to fail, got %d", status)
+	}
+
+	s.Fail("", Malformed())
+	if _, body := post("https://slack.com/api/chat.postMessage"); json.Unmarshal([]byte(body), &struct{}{}) == nil {
+		t.Errorf("expect a malformed body, got %s", body)
+	}
+
+	s.Recover()
+	s.Fail("", Slow(time.Second))
+	client := s.Client()
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Get("https://api.line.me/v2/bot/info"); err == nil {
+		t.Error("expect a slow response to time out")
+	}
+	if len(s.Requests()) != 8 {
+		t.Errorf("expect failed requests to be recorded, got %d", len(s.Requests()))
+	}
+}
@@ -0,0 +1,154 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DirectionInbound  = "in"
+	DirectionOutbound = "out"
+)
+
+// TranscriptEntry is a message of a conversation, in either direction.
+type TranscriptEntry struct {
+	At        time.Time `json:"at"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Platform  string    `json:"platform"`
+	UserId    string    `json:"user_id"`
+	Direction string    `json:"direction"`
+	MessageId string    `json:"message_id,omitempty"`
+	// Type is the type of the content, e.g. "TextContent".
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Media are the urls of attachments.
+	Media   []string        `json:"media,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type TranscriptQuery struct {
+	Tenant string
+	UserId string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (q *TranscriptQuery) match(e *TranscriptEntry) bool {
+	return (q.Tenant == "" || q.Tenant == e.Tenant) &&
+		(q.UserId == "" || q.UserId == e.UserId) &&
+		(q.Since.IsZero() || !e.At.Before(q.Since)) &&
+		(q.Until.IsZero() || e.At.Before(q.Until))
+}
+
+// TranscriptStore keeps the history of conversations.
+type TranscriptStore interface {
+	Append(e *TranscriptEntry) error
+	// Query returns the entries matching a query, the oldest first.
+	Query(q TranscriptQuery) ([]*TranscriptEntry, error)
+}
+
+type MemoryTranscriptStore struct {
+	sync.Mutex
+	entries []*TranscriptEntry
+}
+
+func NewMemoryTranscriptStore() *MemoryTranscriptStore {
+	return &MemoryTranscriptStore{}
+}
+
+func (s *MemoryTranscriptStore) Append(e *TranscriptEntry) error {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = append(s.entries, e)
+	return nil
+}
+
+func (s *MemoryTranscriptStore) Query(q TranscriptQuery) ([]*TranscriptEntry, error) {
+	s.Lock()
+	defer s.Unlock()
+	entries := []*TranscriptEntry{}
+	for _, e := range s.entries {
+		if q.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries, nil
+}
+
+type TranscriptOptions struct {
+	Store  TranscriptStore
+	Tenant string
+	Logger Logger
+}
+
+// inboundEntry describes an inbound message.
+func inboundEntry(platform string, msg Message) *TranscriptEntry {
+	e := &TranscriptEntry{
+		At:        msg.ReceivedAt,
+		Platform:  platform,
+		UserId:    msg.SenderId,
+		Direction: DirectionInbound,
+		MessageId: msg.MessageId,
+		Type:      strings.TrimPrefix(fmt.Sprintf("%T", msg.Content), "*ambassador."),
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	switch c := msg.Content.(type) {
+	case *TextContent:
+		e.Text = c.Text
+	case *CommandContent:
+		e.Text = c.Payload
+	case *FBMessageContent:
+		e.Text = c.Text
+		for _, a := range c.Attachments {
+			var payload struct {
+				Url string `json:"url"`
+			}
+			if json.Unmarshal(a.Payload, &payload) == nil && payload.Url != "" {
+				e.Media = append(e.Media, payload.Url)
+			}
+		}
+	}
+	e.Payload, _ = json.Marshal(msg.Content)
+	return e
+}
+
+// Transcript records inbound messages and the replies sent by the handler.
+// Replies are recorded as the payloads of the platform.
+func Transcript(opts TranscriptOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = stdLogger{}
+	}
+	record := func(msg Message, e *TranscriptEntry) {
+		e.Tenant = opts.Tenant
+		if err := opts.Store.Append(e); err != nil {
+			CorrelationLogger(logger, msg.CorrelationId).Printf("ambassador: fail to record a transcript: %s", err)
+		}
+	}
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			platform := platformOf(a)
+			record(msg, inboundEntry(platform, msg))
+			err = next.Handle(a, msg)
+			for _, sent := range a.GetLastSent() {
+				payload, _ := json.Marshal(sent)
+				record(msg, &TranscriptEntry{
+					At:        time.Now(),
+					Platform:  platform,
+					UserId:    msg.SenderId,
+					Direction: DirectionOutbound,
+					Type:      "payload",
+					Payload:   payload,
+				})
+			}
+			return
+		})
+	}
+}
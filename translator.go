@@ -0,0 +1,80 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Translator turns the raw JSON of an event, or a part of it, into a custom
+// content. A nil content leaves the event to the adapter, and an error skips
+// the event as a partial failure of Translate.
+type Translator func(raw json.RawMessage) (content interface{}, err error)
+
+// Translators are the custom translators of an adapter by kind, so that
+// applications can translate events the adapter does not know, or override
+// how it translates them. Each adapter documents the kinds it looks up.
+type Translators struct {
+	sync.RWMutex
+	translators map[string]Translator
+}
+
+// Register registers a translator of a kind, replacing the previous one.
+func (t *Translators) Register(kind string, f Translator) {
+	t.Lock()
+	defer t.Unlock()
+	if t.translators == nil {
+		t.translators = map[string]Translator{}
+	}
+	t.translators[kind] = f
+}
+
+func (t *Translators) empty() bool {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.translators) == 0
+}
+
+func (t *Translators) translate(kind string, raw json.RawMessage) (content interface{}, err error) {
+	t.RLock()
+	f, ok := t.translators[kind]
+	t.RUnlock()
+	if !ok {
+		return
+	}
+	return f(raw)
+}
+
+// translateFields translates an object by the first of its fields, in order
+// of names, which has a translator of the prefix and the name.
+func (t *Translators) translateFields(prefix string, raw json.RawMessage) (content interface{}, err error) {
+	t.RLock()
+	found := false
+	for kind := range t.translators {
+		if strings.HasPrefix(kind, prefix) {
+			found = true
+			break
+		}
+	}
+	t.RUnlock()
+	if !found {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if jsonCodec.Unmarshal(raw, &fields) != nil {
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if content, err = t.translate(prefix+name, raw); content != nil || err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testReaction struct {
+	Emoji string
+	Mid   string
+}
+
+type testOrderPostback struct {
+	ItemId string
+}
+
+func TestFBTranslators(t *testing.T) {
+	fb := NewFBAmbassador("token", nil)
+	fb.Translators.Register("event:reaction", func(raw json.RawMessage) (interface{}, error) {
+		var v struct {
+			Reaction struct {
+				Emoji string `json:"emoji"`
+				Mid   string `json:"mid"`
+			} `json:"reaction"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return &testReaction{Emoji: v.Reaction.Emoji, Mid: v.Reaction.Mid}, nil
+	})
+	fb.Translators.Register("attachment:fallback", func(raw json.RawMessage) (interface{}, error) {
+		return nil, errors.New("broken fallback")
+	})
+
+	body := `{"object":"page","entry":[{"id":"p","time":1,"messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p"},"timestamp":1,"reaction":{"mid":"m1","action":"react","emoji":"👍"}},
+		{"sender":{"id":"u1"},"recipient":{"id":"p"},"timestamp":2,"message":{"mid":"m2","attachments":[{"type":"fallback","payload":{}}]}},
+		{"sender":{"id":"u1"},"recipient":{"id":"p"},"timestamp":3,"message":{"mid":"m3","text":"hi"}}
+	]}]}`
+	messages, err := fb.Translate(strings.NewReader(body))
+	if partial, ok := err.(*PartialError); !ok || len(partial.Events) != 1 {
+		t.Errorf("expect the broken attachment to be skipped, got %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	if r, ok := messages[0].Content.(*testReaction); !ok || r.Emoji != "👍" || r.Mid != "m1" {
+		t.Errorf("unexpected reaction: %+v", messages[0].Content)
+	}
+	if text, ok := messages[1].Content.(*TextContent); !ok || text.Text != "hi" {
+		t.Errorf("expect other events to be translated as usual, got %+v", messages[1].Content)
+	}
+}
+
+func TestLineTranslators(t *testing.T) {
+	l := NewLineAmbassador("token", nil)
+	l.Translators.Register("postback", func(raw json.RawMessage) (interface{}, error) {
+		var v struct {
+			Postback LinePostback `json:"postback"`
+		}
+		json.Unmarshal(raw, &v)
+		q, err := url.ParseQuery(v.Postback.Payload)
+		if err != nil || q.Get("action") != "buy" {
+			return nil, nil
+		}
+		return &testOrderPostback{ItemId: q.Get("itemid")}, nil
+	})
+
+	body := `{"events":[
+		{"type":"postback","timestamp":1,"source":{"type":"user","userId":"u1"},"postback":{"data":"action=buy&itemid=42"}},
+		{"type":"postback","timestamp":2,"source":{"type":"user","userId":"u1"},"postback":{"data":"MENU"}}
+	]}`
+	messages, err := l.Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := messages[0].Content.(*testOrderPostback); !ok || p.ItemId != "42" {
+		t.Errorf("unexpected postback: %+v", messages[0].Content)
+	}
+	if c, ok := messages[1].Content.(*CommandContent); !ok || c.Payload != "MENU" {
+		t.Errorf("expect other postbacks to be commands, got %+v", messages[1].Content)
+	}
+}
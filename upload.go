@@ -0,0 +1,87 @@
+package ambassador
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Uploader is implemented by ambassadors which upload binary media to a
+// platform and reuse it by the returned handle, e.g. a facebook attachment
+// id.
+type Uploader interface {
+	Upload(r io.Reader, filename, mimeType string) (handle string, err error)
+}
+
+// multipartFile is the file part of a multipart upload.
+type multipartFile struct {
+	Field    string
+	Filename string
+	MimeType string
+	Reader   io.Reader
+}
+
+// newMultipartRequest creates a request whose multipart body is streamed
+// from the file reader while the request is sent, so that a file is never
+// buffered in memory as a whole.
+func newMultipartRequest(uri string, fields map[string]string, file multipartFile) (req *http.Request, err error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, file))
+	}()
+
+	req, err = http.NewRequest("POST", uri, pr)
+	if err != nil {
+		pr.Close()
+		return
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return
+}
+
+func writeMultipart(mw *multipart.Writer, fields map[string]string, file multipartFile) (err error) {
+	for name, value := range fields {
+		if err = mw.WriteField(name, value); err != nil {
+			return
+		}
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(file.Field), escapeQuotes(file.Filename)))
+	if file.MimeType != "" {
+		h.Set("Content-Type", file.MimeType)
+	}
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(part, file.Reader); err != nil {
+		return
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// attachmentType maps a mime type to the attachment types of platforms.
+func attachmentType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	}
+	return "file"
+}
@@ -0,0 +1,41 @@
+package ambassador
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipartRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		f, header, err := r.FormFile("filedata")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(f)
+		if string(b) != "image bytes" || header.Filename != "cat.png" || r.FormValue("message") != "hello" {
+			t.Errorf("unexpected upload: %s %s %s", b, header.Filename, r.FormValue("message"))
+		}
+	}))
+	defer server.Close()
+
+	req, err := newMultipartRequest(server.URL, map[string]string{"message": "hello"}, multipartFile{
+		Field:    "filedata",
+		Filename: "cat.png",
+		MimeType: "image/png",
+		Reader:   strings.NewReader("image bytes"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,85 @@
+package ambassador
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Variants keeps locale specific versions of carousels and question sets
+// and picks the one matching the locale of a recipient.
+type Variants struct {
+	sync.Mutex
+	DefaultLocale string
+	profiles      ProfileStore
+	variants      map[string]map[string][]OutboundMessage
+}
+
+func NewVariants(profiles ProfileStore, defaultLocale string) *Variants {
+	return &Variants{
+		DefaultLocale: normalizeLocale(defaultLocale),
+		profiles:      profiles,
+		variants:      map[string]map[string][]OutboundMessage{},
+	}
+}
+
+// Register adds the messages of a locale, e.g. "zh_TW" or "en", to a set.
+func (v *Variants) Register(id, locale string, messages ...OutboundMessage) {
+	v.Lock()
+	defer v.Unlock()
+	if v.variants[id] == nil {
+		v.variants[id] = map[string][]OutboundMessage{}
+	}
+	v.variants[id][normalizeLocale(locale)] = messages
+}
+
+// Pick returns the variant of a locale. It falls back to the language of the
+// locale and then to the default locale.
+func (v *Variants) Pick(id, locale string) (messages []OutboundMessage, err error) {
+	v.Lock()
+	defer v.Unlock()
+	set, ok := v.variants[id]
+	if !ok {
+		return nil, fmt.Errorf("no variant registered for %s", id)
+	}
+
+	locale = normalizeLocale(locale)
+	candidates := []string{locale, strings.SplitN(locale, "_", 2)[0], v.DefaultLocale}
+	for _, candidate := range candidates {
+		if messages, ok = set[candidate]; ok {
+			return
+		}
+	}
+	return nil, fmt.Errorf("no variant of %s for %s or the default locale", id, locale)
+}
+
+// LocaleOf returns the stored locale of a user, or the default locale.
+func (v *Variants) LocaleOf(userId string) string {
+	if v.profiles != nil {
+		if p, err := v.profiles.GetProfile(userId); err == nil && p != nil && p.Locale != "" {
+			return p.Locale
+		}
+	}
+	return v.DefaultLocale
+}
+
+// Builder returns a builder which stages the variant of a set for a user.
+func (v *Variants) Builder(id, userId string) MessageBuilder {
+	return func(a Ambassador) (err error) {
+		messages, err := v.Pick(id, v.LocaleOf(userId))
+		if err != nil {
+			return
+		}
+		return Messages(messages...)(a)
+	}
+}
+
+// normalizeLocale turns "zh-TW" and "zh_tw" into "zh_TW".
+func normalizeLocale(locale string) string {
+	parts := strings.SplitN(strings.Replace(locale, "-", "_", 1), "_", 2)
+	parts[0] = strings.ToLower(parts[0])
+	if len(parts) == 2 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+	return strings.Join(parts, "_")
+}
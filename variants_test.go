@@ -0,0 +1,65 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestVariantsPick(t *testing.T) {
+	v := NewVariants(nil, "en-us")
+	v.Register("greeting", "en_US", TextMessage("hello"))
+	v.Register("greeting", "zh", TextMessage("你好"))
+	v.Register("greeting", "zh-tw", TextMessage("您好"))
+
+	cases := []struct {
+		locale, text string
+	}{
+		{"zh_TW", "您好"},
+		{"zh-tw", "您好"},
+		{"zh_HK", "你好"},
+		{"ZH", "你好"},
+		{"fr_FR", "hello"},
+		{"", "hello"},
+	}
+	for _, c := range cases {
+		messages, err := v.Pick("greeting", c.locale)
+		if err != nil || len(messages) != 1 || messages[0].Text != c.text {
+			t.Errorf("expect %s for %q, got %+v, %v", c.text, c.locale, messages, err)
+		}
+	}
+
+	if _, err := v.Pick("farewell", "en"); err == nil {
+		t.Error("expect an unknown set to fail")
+	}
+	v.Register("promo", "ja", TextMessage("セール"))
+	if _, err := v.Pick("promo", "fr"); err == nil {
+		t.Error("expect a set without the locale or the default locale to fail")
+	}
+}
+
+func TestVariantsBuilder(t *testing.T) {
+	profiles := NewMemoryProfileStore()
+	profiles.PutProfile(&UserProfile{Id: "u1", Locale: "zh_TW"})
+	v := NewVariants(profiles, "en")
+	v.Register("greeting", "en", TextMessage("hello"))
+	v.Register("greeting", "zh_TW", TextMessage("您好"))
+
+	if locale := v.LocaleOf("u2"); locale != "en" {
+		t.Errorf("expect the default locale of a user without a profile, got %s", locale)
+	}
+
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	fb := NewFBAmbassador("token", server.Client())
+	for _, userId := range []string{"u1", "u2"} {
+		if err := deliver(fb, userId, v.Builder("greeting", userId)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || !strings.Contains(string(requests[0].Body), "您好") || !strings.Contains(string(requests[1].Body), "hello") {
+		t.Errorf("expect a variant per user locale, got %+v", requests)
+	}
+}
@@ -0,0 +1,131 @@
+package ambassador
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrCodeInvalid         = errors.New("ambassador: invalid verification code")
+	ErrCodeExpired         = errors.New("ambassador: verification code expired")
+	ErrCodeTooManyAttempts = errors.New("ambassador: too many verification attempts")
+)
+
+// codePlatforms render codes as inline code, which is copied by a tap.
+var codePlatforms = map[string]bool{"slack": true, "discord": true, "matrix": true}
+
+type verificationCode struct {
+	code      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// VerificationCodes sends one-time codes to users and validates the codes
+// they reply.
+type VerificationCodes struct {
+	sync.Mutex
+	// Length is the number of digits of a code.
+	Length      int
+	TTL         time.Duration
+	MaxAttempts int
+	// Format renders the text of a code.
+	Format func(code string, ttl time.Duration) string
+	// WATemplate is the name and language of an approved authentication
+	// template, whose copy code button is used on WhatsApp.
+	WATemplate *WATemplate
+	codes      map[string]*verificationCode
+	now        func() time.Time
+}
+
+func NewVerificationCodes() *VerificationCodes {
+	return &VerificationCodes{
+		Length:      6,
+		TTL:         5 * time.Minute,
+		MaxAttempts: 3,
+		Format: func(code string, ttl time.Duration) string {
+			return fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(ttl/time.Minute))
+		},
+		codes: map[string]*verificationCode{},
+		now:   time.Now,
+	}
+}
+
+func (v *VerificationCodes) generate() (code string, err error) {
+	digits := make([]byte, v.Length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
+
+// Send sends a new code to a recipient, which replaces the code sent to the
+// recipient before.
+func (v *VerificationCodes) Send(a Ambassador, recipientId string) (err error) {
+	code, err := v.generate()
+	if err != nil {
+		return
+	}
+	v.Lock()
+	v.codes[recipientId] = &verificationCode{code: code, expiresAt: v.now().Add(v.TTL)}
+	v.Unlock()
+
+	err = deliver(a, recipientId, func(a Ambassador) error {
+		if t, ok := unwrap(a).(WATemplateSender); ok && v.WATemplate != nil {
+			return t.SendWATemplate(NewWATemplate(v.WATemplate.Name, v.WATemplate.Language).AuthenticationCode(code))
+		}
+		if codePlatforms[platformOf(a)] {
+			return a.SendText(v.Format("`"+code+"`", v.TTL))
+		}
+		return a.SendText(v.Format(code, v.TTL))
+	})
+	if err != nil {
+		v.Lock()
+		delete(v.codes, recipientId)
+		v.Unlock()
+	}
+	return
+}
+
+// Verify checks a reply of a user against the code sent to the user. Spaces
+// and dashes in the reply are ignored. A code is used up by a successful
+// verification, its expiry or too many wrong replies.
+func (v *VerificationCodes) Verify(userId, reply string) (err error) {
+	v.Lock()
+	defer v.Unlock()
+	c, ok := v.codes[userId]
+	if !ok {
+		return ErrCodeInvalid
+	}
+	if v.now().After(c.expiresAt) {
+		delete(v.codes, userId)
+		return ErrCodeExpired
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, reply)
+	if subtle.ConstantTimeCompare([]byte(digits), []byte(c.code)) == 1 {
+		delete(v.codes, userId)
+		return
+	}
+	c.attempts++
+	if c.attempts >= v.MaxAttempts {
+		delete(v.codes, userId)
+		return ErrCodeTooManyAttempts
+	}
+	return ErrCodeInvalid
+}
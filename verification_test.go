@@ -0,0 +1,75 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestVerificationCodes(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	codes := NewVerificationCodes()
+	codes.now = func() time.Time { return now }
+
+	a := &recordAmbassador{}
+	if err := codes.Send(a, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	code := codes.codes["u1"].code
+	if len(a.sent) != 1 || a.sent[0] != "u1:Your verification code is "+code+". It expires in 5 minutes." {
+		t.Fatalf("unexpected code message: %v", a.sent)
+	}
+
+	wrong := string('0'+(code[0]-'0'+1)%10) + code[1:]
+	if err := codes.Verify("u1", wrong); err != ErrCodeInvalid {
+		t.Errorf("expect a wrong code to be invalid, got %v", err)
+	}
+	if err := codes.Verify("u1", code[:3]+" "+code[3:]); err != nil {
+		t.Errorf("expect the code with a space to pass, got %v", err)
+	}
+	if err := codes.Verify("u1", code); err != ErrCodeInvalid {
+		t.Errorf("expect a code to be used once, got %v", err)
+	}
+
+	codes.Send(a, "u2")
+	now = now.Add(6 * time.Minute)
+	if err := codes.Verify("u2", codes.codes["u2"].code); err != ErrCodeExpired {
+		t.Errorf("expect an expired code, got %v", err)
+	}
+
+	codes.Send(a, "u3")
+	codes.Verify("u3", "x")
+	codes.Verify("u3", "y")
+	if err := codes.Verify("u3", "z"); err != ErrCodeTooManyAttempts {
+		t.Errorf("expect too many attempts, got %v", err)
+	}
+}
+
+func TestVerificationCodeOnWhatsApp(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	codes := NewVerificationCodes()
+	codes.WATemplate = NewWATemplate("login_code", "en_US")
+
+	if err := codes.Send(NewWhatsAppAmbassador("pn1", "token", server.Client()), "886900000000"); err != nil {
+		t.Fatal(err)
+	}
+	code := codes.codes["886900000000"].code
+	var sent struct {
+		Type     string
+		Template struct {
+			Name       string
+			Components []WAComponent
+		}
+	}
+	json.Unmarshal(server.Requests()[0].Body, &sent)
+	if sent.Type != "template" || sent.Template.Name != "login_code" || len(sent.Template.Components) != 2 {
+		t.Fatalf("expect the authentication template, got %s", server.Requests()[0].Body)
+	}
+	if button := sent.Template.Components[1]; button.SubType != "url" || !strings.Contains(button.Parameters[0].Text, code) {
+		t.Errorf("expect the code on the copy code button, got %+v", button)
+	}
+}
@@ -0,0 +1,408 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const ViberSendMessageURI = "https://chatapi.viber.com/pa/send_message"
+
+// ViberConversationStarted is the payload of the CommandContent of a user
+// opening a conversation with the bot.
+const ViberConversationStarted = "VIBER_CONVERSATION_STARTED"
+
+const (
+	viberMinAPIVersion = 7
+	// viberGroupRows is the number of rows of every element of a rich media
+	// carousel.
+	viberGroupRows = 7
+)
+
+type ViberCallback struct {
+	Event        string       `json:"event"`
+	Timestamp    int64        `json:"timestamp"`
+	MessageToken int64        `json:"message_token"`
+	UserId       string       `json:"user_id"`
+	Sender       ViberUser    `json:"sender"`
+	User         ViberUser    `json:"user"`
+	Message      ViberMessage `json:"message"`
+	Type         string       `json:"type"`
+	Context      string       `json:"context"`
+	Subscribed   bool         `json:"subscribed"`
+}
+
+type ViberUser struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
+
+type ViberMessage struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Media    string `json:"media"`
+	Location struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"location"`
+	TrackingData string `json:"tracking_data"`
+}
+
+// ViberButton is a button of a keyboard or a rich media message.
+type ViberButton struct {
+	Columns    int    `json:"Columns"`
+	Rows       int    `json:"Rows"`
+	Text       string `json:"Text,omitempty"`
+	ActionType string `json:"ActionType"`
+	ActionBody string `json:"ActionBody"`
+	Image      string `json:"Image,omitempty"`
+	Silent     bool   `json:"Silent,omitempty"`
+}
+
+// viberTracking is the tracking data of sent messages which Viber passes back
+// with the reply of a user. Texts matching the payloads of buttons are
+// translated into CommandContent.
+type viberTracking struct {
+	Payloads []string `json:"ambassador_payloads"`
+}
+
+// viberMessage is a staged message, or a pause of WithTyping if it has no
+// type.
+type viberMessage struct {
+	payload  map[string]interface{}
+	payloads []string
+	pause    time.Duration
+}
+
+// ViberAmbassador talks to users of a Viber bot by its REST API.
+type ViberAmbassador struct {
+	sync.Mutex
+	token        string
+	name         string
+	client       *http.Client
+	messages     []viberMessage
+	lastMessages []interface{}
+	sentIds      []string
+	correlation  string
+}
+
+// NewViberAmbassador returns an ambassador sending messages as a bot of a
+// name, which Viber requires in every message.
+func NewViberAmbassador(token, name string, client *http.Client) *ViberAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ViberAmbassador{token: token, name: name, client: client}
+}
+
+func (v *ViberAmbassador) SetCorrelationId(id string) {
+	v.correlation = id
+}
+
+func (v *ViberAmbassador) Platform() string {
+	return "viber"
+}
+
+// Translate turns a callback into messages. The callback of setting the
+// webhook, and subscription changes, are translated into no message.
+func (v *ViberAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var c ViberCallback
+	if err = decodeJSON(limitReader(r), &c); err != nil {
+		return
+	}
+
+	messages = []Message{}
+	token := strconv.FormatInt(c.MessageToken, 10)
+	switch c.Event {
+	case "message":
+		msg := Message{SenderId: c.Sender.Id, MessageId: token, Timestamp: c.Timestamp}
+		switch c.Message.Type {
+		case "text":
+			msg.Content = &TextContent{Text: c.Message.Text}
+			var tracking viberTracking
+			if jsonCodec.Unmarshal([]byte(c.Message.TrackingData), &tracking) == nil {
+				for _, payload := range tracking.Payloads {
+					if payload == c.Message.Text {
+						msg.Content = &CommandContent{Payload: payload}
+						break
+					}
+				}
+			}
+		case "location":
+			msg.Content = &LocationContent{Lat: c.Message.Location.Lat, Lon: c.Message.Location.Lon}
+		default:
+			return
+		}
+		messages = append(messages, msg)
+	case "conversation_started":
+		messages = append(messages, Message{
+			SenderId:  c.User.Id,
+			MessageId: token,
+			Timestamp: c.Timestamp,
+			Content: &CommandContent{
+				Payload:  ViberConversationStarted,
+				Referral: &ReferralContent{Ref: c.Context, Source: "viber", Type: c.Type},
+			},
+		})
+	case "delivered":
+		messages = append(messages, Message{
+			SenderId:  c.UserId,
+			Timestamp: c.Timestamp,
+			Content:   &DeliveryContent{MessageIds: []string{token}, Watermark: c.Timestamp},
+		})
+	case "seen":
+		messages = append(messages, Message{
+			SenderId:  c.UserId,
+			Timestamp: c.Timestamp,
+			Content:   &ReadContent{Watermark: c.Timestamp},
+		})
+	}
+	return
+}
+
+func (v *ViberAmbassador) post(payload interface{}) (token int64, err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", ViberSendMessageURI, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Viber-Auth-Token", v.token)
+	setCorrelationHeader(req, v.correlation)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	buffer := &bytes.Buffer{}
+	io.Copy(buffer, resp.Body)
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("fail to deliver a viber message. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	var result struct {
+		Status        int    `json:"status"`
+		StatusMessage string `json:"status_message"`
+		MessageToken  int64  `json:"message_token"`
+	}
+	jsonCodec.Unmarshal(buffer.Bytes(), &result)
+	if result.Status != 0 {
+		return 0, fmt.Errorf("fail to deliver a viber message: %s", result.StatusMessage)
+	}
+	return result.MessageToken, nil
+}
+
+func (v *ViberAmbassador) stage(payload map[string]interface{}, payloads ...string) {
+	v.Lock()
+	defer v.Unlock()
+	v.messages = append(v.messages, viberMessage{payload: payload, payloads: payloads})
+}
+
+func (v *ViberAmbassador) SendText(text string) (err error) {
+	v.stage(map[string]interface{}{"type": "text", "text": text})
+	return
+}
+
+// viberKeyboard lays out rows of buttons within the six columns of a
+// keyboard.
+func viberKeyboard(rows [][]KeyboardButton) (keyboard map[string]interface{}, payloads []string) {
+	buttons := []ViberButton{}
+	for _, row := range rows {
+		columns := 6 / len(row)
+		if columns == 0 {
+			columns = 1
+		}
+		for _, btn := range row {
+			body := btn.Label
+			if btn.Payload != "" {
+				body = btn.Payload
+				payloads = append(payloads, btn.Payload)
+			}
+			buttons = append(buttons, ViberButton{
+				Columns:    columns,
+				Rows:       1,
+				Text:       btn.Label,
+				ActionType: "reply",
+				ActionBody: body,
+			})
+		}
+	}
+	return map[string]interface{}{"Type": "keyboard", "Buttons": buttons}, payloads
+}
+
+// AskQuestion shows answers as a keyboard of full width buttons.
+func (v *ViberAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	rows := [][]KeyboardButton{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			rows = append(rows, []KeyboardButton{{Label: title, Payload: payload}})
+		}
+	}
+	keyboard, payloads := viberKeyboard(rows)
+	v.stage(map[string]interface{}{"type": "text", "text": text, "keyboard": keyboard}, payloads...)
+	return
+}
+
+func (v *ViberAmbassador) SendKeyboard(text string, kb Keyboard) (err error) {
+	keyboard, payloads := viberKeyboard(kb.Rows)
+	v.stage(map[string]interface{}{"type": "text", "text": text, "keyboard": keyboard}, payloads...)
+	return
+}
+
+// SendTemplate renders elements as a rich media carousel. Every element has
+// an image, its title and text, and up to two buttons.
+func (v *ViberAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	buttons := []ViberButton{}
+	payloads := []string{}
+	for _, col := range colItems {
+		var actions []ViberButton
+		for _, btn := range col.Buttons {
+			if len(actions) == 2 {
+				break
+			}
+			action := ViberButton{Columns: 6, Rows: 1, Text: btn.Label, ActionBody: btn.Data}
+			switch btn.Type {
+			case "url":
+				action.ActionType = "open-url"
+			case "postback":
+				action.ActionType = "reply"
+				payloads = append(payloads, btn.Data)
+			default:
+				continue
+			}
+			actions = append(actions, action)
+		}
+
+		imageRows := 0
+		if col.ImageUrl != "" {
+			imageRows = 3
+			image := ViberButton{Columns: 6, Rows: imageRows, ActionType: "none", Image: col.ImageUrl}
+			if col.ItemUrl != "" {
+				image.ActionType, image.ActionBody = "open-url", col.ItemUrl
+			}
+			buttons = append(buttons, image)
+		}
+		buttons = append(buttons, ViberButton{
+			Columns:    6,
+			Rows:       viberGroupRows - imageRows - len(actions),
+			Text:       fmt.Sprintf("<b>%s</b><br>%s", col.Title, col.Text),
+			ActionType: "none",
+		})
+		buttons = append(buttons, actions...)
+	}
+
+	v.stage(map[string]interface{}{
+		"type": "rich_media",
+		"rich_media": map[string]interface{}{
+			"Type":                "rich_media",
+			"ButtonsGroupColumns": 6,
+			"ButtonsGroupRows":    viberGroupRows,
+			"Buttons":             buttons,
+		},
+	}, payloads...)
+	return
+}
+
+// SendTyping is a no-op since bots can not show typing indicators in Viber.
+func (v *ViberAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (v *ViberAmbassador) WithTyping(d time.Duration) (err error) {
+	v.Lock()
+	defer v.Unlock()
+	v.messages = append(v.messages, viberMessage{pause: d})
+	return
+}
+
+// MarkRead is a no-op since Viber marks messages seen by itself.
+func (v *ViberAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (v *ViberAmbassador) cleanMessage() {
+	v.Lock()
+	defer v.Unlock()
+	v.lastMessages = make([]interface{}, 0, len(v.messages))
+	for _, m := range v.messages {
+		if m.payload != nil {
+			v.lastMessages = append(v.lastMessages, m.payload)
+		}
+	}
+	v.messages = nil
+}
+
+func (v *ViberAmbassador) GetLastSent() []interface{} {
+	return v.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (v *ViberAmbassador) Discard() {
+	v.Lock()
+	last := v.lastMessages
+	v.Unlock()
+	v.cleanMessage()
+	v.Lock()
+	v.lastMessages = last
+	v.Unlock()
+}
+
+// SentMessageIds returns the message tokens of the last send.
+func (v *ViberAmbassador) SentMessageIds() []string {
+	return v.sentIds
+}
+
+// Send sends the staged messages to a user one by one. Every message tracks
+// the payloads of all buttons staged, since a reply carries the tracking data
+// of the last message only.
+func (v *ViberAmbassador) Send(recipientId string) (err error) {
+	defer v.cleanMessage()
+	v.sentIds = nil
+
+	tracking := viberTracking{}
+	for _, m := range v.messages {
+		tracking.Payloads = append(tracking.Payloads, m.payloads...)
+	}
+	trackingData := ""
+	if len(tracking.Payloads) > 0 {
+		b, _ := jsonCodec.Marshal(tracking)
+		trackingData = string(b)
+	}
+
+	for _, m := range v.messages {
+		if m.payload == nil {
+			time.Sleep(m.pause)
+			continue
+		}
+		payload := map[string]interface{}{
+			"receiver":        recipientId,
+			"min_api_version": viberMinAPIVersion,
+			"sender":          map[string]string{"name": v.name},
+		}
+		for k, value := range m.payload {
+			payload[k] = value
+		}
+		if trackingData != "" {
+			payload["tracking_data"] = trackingData
+		}
+		var token int64
+		if token, err = v.post(payload); err != nil {
+			return
+		}
+		v.sentIds = append(v.sentIds, strconv.FormatInt(token, 10))
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestViberQuestion(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	v := NewViberAmbassador("token", "Shop", server.Client())
+
+	v.SendText("hello")
+	v.AskQuestion("size?", []map[string]string{
+		{"title": "S", "payload": "SIZE_S"},
+		{"title": "M", "payload": "SIZE_M"},
+	})
+	if err := v.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Header.Get("X-Viber-Auth-Token") != "token" {
+		t.Fatalf("expect two messages, got %+v", requests)
+	}
+	var sent struct {
+		Receiver     string `json:"receiver"`
+		TrackingData string `json:"tracking_data"`
+		Keyboard     struct {
+			Buttons []ViberButton
+		}
+	}
+	json.Unmarshal(requests[1].Body, &sent)
+	if sent.Receiver != "u1" || len(sent.Keyboard.Buttons) != 2 || sent.Keyboard.Buttons[1].ActionBody != "SIZE_M" {
+		t.Fatalf("unexpected question: %s", requests[1].Body)
+	}
+
+	reply, _ := json.Marshal(map[string]interface{}{
+		"event":         "message",
+		"timestamp":     1700000000000,
+		"message_token": 5001,
+		"sender":        map[string]string{"id": "u1"},
+		"message":       map[string]string{"type": "text", "text": "SIZE_M", "tracking_data": sent.TrackingData},
+	})
+	messages, err := v.Translate(strings.NewReader(string(reply)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_M" {
+		t.Errorf("expect the answer as a command, got %+v", messages[0])
+	}
+
+	messages, _ = v.Translate(strings.NewReader(`{"event":"message","message_token":5002,
+		"sender":{"id":"u1"},"message":{"type":"text","text":"SIZE_M please"}}`))
+	if _, ok := messages[0].Content.(*TextContent); !ok {
+		t.Errorf("expect a typed text to stay a text, got %+v", messages[0])
+	}
+}
+
+func TestViberEvents(t *testing.T) {
+	v := NewViberAmbassador("token", "Shop", nil)
+	messages, _ := v.Translate(strings.NewReader(`{"event":"conversation_started","timestamp":1,
+		"message_token":7,"type":"open","context":"promo","user":{"id":"u1"},"subscribed":false}`))
+	command, ok := messages[0].Content.(*CommandContent)
+	if !ok || command.Payload != ViberConversationStarted || command.Referral.Ref != "promo" {
+		t.Errorf("unexpected conversation started: %+v", messages[0])
+	}
+
+	messages, _ = v.Translate(strings.NewReader(`{"event":"delivered","timestamp":2,"message_token":8,"user_id":"u1"}`))
+	if delivery, ok := messages[0].Content.(*DeliveryContent); !ok || delivery.MessageIds[0] != "8" {
+		t.Errorf("unexpected delivery: %+v", messages[0])
+	}
+
+	messages, _ = v.Translate(strings.NewReader(`{"event":"webhook","timestamp":3}`))
+	if len(messages) != 0 {
+		t.Errorf("expect no message of the webhook callback, got %+v", messages)
+	}
+}
@@ -0,0 +1,160 @@
+package ambassador
+
+import (
+	"sync"
+	"time"
+)
+
+// DeliveryContent is the content of a receipt of messages delivered to a
+// user. All messages sent before the watermark are delivered.
+type DeliveryContent struct {
+	MessageIds []string
+	// Watermark is a timestamp in milliseconds.
+	Watermark int64
+}
+
+// ReadContent is the content of a receipt of messages read by a user. All
+// messages sent before the watermark are read.
+type ReadContent struct {
+	Watermark int64
+}
+
+// MessageIdReporter is implemented by ambassadors which know the ids of the
+// messages of their last send.
+type MessageIdReporter interface {
+	SentMessageIds() []string
+}
+
+type sentMessage struct {
+	recipientId string
+	sentAt      int64
+}
+
+// WatermarkStore keeps sent messages and the delivery and read watermarks of
+// users.
+type WatermarkStore interface {
+	PutSent(messageId, recipientId string, sentAt int64) error
+	GetSent(messageId string) (recipientId string, sentAt int64, ok bool, err error)
+	// Advance raises a watermark of a user, e.g. "delivered" or "read". A
+	// lower watermark is ignored.
+	Advance(kind, userId string, watermark int64) error
+	Watermark(kind, userId string) (int64, error)
+}
+
+type MemoryWatermarkStore struct {
+	sync.Mutex
+	sent       map[string]sentMessage
+	watermarks map[string]int64
+}
+
+func NewMemoryWatermarkStore() *MemoryWatermarkStore {
+	return &MemoryWatermarkStore{sent: map[string]sentMessage{}, watermarks: map[string]int64{}}
+}
+
+func (s *MemoryWatermarkStore) PutSent(messageId, recipientId string, sentAt int64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.sent[messageId] = sentMessage{recipientId: recipientId, sentAt: sentAt}
+	return nil
+}
+
+func (s *MemoryWatermarkStore) GetSent(messageId string) (recipientId string, sentAt int64, ok bool, err error) {
+	s.Lock()
+	defer s.Unlock()
+	m, ok := s.sent[messageId]
+	return m.recipientId, m.sentAt, ok, nil
+}
+
+func (s *MemoryWatermarkStore) Advance(kind, userId string, watermark int64) error {
+	s.Lock()
+	defer s.Unlock()
+	if watermark > s.watermarks[kind+":"+userId] {
+		s.watermarks[kind+":"+userId] = watermark
+	}
+	return nil
+}
+
+func (s *MemoryWatermarkStore) Watermark(kind, userId string) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.watermarks[kind+":"+userId], nil
+}
+
+// WatermarkTracker answers whether sent messages are delivered or read, so
+// that bots can follow up on important messages left unread.
+type WatermarkTracker struct {
+	store WatermarkStore
+	now   func() time.Time
+}
+
+func NewWatermarkTracker(store WatermarkStore) *WatermarkTracker {
+	if store == nil {
+		store = NewMemoryWatermarkStore()
+	}
+	return &WatermarkTracker{store: store, now: time.Now}
+}
+
+// Sent records the messages of the last send of an ambassador to a
+// recipient. Ambassadors which do not report message ids are ignored.
+func (t *WatermarkTracker) Sent(a Ambassador, recipientId string) (err error) {
+	r, ok := unwrap(a).(MessageIdReporter)
+	if !ok {
+		return
+	}
+	sentAt := t.now().UnixNano() / int64(time.Millisecond)
+	for _, id := range r.SentMessageIds() {
+		if err = t.store.PutSent(id, recipientId, sentAt); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Track is a middleware which advances watermarks by delivery and read
+// receipts. Receipts are passed on to the next handler.
+func (t *WatermarkTracker) Track() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(a Ambassador, msg Message) (err error) {
+			switch c := msg.Content.(type) {
+			case *DeliveryContent:
+				watermark := c.Watermark
+				for _, id := range c.MessageIds {
+					if _, sentAt, ok, _ := t.store.GetSent(id); ok && sentAt > watermark {
+						watermark = sentAt
+					}
+				}
+				err = t.store.Advance("delivered", msg.SenderId, watermark)
+			case *ReadContent:
+				if err = t.store.Advance("read", msg.SenderId, c.Watermark); err == nil {
+					// a read message is delivered as well.
+					err = t.store.Advance("delivered", msg.SenderId, c.Watermark)
+				}
+			}
+			if err != nil {
+				return
+			}
+			return next.Handle(a, msg)
+		})
+	}
+}
+
+func (t *WatermarkTracker) passed(kind, messageId string) (ok bool, err error) {
+	recipientId, sentAt, found, err := t.store.GetSent(messageId)
+	if err != nil || !found {
+		return
+	}
+	watermark, err := t.store.Watermark(kind, recipientId)
+	return err == nil && sentAt <= watermark, err
+}
+
+// WasDelivered tells whether a sent message is delivered. Unknown messages
+// are not delivered.
+func (t *WatermarkTracker) WasDelivered(messageId string) (bool, error) {
+	return t.passed("delivered", messageId)
+}
+
+// WasRead tells whether a sent message is read. Unknown messages are not
+// read.
+func (t *WatermarkTracker) WasRead(messageId string) (bool, error) {
+	return t.passed("read", messageId)
+}
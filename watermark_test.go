@@ -0,0 +1,49 @@
+package ambassador
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestWatermarkTracker(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	a := NewFBAmbassador("test-token", server.Client())
+
+	tracker := NewWatermarkTracker(nil)
+	now := time.Unix(1500000000, 0)
+	tracker.now = func() time.Time { return now }
+
+	a.SendText("your order shipped")
+	if err := a.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Sent(a, "u1"); err != nil {
+		t.Fatal(err)
+	}
+	id := a.SentMessageIds()[0]
+
+	h := tracker.Track()(HandlerFunc(func(Ambassador, Message) error { return nil }))
+	body := `{"object":"page","entry":[{"messaging":[
+		{"sender":{"id":"u1"},"delivery":{"mids":["` + id + `"],"watermark":1400000000000}}]}]}`
+	messages, err := a.Translate(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Handle(a, messages[0])
+
+	if ok, _ := tracker.WasDelivered(id); !ok {
+		t.Error("expect the message to be delivered by its mid")
+	}
+	if ok, _ := tracker.WasRead(id); ok {
+		t.Error("expect the message not to be read yet")
+	}
+
+	h.Handle(a, Message{SenderId: "u1", Content: &ReadContent{Watermark: now.Unix() * 1000}})
+	if ok, _ := tracker.WasRead(id); !ok {
+		t.Error("expect the message to be read")
+	}
+}
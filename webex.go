@@ -0,0 +1,319 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const WebexBaseURI = "https://webexapis.com/v1/"
+
+// WebexNotification is a webhook notification, which only carries the ids
+// of a message or a submitted card.
+type WebexNotification struct {
+	Id       string `json:"id"`
+	Resource string `json:"resource"`
+	Event    string `json:"event"`
+	Data     struct {
+		Id          string `json:"id"`
+		RoomId      string `json:"roomId"`
+		RoomType    string `json:"roomType"`
+		PersonId    string `json:"personId"`
+		PersonEmail string `json:"personEmail"`
+		MessageId   string `json:"messageId"`
+		Created     string `json:"created"`
+	} `json:"data"`
+}
+
+// WebexMessage is a message fetched by the id of a notification.
+type WebexMessage struct {
+	Id          string `json:"id"`
+	RoomId      string `json:"roomId"`
+	RoomType    string `json:"roomType"`
+	PersonId    string `json:"personId"`
+	PersonEmail string `json:"personEmail"`
+	ParentId    string `json:"parentId"`
+	Text        string `json:"text"`
+	Created     string `json:"created"`
+}
+
+// WebexAttachmentAction is a card submission fetched by the id of a
+// notification.
+type WebexAttachmentAction struct {
+	Id        string                 `json:"id"`
+	MessageId string                 `json:"messageId"`
+	PersonId  string                 `json:"personId"`
+	RoomId    string                 `json:"roomId"`
+	Inputs    map[string]interface{} `json:"inputs"`
+	Created   string                 `json:"created"`
+}
+
+// webexMessage is a staged message, or a pause of WithTyping if it has no
+// markdown.
+type webexMessage struct {
+	Markdown    string        `json:"markdown"`
+	Attachments []interface{} `json:"attachments,omitempty"`
+	pause       time.Duration
+}
+
+// WebexAmbassador talks to Webex spaces as a bot. Notifications only carry
+// ids, so translating one fetches its message or card submission. Messages
+// of bots, whose emails end with "@webex.bot", are skipped.
+type WebexAmbassador struct {
+	sync.Mutex
+	token        string
+	client       *http.Client
+	messages     []webexMessage
+	lastMessages []interface{}
+	parentId     string
+	correlation  string
+}
+
+func NewWebexAmbassador(token string, client *http.Client) *WebexAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebexAmbassador{token: token, client: client}
+}
+
+func (w *WebexAmbassador) SetCorrelationId(id string) {
+	w.correlation = id
+}
+
+func (w *WebexAmbassador) Platform() string {
+	return "webex"
+}
+
+func webexTimestamp(created string) int64 {
+	t, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Translate fetches the message or the card submission of a notification.
+// Submitted cards of questions and templates come back as CommandContent.
+func (w *WebexAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var n WebexNotification
+	if err = decodeJSON(limitReader(r), &n); err != nil {
+		return
+	}
+	messages = []Message{}
+	if n.Event != "created" || n.Data.Id == "" || strings.HasSuffix(n.Data.PersonEmail, "@webex.bot") {
+		return
+	}
+
+	switch n.Resource {
+	case "messages":
+		var m WebexMessage
+		if err = w.call("GET", "messages/"+url.PathEscape(n.Data.Id), nil, &m); err != nil {
+			return
+		}
+		messages = append(messages, Message{
+			SenderId:  m.PersonId,
+			ChatId:    m.RoomId,
+			MessageId: m.Id,
+			InReplyTo: m.ParentId,
+			Timestamp: webexTimestamp(m.Created),
+			Content:   &TextContent{Text: m.Text},
+		})
+	case "attachmentActions":
+		var a WebexAttachmentAction
+		if err = w.call("GET", "attachment/actions/"+url.PathEscape(n.Data.Id), nil, &a); err != nil {
+			return
+		}
+		payload, _ := a.Inputs["payload"].(string)
+		messages = append(messages, Message{
+			SenderId:  a.PersonId,
+			ChatId:    a.RoomId,
+			InReplyTo: a.MessageId,
+			Timestamp: webexTimestamp(a.Created),
+			Content:   &CommandContent{Payload: payload},
+		})
+	}
+	return
+}
+
+// call calls an api of Webex and decodes the response into v if it is not
+// nil.
+func (w *WebexAmbassador) call(method, path string, payload, v interface{}) (err error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := jsonCodec.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(b)
+	}
+	req, _ := http.NewRequest(method, WebexBaseURI+path, body)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+w.token)
+	setCorrelationHeader(req, w.correlation)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to %s webex %s. status: %s, body: %s", method, path, resp.Status, buffer.String())
+	}
+	if v != nil {
+		return decodeJSON(resp.Body, v)
+	}
+	return
+}
+
+func (w *WebexAmbassador) stage(markdown string, attachments ...interface{}) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, webexMessage{Markdown: markdown, Attachments: attachments})
+}
+
+// webexCard is the attachment of an adaptive card. Webex supports adaptive
+// cards up to 1.3.
+func webexCard(card map[string]interface{}) map[string]interface{} {
+	card["version"] = "1.3"
+	return map[string]interface{}{
+		"contentType": "application/vnd.microsoft.card.adaptive",
+		"content":     card,
+	}
+}
+
+// SendText sends a text as markdown.
+func (w *WebexAmbassador) SendText(text string) (err error) {
+	w.stage(text)
+	return
+}
+
+// AskQuestion sends an adaptive card with a submit button per answer. The
+// markdown of the question is shown by clients without cards.
+func (w *WebexAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	actions := []interface{}{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			actions = append(actions, map[string]interface{}{
+				"type":  "Action.Submit",
+				"title": title,
+				"data":  map[string]string{"payload": payload},
+			})
+		}
+	}
+	w.stage(text, webexCard(map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"body":    []interface{}{map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true}},
+		"actions": actions,
+	}))
+	return
+}
+
+// SendTemplate sends every element as a message with an adaptive card,
+// since a Webex message has one attachment at most.
+func (w *WebexAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+	for _, col := range colItems {
+		markdown := "**" + col.Title + "**"
+		if col.Text != "" {
+			markdown += "\n\n" + col.Text
+		}
+		w.stage(markdown, webexCard(adaptiveCard(col)))
+	}
+	return
+}
+
+// SendTyping is a no-op since bots can not show typing in Webex.
+func (w *WebexAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (w *WebexAmbassador) WithTyping(d time.Duration) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, webexMessage{pause: d})
+	return
+}
+
+// MarkRead is a no-op since bots have no read receipts in Webex.
+func (w *WebexAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+// ReplyTo sends the staged messages in the thread of a message.
+func (w *WebexAmbassador) ReplyTo(messageId string) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.parentId = messageId
+	return
+}
+
+func (w *WebexAmbassador) cleanMessage() {
+	w.Lock()
+	defer w.Unlock()
+	w.lastMessages = make([]interface{}, 0, len(w.messages))
+	for _, m := range w.messages {
+		if m.Markdown != "" {
+			w.lastMessages = append(w.lastMessages, m)
+		}
+	}
+	w.messages = nil
+	w.parentId = ""
+}
+
+func (w *WebexAmbassador) GetLastSent() []interface{} {
+	return w.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (w *WebexAmbassador) Discard() {
+	w.Lock()
+	last := w.lastMessages
+	w.Unlock()
+	w.cleanMessage()
+	w.Lock()
+	w.lastMessages = last
+	w.Unlock()
+}
+
+// Send sends the staged messages to a room, or to a person by an email.
+func (w *WebexAmbassador) Send(recipientId string) (err error) {
+	defer w.cleanMessage()
+	for _, m := range w.messages {
+		if m.Markdown == "" {
+			time.Sleep(m.pause)
+			continue
+		}
+		payload := map[string]interface{}{"markdown": m.Markdown}
+		if strings.Contains(recipientId, "@") {
+			payload["toPersonEmail"] = recipientId
+		} else {
+			payload["roomId"] = recipientId
+		}
+		if m.Attachments != nil {
+			payload["attachments"] = m.Attachments
+		}
+		if w.parentId != "" {
+			payload["parentId"] = w.parentId
+		}
+		if err = w.call("POST", "messages", payload, nil); err != nil {
+			return
+		}
+	}
+	return
+}
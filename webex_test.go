@@ -0,0 +1,69 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestWebexConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	w := NewWebexAmbassador("bot-token", server.Client())
+
+	server.Fail("webexapis.com", testutil.Sequence(&testutil.Failure{
+		Body: `{"id":"m1","roomId":"r1","personId":"p1","personEmail":"u1@example.com","text":"hello","created":"2023-11-14T22:13:20.000Z"}`,
+	}))
+	messages, err := w.Translate(strings.NewReader(`{"resource":"messages","event":"created",
+		"data":{"id":"m1","roomId":"r1","personId":"p1","personEmail":"u1@example.com"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "hello" || messages[0].ChatId != "r1" || messages[0].Timestamp != 1700000000000 {
+		t.Errorf("unexpected message: %+v", messages[0])
+	}
+	if server.Requests()[0].Method != "GET" || server.Requests()[0].Path != "/v1/messages/m1" {
+		t.Errorf("expect the message to be fetched, got %+v", server.Requests()[0])
+	}
+	if messages, _ := w.Translate(strings.NewReader(`{"resource":"messages","event":"created",
+		"data":{"id":"m2","personEmail":"shop@webex.bot"}}`)); len(messages) != 0 || len(server.Requests()) != 1 {
+		t.Errorf("expect a message of a bot to be skipped, got %+v", messages)
+	}
+
+	server.Reset()
+	server.Recover()
+	w.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := w.Send("r1"); err != nil {
+		t.Fatal(err)
+	}
+	var sent struct {
+		RoomId      string `json:"roomId"`
+		Markdown    string `json:"markdown"`
+		Attachments []struct {
+			Content struct {
+				Version string `json:"version"`
+				Actions []struct {
+					Data map[string]string `json:"data"`
+				} `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	json.Unmarshal(server.Requests()[0].Body, &sent)
+	card := sent.Attachments[0].Content
+	if sent.RoomId != "r1" || sent.Markdown != "Which size?" || card.Version != "1.3" || card.Actions[1].Data["payload"] != "SIZE_L" {
+		t.Errorf("unexpected question: %s", server.Requests()[0].Body)
+	}
+
+	server.Fail("webexapis.com", testutil.Sequence(&testutil.Failure{
+		Body: `{"id":"a1","messageId":"m3","personId":"p1","roomId":"r1","inputs":{"payload":"SIZE_L"}}`,
+	}))
+	messages, _ = w.Translate(strings.NewReader(`{"resource":"attachmentActions","event":"created","data":{"id":"a1"}}`))
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].InReplyTo != "m3" {
+		t.Errorf("expect the submitted card as its payload, got %+v", messages[0])
+	}
+}
@@ -0,0 +1,217 @@
+package ambassador
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrMessageDropped is reported by a Webhook for a message which its full
+// buffer drops.
+var ErrMessageDropped = errors.New("ambassador: message dropped by a full ingest buffer")
+
+type OverflowPolicy int
+
+const (
+	// OverflowReject drops incoming messages when the buffer is full.
+	OverflowReject OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered message to make room.
+	OverflowDropOldest
+	// OverflowBlock waits for room, which delays the webhook response.
+	OverflowBlock
+)
+
+// IngestBuffer is a bounded queue between a webhook and the processing of
+// messages, so that traffic spikes are acknowledged to platforms quickly
+// instead of timing out and being redelivered.
+type IngestBuffer struct {
+	queue   chan Message
+	policy  OverflowPolicy
+	metrics Metrics
+	wg      sync.WaitGroup
+}
+
+// NewIngestBuffer starts workers which process buffered messages by a
+// handler. Every message is handled with a new ambassador.
+func NewIngestBuffer(size, workers int, policy OverflowPolicy, newAmbassador func() Ambassador, h Handler, metrics Metrics) *IngestBuffer {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	b := &IngestBuffer{
+		queue:   make(chan Message, size),
+		policy:  policy,
+		metrics: metrics,
+	}
+	for i := 0; i < workers; i++ {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for msg := range b.queue {
+				b.metrics.Gauge("ambassador.ingest.depth", float64(len(b.queue)), nil)
+				a := withCorrelation(newAmbassador(), msg.CorrelationId)
+				if err := h.Handle(a, msg); err != nil {
+					b.metrics.Count("ambassador.ingest.failed", 1, nil)
+				} else {
+					b.metrics.Count("ambassador.ingest.processed", 1, nil)
+				}
+			}
+		}()
+	}
+	return b
+}
+
+// Enqueue buffers a message. It returns false if the message is dropped.
+func (b *IngestBuffer) Enqueue(msg Message) (ok bool) {
+	switch b.policy {
+	case OverflowBlock:
+		b.queue <- msg
+		ok = true
+	case OverflowDropOldest:
+		for !ok {
+			select {
+			case b.queue <- msg:
+				ok = true
+			default:
+				select {
+				case <-b.queue:
+					b.metrics.Count("ambassador.ingest.dropped", 1, nil)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case b.queue <- msg:
+			ok = true
+		default:
+			b.metrics.Count("ambassador.ingest.dropped", 1, nil)
+		}
+	}
+	if ok {
+		b.metrics.Count("ambassador.ingest.enqueued", 1, nil)
+	}
+	return
+}
+
+// Close stops accepting messages and waits for buffered messages to be
+// processed.
+func (b *IngestBuffer) Close() {
+	close(b.queue)
+	b.wg.Wait()
+}
+
+// ChallengeResponder is implemented by ambassadors of platforms which verify
+// a webhook by a challenge request in the body, e.g. Slack.
+type ChallengeResponder interface {
+	Challenge(body []byte) (response []byte, ok bool)
+}
+
+// Webhook is an http.Handler which translates webhook requests by an
+// ambassador and passes messages to a buffer, or to a handler directly if
+// there is no buffer. A batch is acknowledged even if some of its messages
+// fail to be handled or are dropped by the buffer, since a platform would
+// redeliver the whole batch, so those failures are only reported.
+type Webhook struct {
+	NewAmbassador func() Ambassador
+	Handler       Handler
+	Buffer        *IngestBuffer
+	// Freshness drops or flags replayed and delayed events.
+	Freshness *FreshnessWindow
+	// Reporter is told about requests which fail to be translated, and
+	// about messages which fail to be handled or are dropped.
+	Reporter ErrorReporter
+}
+
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxPayloadSize))
+	if err != nil {
+		http.Error(w, ErrPayloadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	correlationId := correlationIdOf(r)
+	w.Header().Set(CorrelationHeader, correlationId)
+
+	a := withCorrelation(wh.NewAmbassador(), correlationId)
+	if c, ok := a.(ChallengeResponder); ok {
+		if response, ok := c.Challenge(body); ok {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(response)
+			return
+		}
+	}
+	messages, err := translate(a, body, wh.Reporter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wh.Freshness != nil {
+		messages = wh.Freshness.filter(messages)
+	}
+
+	receivedAt := time.Now()
+	for _, msg := range messages {
+		msg.CorrelationId = correlationId
+		msg.ReceivedAt = receivedAt
+		if wh.Buffer != nil {
+			if !wh.Buffer.Enqueue(msg) {
+				wh.report(a, ErrMessageDropped, msg)
+			}
+			continue
+		}
+		if err = wh.Handler.Handle(withCorrelation(wh.NewAmbassador(), correlationId), msg); err != nil {
+			wh.report(a, err, msg)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (wh *Webhook) report(a Ambassador, err error, msg Message) {
+	if wh.Reporter == nil {
+		return
+	}
+	wh.Reporter.Report(err, ErrorReport{
+		Platform:    platformOf(a),
+		RecipientId: msg.ReplyTarget(),
+		Payload:     summarize([]byte(fmt.Sprintf("%+v", msg.Content))),
+	})
+}
+
+// translate translates a body and reports errors and panics of Translate.
+// Events of a PartialError are reported one by one, and the messages of the
+// other events are returned without an error.
+func translate(a Ambassador, body []byte, reporter ErrorReporter) (messages []Message, err error) {
+	defer func() {
+		var stack []byte
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v}
+			stack = debug.Stack()
+		}
+		if partial, ok := err.(*PartialError); ok {
+			err = nil
+			if reporter == nil {
+				return
+			}
+			for _, event := range partial.Events {
+				reporter.Report(event.Err, ErrorReport{
+					Platform: platformOf(a),
+					Payload:  event.Payload,
+				})
+			}
+			return
+		}
+		if err != nil && reporter != nil {
+			reporter.Report(err, ErrorReport{
+				Platform: platformOf(a),
+				Payload:  summarize(body),
+				Stack:    stack,
+			})
+		}
+	}()
+	return a.Translate(bytes.NewReader(body))
+}
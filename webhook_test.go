@@ -0,0 +1,184 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestIngestBufferOverflow(t *testing.T) {
+	var mu sync.Mutex
+	block := make(chan struct{})
+	handled := []int64{}
+	b := NewIngestBuffer(2, 1, OverflowDropOldest, func() Ambassador { return &recordAmbassador{} },
+		HandlerFunc(func(a Ambassador, msg Message) error {
+			<-block
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, msg.Timestamp)
+			return nil
+		}), nil)
+
+	// the worker takes the first message and blocks
+	b.Enqueue(Message{Timestamp: 0})
+	for len(b.queue) != 0 {
+		runtime.Gosched()
+	}
+	for i := int64(1); i <= 4; i++ {
+		if !b.Enqueue(Message{Timestamp: i}) {
+			t.Fatalf("message %d should not be rejected", i)
+		}
+	}
+	close(block)
+	b.Close()
+
+	if len(handled) != 3 || handled[1] != 3 || handled[2] != 4 {
+		t.Errorf("expect the oldest messages to be dropped, got %v", handled)
+	}
+}
+
+func TestWebhookPartialTranslation(t *testing.T) {
+	var reports []ErrorReport
+	var handled []string
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewFBAmbassador("token", nil) },
+		Handler: HandlerFunc(func(a Ambassador, msg Message) error {
+			handled = append(handled, msg.SenderId)
+			return nil
+		}),
+		Reporter: ErrorReporterFunc(func(err error, report ErrorReport) {
+			reports = append(reports, report)
+		}),
+	}
+	body := `{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"message":{"mid":"m1","text":"hi"}},
+		{"sender":{"id":"u2"},"recipient":{"id":"p1"},"timestamp":"yesterday"},
+		{"sender":{"id":"u3"},"recipient":{"id":"p1"},"message":{"mid":"m3",
+			"attachments":[{"type":"location","payload":{"coordinates":"here"}}]}}]}]}`
+
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect the batch to be accepted, got %d", rec.Code)
+	}
+	if len(handled) != 1 || handled[0] != "u1" {
+		t.Errorf("expect the good event to be handled, got %v", handled)
+	}
+	if len(reports) != 2 || !strings.Contains(reports[0].Payload, "yesterday") {
+		t.Errorf("expect the bad events to be reported one by one, got %+v", reports)
+	}
+}
+
+func TestWebhookFailures(t *testing.T) {
+	var reports []error
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewFBAmbassador("token", nil) },
+		Handler: HandlerFunc(func(a Ambassador, msg Message) error {
+			if msg.SenderId == "u1" {
+				return errors.New("fail")
+			}
+			return nil
+		}),
+		Reporter: ErrorReporterFunc(func(err error, report ErrorReport) {
+			reports = append(reports, err)
+		}),
+	}
+	body := `{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"message":{"mid":"m1","text":"hi"}},
+		{"sender":{"id":"u2"},"recipient":{"id":"p1"},"message":{"mid":"m2","text":"hi"}}]}]}`
+
+	rec := httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || len(reports) != 1 {
+		t.Errorf("expect a failed message to be reported and the batch to be acknowledged, got %d, %v", rec.Code, reports)
+	}
+
+	block := make(chan struct{})
+	wh.Buffer = NewIngestBuffer(0, 1, OverflowReject, func() Ambassador { return &recordAmbassador{} },
+		HandlerFunc(func(a Ambassador, msg Message) error {
+			<-block
+			return nil
+		}), nil)
+	reports = nil
+	rec = httptest.NewRecorder()
+	wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	close(block)
+	wh.Buffer.Close()
+	if rec.Code != http.StatusOK || len(reports) == 0 || reports[0] != ErrMessageDropped {
+		t.Errorf("expect dropped messages to be reported, got %d, %v", rec.Code, reports)
+	}
+
+	rec = httptest.NewRecorder()
+	large := strings.Repeat(" ", MaxPayloadSize+1)
+	wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(large)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect a payload over the limit to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestWebhookMoreAnswers(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	answers := []map[string]string{}
+	for i := 0; i < fbMaxQuickReplies+2; i++ {
+		answers = append(answers, map[string]string{
+			"content_type": "text",
+			"title":        fmt.Sprintf("answer %d", i),
+			"payload":      fmt.Sprintf("ANSWER_%d", i),
+		})
+	}
+	wh := &Webhook{
+		NewAmbassador: func() Ambassador { return NewFBAmbassador("token", server.Client()) },
+		Handler: HandlerFunc(func(a Ambassador, msg Message) error {
+			switch c := msg.Content.(type) {
+			case *MoreAnswersContent:
+				a.AskQuestion(c.Text, c.Answers)
+			default:
+				a.AskQuestion("question", answers)
+			}
+			return a.Send(msg.ReplyTarget())
+		}),
+	}
+	post := func(body string) {
+		rec := httptest.NewRecorder()
+		wh.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+
+	post(`{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"message":{"mid":"m1","text":"hi"}}]}]}`)
+	var question struct {
+		Message struct {
+			QuickReplies []map[string]string `json:"quick_replies"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(server.Requests()[0].Body, &question); err != nil {
+		t.Fatal(err)
+	}
+	more := question.Message.QuickReplies[fbMaxQuickReplies-1]["payload"]
+
+	post(`{"object":"page","entry":[{"id":"p1","messaging":[
+		{"sender":{"id":"u1"},"recipient":{"id":"p1"},"message":{"mid":"m2","quick_reply":{"payload":"` + more + `"}}},
+		{"sender":{"id":"u2"},"recipient":{"id":"p1"},"message":{"mid":"m3","text":"hi"}}]}]}`)
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("expect a request per reply, got %d", len(requests))
+	}
+	page := string(requests[1].Body)
+	if !strings.Contains(page, `"id":"u1"`) || !strings.Contains(page, "ANSWER_12") || strings.Contains(page, "ANSWER_0") {
+		t.Errorf("expect the next page to be sent to the user who asked for it, got %s", page)
+	}
+	if other := string(requests[2].Body); !strings.Contains(other, `"id":"u2"`) || strings.Contains(other, "ANSWER_12") {
+		t.Errorf("expect another user of the batch to get the first page only, got %s", other)
+	}
+}
@@ -0,0 +1,369 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WeChatCustomSendURI   = "https://api.weixin.qq.com/cgi-bin/message/custom/send"
+	WeChatCustomTypingURI = "https://api.weixin.qq.com/cgi-bin/message/custom/typing"
+)
+
+// WeChatEvent is an XML message or event pushed to an official account. In
+// the secure mode only Encrypt is set, which decrypts into another event.
+type WeChatEvent struct {
+	XMLName      xml.Name `xml:"xml"`
+	ToUserName   string   `xml:"ToUserName"`
+	FromUserName string   `xml:"FromUserName"`
+	CreateTime   int64    `xml:"CreateTime"`
+	MsgType      string   `xml:"MsgType"`
+	MsgId        string   `xml:"MsgId"`
+	Content      string   `xml:"Content"`
+	// BizMsgMenuId is the id of a tapped option of a menu message.
+	BizMsgMenuId string  `xml:"bizmsgmenuid"`
+	LocationX    float64 `xml:"Location_X"`
+	LocationY    float64 `xml:"Location_Y"`
+	Event        string  `xml:"Event"`
+	EventKey     string  `xml:"EventKey"`
+	Latitude     float64 `xml:"Latitude"`
+	Longitude    float64 `xml:"Longitude"`
+	Encrypt      string  `xml:"Encrypt"`
+}
+
+// wechatMessage is a staged message, or a pause of WithTyping if it has no
+// type.
+type wechatMessage struct {
+	payload map[string]interface{}
+	pause   time.Duration
+}
+
+// WeChatAmbassador talks to followers of an official account by the customer
+// service message API. The token is an access token of the account, which
+// the caller refreshes.
+type WeChatAmbassador struct {
+	sync.Mutex
+	token        string
+	client       *http.Client
+	aesKey       []byte
+	appId        string
+	messages     []wechatMessage
+	lastMessages []interface{}
+	correlation  string
+}
+
+func NewWeChatAmbassador(token string, client *http.Client) *WeChatAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WeChatAmbassador{token: token, client: client}
+}
+
+// SetAESKey enables decrypting events of the secure mode by the
+// EncodingAESKey and the app id of the account.
+func (w *WeChatAmbassador) SetAESKey(encodingAESKey, appId string) (err error) {
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("invalid wechat EncodingAESKey")
+	}
+	w.aesKey = key
+	w.appId = appId
+	return
+}
+
+func (w *WeChatAmbassador) SetCorrelationId(id string) {
+	w.correlation = id
+}
+
+func (w *WeChatAmbassador) Platform() string {
+	return "wechat"
+}
+
+// decrypt opens an encrypted event, which is a random prefix of 16 bytes,
+// the length of the event, the event and the app id padded by PKCS#7.
+func (w *WeChatAmbassador) decrypt(encrypted string) (plain []byte, err error) {
+	if w.aesKey == nil {
+		return nil, fmt.Errorf("fail to decrypt a wechat event: no aes key")
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrMalformedPayload
+	}
+	block, err := aes.NewCipher(w.aesKey)
+	if err != nil {
+		return
+	}
+	cipher.NewCBCDecrypter(block, w.aesKey[:aes.BlockSize]).CryptBlocks(data, data)
+
+	pad := int(data[len(data)-1])
+	if pad < 1 || pad > 32 || pad > len(data) {
+		return nil, ErrMalformedPayload
+	}
+	data = data[:len(data)-pad]
+	if len(data) < 20 {
+		return nil, ErrMalformedPayload
+	}
+	size := int(binary.BigEndian.Uint32(data[16:20]))
+	if size > len(data)-20 {
+		return nil, ErrMalformedPayload
+	}
+	if string(data[20+size:]) != w.appId {
+		return nil, ErrInvalidSignature
+	}
+	return data[20 : 20+size], nil
+}
+
+// Translate turns an XML event into messages. Menu options, menu clicks and
+// subscriptions are translated into CommandContent and FollowContent.
+func (w *WeChatAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var e WeChatEvent
+	if err = xml.NewDecoder(limitReader(r)).Decode(&e); err != nil {
+		return
+	}
+	if e.Encrypt != "" {
+		var plain []byte
+		if plain, err = w.decrypt(e.Encrypt); err != nil {
+			return
+		}
+		if err = xml.Unmarshal(plain, &e); err != nil {
+			return
+		}
+	}
+
+	messages = []Message{}
+	msg := Message{
+		SenderId:    e.FromUserName,
+		RecipientId: e.ToUserName,
+		MessageId:   e.MsgId,
+		Timestamp:   e.CreateTime * 1000,
+	}
+	switch e.MsgType {
+	case "text":
+		if e.BizMsgMenuId != "" {
+			msg.Content = &CommandContent{Payload: e.BizMsgMenuId}
+		} else {
+			msg.Content = &TextContent{Text: e.Content}
+		}
+	case "location":
+		msg.Content = &LocationContent{Lat: e.LocationX, Lon: e.LocationY}
+	case "event":
+		switch e.Event {
+		case "subscribe":
+			msg.Content = &FollowContent{}
+		case "CLICK":
+			msg.Content = &CommandContent{Payload: e.EventKey}
+		case "LOCATION":
+			msg.Content = &LocationContent{Lat: e.Latitude, Lon: e.Longitude}
+		default:
+			return
+		}
+	default:
+		return
+	}
+	messages = append(messages, msg)
+	return
+}
+
+func (w *WeChatAmbassador) call(uri string, payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", uri+"?access_token="+url.QueryEscape(w.token), bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	setCorrelationHeader(req, w.correlation)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	buffer := &bytes.Buffer{}
+	io.Copy(buffer, resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fail to call wechat. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	jsonCodec.Unmarshal(buffer.Bytes(), &result)
+	if result.ErrCode != 0 {
+		return fmt.Errorf("fail to call wechat: %d %s", result.ErrCode, result.ErrMsg)
+	}
+	return
+}
+
+func (w *WeChatAmbassador) stage(msgType string, body interface{}) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, wechatMessage{payload: map[string]interface{}{
+		"msgtype": msgType,
+		msgType:   body,
+	}})
+}
+
+func (w *WeChatAmbassador) SendText(text string) (err error) {
+	w.stage("text", map[string]string{"content": text})
+	return
+}
+
+// AskQuestion sends a menu message whose tapped option comes back as a
+// CommandContent of its payload.
+func (w *WeChatAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	options := []map[string]string{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			options = append(options, map[string]string{"id": payload, "content": title})
+		}
+	}
+	w.stage("msgmenu", map[string]interface{}{
+		"head_content": text,
+		"list":         options,
+		"tail_content": "",
+	})
+	return
+}
+
+// SendTemplate sends every element as a news article, since an article
+// message may hold one article only.
+func (w *WeChatAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	for _, col := range colItems {
+		link := col.ItemUrl
+		for _, btn := range col.Buttons {
+			if link == "" && btn.Type == "url" {
+				link = btn.Data
+			}
+		}
+		w.stage("news", map[string]interface{}{
+			"articles": []map[string]string{{
+				"title":       col.Title,
+				"description": col.Text,
+				"url":         link,
+				"picurl":      col.ImageUrl,
+			}},
+		})
+	}
+	return
+}
+
+// SendTyping needs the recipient, so it is a no-op. Use WithTyping instead.
+func (w *WeChatAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping shows the typing indicator for a duration before the messages
+// staged after it are sent.
+func (w *WeChatAmbassador) WithTyping(d time.Duration) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, wechatMessage{pause: d})
+	return
+}
+
+// MarkRead is a no-op since official accounts have no read receipts.
+func (w *WeChatAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (w *WeChatAmbassador) cleanMessage() {
+	w.Lock()
+	defer w.Unlock()
+	w.lastMessages = make([]interface{}, 0, len(w.messages))
+	for _, m := range w.messages {
+		if m.payload != nil {
+			w.lastMessages = append(w.lastMessages, m.payload)
+		}
+	}
+	w.messages = nil
+}
+
+func (w *WeChatAmbassador) GetLastSent() []interface{} {
+	return w.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (w *WeChatAmbassador) Discard() {
+	w.Lock()
+	last := w.lastMessages
+	w.Unlock()
+	w.cleanMessage()
+	w.Lock()
+	w.lastMessages = last
+	w.Unlock()
+}
+
+// Send sends the staged messages to a follower by the open id one by one.
+func (w *WeChatAmbassador) Send(recipientId string) (err error) {
+	defer w.cleanMessage()
+	for _, m := range w.messages {
+		if m.payload == nil {
+			if err = w.call(WeChatCustomTypingURI, map[string]string{"touser": recipientId, "command": "Typing"}); err != nil {
+				return
+			}
+			time.Sleep(m.pause)
+			continue
+		}
+		payload := map[string]interface{}{"touser": recipientId}
+		for k, v := range m.payload {
+			payload[k] = v
+		}
+		if err = w.call(WeChatCustomSendURI, payload); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// WeChatSignature is the signature of a request, which is the SHA-1 of the
+// sorted token, timestamp, nonce and, in the secure mode, the encrypted
+// event.
+func WeChatSignature(token string, parts ...string) string {
+	parts = append([]string{token}, parts...)
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyWeChat answers the verification request of the server url and
+// rejects requests without a valid signature before passing them to next,
+// e.g. a Webhook.
+func VerifyWeChat(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if WeChatSignature(token, q.Get("timestamp"), q.Get("nonce")) != q.Get("signature") {
+			http.Error(w, ErrInvalidSignature.Error(), http.StatusForbidden)
+			return
+		}
+		if r.Method == "GET" {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, q.Get("echostr"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
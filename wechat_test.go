@@ -0,0 +1,100 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+// encryptWeChat seals an event as the secure mode of WeChat does.
+func encryptWeChat(key []byte, appId, event string) string {
+	plain := bytes.Repeat([]byte("r"), 16)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(event)))
+	plain = append(append(append(plain, size...), event...), appId...)
+	pad := 32 - len(plain)%32
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(plain, plain)
+	return base64.StdEncoding.EncodeToString(plain)
+}
+
+func TestWeChatTranslate(t *testing.T) {
+	w := NewWeChatAmbassador("token", nil)
+	messages, err := w.Translate(strings.NewReader(`<xml><ToUserName><![CDATA[gh_1]]></ToUserName>
+		<FromUserName><![CDATA[o1]]></FromUserName><CreateTime>1700000000</CreateTime>
+		<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[Large]]></Content>
+		<MsgId>42</MsgId><bizmsgmenuid>SIZE_L</bizmsgmenuid></xml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].SenderId != "o1" {
+		t.Errorf("expect a menu option as a command, got %+v", messages[0])
+	}
+
+	key := bytes.Repeat([]byte("k"), 32)
+	if err := w.SetAESKey(strings.TrimSuffix(base64.StdEncoding.EncodeToString(key), "="), "wx1"); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := encryptWeChat(key, "wx1", `<xml><FromUserName>o1</FromUserName><MsgType>event</MsgType>
+		<Event>CLICK</Event><EventKey>MENU_ORDERS</EventKey></xml>`)
+	messages, err = w.Translate(strings.NewReader("<xml><ToUserName>gh_1</ToUserName><Encrypt>" + encrypted + "</Encrypt></xml>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "MENU_ORDERS" {
+		t.Errorf("expect a decrypted click, got %+v", messages[0])
+	}
+
+	encrypted = encryptWeChat(key, "wx2", `<xml><MsgType>text</MsgType></xml>`)
+	if _, err := w.Translate(strings.NewReader("<xml><Encrypt>" + encrypted + "</Encrypt></xml>")); err != ErrInvalidSignature {
+		t.Errorf("expect an event of another app to be rejected, got %v", err)
+	}
+}
+
+func TestWeChatSend(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	w := NewWeChatAmbassador("access", server.Client())
+
+	w.SendTemplate([]Carousel{
+		{Title: "Tea", Text: "Oolong", ImageUrl: "https://example.com/tea.jpg", ItemUrl: "https://example.com/tea"},
+		{Title: "Cake", ImageUrl: "https://example.com/cake.jpg", Buttons: []CarouselButton{{Type: "url", Data: "https://example.com/cake"}}},
+	})
+	if err := w.Send("o1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Query != "access_token=access" {
+		t.Fatalf("expect an article per element, got %+v", requests)
+	}
+	if !strings.Contains(string(requests[1].Body), `"url":"https://example.com/cake"`) {
+		t.Errorf("expect the url button as the link of an article, got %s", requests[1].Body)
+	}
+}
+
+func TestVerifyWeChat(t *testing.T) {
+	h := VerifyWeChat("secret", http.NotFoundHandler())
+	signature := WeChatSignature("secret", "1700000000", "n1")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?timestamp=1700000000&nonce=n1&echostr=hello&signature="+signature, nil))
+	if rec.Body.String() != "hello" {
+		t.Errorf("expect the echostr, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/?timestamp=1700000000&nonce=n1&signature=bad", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expect a bad signature to be rejected, got %d", rec.Code)
+	}
+}
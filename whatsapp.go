@@ -0,0 +1,512 @@
+package ambassador
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const WhatsAppBaseURI = "https://graph.facebook.com/v17.0/"
+
+const (
+	waMaxButtons  = 3
+	waMaxListRows = 10
+)
+
+type WAObject struct {
+	Object string    `json:"object"`
+	Entry  []WAEntry `json:"entry"`
+}
+
+type WAEntry struct {
+	Id      string     `json:"id"`
+	Changes []WAChange `json:"changes"`
+}
+
+type WAChange struct {
+	Field string  `json:"field"`
+	Value WAValue `json:"value"`
+}
+
+type WAValue struct {
+	Metadata struct {
+		PhoneNumberId string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Messages []WAMessage `json:"messages"`
+	Statuses []WAStatus  `json:"statuses"`
+}
+
+type WAMessage struct {
+	From      string `json:"from"`
+	Id        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Location struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"location"`
+	Interactive struct {
+		Type        string  `json:"type"`
+		ButtonReply WAReply `json:"button_reply"`
+		ListReply   WAReply `json:"list_reply"`
+	} `json:"interactive"`
+	// Button is a tapped quick reply button of a template.
+	Button struct {
+		Payload string `json:"payload"`
+		Text    string `json:"text"`
+	} `json:"button"`
+	Contacts []WAContact `json:"contacts"`
+	Order    *WAOrder    `json:"order"`
+	Context  *struct {
+		Id string `json:"id"`
+		// ReferredProduct is the product a user asks about from a catalog.
+		ReferredProduct *struct {
+			CatalogId         string `json:"catalog_id"`
+			ProductRetailerId string `json:"product_retailer_id"`
+		} `json:"referred_product"`
+	} `json:"context"`
+}
+
+type WAContact struct {
+	Name struct {
+		FormattedName string `json:"formatted_name"`
+		FirstName     string `json:"first_name"`
+		LastName      string `json:"last_name"`
+	} `json:"name"`
+	Org struct {
+		Company string `json:"company"`
+	} `json:"org"`
+	Phones []struct {
+		Phone string `json:"phone"`
+		Type  string `json:"type"`
+		WaId  string `json:"wa_id"`
+	} `json:"phones"`
+	Emails []struct {
+		Email string `json:"email"`
+	} `json:"emails"`
+}
+
+func (c *WAContact) contact() Contact {
+	contact := Contact{
+		Name:         c.Name.FormattedName,
+		FirstName:    c.Name.FirstName,
+		LastName:     c.Name.LastName,
+		Organization: c.Org.Company,
+	}
+	for _, p := range c.Phones {
+		contact.Phones = append(contact.Phones, ContactPhone{Number: p.Phone, Type: p.Type, UserId: p.WaId})
+	}
+	for _, e := range c.Emails {
+		contact.Emails = append(contact.Emails, e.Email)
+	}
+	return contact
+}
+
+// WAOrder is a cart a user sends from a catalog.
+type WAOrder struct {
+	CatalogId    string `json:"catalog_id"`
+	Text         string `json:"text"`
+	ProductItems []struct {
+		ProductRetailerId string  `json:"product_retailer_id"`
+		Quantity          int     `json:"quantity"`
+		ItemPrice         float64 `json:"item_price"`
+		Currency          string  `json:"currency"`
+	} `json:"product_items"`
+}
+
+func (o *WAOrder) content() *ProductContent {
+	content := &ProductContent{CatalogId: o.CatalogId, Text: o.Text}
+	for _, item := range o.ProductItems {
+		content.Products = append(content.Products, Product{
+			RetailerId: item.ProductRetailerId,
+			Quantity:   item.Quantity,
+			Price:      item.ItemPrice,
+			Currency:   item.Currency,
+		})
+	}
+	return content
+}
+
+type WAReply struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type WAStatus struct {
+	Id          string `json:"id"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientId string `json:"recipient_id"`
+}
+
+// waPause delays the messages staged after it.
+type waPause time.Duration
+
+// WhatsAppAmbassador talks to users by the WhatsApp Cloud API on behalf of a
+// business phone number.
+type WhatsAppAmbassador struct {
+	sync.Mutex
+	// Pages keeps paginated questions. Without it, they are kept in the
+	// DefaultPageStore.
+	Pages         PageStore
+	phoneNumberId string
+	token         string
+	client        *http.Client
+	messages      []interface{}
+	lastMessages  []interface{}
+	replyTo       string
+	preview       bool
+	correlation   string
+}
+
+func NewWhatsAppAmbassador(phoneNumberId, token string, client *http.Client) *WhatsAppAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WhatsAppAmbassador{phoneNumberId: phoneNumberId, token: token, client: client}
+}
+
+func (w *WhatsAppAmbassador) SetCorrelationId(id string) {
+	w.correlation = id
+}
+
+func (w *WhatsAppAmbassador) Platform() string {
+	return "whatsapp"
+}
+
+func waTimestamp(s string) int64 {
+	sec, _ := strconv.ParseInt(s, 10, 64)
+	return sec * 1000
+}
+
+// Translate turns webhook notifications into messages. Delivery and read
+// statuses of sent messages are translated into DeliveryContent and
+// ReadContent. Shared contacts become ContactContent, and carts and
+// questions about a product become ProductContent.
+func (w *WhatsAppAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var v WAObject
+	if err = decodeJSON(limitReader(r), &v); err != nil {
+		return
+	}
+	var events int
+	for _, entry := range v.Entry {
+		for _, change := range entry.Changes {
+			events += len(change.Value.Messages) + len(change.Value.Statuses)
+		}
+	}
+	if events > MaxEventsPerPayload {
+		return nil, ErrTooManyEvents
+	}
+
+	messages = make([]Message, 0, events)
+	for _, entry := range v.Entry {
+		for _, change := range entry.Changes {
+			for _, m := range change.Value.Messages {
+				msg := Message{
+					SenderId:    m.From,
+					RecipientId: change.Value.Metadata.PhoneNumberId,
+					MessageId:   m.Id,
+					Timestamp:   waTimestamp(m.Timestamp),
+				}
+				if m.Context != nil {
+					msg.InReplyTo = m.Context.Id
+				}
+				switch m.Type {
+				case "text":
+					if m.Context != nil && m.Context.ReferredProduct != nil {
+						msg.Content = &ProductContent{
+							CatalogId: m.Context.ReferredProduct.CatalogId,
+							Products:  []Product{{RetailerId: m.Context.ReferredProduct.ProductRetailerId}},
+							Text:      m.Text.Body,
+						}
+					} else {
+						msg.Content = &TextContent{Text: m.Text.Body}
+					}
+				case "contacts":
+					contacts := make([]Contact, 0, len(m.Contacts))
+					for _, c := range m.Contacts {
+						contacts = append(contacts, c.contact())
+					}
+					msg.Content = &ContactContent{Contacts: contacts}
+				case "order":
+					if m.Order != nil {
+						msg.Content = m.Order.content()
+					}
+				case "location":
+					msg.Content = &LocationContent{Lat: m.Location.Latitude, Lon: m.Location.Longitude}
+				case "button":
+					msg.Content = &CommandContent{Payload: m.Button.Payload}
+				case "interactive":
+					payload := m.Interactive.ButtonReply.Id
+					if m.Interactive.Type == "list_reply" {
+						payload = m.Interactive.ListReply.Id
+					}
+					if text, offset, page, ok := nextPage(w.Pages, payload, waMaxListRows); ok {
+						msg.Content = &MoreAnswersContent{Text: text, Offset: offset, Answers: page}
+					} else {
+						msg.Content = &CommandContent{Payload: payload}
+					}
+				}
+				messages = append(messages, msg)
+			}
+			for _, s := range change.Value.Statuses {
+				msg := Message{
+					SenderId:    s.RecipientId,
+					RecipientId: change.Value.Metadata.PhoneNumberId,
+					Timestamp:   waTimestamp(s.Timestamp),
+				}
+				switch s.Status {
+				case "delivered":
+					msg.Content = &DeliveryContent{MessageIds: []string{s.Id}, Watermark: msg.Timestamp}
+				case "read":
+					msg.Content = &ReadContent{Watermark: msg.Timestamp}
+				default:
+					continue
+				}
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return
+}
+
+func (w *WhatsAppAmbassador) post(payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", WhatsAppBaseURI+w.phoneNumberId+"/messages", bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.token)
+	setCorrelationHeader(req, w.correlation)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		buffer := &bytes.Buffer{}
+		io.Copy(buffer, resp.Body)
+		return fmt.Errorf("fail to deliver a whatsapp message. status: %s, body: %s",
+			resp.Status, buffer.String())
+	}
+	return
+}
+
+func (w *WhatsAppAmbassador) stage(message map[string]interface{}) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, message)
+}
+
+func (w *WhatsAppAmbassador) SendText(text string) (err error) {
+	w.stage(map[string]interface{}{
+		"type": "text",
+		"text": map[string]interface{}{"body": text},
+	})
+	return
+}
+
+// AskQuestion sends up to three answers as reply buttons and up to ten as a
+// list. Answers beyond ten are paginated behind a "More…" row.
+func (w *WhatsAppAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	page, err := paginate(w.Pages, text, answers, waMaxListRows)
+	if err != nil {
+		return
+	}
+	w.askQuestion(text, page)
+	return
+}
+
+func (w *WhatsAppAmbassador) askQuestion(text string, answers []map[string]string) {
+	replies := []WAReply{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			replies = append(replies, WAReply{Id: payload, Title: title})
+		}
+	}
+
+	var interactive map[string]interface{}
+	if len(replies) <= waMaxButtons {
+		buttons := []interface{}{}
+		for _, r := range replies {
+			buttons = append(buttons, map[string]interface{}{"type": "reply", "reply": r})
+		}
+		interactive = map[string]interface{}{
+			"type":   "button",
+			"body":   map[string]string{"text": text},
+			"action": map[string]interface{}{"buttons": buttons},
+		}
+	} else {
+		interactive = map[string]interface{}{
+			"type": "list",
+			"body": map[string]string{"text": text},
+			"action": map[string]interface{}{
+				"button":   "Choose",
+				"sections": []interface{}{map[string]interface{}{"rows": replies}},
+			},
+		}
+	}
+	w.stage(map[string]interface{}{"type": "interactive", "interactive": interactive})
+}
+
+// SendTemplate sends every element as a message with its image, and with a
+// call to action button of its url.
+func (w *WhatsAppAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	for _, col := range colItems {
+		text := "*" + col.Title + "*"
+		if col.Text != "" {
+			text += "\n" + col.Text
+		}
+		label, link := "Open", col.ItemUrl
+		for _, btn := range col.Buttons {
+			if btn.Type == "url" {
+				label, link = btn.Label, btn.Data
+				break
+			}
+		}
+
+		if link == "" {
+			if col.ImageUrl != "" {
+				w.stage(map[string]interface{}{
+					"type":  "image",
+					"image": map[string]string{"link": col.ImageUrl, "caption": text},
+				})
+			} else {
+				w.SendText(text)
+			}
+			continue
+		}
+
+		interactive := map[string]interface{}{
+			"type": "cta_url",
+			"body": map[string]string{"text": text},
+			"action": map[string]interface{}{
+				"name":       "cta_url",
+				"parameters": map[string]string{"display_text": label, "url": link},
+			},
+		}
+		if col.ImageUrl != "" {
+			interactive["header"] = map[string]interface{}{
+				"type":  "image",
+				"image": map[string]string{"link": col.ImageUrl},
+			}
+		}
+		w.stage(map[string]interface{}{"type": "interactive", "interactive": interactive})
+	}
+	return
+}
+
+// SendWATemplate stages a pre-approved template, which is the only message
+// allowed outside the 24 hour customer service window.
+func (w *WhatsAppAmbassador) SendWATemplate(t *WATemplate) (err error) {
+	w.stage(map[string]interface{}{"type": "template", "template": t})
+	return
+}
+
+// SendTyping is a no-op since typing indicators can only be shown while
+// marking a message as read.
+func (w *WhatsAppAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (w *WhatsAppAmbassador) WithTyping(d time.Duration) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.messages = append(w.messages, waPause(d))
+	return
+}
+
+func (w *WhatsAppAmbassador) MarkRead(msg Message) (err error) {
+	return w.post(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"status":            "read",
+		"message_id":        msg.MessageId,
+	})
+}
+
+// ReplyTo quotes a message in the staged messages.
+func (w *WhatsAppAmbassador) ReplyTo(messageId string) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.replyTo = messageId
+	return
+}
+
+// SetLinkPreview turns the preview of the first url of texts on or off.
+func (w *WhatsAppAmbassador) SetLinkPreview(enabled bool) (err error) {
+	w.Lock()
+	defer w.Unlock()
+	w.preview = enabled
+	return
+}
+
+func (w *WhatsAppAmbassador) cleanMessage() {
+	w.Lock()
+	defer w.Unlock()
+	w.replyTo = ""
+	w.preview = false
+	w.lastMessages = w.messages
+	w.messages = []interface{}{}
+}
+
+func (w *WhatsAppAmbassador) GetLastSent() []interface{} {
+	return w.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (w *WhatsAppAmbassador) Discard() {
+	w.Lock()
+	last := w.lastMessages
+	w.Unlock()
+	w.cleanMessage()
+	w.Lock()
+	w.lastMessages = last
+	w.Unlock()
+}
+
+// Send sends the staged messages to a phone number one by one.
+func (w *WhatsAppAmbassador) Send(recipientId string) (err error) {
+	defer w.cleanMessage()
+	for _, m := range w.messages {
+		if pause, ok := m.(waPause); ok {
+			time.Sleep(time.Duration(pause))
+			continue
+		}
+		message := m.(map[string]interface{})
+		payload := map[string]interface{}{
+			"messaging_product": "whatsapp",
+			"recipient_type":    "individual",
+			"to":                recipientId,
+		}
+		for k, v := range message {
+			payload[k] = v
+		}
+		if text, ok := message["text"].(map[string]interface{}); ok && w.preview {
+			text["preview_url"] = true
+		}
+		if w.replyTo != "" {
+			payload["context"] = map[string]string{"message_id": w.replyTo}
+		}
+		if err = w.post(payload); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// WATemplate is a pre-approved WhatsApp template message. Business initiated
+// conversations can only be started by templates.
+type WATemplate struct {
+	Name string
+	// Language is the language code of the approved translation, e.g.
+	// "en_US".
+	Language   string
+	Components []WAComponent
+}
+
+type WAComponent struct {
+	Type       string        `json:"type"`
+	SubType    string        `json:"sub_type,omitempty"`
+	Index      string        `json:"index,omitempty"`
+	Parameters []WAParameter `json:"parameters,omitempty"`
+}
+
+type WAParameter struct {
+	Type          string `json:"type"`
+	ParameterName string `json:"parameter_name,omitempty"`
+	Text          string `json:"text,omitempty"`
+	Payload       string `json:"payload,omitempty"`
+}
+
+func NewWATemplate(name, language string) *WATemplate {
+	return &WATemplate{Name: name, Language: language}
+}
+
+func namedParameters(params map[string]string) []WAParameter {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parameters := make([]WAParameter, 0, len(params))
+	for _, name := range names {
+		parameters = append(parameters, WAParameter{Type: "text", ParameterName: name, Text: params[name]})
+	}
+	return parameters
+}
+
+// Header fills the named parameters of the header.
+func (t *WATemplate) Header(params map[string]string) *WATemplate {
+	t.Components = append(t.Components, WAComponent{Type: "header", Parameters: namedParameters(params)})
+	return t
+}
+
+// Body fills the named parameters of the body.
+func (t *WATemplate) Body(params map[string]string) *WATemplate {
+	t.Components = append(t.Components, WAComponent{Type: "body", Parameters: namedParameters(params)})
+	return t
+}
+
+// QuickReply sets the payload of the quick reply button at an index, which
+// comes back as a CommandContent when the button is tapped.
+func (t *WATemplate) QuickReply(index int, payload string) *WATemplate {
+	t.Components = append(t.Components, WAComponent{
+		Type:       "button",
+		SubType:    "quick_reply",
+		Index:      strconv.Itoa(index),
+		Parameters: []WAParameter{{Type: "payload", Payload: payload}},
+	})
+	return t
+}
+
+// URLButton sets the dynamic suffix of the url button at an index.
+func (t *WATemplate) URLButton(index int, suffix string) *WATemplate {
+	t.Components = append(t.Components, WAComponent{
+		Type:       "button",
+		SubType:    "url",
+		Index:      strconv.Itoa(index),
+		Parameters: []WAParameter{{Type: "text", Text: suffix}},
+	})
+	return t
+}
+
+// AuthenticationCode fills the code of an authentication template, which is
+// copied by its copy code button.
+func (t *WATemplate) AuthenticationCode(code string) *WATemplate {
+	t.Components = append(t.Components,
+		WAComponent{Type: "body", Parameters: []WAParameter{{Type: "text", Text: code}}},
+		WAComponent{
+			Type:       "button",
+			SubType:    "url",
+			Index:      "0",
+			Parameters: []WAParameter{{Type: "text", Text: code}},
+		})
+	return t
+}
+
+func (t *WATemplate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":       t.Name,
+		"language":   map[string]string{"code": t.Language},
+		"components": t.Components,
+	})
+}
+
+// WATemplateSender is implemented by ambassadors which can send WhatsApp
+// templates.
+type WATemplateSender interface {
+	SendWATemplate(t *WATemplate) (err error)
+}
+
+// waTemplateMessage is the message payload of a template to a recipient.
+func waTemplateMessage(to string, t *WATemplate) map[string]interface{} {
+	return map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "template",
+		"template":          t,
+	}
+}
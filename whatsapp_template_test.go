@@ -0,0 +1,24 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWATemplate(t *testing.T) {
+	tpl := NewWATemplate("order_shipped", "en_US").
+		Body(map[string]string{"order": "#42", "name": "Ann"}).
+		QuickReply(0, "TRACK_42")
+
+	b, err := json.Marshal(waTemplateMessage("886900000000", tpl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"messaging_product":"whatsapp","recipient_type":"individual","template":{"components":[` +
+		`{"type":"body","parameters":[{"type":"text","parameter_name":"name","text":"Ann"},{"type":"text","parameter_name":"order","text":"#42"}]},` +
+		`{"type":"button","sub_type":"quick_reply","index":"0","parameters":[{"type":"payload","payload":"TRACK_42"}]}],` +
+		`"language":{"code":"en_US"},"name":"order_shipped"},"to":"886900000000","type":"template"}`
+	if string(b) != expected {
+		t.Errorf("unexpected payload:\n%s", b)
+	}
+}
@@ -0,0 +1,81 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestWhatsAppTranslate(t *testing.T) {
+	w := NewWhatsAppAmbassador("pn1", "token", nil)
+	messages, err := w.Translate(strings.NewReader(`{"object":"whatsapp_business_account","entry":[{"id":"b1","changes":[
+		{"field":"messages","value":{"metadata":{"phone_number_id":"pn1"},
+		"messages":[
+			{"from":"886900000000","id":"wamid.1","timestamp":"1700000000","type":"text","text":{"body":"hi"}},
+			{"from":"886900000000","id":"wamid.2","timestamp":"1700000001","type":"location","location":{"latitude":25.03,"longitude":121.56}},
+			{"from":"886900000000","id":"wamid.3","timestamp":"1700000002","type":"interactive","context":{"id":"wamid.out"},
+				"interactive":{"type":"list_reply","list_reply":{"id":"SIZE_M","title":"M"}}}],
+		"statuses":[{"id":"wamid.out","status":"delivered","timestamp":"1700000003","recipient_id":"886900000000"}]}}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("expect 4 messages, got %d", len(messages))
+	}
+	if text, ok := messages[0].Content.(*TextContent); !ok || text.Text != "hi" || messages[0].Timestamp != 1700000000000 {
+		t.Errorf("unexpected text message: %+v", messages[0])
+	}
+	if loc, ok := messages[1].Content.(*LocationContent); !ok || loc.Lat != 25.03 {
+		t.Errorf("unexpected location message: %+v", messages[1])
+	}
+	if command, ok := messages[2].Content.(*CommandContent); !ok || command.Payload != "SIZE_M" || messages[2].InReplyTo != "wamid.out" {
+		t.Errorf("unexpected interactive reply: %+v", messages[2])
+	}
+	if delivery, ok := messages[3].Content.(*DeliveryContent); !ok || delivery.MessageIds[0] != "wamid.out" {
+		t.Errorf("unexpected status: %+v", messages[3])
+	}
+}
+
+func TestWhatsAppSend(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	w := NewWhatsAppAmbassador("pn1", "token", server.Client())
+
+	w.ReplyTo("wamid.1")
+	w.AskQuestion("size?", []map[string]string{
+		{"title": "S", "payload": "SIZE_S"},
+		{"title": "M", "payload": "SIZE_M"},
+	})
+	answers := []map[string]string{}
+	for _, size := range []string{"XS", "S", "M", "L", "XL"} {
+		answers = append(answers, map[string]string{"title": size, "payload": "SIZE_" + size})
+	}
+	w.AskQuestion("size?", answers)
+	if err := w.Send("886900000000"); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 || requests[0].Path != "/v17.0/pn1/messages" {
+		t.Fatalf("expect two messages, got %+v", requests)
+	}
+	type waSent struct {
+		To      string `json:"to"`
+		Context struct {
+			MessageId string `json:"message_id"`
+		}
+		Interactive struct{ Type string }
+	}
+	sent := make([]waSent, len(requests))
+	for i, r := range requests {
+		json.Unmarshal(r.Body, &sent[i])
+	}
+	if sent[0].Interactive.Type != "button" || sent[1].Interactive.Type != "list" {
+		t.Errorf("expect buttons then a list, got %+v", sent)
+	}
+	if sent[0].To != "886900000000" || sent[0].Context.MessageId != "wamid.1" {
+		t.Errorf("unexpected recipient or context: %+v", sent[0])
+	}
+}
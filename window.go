@@ -0,0 +1,81 @@
+package ambassador
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeliveryWindow is the period of a day in a user's local time when
+// non-urgent messages may be delivered, e.g. 09:00 to 21:00. A window may
+// span midnight.
+type DeliveryWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Location is used for users whose time zone is unknown.
+	Location *time.Location
+	Profiles ProfileStore
+}
+
+// NewDeliveryWindow creates a window from "15:04" formatted times.
+func NewDeliveryWindow(start, end string, profiles ProfileStore) (w *DeliveryWindow, err error) {
+	w = &DeliveryWindow{Location: time.Local, Profiles: profiles}
+	if w.Start, err = parseClock(start); err != nil {
+		return nil, err
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func parseClock(s string) (d time.Duration, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *DeliveryWindow) location(userId string) (loc *time.Location, err error) {
+	if w.Profiles != nil {
+		var p *UserProfile
+		if p, err = w.Profiles.GetProfile(userId); err != nil {
+			return
+		}
+		if loc = p.Location(); loc != nil {
+			return
+		}
+	}
+	if loc = w.Location; loc == nil {
+		loc = time.Local
+	}
+	return
+}
+
+// NextOpen returns t if the window of a user is open at t, otherwise the
+// time when it opens next.
+func (w *DeliveryWindow) NextOpen(userId string, t time.Time) (open time.Time, err error) {
+	loc, err := w.location(userId)
+	if err != nil {
+		return
+	}
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	clock := local.Sub(midnight)
+
+	if w.contains(clock) {
+		return t, nil
+	}
+	open = midnight.Add(w.Start)
+	if clock >= w.Start {
+		open = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc).Add(w.Start)
+	}
+	return
+}
+
+func (w *DeliveryWindow) contains(clock time.Duration) bool {
+	if w.Start <= w.End {
+		return clock >= w.Start && clock < w.End
+	}
+	return clock >= w.Start || clock < w.End
+}
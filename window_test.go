@@ -0,0 +1,37 @@
+package ambassador
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveryWindow(t *testing.T) {
+	profiles := NewMemoryProfileStore()
+	profiles.PutProfile(&UserProfile{Id: "tw", UTCOffset: 8})
+
+	w, err := NewDeliveryWindow("09:00", "21:00", profiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Location = time.UTC
+
+	now := time.Date(2017, 3, 10, 23, 0, 0, 0, time.UTC)
+	// 07:00 in taipei
+	open, err := w.NextOpen("tw", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2017, 3, 11, 1, 0, 0, 0, time.UTC); !open.Equal(expected) {
+		t.Errorf("expect %s, got %s", expected, open)
+	}
+
+	open, _ = w.NextOpen("unknown", now)
+	if expected := time.Date(2017, 3, 11, 9, 0, 0, 0, time.UTC); !open.Equal(expected) {
+		t.Errorf("expect %s, got %s", expected, open)
+	}
+
+	noon := time.Date(2017, 3, 10, 12, 0, 0, 0, time.UTC)
+	if open, _ := w.NextOpen("unknown", noon); !open.Equal(noon) {
+		t.Errorf("expect the window to be open at noon, got %s", open)
+	}
+}
@@ -0,0 +1,338 @@
+package ambassador
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ZaloMessageURI = "https://openapi.zalo.me/v3.0/oa/message/cs"
+
+const (
+	// ZaloQueryPrefix marks the texts of query buttons, which are sent back
+	// as user messages when the buttons are tapped.
+	ZaloQueryPrefix = "#ambassador:"
+
+	zaloMaxButtons  = 5
+	zaloMaxElements = 5
+)
+
+// ZaloEvent is a webhook event of an official account.
+type ZaloEvent struct {
+	AppId     string `json:"app_id"`
+	EventName string `json:"event_name"`
+	Timestamp string `json:"timestamp"`
+	Sender    struct {
+		Id string `json:"id"`
+	} `json:"sender"`
+	Recipient struct {
+		Id string `json:"id"`
+	} `json:"recipient"`
+	Follower struct {
+		Id string `json:"id"`
+	} `json:"follower"`
+	OAId    string `json:"oa_id"`
+	Message struct {
+		MsgId       string   `json:"msg_id"`
+		MsgIds      []string `json:"msg_ids"`
+		Text        string   `json:"text"`
+		Attachments []struct {
+			Type    string `json:"type"`
+			Payload struct {
+				Coordinates struct {
+					Latitude  string `json:"latitude"`
+					Longitude string `json:"longitude"`
+				} `json:"coordinates"`
+			} `json:"payload"`
+		} `json:"attachments"`
+	} `json:"message"`
+}
+
+// ZaloAmbassador talks to followers of a Zalo official account. Answers of
+// questions are query buttons, whose prefixed texts come back as
+// CommandContent of their payloads.
+type ZaloAmbassador struct {
+	sync.Mutex
+	// Pages keeps paginated questions. Without it, they are kept in the
+	// DefaultPageStore.
+	Pages        PageStore
+	accessToken  string
+	client       *http.Client
+	messages     []interface{}
+	lastMessages []interface{}
+	correlation  string
+}
+
+// zaloPause is a staged pause of WithTyping.
+type zaloPause time.Duration
+
+func NewZaloAmbassador(accessToken string, client *http.Client) *ZaloAmbassador {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ZaloAmbassador{accessToken: accessToken, client: client}
+}
+
+func (z *ZaloAmbassador) SetCorrelationId(id string) {
+	z.correlation = id
+}
+
+func (z *ZaloAmbassador) Platform() string {
+	return "zalo"
+}
+
+// Translate turns a webhook event into a message. Events sent by the
+// official account itself are skipped.
+func (z *ZaloAmbassador) Translate(r io.Reader) (messages []Message, err error) {
+	var e ZaloEvent
+	if err = decodeJSON(limitReader(r), &e); err != nil {
+		return
+	}
+	timestamp, _ := strconv.ParseInt(e.Timestamp, 10, 64)
+	msg := Message{
+		SenderId:    e.Sender.Id,
+		RecipientId: e.Recipient.Id,
+		MessageId:   e.Message.MsgId,
+		Timestamp:   timestamp,
+	}
+
+	messages = []Message{}
+	switch e.EventName {
+	case "user_send_text":
+		text := e.Message.Text
+		if !strings.HasPrefix(text, ZaloQueryPrefix) {
+			msg.Content = &TextContent{Text: text}
+			break
+		}
+		payload := strings.TrimPrefix(text, ZaloQueryPrefix)
+		if text, offset, page, ok := nextPage(z.Pages, payload, zaloMaxButtons); ok {
+			msg.Content = &MoreAnswersContent{Text: text, Offset: offset, Answers: page}
+		} else {
+			msg.Content = &CommandContent{Payload: payload}
+		}
+	case "user_send_location":
+		if len(e.Message.Attachments) == 0 {
+			return
+		}
+		c := e.Message.Attachments[0].Payload.Coordinates
+		lat, _ := strconv.ParseFloat(c.Latitude, 64)
+		lon, _ := strconv.ParseFloat(c.Longitude, 64)
+		msg.Content = &LocationContent{Lat: lat, Lon: lon}
+	case "follow":
+		msg.SenderId, msg.RecipientId = e.Follower.Id, e.OAId
+		msg.Content = &FollowContent{}
+	case "user_received_message":
+		msg.Content = &DeliveryContent{MessageIds: e.Message.MsgIds, Watermark: timestamp}
+	case "user_seen_message":
+		msg.Content = &ReadContent{Watermark: timestamp}
+	default:
+		return
+	}
+	messages = append(messages, msg)
+	return
+}
+
+func (z *ZaloAmbassador) post(payload interface{}) (err error) {
+	b, err := jsonCodec.Marshal(payload)
+	if err != nil {
+		return
+	}
+	req, _ := http.NewRequest("POST", ZaloMessageURI, bytes.NewBuffer(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("access_token", z.accessToken)
+	setCorrelationHeader(req, z.correlation)
+	resp, err := z.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	buffer := &bytes.Buffer{}
+	io.Copy(buffer, resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("fail to deliver a zalo message. status: %s, body: %s", resp.Status, buffer.String())
+	}
+	// zalo reports errors in the body of a 200 response
+	var result struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	jsonCodec.Unmarshal(buffer.Bytes(), &result)
+	if result.Error != 0 {
+		return fmt.Errorf("fail to deliver a zalo message. error: %d, message: %s", result.Error, result.Message)
+	}
+	return
+}
+
+func (z *ZaloAmbassador) stage(message interface{}) {
+	z.Lock()
+	defer z.Unlock()
+	z.messages = append(z.messages, message)
+}
+
+func (z *ZaloAmbassador) SendText(text string) (err error) {
+	z.stage(map[string]interface{}{"text": text})
+	return
+}
+
+func zaloQueryButton(title, payload string) map[string]interface{} {
+	return map[string]interface{}{
+		"title":   title,
+		"type":    "oa.query.hide",
+		"payload": ZaloQueryPrefix + payload,
+	}
+}
+
+func zaloList(elements, buttons []interface{}) map[string]interface{} {
+	payload := map[string]interface{}{
+		"template_type": "list",
+		"elements":      elements,
+	}
+	if len(buttons) > 0 {
+		payload["buttons"] = buttons
+	}
+	return map[string]interface{}{
+		"attachment": map[string]interface{}{"type": "template", "payload": payload},
+	}
+}
+
+// AskQuestion sends the answers as query buttons of a list. Answers beyond
+// five are paginated behind a "More…" button.
+func (z *ZaloAmbassador) AskQuestion(text string, answers []map[string]string) (err error) {
+	page, err := paginate(z.Pages, text, answers, zaloMaxButtons)
+	if err != nil {
+		return
+	}
+	z.askQuestion(text, page)
+	return
+}
+
+func (z *ZaloAmbassador) askQuestion(text string, answers []map[string]string) {
+	buttons := []interface{}{}
+	for _, answer := range answers {
+		title, ok1 := answer["title"]
+		payload, ok2 := answer["payload"]
+		if ok1 && ok2 {
+			buttons = append(buttons, zaloQueryButton(title, payload))
+		}
+	}
+	z.stage(zaloList([]interface{}{map[string]interface{}{"title": text}}, buttons))
+}
+
+// SendTemplate sends the elements as lists of up to five elements. An
+// element opens its item url or its first url button, and its postback
+// buttons become query buttons of the list.
+func (z *ZaloAmbassador) SendTemplate(elements interface{}) (err error) {
+	colItems, ok := elements.([]Carousel)
+	if !ok {
+		return fmt.Errorf("can not type assert the elements")
+	}
+
+	for start := 0; start < len(colItems); start += zaloMaxElements {
+		end := start + zaloMaxElements
+		if end > len(colItems) {
+			end = len(colItems)
+		}
+		items := []interface{}{}
+		buttons := []interface{}{}
+		for _, col := range colItems[start:end] {
+			item := map[string]interface{}{"title": col.Title, "subtitle": col.Text}
+			if col.ImageUrl != "" {
+				item["image_url"] = col.ImageUrl
+			}
+			link := col.ItemUrl
+			for _, btn := range col.Buttons {
+				switch {
+				case btn.Type == "url" && link == "":
+					link = btn.Data
+				case btn.Type == "postback" && len(buttons) < zaloMaxButtons:
+					buttons = append(buttons, zaloQueryButton(btn.Label, btn.Data))
+				}
+			}
+			if link != "" {
+				item["default_action"] = map[string]string{"type": "oa.open.url", "url": link}
+			}
+			items = append(items, item)
+		}
+		z.stage(zaloList(items, buttons))
+	}
+	return
+}
+
+// SendTyping is a no-op since Zalo has no typing indicator for official
+// accounts.
+func (z *ZaloAmbassador) SendTyping(on bool) (err error) {
+	return
+}
+
+// WithTyping delays the messages staged after it.
+func (z *ZaloAmbassador) WithTyping(d time.Duration) (err error) {
+	z.stage(zaloPause(d))
+	return
+}
+
+// MarkRead is a no-op since Zalo has no read receipts for official
+// accounts.
+func (z *ZaloAmbassador) MarkRead(msg Message) (err error) {
+	return
+}
+
+func (z *ZaloAmbassador) cleanMessage() {
+	z.Lock()
+	defer z.Unlock()
+	z.lastMessages = make([]interface{}, 0, len(z.messages))
+	for _, m := range z.messages {
+		if _, ok := m.(zaloPause); !ok {
+			z.lastMessages = append(z.lastMessages, m)
+		}
+	}
+	z.messages = nil
+}
+
+func (z *ZaloAmbassador) GetLastSent() []interface{} {
+	return z.lastMessages
+}
+
+// Discard drops the staged messages without sending them.
+func (z *ZaloAmbassador) Discard() {
+	z.Lock()
+	last := z.lastMessages
+	z.Unlock()
+	z.cleanMessage()
+	z.Lock()
+	z.lastMessages = last
+	z.Unlock()
+}
+
+// Send sends the staged messages to a follower by its user id.
+func (z *ZaloAmbassador) Send(recipientId string) (err error) {
+	defer z.cleanMessage()
+	for _, m := range z.messages {
+		if pause, ok := m.(zaloPause); ok {
+			time.Sleep(time.Duration(pause))
+			continue
+		}
+		err = z.post(map[string]interface{}{
+			"recipient": map[string]string{"user_id": recipientId},
+			"message":   m,
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ZaloSignature is the X-ZEvent-Signature of a webhook body sent at a
+// timestamp to an app.
+func ZaloSignature(appId, body, timestamp, secret string) string {
+	sum := sha256.Sum256([]byte(appId + body + timestamp + secret))
+	return "mac=" + hex.EncodeToString(sum[:])
+}
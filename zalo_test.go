@@ -0,0 +1,64 @@
+package ambassador
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lemonlatte/ambassador/testutil"
+)
+
+func TestZaloConversation(t *testing.T) {
+	server := testutil.NewFakeServer()
+	defer server.Close()
+	z := NewZaloAmbassador("token", server.Client())
+
+	z.AskQuestion("Which size?", []map[string]string{
+		{"title": "Small", "payload": "SIZE_S"},
+		{"title": "Large", "payload": "SIZE_L"},
+	})
+	if err := z.Send("u1"); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 || requests[0].Path != "/v3.0/oa/message/cs" || requests[0].Header.Get("access_token") != "token" {
+		t.Fatalf("expect one message, got %+v", requests)
+	}
+	var sent struct {
+		Recipient struct {
+			UserId string `json:"user_id"`
+		} `json:"recipient"`
+		Message struct {
+			Attachment struct {
+				Payload struct {
+					Elements []map[string]string `json:"elements"`
+					Buttons  []map[string]string `json:"buttons"`
+				} `json:"payload"`
+			} `json:"attachment"`
+		} `json:"message"`
+	}
+	json.Unmarshal(requests[0].Body, &sent)
+	payload := sent.Message.Attachment.Payload
+	if sent.Recipient.UserId != "u1" || payload.Elements[0]["title"] != "Which size?" ||
+		len(payload.Buttons) != 2 || payload.Buttons[1]["payload"] != ZaloQueryPrefix+"SIZE_L" {
+		t.Errorf("unexpected question: %s", requests[0].Body)
+	}
+
+	messages, err := z.Translate(strings.NewReader(`{"app_id":"a1","event_name":"user_send_text","timestamp":"1700000000000",
+		"sender":{"id":"u1"},"recipient":{"id":"oa1"},"message":{"msg_id":"m1","text":"#ambassador:SIZE_L"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command, ok := messages[0].Content.(*CommandContent); !ok || command.Payload != "SIZE_L" || messages[0].Timestamp != 1700000000000 {
+		t.Errorf("expect the query as its payload, got %+v", messages[0])
+	}
+	messages, _ = z.Translate(strings.NewReader(`{"event_name":"follow","oa_id":"oa1","follower":{"id":"u2"}}`))
+	if _, ok := messages[0].Content.(*FollowContent); !ok || messages[0].SenderId != "u2" {
+		t.Errorf("unexpected follow: %+v", messages[0])
+	}
+	messages, _ = z.Translate(strings.NewReader(`{"event_name":"user_send_location","sender":{"id":"u1"},
+		"message":{"attachments":[{"type":"location","payload":{"coordinates":{"latitude":"10.77","longitude":"106.7"}}}]}}`))
+	if loc, ok := messages[0].Content.(*LocationContent); !ok || loc.Lat != 10.77 {
+		t.Errorf("unexpected location: %+v", messages[0])
+	}
+}